@@ -23,8 +23,12 @@ import (
 
 func main() {
 	f := factory.Build()
-	server.Middleware(f.Echo)
-	server.Router(f.Echo, f.Handlers)
+	server.Middleware(f.Echo, f.Config.Metrics, f.Middlewares...)
+	server.Router(f.Echo, f.Tenants)
+	server.Metrics(f.Echo, f.Config.Metrics, f.Registry)
+	server.Probes(f.Echo, f.Probe)
+	f.Purger.Start()
 	server.Start(f.Echo, f.Config)
+	f.Purger.Stop()
 	f.Close()
 }
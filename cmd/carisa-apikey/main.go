@@ -0,0 +1,91 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carisa/internal/api/auth"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+)
+
+const loc = "carisa-apikey"
+
+// carisa-apikey manages the API keys without authentication, for example to create the first key
+// when the authentication is enabled. The token of the key created is written to the standard output.
+// The configuration is read from the same environment variable than the API
+func main() {
+	name := flag.String("name", "", "creates an API key with the name")
+	tenant := flag.String("tenant", "", "binds the API key created to the tenant")
+	revoke := flag.String("revoke", "", "revokes the API key with the identifier")
+	list := flag.Bool("list", false, "lists the API keys")
+	flag.Parse()
+
+	cnf := runtime.LoadConfig()
+	log, _ := logging.NewZapLogger(cnf.ZapConfig)
+	cnt := runtime.NewContainer(cnf, log)
+	store := storage.NewEtcdConfig(cnf.EtcdConfig)
+	keys := auth.NewAPIKeys(cnt, storage.NewCrudOperation(store, log, storage.NewTxn))
+
+	var err error
+	switch {
+	case len(*name) > 0:
+		var token string
+		if _, token, err = keys.Create(context.Background(), *name, *tenant, loc); err == nil {
+			fmt.Println(token)
+		}
+	case len(*revoke) > 0:
+		err = revokeKey(&keys, *revoke)
+	case *list:
+		var list []storage.Entity
+		if list, err = keys.List(context.Background()); err == nil {
+			for _, e := range list {
+				key := e.(*auth.APIKey)
+				fmt.Println(key.ID.String(), key.Name, key.Created.Format("2006-01-02T15:04:05Z"))
+			}
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if errc := store.Close(); errc != nil {
+		log.ErrorE(errc, loc)
+	}
+	if err != nil {
+		log.ErrorE(err, loc)
+		os.Exit(1)
+	}
+}
+
+func revokeKey(keys *auth.APIKeys, id string) error {
+	xID, err := xid.FromString(id)
+	if err != nil {
+		return err
+	}
+	found, err := keys.Revoke(context.Background(), xID)
+	if err == nil && !found {
+		return fmt.Errorf("API key not found: %s", id)
+	}
+	return err
+}
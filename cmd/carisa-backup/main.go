@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/backup"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const loc = "carisa-backup"
+
+// carisa-backup writes the keys of Carisa to an archive or restores them into an empty store.
+// The configuration is read from the same environment variable than the API
+func main() {
+	file := flag.String("file", "", "path of the archive")
+	restore := flag.Bool("restore", false, "restores the archive into an empty store")
+	verify := flag.Bool("verify", false, "verifies the integrity of the archive without restoring it")
+	batch := flag.Int("batch", 50, "number of keys read or written by request")
+	flag.Parse()
+
+	cnf := runtime.LoadConfig()
+	log, _ := logging.NewZapLogger(cnf.ZapConfig)
+	if len(*file) == 0 {
+		log.Error("the file of the archive is required", loc)
+		os.Exit(2)
+	}
+
+	cnt := runtime.NewContainer(cnf, log)
+	var store storage.CRUD
+	if !*verify {
+		store = storage.NewEtcdConfig(cnf.EtcdConfig)
+	}
+	b := backup.NewBackup(cnt, store, *batch)
+
+	var header backup.Header
+	var count int
+	var err error
+	switch {
+	case *verify:
+		header, count, err = open(*file, func(f *os.File) (backup.Header, int, error) { return b.Verify(f) })
+	case *restore:
+		header, count, err = open(*file, func(f *os.File) (backup.Header, int, error) { return b.Restore(f) })
+	default:
+		header, count, err = create(*file, b.Write)
+	}
+
+	if store != nil {
+		if err := store.Close(); err != nil {
+			log.ErrorE(err, loc)
+		}
+	}
+	if err != nil {
+		log.ErrorE(err, loc)
+		os.Exit(1)
+	}
+	log.Info3(
+		"archive processed",
+		loc,
+		logging.String("schema", strconv.Itoa(header.Schema)),
+		logging.String("codec", header.Codec),
+		logging.String("keys", strconv.Itoa(count)))
+}
+
+// open opens the archive to read
+func open(file string, fn func(f *os.File) (backup.Header, int, error)) (backup.Header, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return backup.Header{}, 0, err
+	}
+	defer f.Close()
+	return fn(f)
+}
+
+// create creates the archive to write. If the write fails the archive is removed
+func create(file string, fn func(w io.Writer) (backup.Header, int, error)) (backup.Header, int, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return backup.Header{}, 0, err
+	}
+	header, count, err := fn(f)
+	if errc := f.Close(); err == nil {
+		err = errc
+	}
+	if err != nil {
+		_ = os.Remove(file)
+	}
+	return header, count, err
+}
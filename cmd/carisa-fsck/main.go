@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/fsck"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const loc = "carisa-fsck"
+
+// carisa-fsck checks the referential integrity of the links and the doubly linked relations.
+// The configuration is read from the same environment variable than the API.
+// The keys of a tenant are checked with the flag -tenant.
+// Exits with 1 if the check fails and with 2 if there are issues not repaired
+func main() {
+	repair := flag.Bool("repair", false, "removes the orphan and dangling keys")
+	batch := flag.Int("batch", 50, "number of keys read by request")
+	tenant := flag.String("tenant", "", "checks the keys of the tenant instead of the keys without tenant")
+	flag.Parse()
+
+	cnf := runtime.LoadConfig()
+	log, _ := logging.NewZapLogger(cnf.ZapConfig)
+	cnt := runtime.NewContainer(cnf, log)
+	store := storage.NewEtcdConfig(cnf.EtcdConfig)
+	checked := store
+	if len(*tenant) > 0 {
+		if !storage.ValidNamespace(*tenant) {
+			log.Error("the tenant only can contain lowercase letters, digits, '-' or '_'", loc)
+			os.Exit(1)
+		}
+		checked = storage.NewNamespace(store, *tenant)
+	}
+	checker := fsck.NewChecker(cnt, checked, *batch)
+
+	report, err := checker.Check(*repair)
+	if errc := store.Close(); errc != nil {
+		log.ErrorE(errc, loc)
+	}
+	if err != nil {
+		log.ErrorE(err, loc)
+		os.Exit(1)
+	}
+
+	for _, issue := range report.Issues {
+		log.Info3(
+			string(issue.Kind),
+			loc,
+			logging.String("key", issue.Key),
+			logging.String("ref", issue.Ref),
+			logging.String("repaired", strconv.FormatBool(issue.Repaired)))
+	}
+	log.Info3(
+		"keyspace checked",
+		loc,
+		logging.String("scanned", strconv.Itoa(report.Scanned)),
+		logging.String("issues", strconv.Itoa(len(report.Issues))),
+		logging.String("repaired", strconv.Itoa(report.Repaired)))
+
+	if report.Pending() > 0 {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/migrate"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const loc = "carisa-migrate"
+
+// carisa-migrate applies the pending migration steps to the store of the API.
+// The configuration is read from the same environment variable than the API
+func main() {
+	dryRun := flag.Bool("dry-run", false, "reports the changes without writing them")
+	batch := flag.Int("batch", migrate.MaxBatch, fmt.Sprintf("number of keys rewritten by transaction (%d at most)", migrate.MaxBatch))
+	flag.Parse()
+
+	cnf := runtime.LoadConfig()
+	log, _ := logging.NewZapLogger(cnf.ZapConfig)
+	cnt := runtime.NewContainer(cnf, log)
+	store := storage.NewEtcdConfig(cnf.EtcdConfig)
+
+	m := migrate.NewMigrator(cnt, store, *batch, migrate.Steps()...)
+	report, err := m.Run(*dryRun)
+	if err := store.Close(); err != nil {
+		log.ErrorE(err, loc)
+	}
+	if err != nil {
+		log.ErrorE(err, loc)
+		os.Exit(1)
+	}
+	log.Info(report.String(), loc)
+}
@@ -16,9 +16,28 @@
 
 package main
 
-import "github.com/carisa/internal/splitter/factory"
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/carisa/internal/splitter/factory"
+)
+
+// carisa-splitter runs until it receives SIGINT or SIGTERM. Then it drains its works,
+// removes its keys from the cluster and exits with error if the drain is not clean
 func main() {
 	f := factory.Build()
-	f.Controller.Start()
+	ctx, cancel := context.WithCancel(context.Background())
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-quit
+		cancel()
+	}()
+	code := f.Run(ctx)
+	signal.Stop(quit)
+	cancel()
+	os.Exit(code)
 }
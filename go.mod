@@ -3,9 +3,11 @@ module github.com/carisa
 go 1.14
 
 require (
+	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/labstack/echo/v4 v4.1.16
 	github.com/labstack/gommon v0.3.0
 	github.com/pkg/errors v0.8.0
+	github.com/prometheus/client_golang v1.0.0
 	github.com/rs/xid v1.2.1
 	github.com/stretchr/testify v1.4.0
 	go.etcd.io/etcd v0.5.0-alpha.5.0.20200401174654-e694b7bb0875
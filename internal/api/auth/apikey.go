@@ -0,0 +1,160 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	nethttp "net/http"
+	strs "strings"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+)
+
+const (
+	locAPIKey = "auth.apikey"
+	// MethodAPIKey is the method of the principals authenticated with API key
+	MethodAPIKey = "apikey"
+	// secretLen is the number of random bytes of the secret
+	secretLen = 32
+	tokenSep  = "."
+)
+
+// APIKey is a key to access to the API. The token of the key is 'ID.secret'.
+// The secret is only known when the key is created, the store only keeps its hash
+type APIKey struct {
+	ID        xid.ID    `json:"id"`
+	Name      string    `json:"name"`
+	Tenant    string    `json:"tenant,omitempty"` // Only tenant that the key reaches
+	Hash      string    `json:"hash,omitempty"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"createdBy,omitempty"` // Principal that created the key
+}
+
+func (a *APIKey) ToString() string {
+	return strings.Concat("apikey: ID:", a.ID.String(), ", name:", a.Name)
+}
+
+func (a *APIKey) Key() string {
+	return entity.APIKeyKey(a.ID.String())
+}
+
+// APIKeys manages the API keys and authenticates the requests with the API key into the header runtime.Auth.KeyHeader
+type APIKeys struct {
+	cnt  *runtime.Container
+	crud storage.CrudOperation
+}
+
+// NewAPIKeys builds the API key service
+func NewAPIKeys(cnt *runtime.Container, crud storage.CrudOperation) APIKeys {
+	return APIKeys{
+		cnt:  cnt,
+		crud: crud,
+	}
+}
+
+// Create creates an API key with the name bound to the tenant. createdBy is the principal that creates the key.
+// Returns the key and its token. The token can not be recovered later
+func (a *APIKeys) Create(ctx context.Context, name string, tenant string, createdBy string) (APIKey, string, error) {
+	secret := make([]byte, secretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return APIKey{}, "", a.cnt.Log.ErrWrap(err, "generating the secret of the API key", locAPIKey)
+	}
+	key := APIKey{
+		ID:        xid.New(),
+		Name:      name,
+		Tenant:    tenant,
+		Hash:      hash(hex.EncodeToString(secret)),
+		Created:   time.Now().UTC(),
+		CreatedBy: createdBy,
+	}
+	if _, err := a.crud.Create(locAPIKey, a.cnt.StoreTimeout(ctx), &key); err != nil {
+		return APIKey{}, "", err
+	}
+	return key, strings.Concat(key.ID.String(), tokenSep, hex.EncodeToString(secret)), nil
+}
+
+// List lists all API keys
+func (a *APIKeys) List(ctx context.Context) ([]storage.Entity, error) {
+	sctx, cancel := a.cnt.StoreWithTimeout(ctx)
+	keys, err := a.crud.Store().StartKey(sctx, entity.APIKeyKey(""), 0, func() storage.Entity { return &APIKey{} })
+	cancel()
+	if err != nil {
+		return nil, a.cnt.Log.ErrWrap(err, "listing the API keys", locAPIKey)
+	}
+	return keys, nil
+}
+
+// Revoke removes the API key. If the key doesn't exist return false
+func (a *APIKeys) Revoke(ctx context.Context, id xid.ID) (bool, error) {
+	key := entity.APIKeyKey(id.String())
+	txn := storage.NewTxn(a.crud.Store())
+	txn.Find(key)
+	txn.DoFound(a.crud.Store().Remove(key))
+	sctx, cancel := a.cnt.StoreWithTimeout(ctx)
+	found, err := txn.Commit(sctx)
+	cancel()
+	if err != nil {
+		return false, a.cnt.Log.ErrWrap1(err, "revoking the API key", locAPIKey, logging.String("id", id.String()))
+	}
+	return found, nil
+}
+
+// Authenticate implements Authenticator.Authenticate
+func (a *APIKeys) Authenticate(r *nethttp.Request) (httpc.Principal, bool, error) {
+	token := r.Header.Get(a.cnt.Auth.KeyHeader)
+	if len(token) == 0 {
+		return httpc.Principal{}, false, nil
+	}
+	sep := strs.Index(token, tokenSep)
+	if sep < 0 {
+		return httpc.Principal{}, true, ErrInvalidCredentials
+	}
+	id, err := xid.FromString(token[:sep])
+	if err != nil {
+		return httpc.Principal{}, true, ErrInvalidCredentials
+	}
+
+	var key APIKey
+	ctx, cancel := a.cnt.StoreWithTimeout(r.Context())
+	found, err := a.crud.Store().Get(ctx, entity.APIKeyKey(id.String()), &key)
+	cancel()
+	if err != nil {
+		return httpc.Principal{}, true, err
+	}
+	if !found || subtle.ConstantTimeCompare([]byte(hash(token[sep+1:])), []byte(key.Hash)) != 1 {
+		return httpc.Principal{}, true, ErrInvalidCredentials
+	}
+	return httpc.Principal{ID: key.ID.String(), Method: MethodAPIKey, Tenant: key.Tenant}, true, nil
+}
+
+// hash gets the hexadecimal SHA-256 of the secret.
+// The secrets are random, so they don't need a slow hash function
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
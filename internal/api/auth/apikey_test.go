@@ -0,0 +1,127 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package auth
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carisa/internal/api/mock"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeys_CreateAndAuthenticate(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+	keys := NewAPIKeys(cnt, crud)
+
+	key, token, err := keys.Create(context.Background(), "admin", "acme", "creator")
+	if !assert.NoError(t, err, "Creating") {
+		return
+	}
+	assert.Equal(t, "creator", key.CreatedBy, "Created by")
+	assert.NotContains(t, key.Hash, token[len(key.ID.String())+1:], "The secret is not stored")
+
+	_, other, err := keys.Create(context.Background(), "other", "", "creator")
+	if !assert.NoError(t, err, "Creating other") {
+		return
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		found     bool
+		principal httpc.Principal
+		err       error
+	}{
+		{
+			name:      "Valid token.",
+			token:     token,
+			found:     true,
+			principal: httpc.Principal{ID: key.ID.String(), Method: MethodAPIKey, Tenant: "acme"},
+		},
+		{
+			name: "Without token.",
+		},
+		{
+			name:  "Token without separator.",
+			token: "token",
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name:  "Identifier not valid.",
+			token: "id.secret",
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name:  "Key not found.",
+			token: xid.New().String() + ".secret",
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name:  "Secret of other key.",
+			token: key.ID.String() + other[len(key.ID.String()):],
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.Header.Set(cnt.Auth.KeyHeader, tt.token)
+		p, found, err := keys.Authenticate(req)
+		assert.Equal(t, tt.err, err, tt.name)
+		assert.Equal(t, tt.found, found, tt.name)
+		assert.Equal(t, tt.principal, p, tt.name)
+	}
+}
+
+func TestAPIKeys_ListAndRevoke(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+	keys := NewAPIKeys(cnt, crud)
+
+	key, token, err := keys.Create(context.Background(), "admin", "", "")
+	if !assert.NoError(t, err, "Creating") {
+		return
+	}
+
+	list, err := keys.List(context.Background())
+	if assert.NoError(t, err, "Listing") && assert.Len(t, list, 1, "Keys") {
+		assert.Equal(t, key.Name, list[0].(*APIKey).Name, "Name")
+	}
+
+	found, err := keys.Revoke(context.Background(), key.ID)
+	if assert.NoError(t, err, "Revoking") {
+		assert.True(t, found, "Revoked")
+	}
+	found, err = keys.Revoke(context.Background(), key.ID)
+	if assert.NoError(t, err, "Revoking again") {
+		assert.False(t, found, "Not found")
+	}
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+	req.Header.Set(cnt.Auth.KeyHeader, token)
+	_, _, err = keys.Authenticate(req)
+	assert.Equal(t, ErrInvalidCredentials, err, "The key revoked is not valid")
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package auth authenticates the requests of the API.
+// The authentication methods are pluggable, see Authenticator
+package auth
+
+import (
+	"errors"
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+)
+
+const locAuth = "auth.middleware"
+
+// ErrInvalidCredentials is returned when the credentials of the request are not valid
+var ErrInvalidCredentials = errors.New("the credentials are not valid")
+
+// Authenticator authenticates the requests with a method
+type Authenticator interface {
+	// Authenticate gets the principal of the request.
+	// If the request has not credentials of this method returns false in the second param returned.
+	// If the credentials are not valid returns ErrInvalidCredentials
+	Authenticate(r *nethttp.Request) (httpc.Principal, bool, error)
+}
+
+// Middleware authenticates each request with the first authenticator that finds credentials into the request.
+// The principal is exposed into the context. See httpc.Context.Principal.
+// The requests without credentials or with credentials not valid are rejected
+func Middleware(cnt *runtime.Container, auths ...Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			for _, a := range auths {
+				p, found, err := a.Authenticate(ctx.Request())
+				if err == ErrInvalidCredentials {
+					return echo.NewHTTPError(nethttp.StatusUnauthorized, err.Error())
+				}
+				if err != nil {
+					_ = cnt.Log.ErrWrap(err, "authenticating the request", locAuth)
+					return echo.NewHTTPError(nethttp.StatusInternalServerError, "it was impossible to authenticate the request")
+				}
+				if found {
+					ctx.Set(httpc.PrincipalKey, p)
+					return next(ctx)
+				}
+			}
+			return echo.NewHTTPError(nethttp.StatusUnauthorized, "the request has not credentials")
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package auth
+
+import (
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carisa/internal/api/mock"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type authenticatorFake struct {
+	header string
+	err    error
+}
+
+func (a authenticatorFake) Authenticate(r *nethttp.Request) (httpc.Principal, bool, error) {
+	value := r.Header.Get(a.header)
+	if len(value) == 0 {
+		return httpc.Principal{}, false, nil
+	}
+	if a.err != nil {
+		return httpc.Principal{}, true, a.err
+	}
+	return httpc.Principal{ID: value, Method: a.header}, true, nil
+}
+
+func TestAuth_Middleware(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	mw := Middleware(
+		cnt,
+		authenticatorFake{header: "first"},
+		authenticatorFake{header: "invalid", err: ErrInvalidCredentials},
+		authenticatorFake{header: "error", err: errors.New("store")},
+		authenticatorFake{header: "second"})
+
+	tests := []struct {
+		name      string
+		header    string
+		principal httpc.Principal
+		status    int
+	}{
+		{
+			name:      "First authenticator.",
+			header:    "first",
+			principal: httpc.Principal{ID: "id", Method: "first"},
+		},
+		{
+			name:      "Second authenticator.",
+			header:    "second",
+			principal: httpc.Principal{ID: "id", Method: "second"},
+		},
+		{
+			name:   "Credentials not valid.",
+			header: "invalid",
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:   "Error authenticating.",
+			header: "error",
+			status: nethttp.StatusInternalServerError,
+		},
+		{
+			name:   "Without credentials.",
+			header: "none",
+			status: nethttp.StatusUnauthorized,
+		},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.Header.Set(tt.header, "id")
+		ctx := e.NewContext(req, httptest.NewRecorder())
+
+		var principal httpc.Principal
+		err := mw(func(ctx echo.Context) error {
+			principal = ctx.Get(httpc.PrincipalKey).(httpc.Principal)
+			return nil
+		})(ctx)
+
+		if tt.status != 0 {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.principal, principal, tt.name)
+		}
+	}
+}
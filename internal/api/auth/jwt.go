@@ -0,0 +1,251 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	nethttp "net/http"
+	strs "strings"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/strings"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MethodJWT is the method of the principals authenticated with bearer token
+	MethodJWT = "jwt"
+	bearer    = "Bearer "
+	// leeway is the clock skew allowed validating the time claims
+	leeway = time.Minute
+)
+
+// methods are the signing methods allowed. The symmetric methods are not allowed
+// because the keys are public
+var methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// JWT authenticates the requests with the bearer token of the header Authorization.
+// The tokens are validated with the public keys of the configuration. See runtime.JWT
+type JWT struct {
+	keys     map[string]interface{} // Keys with identifier (kid) of the JWKS files
+	anon     []interface{}          // Keys without identifier
+	issuer   string
+	audience string
+	parser   *jwt.Parser
+}
+
+// NewJWT loads the public keys of the PEM or JWKS files
+func NewJWT(cnf runtime.JWT) (JWT, error) {
+	j := JWT{
+		keys:     make(map[string]interface{}),
+		issuer:   cnf.Issuer,
+		audience: cnf.Audience,
+		parser:   &jwt.Parser{ValidMethods: methods, SkipClaimsValidation: true},
+	}
+	for _, file := range cnf.Keys {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return JWT{}, errors.Wrap(err, strings.Concat("reading the key file: ", file))
+		}
+		if err := j.load(data); err != nil {
+			return JWT{}, errors.Wrap(err, strings.Concat("loading the key file: ", file))
+		}
+	}
+	return j, nil
+}
+
+// load loads a JWKS document or a PEM public key
+func (j *JWT) load(data []byte) error {
+	if strs.HasPrefix(strs.TrimSpace(string(data)), "{") {
+		return j.loadJWKS(data)
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		j.anon = append(j.anon, key)
+		return nil
+	}
+	key, err := jwt.ParseECPublicKeyFromPEM(data)
+	if err != nil {
+		return errors.New("the PEM doesn't contain a RSA or EC public key")
+	}
+	j.anon = append(j.anon, key)
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j *JWT) loadJWKS(data []byte) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	for _, k := range set.Keys {
+		key, err := k.public()
+		if err != nil {
+			return errors.Wrap(err, strings.Concat("kid: ", k.Kid))
+		}
+		if len(k.Kid) == 0 {
+			j.anon = append(j.anon, key)
+		} else {
+			j.keys[k.Kid] = key
+		}
+	}
+	return nil
+}
+
+// public builds the public key of the JWK
+func (k *jwk) public() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New(strings.Concat("curve not supported: ", k.Crv))
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, errors.New(strings.Concat("key type not supported: ", k.Kty))
+	}
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Authenticate implements Authenticator.Authenticate. The subject of the token is the principal
+// and the claim 'tenant' is the tenant of the principal
+func (j *JWT) Authenticate(r *nethttp.Request) (httpc.Principal, bool, error) {
+	header := r.Header.Get(echo.HeaderAuthorization)
+	if !strs.HasPrefix(header, bearer) {
+		return httpc.Principal{}, false, nil
+	}
+	token := header[len(bearer):]
+
+	claims, ok := j.parse(token)
+	if !ok || !j.valid(claims) {
+		return httpc.Principal{}, true, ErrInvalidCredentials
+	}
+	sub, _ := claims["sub"].(string)
+	if len(sub) == 0 {
+		return httpc.Principal{}, true, ErrInvalidCredentials
+	}
+	tenant, _ := claims["tenant"].(string)
+	return httpc.Principal{ID: sub, Method: MethodJWT, Tenant: tenant}, true, nil
+}
+
+// parse verifies the signature of the token.
+// If the token has identifier of key (kid) only this key is used, otherwise the keys without identifier are tried
+func (j *JWT) parse(token string) (jwt.MapClaims, bool) {
+	var kid string
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ = t.Header["kid"].(string)
+		if key, ok := j.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, ErrInvalidCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := j.parser.ParseWithClaims(token, claims, keyFunc); err == nil {
+		return claims, true
+	}
+	if len(kid) > 0 {
+		return nil, false
+	}
+	for _, key := range j.anon {
+		key := key
+		claims := jwt.MapClaims{}
+		if _, err := j.parser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return key, nil }); err == nil {
+			return claims, true
+		}
+	}
+	return nil, false
+}
+
+// valid validates the time claims, the expiration is required, and
+// the issuer and the audience if they are configured
+func (j *JWT) valid(claims jwt.MapClaims) bool {
+	now := time.Now()
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), true) ||
+		!claims.VerifyNotBefore(now.Add(leeway).Unix(), false) ||
+		!claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return false
+	}
+	if len(j.issuer) > 0 && !claims.VerifyIssuer(j.issuer, true) {
+		return false
+	}
+	if len(j.audience) == 0 {
+		return true
+	}
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == j.audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == j.audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWT_Authenticate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwt")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	pemFile := filepath.Join(dir, "key.pem")
+	_ = ioutil.WriteFile(pemFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	jwksFile := filepath.Join(dir, "jwks.json")
+	_ = ioutil.WriteFile(jwksFile, []byte(fmt.Sprintf(
+		`{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"%s","y":"%s"}]}`,
+		encodeInt(ecKey.X),
+		encodeInt(ecKey.Y))), 0600)
+
+	j, err := NewJWT(runtime.JWT{Keys: []string{pemFile, jwksFile}, Issuer: "carisa", Audience: "api"})
+	if !assert.NoError(t, err, "Loading keys") {
+		return
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"sub": "user", "tenant": "acme", "iss": "carisa", "aud": []string{"other", "api"}, "exp": exp}
+	tests := []struct {
+		name   string
+		header string
+		found  bool
+		err    error
+	}{
+		{
+			name:   "Token signed with the PEM key.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", valid),
+			found:  true,
+		},
+		{
+			name:   "Token signed with the JWKS key.",
+			header: "Bearer " + sign(jwt.SigningMethodES256, ecKey, "ec", valid),
+			found:  true,
+		},
+		{
+			name: "Without token.",
+		},
+		{
+			name:   "Other scheme.",
+			header: "Basic dXNlcg==",
+		},
+		{
+			name:   "Token signed with other key.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, otherKey, "", valid),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name:   "Identifier of key not found.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "none", valid),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name:   "Symmetric method.",
+			header: "Bearer " + sign(jwt.SigningMethodHS256, der, "", valid),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name: "Token expired.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{
+				"sub": "user", "iss": "carisa", "aud": "api", "exp": time.Now().Add(-2 * leeway).Unix()}),
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name: "Token expired within the clock skew.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{
+				"sub": "user", "tenant": "acme", "iss": "carisa", "aud": "api", "exp": time.Now().Add(-leeway / 2).Unix()}),
+			found: true,
+		},
+		{
+			name:   "Without expiration.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"sub": "user", "iss": "carisa", "aud": "api"}),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name: "Token not valid yet.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{
+				"sub": "user", "iss": "carisa", "aud": "api", "exp": exp, "nbf": time.Now().Add(2 * leeway).Unix()}),
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name: "Token issued in the future.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{
+				"sub": "user", "iss": "carisa", "aud": "api", "exp": exp, "iat": time.Now().Add(2 * leeway).Unix()}),
+			found: true,
+			err:   ErrInvalidCredentials,
+		},
+		{
+			name:   "Other issuer.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"sub": "user", "iss": "other", "aud": "api", "exp": exp}),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name:   "Other audience.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"sub": "user", "iss": "carisa", "aud": "other", "exp": exp}),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+		{
+			name:   "Without subject.",
+			header: "Bearer " + sign(jwt.SigningMethodRS256, rsaKey, "", jwt.MapClaims{"iss": "carisa", "aud": "api", "exp": exp}),
+			found:  true,
+			err:    ErrInvalidCredentials,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.Header.Set("Authorization", tt.header)
+		p, found, err := j.Authenticate(req)
+		assert.Equal(t, tt.err, err, tt.name)
+		assert.Equal(t, tt.found, found, tt.name)
+		if tt.found && tt.err == nil {
+			assert.Equal(t, httpc.Principal{ID: "user", Method: MethodJWT, Tenant: "acme"}, p, tt.name)
+		}
+	}
+}
+
+func TestJWT_NewJWTWithError(t *testing.T) {
+	_, err := NewJWT(runtime.JWT{Keys: []string{"none.pem"}})
+	assert.Error(t, err, "File not found")
+
+	f, err := ioutil.TempFile("", "key")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(f.Name())
+
+	for _, data := range []string{"not a key", `{"keys":[{"kty":"oct"}]}`, `{"keys":[{"kty":"EC","crv":"P-224"}]}`} {
+		_ = ioutil.WriteFile(f.Name(), []byte(data), 0600)
+		_, err = NewJWT(runtime.JWT{Keys: []string{f.Name()}})
+		assert.Error(t, err, data)
+	}
+}
+
+func sign(method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if len(kid) > 0 {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/category"
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/pkg/storage"
@@ -29,7 +31,7 @@ func CreateCat(mng storage.Integration) (category.Category, error) {
 	cat.Name = "Name"
 	cat.Desc = "desc"
 	cat.Root = true
-	_, err := crudOper.Put("loc", cnt.StoreWithTimeout, &cat)
+	_, err := crudOper.Put("loc", cnt.StoreTimeout(context.Background()), &cat)
 	return cat, err
 }
 
@@ -49,7 +51,7 @@ func createLink(mng storage.Integration, catID xid.ID, root bool) (storage.Entit
 	s.ParentID = catID
 	s.Root = root
 	link := s.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, s, err
 }
 
@@ -60,6 +62,6 @@ func CreateLinkProp(mng storage.Integration, catID xid.ID) (storage.Entity, cate
 	prop.Desc = "descp"
 	prop.CatID = catID
 	link := prop.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, prop, err
 }
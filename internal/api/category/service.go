@@ -17,6 +17,8 @@
 package category
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/ente"
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/relation"
@@ -25,6 +27,7 @@ import (
 	"github.com/carisa/pkg/logging"
 	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
+	"github.com/carisa/pkg/tracing"
 	"github.com/rs/xid"
 )
 
@@ -55,37 +58,40 @@ func NewService(
 
 // Create creates a Category into of the repository and links Category and space.Space or other Category.
 // If the Category exists return false in the first param returned.
-// If the space.Space or Category doesn't exist return false in the second param returned.
-func (s *Service) Create(cat *Category) (bool, bool, error) {
+// If the space.Space or Category doesn't exist return service.ParentNotFound.
+func (s *Service) Create(ctx context.Context, cat *Category) (bool, error) {
 	cat.AutoID()
-	return s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, cat)
+	ok, found, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), cat)
+	return service.WithParent(ok, found, err, "category or space not found")
 }
 
 // Put creates or updates a Category into of the repository.
 // If the Category exists return true in the first param returned otherwise return false.
-// If the space.Space or cat doesn't exist return false in the second param returned.
-func (s *Service) Put(cat *Category) (bool, bool, error) {
-	return s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, cat)
+// If the space.Space or Category doesn't exist return service.ParentNotFound.
+func (s *Service) Put(ctx context.Context, cat *Category) (bool, error) {
+	ok, found, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), cat)
+	return service.WithParent(ok, found, err, "category or space not found")
 }
 
 // Get gets the Category from storage
-func (s *Service) Get(id xid.ID, cat *Category) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.CategoryKey(id), cat)
+func (s *Service) Get(ctx context.Context, id xid.ID, cat *Category) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.CategoryKey(id), cat)
 	cancel()
 	return ok, err
 }
 
 // ListCategories lists categories depending of 'ranges' parameter.
 // Look at service.Extension
-func (s *Service) ListCategories(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
-	return s.ext.List(entity.CategoryKey(id), name, ranges, top, func() storage.Entity { return &relation.Hierarchy{} })
+func (s *Service) ListCategories(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+	return s.ext.List(ctx, entity.CategoryKey(id), name, ranges, top, func() storage.Entity { return &relation.Hierarchy{} })
 }
 
 // ListProps lists properties depending ranges parameter.
 // Look at service.Extension
-func (s *Service) ListProps(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListProps(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		entity.CategoryKey(id),
 		strings.Concat(relation.CatPropLn, name),
 		ranges,
@@ -95,23 +101,25 @@ func (s *Service) ListProps(id xid.ID, name string, ranges bool, top int) ([]sto
 
 // CreateProp creates a property into of the repository and links Category property and Category.
 // If the property exists return false in the first param returned.
-// If the Category doesn't exist return false in the second param returned.
-func (s *Service) CreateProp(prop *Prop) (bool, bool, error) {
+// If the Category doesn't exist return service.ParentNotFound.
+func (s *Service) CreateProp(ctx context.Context, prop *Prop) (bool, error) {
 	prop.AutoID()
-	return s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, prop)
+	ok, found, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), prop)
+	return service.WithParent(ok, found, err, "category not found")
 }
 
 // PutProp creates or updates a property into of the repository.
 // If the property exists return true in the first param returned otherwise return false.
-// If the Category doesn't exist return false in the second param returned.
-func (s *Service) PutProp(prop *Prop) (bool, bool, error) {
-	return s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, prop)
+// If the Category doesn't exist return service.ParentNotFound.
+func (s *Service) PutProp(ctx context.Context, prop *Prop) (bool, error) {
+	ok, found, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), prop)
+	return service.WithParent(ok, found, err, "category not found")
 }
 
 // GetProp gets the property from storage
-func (s *Service) GetProp(id xid.ID, prop *Prop) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.CatPropKey(id), prop)
+func (s *Service) GetProp(ctx context.Context, id xid.ID, prop *Prop) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.CatPropKey(id), prop)
 	cancel()
 	return ok, err
 }
@@ -119,34 +127,34 @@ func (s *Service) GetProp(id xid.ID, prop *Prop) (bool, error) {
 // LinkToProp links a Category property with other Category property or ente.Ente property
 // of the child Category. tPropID can be category property or ente.Ente property.
 // The source an target must have the same type of data.
-// The first parameter returned is true if the catPropID is found.
-// The second parameter returned is true if the tPropID is found.
-// The third parameter returned is true if the parent of tPropID is a child of the catPropID.
-// The fourth parameter returned is true if the type of catPropID is equal to tPropID.
-func (s *Service) LinkToProp(catPropID xid.ID, tPropID xid.ID) (bool, bool, bool, bool, relation.CatPropProp, error) {
+// If the catPropID doesn't exist returns service.ErrParentNotFound.
+// If the tPropID doesn't exist returns service.ErrNotFound.
+// If the parent of tPropID is not a child of the catPropID returns service.ErrNotChild.
+// If the type of catPropID is not equal to tPropID returns service.ErrTypeMismatch.
+func (s *Service) LinkToProp(ctx context.Context, catPropID xid.ID, tPropID xid.ID) (relation.CatPropProp, error) {
+	ctx, span := tracing.Start(ctx, "category.linkToProp", tracing.String("property", catPropID.String()))
+	defer span.End()
+
 	var scatProp Prop
-	found, err := s.getProp(catPropID, &scatProp, "getting the source category property for linking")
+	found, err := s.getProp(ctx, catPropID, &scatProp, "getting the source category property for linking")
 	if err != nil {
-		return false, false, false, false, relation.CatPropProp{}, err
+		return relation.CatPropProp{}, err
 	}
 	if !found {
-		return false, false, false, false, relation.CatPropProp{}, nil
+		return relation.CatPropProp{}, service.ErrParentNotFound.With("category property not found")
 	}
 
-	found, tprop, err := s.propType(tPropID)
+	tprop, err := s.propType(ctx, tPropID)
 	if err != nil {
-		return false, false, false, false, relation.CatPropProp{}, err
-	}
-	if !found {
-		return true, false, false, false, relation.CatPropProp{}, nil
+		return relation.CatPropProp{}, err
 	}
 
 	// Checks if the target property is child of the source property category
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	found, err = s.crud.Store().Exists(ctx, storage.DLRKey(tprop.ParentKey(), scatProp.ParentKey()))
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, err = s.crud.Store().Exists(sctx, storage.DLRKey(tprop.ParentKey(), scatProp.ParentKey()))
 	cancel()
 	if err != nil {
-		return true, true, false, false, relation.CatPropProp{},
+		return relation.CatPropProp{},
 			s.cnt.Log.ErrWrap2(
 				err,
 				"checking if the target category property is child of the source property category",
@@ -155,7 +163,9 @@ func (s *Service) LinkToProp(catPropID xid.ID, tPropID xid.ID) (bool, bool, bool
 				logging.String("Target property", tPropID.String()))
 	}
 	if !found {
-		return true, true, false, false, relation.CatPropProp{}, nil
+		return relation.CatPropProp{}, service.ErrNotChild.With(
+			"the category of the target property (category or ente property) must be child of the category " +
+				"of the property for linking")
 	}
 
 	var txn storage.Txn
@@ -168,7 +178,7 @@ func (s *Service) LinkToProp(catPropID xid.ID, tPropID xid.ID) (bool, bool, bool
 		scatProp.Type = tprop.GetType()
 		upd, err := s.crud.Store().Put(&scatProp)
 		if err != nil {
-			return true, true, true, false, relation.CatPropProp{},
+			return relation.CatPropProp{},
 				s.cnt.Log.ErrWrap2(
 					err,
 					"updating the type of category property before linking",
@@ -180,13 +190,14 @@ func (s *Service) LinkToProp(catPropID xid.ID, tPropID xid.ID) (bool, bool, bool
 	}
 
 	if scatProp.Type != tprop.GetType() {
-		return true, true, true, false, relation.CatPropProp{}, nil
+		return relation.CatPropProp{}, service.ErrTypeMismatch.With(
+			"the target property (category or ente property) must be of the same type than the category property for linking")
 	}
 
 	// Link porperties and the same transaction updates the type of property
 	cfound, pfound, link, err := s.crud.LinkTo(
 		locService,
-		s.cnt.StoreWithTimeout,
+		s.cnt.StoreTimeout(ctx),
 		txn,
 		tprop.(storage.EntityRelation),
 		entity.CatPropKey(catPropID),
@@ -199,25 +210,31 @@ func (s *Service) LinkToProp(catPropID xid.ID, tPropID xid.ID) (bool, bool, bool
 			}
 		})
 	if err != nil {
-		return true, true, true, true, relation.CatPropProp{}, err
+		return relation.CatPropProp{}, err
 	}
-
-	if !cfound || !pfound {
-		return pfound, cfound, true, true, relation.CatPropProp{}, nil
+	if !pfound {
+		return relation.CatPropProp{}, service.ErrParentNotFound.With("category property not found")
+	}
+	if !cfound {
+		return relation.CatPropProp{}, targetNotFound
 	}
 
-	return true, true, true, true, *link.(*relation.CatPropProp), nil
+	return *link.(*relation.CatPropProp), nil
 }
 
-// propType gets the type of property (entity.TypeProp) and the parent identifier
-func (s *Service) propType(tPropID xid.ID) (bool, entity.Property, error) {
+// targetNotFound is returned when the target property of the link doesn't exist
+var targetNotFound = service.ErrNotFound.With("target property (category or ente property) not found")
+
+// propType gets the type of property (entity.TypeProp) and the parent identifier.
+// If the property doesn't exist returns service.ErrNotFound
+func (s *Service) propType(ctx context.Context, tPropID xid.ID) (entity.Property, error) {
 	var prop entity.Property
 	// I research the property type (category or ente)
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	found, err := s.crud.Store().Exists(ctx, entity.CatPropKey(tPropID))
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, err := s.crud.Store().Exists(sctx, entity.CatPropKey(tPropID))
 	cancel()
 	if err != nil {
-		return false, nil,
+		return nil,
 			s.cnt.Log.ErrWrap1(
 				err,
 				"it researching the the property type for linking",
@@ -226,19 +243,19 @@ func (s *Service) propType(tPropID xid.ID) (bool, entity.Property, error) {
 	}
 	if found { // Category property
 		var tcatProp Prop
-		found, err := s.getProp(tPropID, &tcatProp, "getting the target category property for linking")
+		found, err := s.getProp(ctx, tPropID, &tcatProp, "getting the target category property for linking")
 		if err != nil {
-			return false, nil, err
+			return nil, err
 		}
 		if !found {
-			return false, nil, err
+			return nil, targetNotFound
 		}
 		prop = &tcatProp
 	} else { // Ente property
 		var tenteProp ente.Prop
-		found, err := s.entesrv.GetProp(tPropID, &tenteProp)
+		found, err := s.entesrv.GetProp(ctx, tPropID, &tenteProp)
 		if err != nil {
-			return false, nil,
+			return nil,
 				s.cnt.Log.ErrWrap1(
 					err,
 					"getting the target ente property for linking",
@@ -246,15 +263,15 @@ func (s *Service) propType(tPropID xid.ID) (bool, entity.Property, error) {
 					logging.String("Property", tPropID.String()))
 		}
 		if !found {
-			return false, nil, nil
+			return nil, targetNotFound
 		}
 		prop = &tenteProp
 	}
-	return true, prop, nil
+	return prop, nil
 }
 
-func (s *Service) getProp(propID xid.ID, catsProp *Prop, errDesc string) (bool, error) {
-	found, err := s.GetProp(propID, catsProp)
+func (s *Service) getProp(ctx context.Context, propID xid.ID, catsProp *Prop, errDesc string) (bool, error) {
+	found, err := s.GetProp(ctx, propID, catsProp)
 	if err != nil {
 		return false, s.cnt.Log.ErrWrap1(
 			err,
@@ -17,6 +17,7 @@
 package category
 
 import (
+	"context"
 	"testing"
 
 	"github.com/carisa/internal/api/test"
@@ -64,11 +65,10 @@ func TestCatService_Create(t *testing.T) {
 	for _, tt := range tests {
 		cat, err := category(mng, &srv, tt.root)
 		if assert.NoError(t, err) {
-			ok, found, err := srv.Create(cat)
+			ok, err := srv.Create(context.Background(), cat)
 
 			if assert.NoError(t, err, tt.name) {
 				assert.True(t, ok, strings.Concat(tt.name, "Created"))
-				assert.True(t, found, strings.Concat(tt.name, "Parent found"))
 				checkCat(t, tt.name, srv, *cat)
 			}
 		}
@@ -118,10 +118,9 @@ func TestCatService_Put(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, found, err := srv.Put(tt.cat)
+		updated, err := srv.Put(context.Background(), tt.cat)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "CAtegory updated"))
-			assert.True(t, found, strings.Concat(tt.name, "Space found"))
 			checkCat(t, tt.name, srv, *tt.cat)
 		}
 	}
@@ -129,7 +128,7 @@ func TestCatService_Put(t *testing.T) {
 
 func checkCat(t *testing.T, name string, srv Service, cat Category) {
 	var catr Category
-	_, err := srv.Get(cat.ID, &catr)
+	_, err := srv.Get(context.Background(), cat.ID, &catr)
 	if assert.NoError(t, err, name) {
 		assert.Equal(t, cat, catr, strings.Concat(name, "Getting category"))
 	}
@@ -143,10 +142,10 @@ func TestCatService_Get(t *testing.T) {
 	cat, err := category(mng, &srv, true)
 
 	if assert.NoError(t, err) {
-		_, _, err := srv.Create(cat)
+		_, err := srv.Create(context.Background(), cat)
 		if assert.NoError(t, err) {
 			var get Category
-			ok, err := srv.Get(cat.ID, &get)
+			ok, err := srv.Get(context.Background(), cat.ID, &get)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, cat, &get, "Category returned")
@@ -167,14 +166,14 @@ func TestCatService_ListCategories(t *testing.T) {
 	cat.Name = "namep"
 	link := cat.Link()
 
-	_, err := s.crud.Create("", s.cnt.StoreWithTimeout, link)
+	_, err := s.crud.Create("", s.cnt.StoreTimeout(context.Background()), link)
 	if err != nil {
 		assert.NoError(t, err, "Create category link to category")
 		return
 	}
 
 	for _, tt := range tests {
-		list, err := s.ListCategories(id, "namep", tt.Ranges, 2)
+		list, err := s.ListCategories(context.Background(), id, "namep", tt.Ranges, 2)
 		if assert.NoError(t, err, tt.Name) {
 			assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 		}
@@ -193,11 +192,11 @@ func TestCatService_ListProps(t *testing.T) {
 	cat.Name = "namep"
 	link := cat.Link()
 
-	_, err := s.crud.Create("", s.cnt.StoreWithTimeout, link)
+	_, err := s.crud.Create("", s.cnt.StoreTimeout(context.Background()), link)
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListProps(id, "namep", tt.Ranges, 1)
+			list, err := s.ListProps(context.Background(), id, "namep", tt.Ranges, 1)
 			if assert.NoError(t, err, tt.Name) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
@@ -212,11 +211,10 @@ func TestCatService_CreateProp(t *testing.T) {
 	prop, err := prop(srv.cnt, srv.crud)
 
 	if assert.NoError(t, err) {
-		ok, found, err := srv.CreateProp(prop)
+		ok, err := srv.CreateProp(context.Background(), prop)
 
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Created")
-			assert.True(t, found, "Category found")
 			checkProp(t, srv, "Checking relations", *prop)
 		}
 	}
@@ -264,10 +262,9 @@ func TestCatService_PutProp(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, found, err := srv.PutProp(tt.prop)
+		updated, err := srv.PutProp(context.Background(), tt.prop)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Property updated"))
-			assert.True(t, found, strings.Concat(tt.name, "Category found"))
 			checkProp(t, srv, tt.name, *tt.prop)
 		}
 	}
@@ -275,7 +272,7 @@ func TestCatService_PutProp(t *testing.T) {
 
 func checkProp(t *testing.T, srv Service, name string, p Prop) {
 	var prop Prop
-	_, err := srv.GetProp(p.ID, &prop)
+	_, err := srv.GetProp(context.Background(), p.ID, &prop)
 	if assert.NoError(t, err) {
 		assert.Equal(t, p, prop, "Getting property")
 	}
@@ -289,10 +286,10 @@ func TestCatService_GetProp(t *testing.T) {
 	prop, err := prop(srv.cnt, srv.crud)
 
 	if assert.NoError(t, err) {
-		_, _, err := srv.CreateProp(prop)
+		_, err := srv.CreateProp(context.Background(), prop)
 		if assert.NoError(t, err) {
 			var get Prop
-			ok, err := srv.GetProp(prop.ID, &get)
+			ok, err := srv.GetProp(context.Background(), prop.ID, &get)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, prop, &get, "Property returned")
@@ -339,7 +336,7 @@ func prop(cnt *runtime.Container, crud storage.CrudOperation) (*Prop, error) {
 
 func createCat(cnt *runtime.Container, crud storage.CrudOperation) (Category, error) {
 	cat := New()
-	_, err := crud.Put("loc", cnt.StoreWithTimeout, &cat)
+	_, err := crud.Put("loc", cnt.StoreTimeout(context.Background()), &cat)
 	return cat, err
 }
 
@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/ente"
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/relation"
@@ -30,7 +32,7 @@ func CreateEnte(mng storage.Integration) (ente.Ente, error) {
 	ente := ente.New()
 	ente.Name = "Name"
 	ente.Desc = "desc"
-	_, err := crudOper.Put("loc", cnt.StoreWithTimeout, &ente)
+	_, err := crudOper.Put("loc", cnt.StoreTimeout(context.Background()), &ente)
 	return ente, err
 }
 
@@ -41,7 +43,7 @@ func CreateLinkForSpace(mng storage.Integration, spaceID xid.ID) (storage.Entity
 	s.Desc = "desc"
 	s.SpaceID = spaceID
 	link := s.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, s, err
 }
 
@@ -53,7 +55,7 @@ func CatLinkForEnte(mng storage.Integration, catID xid.ID, name string, enteID x
 		LinkID:   enteID.String(),
 		Category: false,
 	}
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, err
 }
 
@@ -64,6 +66,6 @@ func CreateLinkProp(mng storage.Integration, enteID xid.ID) (storage.Entity, ent
 	prop.Desc = "descp"
 	prop.EnteID = enteID
 	link := prop.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, prop, err
 }
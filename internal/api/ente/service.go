@@ -17,6 +17,8 @@
 package ente
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/relation"
 	"github.com/carisa/internal/api/runtime"
@@ -47,37 +49,39 @@ func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.Cru
 
 // Create creates a Ente into of the repository and links Ente and space.Space.
 // If the Ente exists return false in the first param returned.
-// If the space.Space doesn't exist return false in the second param returned.
-func (s *Service) Create(ente *Ente) (bool, bool, error) {
+// If the space.Space doesn't exist return service.ParentNotFound.
+func (s *Service) Create(ctx context.Context, ente *Ente) (bool, error) {
 	ente.AutoID()
-	return s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, ente)
+	ok, found, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), ente)
+	return service.WithParent(ok, found, err, "space not found")
 }
 
 // Put creates or updates a Ente into of the repository.
 // If the Ente exists return true in the first param returned otherwise return false.
-// If the space.Space doesn't exist return false in the second param returned.
-func (s *Service) Put(ente *Ente) (bool, bool, error) {
-	return s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, ente)
+// If the space.Space doesn't exist return service.ParentNotFound.
+func (s *Service) Put(ctx context.Context, ente *Ente) (bool, error) {
+	ok, found, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), ente)
+	return service.WithParent(ok, found, err, "space not found")
 }
 
 // Get gets the Ente from storage
-func (s *Service) Get(id xid.ID, ente *Ente) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.EnteKey(id), ente)
+func (s *Service) Get(ctx context.Context, id xid.ID, ente *Ente) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.EnteKey(id), ente)
 	cancel()
 	return ok, err
 }
 
 // LinkToCat connect Ente to category.Category
-// If the Ente exists return true in the first param returned otherwise return false.
-// If the category.Category exists return true in the second param returned otherwise return false.
-func (s *Service) LinkToCat(enteID xid.ID, categoryID xid.ID) (bool, bool, relation.Hierarchy, error) {
+// If the Ente doesn't exist returns service.ErrNotFound.
+// If the category.Category doesn't exist returns service.ErrParentNotFound.
+func (s *Service) LinkToCat(ctx context.Context, enteID xid.ID, categoryID xid.ID) (relation.Hierarchy, error) {
 	ente := New()
 	ente.ID = enteID
 
 	cfound, pfound, link, err := s.crud.LinkTo(
 		locService,
-		s.cnt.StoreWithTimeout,
+		s.cnt.StoreTimeout(ctx),
 		nil,
 		&ente,
 		entity.CategoryKey(categoryID),
@@ -85,7 +89,7 @@ func (s *Service) LinkToCat(enteID xid.ID, categoryID xid.ID) (bool, bool, relat
 			e.(*Ente).CatID = categoryID
 		})
 	if err != nil {
-		return cfound, pfound, relation.Hierarchy{},
+		return relation.Hierarchy{},
 			s.cnt.Log.ErrWrap2(
 				err,
 				"ente cannot be linked to category",
@@ -94,16 +98,20 @@ func (s *Service) LinkToCat(enteID xid.ID, categoryID xid.ID) (bool, bool, relat
 				logging.String("EnteId", ente.Key()))
 	}
 
-	if !cfound || !pfound {
-		return cfound, pfound, relation.Hierarchy{}, nil
+	if !cfound {
+		return relation.Hierarchy{}, service.ErrNotFound.With("ente not found")
+	}
+	if !pfound {
+		return relation.Hierarchy{}, service.ErrParentNotFound.With("category not found")
 	}
-	return cfound, pfound, *link.(*relation.Hierarchy), nil
+	return *link.(*relation.Hierarchy), nil
 }
 
 // ListProps lists properties depending ranges parameter.
 // Look at service.Extension
-func (s *Service) ListProps(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListProps(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		entity.EnteKey(id),
 		strings.Concat(relation.EntePropLn, name),
 		ranges,
@@ -113,23 +121,25 @@ func (s *Service) ListProps(id xid.ID, name string, ranges bool, top int) ([]sto
 
 // CreateProp creates a property into of the repository and links Ente property and property.
 // If the property exists return false in the first param returned.
-// If the prop doesn't exist return false in the second param returned.
-func (s *Service) CreateProp(prop *Prop) (bool, bool, error) {
+// If the Ente doesn't exist return service.ParentNotFound.
+func (s *Service) CreateProp(ctx context.Context, prop *Prop) (bool, error) {
 	prop.AutoID()
-	return s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, prop)
+	ok, found, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), prop)
+	return service.WithParent(ok, found, err, "ente not found")
 }
 
 // PutProp creates or updates a property into of the repository.
 // If the property exists return true in the first param returned otherwise return false.
-// If the prop doesn't exist return false in the second param returned.
-func (s *Service) PutProp(prop *Prop) (bool, bool, error) {
-	return s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, prop)
+// If the Ente doesn't exist return service.ParentNotFound.
+func (s *Service) PutProp(ctx context.Context, prop *Prop) (bool, error) {
+	ok, found, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), prop)
+	return service.WithParent(ok, found, err, "ente not found")
 }
 
 // GetProp gets the property from storage
-func (s *Service) GetProp(id xid.ID, prop *Prop) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.EntePropKey(id), prop)
+func (s *Service) GetProp(ctx context.Context, id xid.ID, prop *Prop) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.EntePropKey(id), prop)
 	cancel()
 	return ok, err
 }
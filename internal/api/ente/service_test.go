@@ -49,11 +49,10 @@ func TestEnteService_Create(t *testing.T) {
 	s, err := ente(mng)
 
 	if assert.NoError(t, err) {
-		ok, found, err := srv.Create(s)
+		ok, err := srv.Create(context.Background(), s)
 
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Created")
-			assert.True(t, found, "Space found")
 			checkEnte(t, srv, "Checking relations", *s)
 		}
 	}
@@ -100,10 +99,9 @@ func TestEnteService_Put(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, found, err := srv.Put(tt.ente)
+		updated, err := srv.Put(context.Background(), tt.ente)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Ente updated"))
-			assert.True(t, found, strings.Concat(tt.name, "Space found"))
 			checkEnte(t, srv, tt.name, *tt.ente)
 		}
 	}
@@ -111,7 +109,7 @@ func TestEnteService_Put(t *testing.T) {
 
 func checkEnte(t *testing.T, srv Service, name string, e Ente) {
 	var er Ente
-	_, err := srv.Get(e.ID, &er)
+	_, err := srv.Get(context.Background(), e.ID, &er)
 	if assert.NoError(t, err) {
 		assert.Equal(t, e, er, "Getting ente")
 	}
@@ -125,10 +123,10 @@ func TestEnteService_Get(t *testing.T) {
 	e, err := ente(mng)
 
 	if assert.NoError(t, err) {
-		_, _, err := srv.Create(e)
+		_, err := srv.Create(context.Background(), e)
 		if assert.NoError(t, err) {
 			var get Ente
-			ok, err := srv.Get(e.ID, &get)
+			ok, err := srv.Get(context.Background(), e.ID, &get)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, e, &get, "Ente returned")
@@ -153,12 +151,9 @@ func TestEnteService_LinkToCat(t *testing.T) {
 		return
 	}
 
-	sfound, tfound, rel, err := srv.LinkToCat(ente.ID, cat.ID)
+	rel, err := srv.LinkToCat(context.Background(), ente.ID, cat.ID)
 
 	if assert.NoError(t, err) {
-		assert.True(t, sfound, "Ente found")
-		assert.True(t, tfound, "Category found")
-
 		found, err := srv.crud.Store().Exists(context.TODO(), rel.Key())
 		if assert.NoError(t, err) {
 			assert.True(t, found, "Getting link")
@@ -182,11 +177,11 @@ func TestEnteService_ListProps(t *testing.T) {
 	prop.Name = "namep"
 	link := prop.Link()
 
-	_, err := s.crud.Create("", s.cnt.StoreWithTimeout, link)
+	_, err := s.crud.Create("", s.cnt.StoreTimeout(context.Background()), link)
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListProps(id, "namep", tt.Ranges, 1)
+			list, err := s.ListProps(context.Background(), id, "namep", tt.Ranges, 1)
 			if assert.NoError(t, err, tt.Name) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
@@ -201,11 +196,10 @@ func TestEnteService_CreateProp(t *testing.T) {
 	prop, err := prop(srv.cnt, srv.crud)
 
 	if assert.NoError(t, err) {
-		ok, found, err := srv.CreateProp(prop)
+		ok, err := srv.CreateProp(context.Background(), prop)
 
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Created")
-			assert.True(t, found, "Ente found")
 			checkProp(t, srv, "Checking relations", *prop)
 		}
 	}
@@ -253,10 +247,9 @@ func TestEnteService_PutProp(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, found, err := srv.PutProp(tt.prop)
+		updated, err := srv.PutProp(context.Background(), tt.prop)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Property updated"))
-			assert.True(t, found, strings.Concat(tt.name, "Ente found"))
 			checkProp(t, srv, tt.name, *tt.prop)
 		}
 	}
@@ -264,7 +257,7 @@ func TestEnteService_PutProp(t *testing.T) {
 
 func checkProp(t *testing.T, srv Service, name string, p Prop) {
 	var prop Prop
-	_, err := srv.GetProp(p.ID, &prop)
+	_, err := srv.GetProp(context.Background(), p.ID, &prop)
 	if assert.NoError(t, err) {
 		assert.Equal(t, p, prop, "Getting property")
 	}
@@ -278,10 +271,10 @@ func TestEnteService_GetProp(t *testing.T) {
 	prop, err := prop(srv.cnt, srv.crud)
 
 	if assert.NoError(t, err) {
-		_, _, err := srv.CreateProp(prop)
+		_, err := srv.CreateProp(context.Background(), prop)
 		if assert.NoError(t, err) {
 			var get Prop
-			ok, err := srv.GetProp(prop.ID, &get)
+			ok, err := srv.GetProp(context.Background(), prop.ID, &get)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, prop, &get, "Property returned")
@@ -317,7 +310,7 @@ func prop(cnt *runtime.Container, crud storage.CrudOperation) (*Prop, error) {
 
 func createEnte(cnt *runtime.Container, crud storage.CrudOperation) (Ente, error) {
 	ente := New()
-	_, err := crud.Put("loc", cnt.StoreWithTimeout, &ente)
+	_, err := crud.Put("loc", cnt.StoreTimeout(context.Background()), &ente)
 	return ente, err
 }
 
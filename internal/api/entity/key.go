@@ -18,6 +18,7 @@
 package entity
 
 import (
+	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
 	"github.com/rs/xid"
 )
@@ -31,8 +32,17 @@ const (
 	SchCatProp  string = "CP"
 	SchPlugin   string = "P"
 	SchObject   string = "O"
+	SchTrash    string = "T"
+	SchMeta     string = "M"
 )
 
+// Prefixes gets the prefixes of all keys of the entities, their links and their DLRels.
+// The links and DLRels start by the key of the parent or child entity.
+// The links of the platform start by the virtual parent
+func Prefixes() []string {
+	return []string{SchInstance, SchSpace, SchEnte, SchCategory, SchPlugin, SchObject, SchTrash, storage.Virtual}
+}
+
 func Key(scheme string, id xid.ID) string {
 	return strings.Concat(scheme, id.String())
 }
@@ -68,3 +78,34 @@ func PluginKey(id xid.ID) string {
 func ObjectKey(id xid.ID) string {
 	return Key(SchObject, id)
 }
+
+// TenantKey gets the key that registers the tenant. The prefix of all tenants is TenantKey("")
+func TenantKey(name string) string {
+	return strings.Concat(SchMeta, "tenant#", name)
+}
+
+// APIKeyKey gets the key of the API key. The prefix of all API keys is APIKeyKey("")
+func APIKeyKey(id string) string {
+	return strings.Concat(SchMeta, "apikey#", id)
+}
+
+// GrantKey gets the key of the role granted to the principal over the scope (instance or space key).
+// The prefix of all grants of the scope is GrantKey(scope, "")
+func GrantKey(scope string, principal string) string {
+	return strings.Concat(SchMeta, "grant#", scope, "#", principal)
+}
+
+// QuotaKey gets the key of the counter of the entities with the name into the instance. See storage.Counter
+func QuotaKey(instID xid.ID, name string) string {
+	return strings.Concat(SchMeta, "quota#", instID.String(), "#", name)
+}
+
+// RateLimitKey gets the key of the token bucket of the client. See runtime.RateLimit
+func RateLimitKey(client string) string {
+	return strings.Concat(SchMeta, "ratelimit#", client)
+}
+
+// TrashKey gets the key of the deleted entity into the trash of the instance
+func TrashKey(instID xid.ID, key string) string {
+	return strings.Concat(SchTrash, instID.String(), key)
+}
@@ -17,6 +17,7 @@
 package entity
 
 import (
+	strs "strings"
 	"testing"
 
 	"github.com/carisa/pkg/strings"
@@ -63,3 +64,22 @@ func TestObjectKey(t *testing.T) {
 	id := xid.New()
 	assert.Equal(t, strings.Concat(SchObject, id.String()), ObjectKey(id))
 }
+
+func TestTrashKey(t *testing.T) {
+	id := xid.New()
+	key := EnteKey(xid.New())
+	assert.Equal(t, strings.Concat(SchTrash, id.String(), key), TrashKey(id, key))
+}
+
+func TestPrefixes(t *testing.T) {
+	prefixes := Prefixes()
+	for _, sch := range []string{SchEnteProp, SchCatProp} {
+		found := false
+		for _, p := range prefixes {
+			if strs.HasPrefix(sch, p) {
+				found = true
+			}
+		}
+		assert.True(t, found, sch)
+	}
+}
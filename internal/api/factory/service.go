@@ -22,9 +22,12 @@ import (
 	"github.com/carisa/internal/api/instance"
 	"github.com/carisa/internal/api/object"
 	"github.com/carisa/internal/api/plugin"
+	"github.com/carisa/internal/api/rbac"
 	"github.com/carisa/internal/api/runtime"
 	srv "github.com/carisa/internal/api/service"
 	"github.com/carisa/internal/api/space"
+	"github.com/carisa/internal/api/trash"
+	"github.com/carisa/internal/fsck"
 	"github.com/carisa/pkg/storage"
 )
 
@@ -36,18 +39,29 @@ type service struct {
 	catSrv      category.Service
 	pluginSrv   plugin.Service
 	objectSrv   object.Service
+	trashSrv    trash.Service
+	checker     fsck.Checker
+	grantSrv    rbac.Grants
+	authorizer  rbac.Authorizer
 }
 
+// batch is the number of keys read or written by request in the massive operations
+const batch = 50
+
 // configService builds the services
 func configService(cnt *runtime.Container, store storage.CRUD) service {
-	crud := storage.NewCrudOperation(store, cnt.Log, storage.NewTxn)
 	ext := srv.NewExt(cnt, store)
+	crud := storage.NewCountedCrudOperation(store, cnt.Log, storage.NewTxn, instance.NewQuota(cnt, ext, store).Counters)
 	s := service{
 		instanceSrv: instance.NewService(cnt, ext, crud),
 		spaceSrv:    space.NewService(cnt, ext, crud),
 		enteSrv:     ente.NewService(cnt, ext, crud),
 		pluginSrv:   plugin.NewService(cnt, ext, crud),
+		trashSrv:    trash.NewService(cnt, ext, crud),
+		checker:     fsck.NewChecker(cnt, store, batch),
+		grantSrv:    rbac.NewGrants(cnt, crud),
 	}
+	s.authorizer = rbac.NewAuthorizer(cnt, ext, s.grantSrv)
 	s.catSrv = category.NewService(cnt, ext, crud, &s.enteSrv)
 	s.objectSrv = object.NewService(cnt, ext, crud, &s.pluginSrv)
 	return s
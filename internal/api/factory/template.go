@@ -17,12 +17,23 @@
 package factory
 
 import (
+	"time"
+
+	"github.com/carisa/internal/api/auth"
 	"github.com/carisa/internal/api/http/handler"
+	"github.com/carisa/internal/api/ratelimit"
 	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/trash"
+	"github.com/carisa/internal/cluster"
+	"github.com/carisa/pkg/health"
 	loge "github.com/carisa/pkg/http/echo"
 	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/metrics"
 	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/tracing"
 	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/xid"
 )
 
 const locBuild = "factory.build"
@@ -31,20 +42,40 @@ const locBuild = "factory.build"
 type Template struct {
 	Config   runtime.Config
 	Handlers handler.Handlers
+	Tenants  handler.Tenants
 	Echo     *echo.Echo
+	// Middlewares are the middlewares of the http server. See server.Middleware
+	Middlewares []echo.MiddlewareFunc
+	// Purger purges the trash if this replica is the leader among the replicas of the API
+	Purger *trash.Purger
+	// Registry collects the metrics of the API. It is nil if the metrics are disabled. See server.Metrics
+	Registry *prometheus.Registry
+	// Probe answers the liveness and readiness probes. See server.Probes
+	Probe *health.Probe
 
-	store storage.CRUD
-	cnt   *runtime.Container
+	store     storage.CRUD
+	elections storage.Elections
+	cnt       *runtime.Container
+	tenants   *tenants
+	tracer    *tracing.Tracer
 }
 
 func (c *Template) Close() {
 	const loc = "factory.close"
 	c.cnt.Log.Info("closing connections", loc)
+	if err := c.elections.Close(); err != nil {
+		c.cnt.Log.ErrorE(err, loc)
+	}
 	if err := c.store.Close(); err != nil {
 		c.cnt.Log.ErrorE(err, loc)
 	} else {
 		c.cnt.Log.Info("closed connections", loc)
 	}
+	if c.tracer != nil {
+		if err := c.tracer.Close(); err != nil {
+			c.cnt.Log.ErrorE(err, loc)
+		}
+	}
 }
 
 // Build builds the services, store, log, etc..
@@ -54,16 +85,41 @@ func Build() Template {
 
 func build(mng storage.Integration /*for test*/) Template {
 	cnf, cnt, store, e := servers(mng)
+	reg, store := instrument(cnt, store)
 	srv := services(cnt, store)
 	handlers := handlers(srv, cnt)
+	keys := auth.NewAPIKeys(cnt, storage.NewCrudOperation(store, cnt.Log, storage.NewTxn))
+	handlers.APIKeyHandler = handler.NewAPIKeyHandle(keys, cnt)
+	handlers.SplitterHandler = handler.NewSplitterHandle(
+		cluster.NewRegistry(cnt.Cluster, store, cnt.StoreWithTimeout, batch),
+		cluster.NewAssigner(cnt.Cluster, store, cnt.StoreWithTimeout, batch),
+		cnt)
+	tenants := newTenants(cnt, store, &tenant{srv: srv, handlers: handlers})
+	if err := tenants.load(); err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "loading the tenants", locBuild), locBuild)
+	}
+	handlers.TenantHandler = handler.NewTenantHandle(cnt, tenants.create)
+	tracer := newTracer(cnt)
+	elections, err := storage.NewElections(store, cnt.LeaderTTLInSecs*time.Second)
+	if err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "building the elections", locBuild), locBuild)
+	}
 	cnt.Log.Info1("http server started", locBuild, logging.String("address", cnf.Server.Address()))
 
 	return Template{
-		Config:   cnf,
-		Handlers: handlers,
-		Echo:     e,
-		store:    store,
-		cnt:      cnt,
+		Config:      cnf,
+		Handlers:    handlers,
+		Tenants:     handler.NewTenants(cnt, handlers, tenants.of),
+		Echo:        e,
+		Middlewares: append(observers(cnt, tracer, reg), middlewares(cnt, &keys, store)...),
+		Purger:      trash.NewPurger(cnt, tenants.trash, xid.New().String(), elections),
+		Registry:    reg,
+		Probe:       probe(cnt, store),
+		store:       store,
+		elections:   elections,
+		cnt:         cnt,
+		tenants:     tenants,
+		tracer:      tracer,
 	}
 }
 
@@ -75,6 +131,7 @@ func servers(mng storage.Integration) (runtime.Config, *runtime.Container, stora
 	log.Info1("initializing http server", locBuild, logging.String("address", cnf.Server.Address()))
 	e := echo.New()
 	e.Logger = loge.NewLogging("echo", loge.ConvertLevel(log.Level()), zLog)
+	e.HTTPErrorHandler = loge.ErrorHandler(log)
 
 	cnt := runtime.NewContainer(cnf, log)
 
@@ -94,6 +151,97 @@ func services(cnt *runtime.Container, store storage.CRUD) service {
 	return srv
 }
 
+// newTracer builds the tracer if the tracing is enabled
+func newTracer(cnt *runtime.Container) *tracing.Tracer {
+	tracer, err := tracing.New(cnt.Tracing, cnt.Log)
+	if err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "creating the exporter of the tracing", locBuild), locBuild)
+	}
+	return tracer
+}
+
+// probe builds the probes. The API is ready if the store answers
+func probe(cnt *runtime.Container, store storage.CRUD) *health.Probe {
+	p := health.NewProbe(cnt.Version)
+	p.Add("store", health.StoreCheck(store, cnt.StoreWithTimeout))
+	return p
+}
+
+// instrument decorates the store to collect its metrics if the metrics are enabled.
+// Returns the registry of the metrics or nil if they are disabled
+func instrument(cnt *runtime.Container, store storage.CRUD) (*prometheus.Registry, storage.CRUD) {
+	if !cnt.Metrics.Enabled {
+		return nil, store
+	}
+	reg := metrics.NewRegistry()
+	m, err := storage.NewMetrics(reg)
+	if err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "registering the metrics of the store", locBuild), locBuild)
+	}
+	return reg, storage.NewInstrumented(store, m)
+}
+
+// observers builds the middlewares that identify and measure the requests. They go before the others
+// so that the errors of the authentication and the rate limit carry the request ID and the span and are measured
+func observers(cnt *runtime.Container, tracer *tracing.Tracer, reg *prometheus.Registry) []echo.MiddlewareFunc {
+	mws := []echo.MiddlewareFunc{loge.RequestIDMiddleware()}
+	if tracer != nil {
+		mws = append(mws, loge.Trace(tracer, cnt.Log))
+	}
+	if reg != nil {
+		mw, err := loge.Metrics(reg)
+		if err != nil {
+			cnt.Log.PanicE(cnt.Log.ErrWrap(err, "registering the metrics of the http server", locBuild), locBuild)
+		}
+		mws = append(mws, mw)
+	}
+	return mws
+}
+
+// middlewares builds the authentication and rate limit middlewares if they are enabled.
+// The API keys are always accepted and the bearer tokens are accepted if there are JWT keys.
+// The rate limit goes after the authentication to limit by principal
+func middlewares(cnt *runtime.Container, keys *auth.APIKeys, store storage.CRUD) []echo.MiddlewareFunc {
+	var mws []echo.MiddlewareFunc
+	var lim ratelimit.Limiter
+	var proxies ratelimit.Proxies
+	if cnt.RateLimit.Enabled {
+		var err error
+		if proxies, err = ratelimit.NewProxies(cnt.RateLimit.TrustedProxies); err != nil {
+			cnt.Log.PanicE(cnt.Log.ErrWrap(err, "parsing the trusted proxies", locBuild), locBuild)
+		}
+		lim = limiter(cnt, store)
+	}
+	if cnt.Auth.Enabled {
+		auths := []auth.Authenticator{keys}
+		if len(cnt.Auth.JWT.Keys) > 0 {
+			j, err := auth.NewJWT(cnt.Auth.JWT)
+			if err != nil {
+				cnt.Log.PanicE(cnt.Log.ErrWrap(err, "loading the JWT keys", locBuild), locBuild)
+			}
+			auths = append(auths, &j)
+		}
+		if cnt.RateLimit.Enabled {
+			mws = append(mws, ratelimit.Failures(cnt, lim, proxies))
+		}
+		mws = append(mws, auth.Middleware(cnt, auths...))
+	} else {
+		cnt.Log.Warn("the authentication is disabled", locBuild)
+	}
+	if cnt.RateLimit.Enabled {
+		mws = append(mws, ratelimit.Middleware(cnt, lim, proxies))
+	}
+	return mws
+}
+
+// limiter builds the limiter into the store if the buckets are shared among replicas or else into memory
+func limiter(cnt *runtime.Container, store storage.CRUD) ratelimit.Limiter {
+	if cnt.RateLimit.Shared {
+		return ratelimit.NewShared(cnt, store)
+	}
+	return ratelimit.NewLocal(cnt.RateLimit)
+}
+
 func handlers(srv service, cnt *runtime.Container) handler.Handlers {
 	cnt.Log.Info("configuring http handlers", locBuild)
 	return handler.Handlers{
@@ -103,5 +251,9 @@ func handlers(srv service, cnt *runtime.Container) handler.Handlers {
 		CategoryHandler: handler.NewCatHandle(srv.catSrv, cnt),
 		PluginHandler:   handler.NewPluginHandle(srv.pluginSrv, cnt),
 		ObjectHandler:   handler.NewObjectHandle(srv.objectSrv, cnt),
+		TrashHandler:    handler.NewTrashHandle(srv.trashSrv, cnt),
+		FsckHandler:     handler.NewFsckHandle(srv.checker, cnt),
+		GrantHandler:    handler.NewGrantHandle(srv.grantSrv, cnt),
+		Authorizer:      srv.authorizer,
 	}
 }
@@ -17,11 +17,17 @@
 package factory
 
 import (
+	"context"
 	"testing"
 
 	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/ratelimit"
+	"github.com/carisa/internal/cluster"
 
+	"github.com/carisa/pkg/health"
+	"github.com/carisa/pkg/metrics"
 	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/tracing"
 
 	"github.com/carisa/internal/api/runtime"
 	"github.com/stretchr/testify/assert"
@@ -31,7 +37,16 @@ import (
 
 func TestTemplate_Build(t *testing.T) {
 	cnf := runtime.Config{
-		Server: runtime.Server{Port: 8080},
+		Server:  runtime.Server{Port: 8080},
+		Trash:   runtime.Trash{RetentionInHours: 168, PurgeInSecs: 3600, LeaderTTLInSecs: 30},
+		Tenancy: runtime.Tenancy{Header: "X-Carisa-Tenant"},
+		Auth:    runtime.Auth{KeyHeader: "X-Carisa-Key", MaxBodyBytes: 1 << 20},
+		RateLimit: runtime.RateLimit{
+			Rate:     50,
+			Burst:    100,
+			ListCost: 5,
+		},
+		Cluster: cluster.Config{HeartbeatInSecs: 15, DeadAfter: 3},
 		CommonConfig: pkgr.CommonConfig{
 			EtcdConfig: storage.EtcdConfig{RequestTimeout: 10},
 		},
@@ -51,4 +66,77 @@ func TestTemplate_Build(t *testing.T) {
 	assert.NotNil(t, factory.Handlers.CategoryHandler, "Category Handler")
 	assert.NotNil(t, factory.Handlers.PluginHandler, "Plugin Handler")
 	assert.NotNil(t, factory.Handlers.ObjectHandler, "Object Handler")
+	assert.NotNil(t, factory.Handlers.TrashHandler, "Trash Handler")
+	assert.NotNil(t, factory.Handlers.FsckHandler, "Fsck Handler")
+	assert.NotNil(t, factory.Handlers.APIKeyHandler, "API key Handler")
+	assert.NotNil(t, factory.Handlers.GrantHandler, "Grant Handler")
+	assert.NotNil(t, factory.Handlers.SplitterHandler, "Splitter Handler")
+	assert.NotNil(t, factory.Handlers.Authorizer, "Authorizer")
+	assert.NotNil(t, factory.Tenants, "Tenants")
+	assert.Len(t, factory.Middlewares, 1, "Authentication disabled, only the request ID")
+	assert.NotNil(t, factory.Purger, "Trash purger")
+	assert.NotNil(t, factory.Probe, "Probe")
+}
+
+func TestTemplate_Middlewares(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.Auth.Enabled = true
+	assert.Len(t, middlewares(cnt, nil, nil), 1, "Authentication enabled")
+
+	cnt.RateLimit.Enabled = true
+	assert.Len(t, middlewares(cnt, nil, nil), 3, "Rate limit and failed authentications enabled")
+	assert.IsType(t, &ratelimit.Local{}, limiter(cnt, nil), "Limiter into memory")
+	cnt.RateLimit.Shared = true
+	assert.IsType(t, ratelimit.Shared{}, limiter(cnt, nil), "Limiter into store")
+
+	cnt.Auth.JWT.Keys = []string{"none.pem"}
+	assert.Panics(t, func() { middlewares(cnt, nil, nil) }, "JWT keys not found")
+}
+
+func TestTemplate_Instrument(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	store := mng.Store()
+
+	reg, s := instrument(cnt, store)
+	assert.Nil(t, reg, "Metrics disabled")
+	assert.Equal(t, store, s, "Store not instrumented")
+
+	cnt.Metrics.Enabled = true
+	reg, s = instrument(cnt, store)
+	assert.NotNil(t, reg, "Metrics enabled")
+	assert.NotEqual(t, store, s, "Store instrumented")
+}
+
+func TestTemplate_Probe(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.Version = "v3"
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+
+	r, ready := probe(cnt, mng.Store()).Ready(context.TODO())
+	assert.True(t, ready, "Store ready")
+	assert.Equal(t, map[string]string{"store": health.StatusOk}, r.Checks, "Checks")
+	assert.Equal(t, "v3", r.ConfigVersion, "Config version")
+
+	failing := &storage.ErrMockCRUD{}
+	failing.Activate("Exists")
+	_, ready = probe(cnt, failing).Ready(context.TODO())
+	assert.False(t, ready, "Store not ready")
+}
+
+func TestTemplate_Observers(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	assert.Nil(t, newTracer(cnt), "Tracing disabled")
+	assert.Len(t, observers(cnt, nil, nil), 1, "Request ID")
+
+	cnt.Tracing = tracing.Config{Enabled: true, Exporter: tracing.StdoutExporter}
+	tracer := newTracer(cnt)
+	assert.NotNil(t, tracer, "Tracing enabled")
+	assert.Len(t, observers(cnt, tracer, nil), 2, "Request ID and trace")
+	assert.Len(t, observers(cnt, tracer, metrics.NewRegistry()), 3, "Request ID, trace and metrics")
+
+	cnt.Tracing = tracing.Config{Enabled: true, Exporter: tracing.FileExporter, Path: "/none/spans.json"}
+	assert.Panics(t, func() { newTracer(cnt) }, "Exporter not found")
 }
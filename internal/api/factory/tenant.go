@@ -0,0 +1,188 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package factory
+
+import (
+	"context"
+	strs "strings"
+	"sync"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/http/handler"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/trash"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const locTenant = "factory.tenant"
+
+// The tenants not registered are cached during unknownTTL to avoid finding them into the store
+// on each request. The cache keeps maxUnknown tenants at most
+const (
+	unknownTTL = 5 * time.Second
+	maxUnknown = 1024
+)
+
+// tenant groups the services and handlers of a tenant
+type tenant struct {
+	srv      service
+	handlers handler.Handlers
+}
+
+// tenants builds and caches the services of each tenant.
+// The services of a tenant use a store isolated into the namespace of the tenant.
+// The tenants are registered into the store to be loaded when the API starts
+type tenants struct {
+	cnt     *runtime.Container
+	store   storage.CRUD
+	root    *tenant
+	mu      sync.RWMutex
+	byName  map[string]*tenant
+	unknown map[string]time.Time
+}
+
+func newTenants(cnt *runtime.Container, store storage.CRUD, root *tenant) *tenants {
+	return &tenants{
+		cnt:     cnt,
+		store:   store,
+		root:    root,
+		byName:  make(map[string]*tenant),
+		unknown: make(map[string]time.Time),
+	}
+}
+
+// of gets the handlers of the tenant. The tenants registered by other instances of the API
+// are built when they are requested the first time. If the tenant is not registered returns false.
+// The tenants registered by other instances may be not found until unknownTTL after they were requested
+func (t *tenants) of(name string) (*handler.Handlers, bool, error) {
+	t.mu.RLock()
+	tn, ok := t.byName[name]
+	expires, unknown := t.unknown[name]
+	t.mu.RUnlock()
+	if ok {
+		return &tn.handlers, true, nil
+	}
+	if unknown && time.Now().Before(expires) {
+		return nil, false, nil
+	}
+
+	ctx, cancel := t.cnt.StoreWithTimeout(context.Background())
+	found, err := t.store.Exists(ctx, entity.TenantKey(name))
+	cancel()
+	if err != nil {
+		return nil, false, t.cnt.Log.ErrWrap1(err, "finding the tenant", locTenant, logging.String("tenant", name))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !found {
+		t.forget(name)
+		return nil, false, nil
+	}
+	if tn, ok := t.byName[name]; ok {
+		return &tn.handlers, true, nil
+	}
+	return &t.build(name).handlers, true, nil
+}
+
+// forget caches the tenant not registered. If the cache is full the expired tenants are removed
+// and if it is full yet, it is emptied
+func (t *tenants) forget(name string) {
+	now := time.Now()
+	if len(t.unknown) >= maxUnknown {
+		for n, expires := range t.unknown {
+			if !now.Before(expires) {
+				delete(t.unknown, n)
+			}
+		}
+		if len(t.unknown) >= maxUnknown {
+			t.unknown = make(map[string]time.Time)
+		}
+	}
+	t.unknown[name] = now.Add(unknownTTL)
+}
+
+// create registers the tenant. If the tenant is already registered returns false
+func (t *tenants) create(name string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	created, err := t.register(name)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := t.byName[name]; !ok {
+		t.build(name)
+	}
+	if created {
+		t.cnt.Log.Info1("registered tenant", locTenant, logging.String("tenant", name))
+	}
+	return created, nil
+}
+
+// load builds the tenants registered into the store. The tenants with invalid names are skipped
+func (t *tenants) load() error {
+	prefix := entity.TenantKey("")
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	storeTimeout := t.cnt.StoreTimeout(context.Background())
+	return storage.ScanRaw(storeTimeout, t.store, prefix, batch, func(keys []string, _ map[string]string) error {
+		for _, key := range keys {
+			name := strs.TrimPrefix(key, prefix)
+			if !storage.ValidNamespace(name) {
+				t.cnt.Log.Warn1("skipped the tenant with invalid name", locTenant, logging.String("tenant", name))
+				continue
+			}
+			t.build(name)
+		}
+		return nil
+	})
+}
+
+// trash gets the trash services of the root and all tenants
+func (t *tenants) trash() []*trash.Service {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	srvs := make([]*trash.Service, 0, len(t.byName)+1)
+	srvs = append(srvs, &t.root.srv.trashSrv)
+	for _, tn := range t.byName {
+		srvs = append(srvs, &tn.srv.trashSrv)
+	}
+	return srvs
+}
+
+func (t *tenants) register(name string) (bool, error) {
+	key := entity.TenantKey(name)
+	txn := storage.NewTxn(t.store)
+	txn.Find(key)
+	txn.DoNotFound(t.store.PutRaw(key, name))
+	ctx, cancel := t.cnt.StoreWithTimeout(context.Background())
+	created, err := txn.Commit(ctx)
+	cancel()
+	if err != nil {
+		return false, t.cnt.Log.ErrWrap1(err, "registering the tenant", locTenant, logging.String("tenant", name))
+	}
+	return created, nil
+}
+
+func (t *tenants) build(name string) *tenant {
+	delete(t.unknown, name)
+	srv := configService(t.cnt, storage.NewNamespace(t.store, name))
+	tn := &tenant{srv: srv, handlers: handlers(srv, t.cnt)}
+	t.byName[name] = tn
+	return tn
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/http/server"
+	"github.com/carisa/internal/api/instance"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenants_Isolation(t *testing.T) {
+	sMock := mock.NewStorageFake(t)
+	defer sMock.Close()
+
+	f := build(sMock)
+	server.Router(f.Echo, f.Tenants)
+
+	rec := serve(f.Echo, nethttp.MethodPost, "/api/instances", "acme", `{"name":"name","description":"desc"}`)
+	if !assert.Equal(t, nethttp.StatusNotFound, rec.Code, "The tenant is not registered") {
+		return
+	}
+	rec = serve(f.Echo, nethttp.MethodPut, "/api/admin/tenants/acme", "", "")
+	if !assert.Equal(t, nethttp.StatusCreated, rec.Code, "Registering the tenant") {
+		return
+	}
+	rec = serve(f.Echo, nethttp.MethodPost, "/api/instances", "acme", `{"name":"name","description":"desc"}`)
+	if !assert.Equal(t, nethttp.StatusCreated, rec.Code, "Creating instance into the tenant") {
+		return
+	}
+	var inst instance.Instance
+	if err := json.Unmarshal(rec.Body.Bytes(), &inst); !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		status int
+	}{
+		{
+			name:   "Getting from the same tenant.",
+			tenant: "acme",
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Getting from other tenant.",
+			tenant: "other",
+			status: nethttp.StatusNotFound,
+		},
+		{
+			name:   "Getting from other tenant registered.",
+			tenant: "beta",
+			status: nethttp.StatusNotFound,
+		},
+		{
+			name:   "Getting without tenant.",
+			status: nethttp.StatusNotFound,
+		},
+	}
+	rec = serve(f.Echo, nethttp.MethodPut, "/api/admin/tenants/beta", "", "")
+	assert.Equal(t, nethttp.StatusCreated, rec.Code, "Registering other tenant")
+	for _, tt := range tests {
+		rec := serve(f.Echo, nethttp.MethodGet, "/api/instances/"+inst.ID.String(), tt.tenant, "")
+		assert.Equal(t, tt.status, rec.Code, tt.name)
+	}
+
+	// The tenants registered by other instance are found when they are requested
+	root := f.tenants.root
+	tenants := newTenants(f.cnt, f.store, root)
+	_, found, err := tenants.of("acme")
+	if assert.NoError(t, err, "Getting the tenant registered") {
+		assert.True(t, found, "Tenant registered found")
+	}
+	_, found, err = tenants.of("other")
+	if assert.NoError(t, err, "Getting the tenant not registered") {
+		assert.False(t, found, "Tenant not registered")
+	}
+
+	// The tenants not registered are cached for a while
+	if _, err := f.tenants.register("other"); !assert.NoError(t, err, "Registering by other instance") {
+		return
+	}
+	_, found, _ = tenants.of("other")
+	assert.False(t, found, "Tenant not registered cached")
+	tenants.unknown["other"] = time.Now().Add(-time.Second)
+	_, found, _ = tenants.of("other")
+	assert.True(t, found, "Tenant not registered expired")
+
+	// The tenants registered are loaded when the API starts. The invalid names are skipped
+	ctx, cancel := f.cnt.StoreWithTimeout(context.Background())
+	txn := storage.NewTxn(f.store)
+	txn.Find(entity.TenantKey("Invalid/Name"))
+	txn.DoNotFound(f.store.PutRaw(entity.TenantKey("Invalid/Name"), "Invalid/Name"))
+	_, err = txn.Commit(ctx)
+	cancel()
+	if !assert.NoError(t, err, "Registering invalid tenant") {
+		return
+	}
+	tenants = newTenants(f.cnt, f.store, root)
+	if assert.NoError(t, tenants.load(), "Loading") {
+		assert.Len(t, tenants.byName, 3, "Tenants loaded")
+		assert.Contains(t, tenants.byName, "acme", "Tenant acme")
+		assert.NotContains(t, tenants.byName, "Invalid/Name", "Tenant invalid")
+		assert.Len(t, tenants.trash(), 4, "Trash of the root and the tenants")
+	}
+}
+
+func serve(e *echo.Echo, method string, url string, tenant string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if len(tenant) > 0 {
+		req.Header.Set("X-Carisa-Tenant", tenant)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
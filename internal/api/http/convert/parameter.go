@@ -46,24 +46,9 @@ func FilterLink(c http.Context, excludeID bool) (xid.ID, string, int, bool, erro
 		}
 	}
 
-	tops := c.QueryParam("top")
-	top := 20
-	if len(tops) != 0 {
-		top, err = strconv.Atoi(tops)
-		if err != nil {
-			return xid.NilID(),
-				"",
-				0,
-				false,
-				c.HTTPError(nethttp.StatusBadRequest, "the filter top parameter has a incorrect format")
-		}
-	}
-	if !(top >= 1 && top <= 100) {
-		return xid.NilID(),
-			"",
-			0,
-			false,
-			c.HTTPError(nethttp.StatusBadRequest, "the top parameters must be between 1 and 100")
+	top, err := Top(c)
+	if err != nil {
+		return xid.NilID(), "", 0, false, err
 	}
 
 	sname := c.QueryParam("sname")
@@ -83,6 +68,23 @@ func FilterLink(c http.Context, excludeID bool) (xid.ID, string, int, bool, erro
 		c.HTTPError(nethttp.StatusBadRequest, "the filter parameters are missing. sname or qtname")
 }
 
+// Top gets the top query parameter. The default top parameter is 20
+func Top(c http.Context) (int, error) {
+	tops := c.QueryParam("top")
+	top := 20
+	if len(tops) != 0 {
+		var err error
+		top, err = strconv.Atoi(tops)
+		if err != nil {
+			return 0, c.HTTPError(nethttp.StatusBadRequest, "the filter top parameter has a incorrect format")
+		}
+	}
+	if !(top >= 1 && top <= 100) {
+		return 0, c.HTTPError(nethttp.StatusBadRequest, "the top parameters must be between 1 and 100")
+	}
+	return top, nil
+}
+
 func ParamXID(c http.Context, name string) (xid.ID, error) {
 	value := c.Param(name)
 
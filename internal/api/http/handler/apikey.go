@@ -0,0 +1,107 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/auth"
+	"github.com/carisa/internal/api/http/convert"
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/storage"
+)
+
+const locAPIKey = "http.apikey"
+
+// APIKey hands the http request of the API keys
+type APIKey struct {
+	srv auth.APIKeys
+	cnt *runtime.Container
+}
+
+// apiKeyCreated is the response of the creation. The token is only returned when the key is created
+type apiKeyCreated struct {
+	auth.APIKey
+	Token string `json:"token"`
+}
+
+// NewAPIKeyHandle creates handler
+func NewAPIKeyHandle(srv auth.APIKeys, cnt *runtime.Container) APIKey {
+	return APIKey{
+		srv: srv,
+		cnt: cnt,
+	}
+}
+
+// Create creates an API key. The principal of the request is recorded as creator.
+// The key only reaches the tenant of the request body, without tenant it reaches the keys out of any tenant
+func (a *APIKey) Create(c httpc.Context) error {
+	req := struct {
+		Name   string `json:"name"`
+		Tenant string `json:"tenant"`
+	}{}
+	if err := c.Bind(&req); err != nil {
+		return c.HTTPErrorLog(nethttp.StatusBadRequest, "cannot recover the API key", err, a.cnt.Log, locAPIKey)
+	}
+	if err := c.NoEmpty("name", req.Name); err != nil {
+		return err
+	}
+	if err := c.MaxLen("name", req.Name, 50); err != nil {
+		return err
+	}
+
+	if len(req.Tenant) > 0 && !storage.ValidNamespace(req.Tenant) {
+		return c.HTTPError(nethttp.StatusBadRequest, "the tenant only can contain lowercase letters, digits, '-' or '_'")
+	}
+
+	key, token, err := a.srv.Create(c.Context(), req.Name, req.Tenant, c.Principal().ID)
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to create the API key")
+	}
+
+	key.Hash = "" // The hash is internal
+	return c.JSON(nethttp.StatusCreated, apiKeyCreated{APIKey: key, Token: token})
+}
+
+// List lists all API keys
+func (a *APIKey) List(c httpc.Context) error {
+	keys, err := a.srv.List(c.Context())
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the API keys")
+	}
+	for _, key := range keys {
+		key.(*auth.APIKey).Hash = ""
+	}
+	return c.JSON(nethttp.StatusOK, keys)
+}
+
+// Revoke removes the API key by ID
+func (a *APIKey) Revoke(c httpc.Context) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+
+	found, err := a.srv.Revoke(c.Context(), id)
+	if err := errCRUDSrv(c, err, "it was impossible to revoke the API key", "API key not found", found); err != nil {
+		return err
+	}
+	return c.JSON(nethttp.StatusOK, struct {
+		ID string `json:"id"`
+	}{ID: id.String()})
+}
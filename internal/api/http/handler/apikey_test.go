@@ -0,0 +1,165 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/carisa/internal/api/auth"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyHandler_Create(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, mng := newAPIKeyHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{
+			name:   "Creating API key.",
+			body:   `{"name":"admin"}`,
+			status: nethttp.StatusCreated,
+		},
+		{
+			name:   "Creating API key bound to a tenant.",
+			body:   `{"name":"acme","tenant":"acme"}`,
+			status: nethttp.StatusCreated,
+		},
+		{
+			name:   "Creating API key. Tenant not valid.",
+			body:   `{"name":"acme","tenant":"Acme#"}`,
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Creating API key. Without name.",
+			body:   `{}`,
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Creating API key. Body not valid.",
+			body:   `{"name":`,
+			status: nethttp.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(nethttp.MethodPost, "/api/admin/apikeys", tt.body, nil, nil)
+		err := handlers.APIKeyHandler.Create(ctx)
+		if tt.status != nethttp.StatusCreated {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, tt.name)
+			var created apiKeyCreated
+			if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created), tt.name) {
+				assert.NotEmpty(t, created.Token, tt.name)
+				assert.Empty(t, created.Hash, tt.name)
+			}
+		}
+	}
+}
+
+func TestAPIKeyHandler_ListAndRevoke(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, mng := newAPIKeyHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	key, _, err := handlers.APIKeyHandler.srv.Create(context.Background(), "admin", "", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rec, ctx := h.NewHTTP(nethttp.MethodGet, "/api/admin/apikeys", "", nil, nil)
+	if assert.NoError(t, handlers.APIKeyHandler.List(ctx), "Listing") {
+		assert.Contains(t, rec.Body.String(), key.ID.String(), "Listing")
+		assert.NotContains(t, rec.Body.String(), key.Hash, "The hash is not returned")
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{
+			name:   "Revoking API key.",
+			id:     key.ID.String(),
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Revoking API key. Not found.",
+			id:     xid.New().String(),
+			status: nethttp.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(nethttp.MethodDelete, "/api/admin/apikeys/:id", "", map[string]string{"id": tt.id}, nil)
+		err := handlers.APIKeyHandler.Revoke(ctx)
+		if tt.status != nethttp.StatusOK {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, tt.name)
+		}
+	}
+}
+
+func TestAPIKeyHandler_WithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	crud := storage.NewErrMockCRUDOper()
+	crud.Activate("Create")
+	crud.Store().(*storage.ErrMockCRUD).Activate("StartKey")
+	handlers := Handlers{APIKeyHandler: NewAPIKeyHandle(auth.NewAPIKeys(cnt, crud), cnt)}
+
+	_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/admin/apikeys", "", nil, nil)
+	err := handlers.APIKeyHandler.List(ctx)
+	if assert.Error(t, err, "Listing") {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code, "Listing")
+	}
+
+	_, ctx = h.NewHTTP(nethttp.MethodPost, "/api/admin/apikeys", `{"name":"admin"}`, nil, nil)
+	err = handlers.APIKeyHandler.Create(ctx)
+	if assert.Error(t, err, "Creating") {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code, "Creating")
+	}
+}
+
+func newAPIKeyHandlerFaked(t *testing.T) (*runtime.Container, Handlers, storage.Integration) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	hands := Handlers{APIKeyHandler: NewAPIKeyHandle(auth.NewAPIKeys(cnt, crud), cnt)}
+	return cnt, hands, mng
+}
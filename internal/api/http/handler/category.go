@@ -52,12 +52,8 @@ func (c *Category) Create(ctx httpc.Context) error {
 		return err
 	}
 
-	created, found, err := c.srv.Create(&cat)
-	if err = errCRUDSrv(
-		ctx,
-		err,
-		"it was impossible to create the category",
-		"category or space not found", found); err != nil {
+	created, err := c.srv.Create(ctx.Context(), &cat)
+	if err = errSrv(ctx, err, "it was impossible to create the category"); err != nil {
 		return err
 	}
 
@@ -77,12 +73,8 @@ func (c *Category) Put(ctx httpc.Context) error {
 	}
 
 	cat.ID = id
-	updated, found, err := c.srv.Put(&cat)
-	if err = errCRUDSrv(
-		ctx,
-		err,
-		"it was impossible to create or update the category",
-		"space or category not found", found); err != nil {
+	updated, err := c.srv.Put(ctx.Context(), &cat)
+	if err = errSrv(ctx, err, "it was impossible to create or update the category"); err != nil {
 		return err
 	}
 
@@ -98,7 +90,7 @@ func (c *Category) Get(ctx httpc.Context) error {
 		return err
 	}
 
-	found, err := c.srv.Get(id, &cat)
+	found, err := c.srv.Get(ctx.Context(), id, &cat)
 	if err != nil {
 		return ctx.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the category")
 	}
@@ -115,7 +107,7 @@ func (c *Category) ListCategories(ctx httpc.Context) error {
 		return err
 	}
 
-	props, err := c.srv.ListCategories(id, name, ranges, top)
+	props, err := c.srv.ListCategories(ctx.Context(), id, name, ranges, top)
 	if err != nil {
 		return ctx.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the child categories of the category")
 	}
@@ -132,7 +124,7 @@ func (c *Category) ListProps(ctx httpc.Context) error {
 		return err
 	}
 
-	props, err := c.srv.ListProps(id, name, ranges, top)
+	props, err := c.srv.ListProps(ctx.Context(), id, name, ranges, top)
 	if err != nil {
 		return ctx.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the properties of the category")
 	}
@@ -150,13 +142,8 @@ func (c *Category) CreateProp(ctx httpc.Context) error {
 		return err
 	}
 
-	created, found, err := c.srv.CreateProp(&prop)
-	if err = errCRUDSrv(
-		ctx,
-		err,
-		"it was impossible to create the property of the category",
-		"category not found",
-		found); err != nil {
+	created, err := c.srv.CreateProp(ctx.Context(), &prop)
+	if err = errSrv(ctx, err, "it was impossible to create the property of the category"); err != nil {
 		return err
 	}
 
@@ -179,9 +166,8 @@ func (c *Category) PutProp(ctx httpc.Context) error {
 	}
 
 	prop.ID = id
-	updated, found, err := c.srv.PutProp(&prop)
-	if err = errCRUDSrv(
-		ctx, err, "it was impossible to create or update the property of the category", "category not found", found); err != nil {
+	updated, err := c.srv.PutProp(ctx.Context(), &prop)
+	if err = errSrv(ctx, err, "it was impossible to create or update the property of the category"); err != nil {
 		return err
 	}
 
@@ -197,7 +183,7 @@ func (c *Category) GetProp(ctx httpc.Context) error {
 		return err
 	}
 
-	found, err := c.srv.GetProp(id, &prop)
+	found, err := c.srv.GetProp(ctx.Context(), id, &prop)
 	if err != nil {
 		return ctx.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the property of the category")
 	}
@@ -216,26 +202,9 @@ func (c *Category) LinkToProp(ctx httpc.Context) error {
 		return err
 	}
 
-	pfound, cfound, isChild, equalType, rel, err := c.srv.LinkToProp(catPropID, propID)
-	if err != nil {
-		return ctx.HTTPError(nethttp.StatusInternalServerError, err)
-	}
-
-	if !pfound {
-		return ctx.HTTPError(nethttp.StatusNotFound, "Category property not found")
-	}
-	if !cfound {
-		return ctx.HTTPError(nethttp.StatusNotFound, "Target property (category or ente property) not found")
-	}
-	if !isChild {
-		return ctx.HTTPError(
-			nethttp.StatusBadRequest,
-			"The category of the target property (category or ente property) must be child of the category of the property for linking")
-	}
-	if !equalType {
-		return ctx.HTTPError(
-			nethttp.StatusConflict,
-			"The target property (category or ente property) must be of the same type than the category property for linking")
+	rel, err := c.srv.LinkToProp(ctx.Context(), catPropID, propID)
+	if err := errSrv(ctx, err, "it was impossible to link the properties"); err != nil {
+		return err
 	}
 
 	return ctx.JSON(nethttp.StatusOK, rel)
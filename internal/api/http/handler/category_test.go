@@ -30,6 +30,7 @@ import (
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/runtime"
 	"github.com/carisa/internal/api/service"
+	httpc "github.com/carisa/pkg/http"
 
 	"github.com/rs/xid"
 
@@ -208,7 +209,7 @@ func TestCategoryHandler_Get(t *testing.T) {
 	cat.Desc = "cdesc"
 	cat.ParentID = spc.ID
 	cat.Root = true
-	created, _, err := srv.Create(&cat)
+	created, err := srv.Create(context.Background(), &cat)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Category created")
@@ -376,7 +377,7 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 	}
 	catPropRoot := category.NewProp()
 	catPropRoot.CatID = catRoot.ID
-	_, _, err = srv.CreateProp(&catPropRoot)
+	_, err = srv.CreateProp(context.Background(), &catPropRoot)
 	if err != nil {
 		assert.NoError(t, err, "Creating root category property")
 		return
@@ -389,7 +390,7 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 	catChildProp2 := category.NewProp()
 	catChildProp2.CatID = catChild.ID
 	catChildProp2.Type = entity.Boolean
-	_, _, err = srv.CreateProp(&catChildProp2)
+	_, err = srv.CreateProp(context.Background(), &catChildProp2)
 	if err != nil {
 		assert.NoError(t, err, "Creating a second property in the child category")
 		return
@@ -409,12 +410,12 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 	enteChildProp.Type = entity.Integer
 	enteChildProp.EnteID = enteChild.ID
 	enteChildProp.Name = "nameep"
-	_, _, err = srve.CreateProp(&enteChildProp)
+	_, err = srve.CreateProp(context.Background(), &enteChildProp)
 	if err != nil {
 		assert.NoError(t, err, "Creating child ente property")
 		return
 	}
-	_, _, _, err = srve.LinkToCat(enteChild.ID, catRoot.ID)
+	_, err = srve.LinkToCat(context.Background(), enteChild.ID, catRoot.ID)
 	if err != nil {
 		assert.NoError(t, err, "Creating linking between category root and ente")
 		return
@@ -428,24 +429,28 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 		status  int
 		resBody string
 		typep   entity.TypeProp
+		code    string
 	}{
 		{
 			name:   "Category property not found.",
 			source: xid.NilID(),
 			target: xid.NilID(),
 			status: nethttp.StatusNotFound,
+			code:   service.CodeParentNotFound,
 		},
 		{
 			name:   "Target property not found.",
 			source: catPropRoot.ID,
 			target: xid.NilID(),
 			status: nethttp.StatusNotFound,
+			code:   service.CodeNotFound,
 		},
 		{
 			name:   "The category or ente of the property is not child of the category of the property.",
 			source: catPropRoot.ID,
 			target: catccProp.ID,
 			status: nethttp.StatusBadRequest,
+			code:   service.CodeNotChild,
 		},
 		{
 			name:    "The category property is linked successfully with other category property.",
@@ -461,6 +466,7 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 			source: catPropRoot.ID,
 			target: catChildProp2.ID,
 			status: nethttp.StatusConflict,
+			code:   service.CodeTypeMismatch,
 		},
 		{
 			name:    "The category property is linked successfully with a ente property.",
@@ -479,6 +485,7 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 		err := handlers.CategoryHandler.LinkToProp(ctx)
 
 		if err != nil && tt.status == err.(*echo.HTTPError).Code {
+			assert.Equal(t, tt.code, err.(*echo.HTTPError).Message.(httpc.ErrorBody).Code, tt.name)
 			continue
 		}
 		if err != nil {
@@ -488,7 +495,7 @@ func TestCategoryHandler_LinkToProp(t *testing.T) {
 
 		assert.Contains(t, rec.Body.String(), tt.resBody, tt.name)
 		var catp category.Prop
-		_, err = srv.GetProp(catPropRoot.ID, &catp)
+		_, err = srv.GetProp(context.Background(), catPropRoot.ID, &catp)
 		if assert.NoError(t, err) {
 			assert.Equal(t, tt.typep, catp.Type, tt.name)
 		}
@@ -509,7 +516,7 @@ func createCat(t *testing.T,
 	//
 	catChild := category.New()
 	catChild.ParentID = catParent.ID
-	_, _, err := service.Create(&catChild)
+	_, err := service.Create(context.Background(), &catChild)
 	if err != nil {
 		assert.NoError(t, err, "Creating child category")
 		return false, category.Category{}, category.Prop{}
@@ -518,7 +525,7 @@ func createCat(t *testing.T,
 	catChildProp.CatID = catChild.ID
 	catChildProp.Name = "namecp"
 	catChildProp.Type = typep
-	_, _, err = service.CreateProp(&catChildProp)
+	_, err = service.CreateProp(context.Background(), &catChildProp)
 	if err != nil {
 		assert.NoError(t, err, "Creating child category property")
 		return false, category.Category{}, category.Prop{}
@@ -698,7 +705,7 @@ func TestCategoryHandler_GetProp(t *testing.T) {
 	prop.Desc = "descp"
 	prop.CatID = cat.ID
 	prop.Type = entity.Integer
-	created, _, err := srv.CreateProp(&prop)
+	created, err := srv.CreateProp(context.Background(), &prop)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Category property created")
@@ -21,16 +21,40 @@ import (
 
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/http/validator"
+	"github.com/carisa/internal/api/service"
 	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
 
 	httpc "github.com/carisa/pkg/http"
 )
 
-// errCRUDSrv checks the service errors
+// srvStatus maps the kind of the service errors to the http status
+var srvStatus = map[service.Kind]int{
+	service.Internal: nethttp.StatusInternalServerError,
+	service.NotFound: nethttp.StatusNotFound,
+	service.Conflict: nethttp.StatusConflict,
+	service.Invalid:  nethttp.StatusBadRequest,
+}
+
+// errSrv checks the service errors. The typed errors are sent with their code and
+// the entities created beyond the quotas are forbidden. See service.Error
+func errSrv(c httpc.Context, err error, msg string) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *service.Error:
+		return c.HTTPErrorBody(srvStatus[e.Kind], httpc.ErrorBody{Code: e.Code, Message: e.Msg, Field: e.Field})
+	case *storage.LimitError:
+		return c.HTTPErrorBody(nethttp.StatusForbidden, httpc.ErrorBody{Code: httpc.CodeQuotaExceeded, Message: e.Error()})
+	}
+	return c.HTTPError(nethttp.StatusInternalServerError, msg)
+}
+
+// errCRUDSrv checks the service errors and if the entity is not found. See errSrv
 func errCRUDSrv(c httpc.Context, err error, msg string, msgNotFound string, found bool) error {
-	if err != nil {
-		return c.HTTPError(nethttp.StatusInternalServerError, msg)
+	if err := errSrv(c, err, msg); err != nil {
+		return err
 	}
 	if !found {
 		return c.HTTPError(nethttp.StatusNotFound, msgNotFound)
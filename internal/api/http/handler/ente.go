@@ -52,8 +52,8 @@ func (p *Ente) Create(c httpc.Context) error {
 		return err
 	}
 
-	created, found, err := p.srv.Create(&ente)
-	if err = errCRUDSrv(c, err, "it was impossible to create the ente", "space not found", found); err != nil {
+	created, err := p.srv.Create(c.Context(), &ente)
+	if err = errSrv(c, err, "it was impossible to create the ente"); err != nil {
 		return err
 	}
 
@@ -73,9 +73,8 @@ func (p *Ente) Put(c httpc.Context) error {
 	}
 
 	ente.ID = id
-	updated, found, err := p.srv.Put(&ente)
-	if err = errCRUDSrv(
-		c, err, "it was impossible to create or update the ente", "space not found", found); err != nil {
+	updated, err := p.srv.Put(c.Context(), &ente)
+	if err = errSrv(c, err, "it was impossible to create or update the ente"); err != nil {
 		return err
 	}
 
@@ -91,7 +90,7 @@ func (p *Ente) Get(c httpc.Context) error {
 		return err
 	}
 
-	found, err := p.srv.Get(id, &ente)
+	found, err := p.srv.Get(c.Context(), id, &ente)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the ente")
 	}
@@ -110,16 +109,9 @@ func (p *Ente) LinkToCat(c httpc.Context) error {
 		return err
 	}
 
-	efound, cfound, rel, err := p.srv.LinkToCat(enteID, catID)
-	if err != nil {
-		return c.HTTPError(nethttp.StatusInternalServerError, err)
-	}
-
-	if !efound {
-		return c.HTTPError(nethttp.StatusNotFound, "Ente not found")
-	}
-	if !cfound {
-		return c.HTTPError(nethttp.StatusNotFound, "Category not found")
+	rel, err := p.srv.LinkToCat(c.Context(), enteID, catID)
+	if err := errSrv(c, err, "it was impossible to link the ente to the category"); err != nil {
+		return err
 	}
 
 	return c.JSON(nethttp.StatusOK, rel)
@@ -134,7 +126,7 @@ func (p *Ente) ListProps(c httpc.Context) error {
 		return err
 	}
 
-	props, err := p.srv.ListProps(id, name, ranges, top)
+	props, err := p.srv.ListProps(c.Context(), id, name, ranges, top)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the properties of the ente")
 	}
@@ -149,8 +141,8 @@ func (p *Ente) CreateProp(c httpc.Context) error {
 		return err
 	}
 
-	created, found, err := p.srv.CreateProp(&prop)
-	if err = errCRUDSrv(c, err, "it was impossible to create the property of the ente", "ente not found", found); err != nil {
+	created, err := p.srv.CreateProp(c.Context(), &prop)
+	if err = errSrv(c, err, "it was impossible to create the property of the ente"); err != nil {
 		return err
 	}
 
@@ -170,9 +162,8 @@ func (p *Ente) PutProp(c httpc.Context) error {
 	}
 
 	prop.ID = id
-	updated, found, err := p.srv.PutProp(&prop)
-	if err = errCRUDSrv(
-		c, err, "it was impossible to create or update the property of the ente", "ente not found", found); err != nil {
+	updated, err := p.srv.PutProp(c.Context(), &prop)
+	if err = errSrv(c, err, "it was impossible to create or update the property of the ente"); err != nil {
 		return err
 	}
 
@@ -188,7 +179,7 @@ func (p *Ente) GetProp(c httpc.Context) error {
 		return err
 	}
 
-	found, err := p.srv.GetProp(id, &prop)
+	found, err := p.srv.GetProp(c.Context(), id, &prop)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the property of the ente")
 	}
@@ -17,6 +17,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	nethttp "net/http"
@@ -208,7 +209,7 @@ func TestEnteHandler_Get(t *testing.T) {
 	ente.Name = "ename"
 	ente.Desc = "edesc"
 	ente.SpaceID = spc.ID
-	created, _, err := srv.Create(&ente)
+	created, err := srv.Create(context.Background(), &ente)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Ente created")
@@ -577,7 +578,7 @@ func TestEnteHandler_GetProp(t *testing.T) {
 	prop.Name = "namep"
 	prop.Desc = "descp"
 	prop.EnteID = e.ID
-	created, _, err := srv.CreateProp(&prop)
+	created, err := srv.CreateProp(context.Background(), &prop)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Ente property created")
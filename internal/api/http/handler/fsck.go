@@ -0,0 +1,57 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/fsck"
+	httpc "github.com/carisa/pkg/http"
+)
+
+// Fsck hands the http request of the referential integrity checker
+type Fsck struct {
+	checker fsck.Checker
+	cnt     *runtime.Container
+}
+
+// NewFsckHandle creates handler
+func NewFsckHandle(checker fsck.Checker, cnt *runtime.Container) Fsck {
+	return Fsck{
+		checker: checker,
+		cnt:     cnt,
+	}
+}
+
+// Check reports the integrity issues of the links and the doubly linked relations
+func (f *Fsck) Check(c httpc.Context) error {
+	return f.check(c, false)
+}
+
+// Repair removes the orphan and dangling links and doubly linked relations
+func (f *Fsck) Repair(c httpc.Context) error {
+	return f.check(c, true)
+}
+
+func (f *Fsck) check(c httpc.Context, repair bool) error {
+	report, err := f.checker.Check(repair)
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to check the integrity")
+	}
+	return c.JSON(nethttp.StatusOK, report)
+}
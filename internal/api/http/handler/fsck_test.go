@@ -0,0 +1,105 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/space"
+	"github.com/carisa/internal/fsck"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFsckHandler_Check(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, mng := newFsckHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	// The link of the space is orphan
+	link := space.New()
+	link.Name = "name"
+	_, crud := mock.NewCrudOperFaked(mng)
+	if _, err := crud.Put("loc", cnt.StoreTimeout(context.Background()), link.Link()); !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		repair bool
+		body   string
+	}{
+		{
+			name:   "Checking.",
+			method: nethttp.MethodGet,
+			body:   `"kind":"orphan-link"`,
+		},
+		{
+			name:   "Repairing.",
+			method: nethttp.MethodPost,
+			repair: true,
+			body:   `"repaired":1`,
+		},
+		{
+			name:   "Checking after repairing.",
+			method: nethttp.MethodGet,
+			body:   `"issues":null`,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(tt.method, "/api/admin/fsck", "", nil, nil)
+		var err error
+		if tt.repair {
+			err = handlers.FsckHandler.Repair(ctx)
+		} else {
+			err = handlers.FsckHandler.Check(ctx)
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, nethttp.StatusOK, rec.Code, tt.name)
+			assert.Contains(t, rec.Body.String(), tt.body, tt.name)
+		}
+	}
+}
+
+func TestFsckHandler_CheckWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	crud := storage.NewErrMockCRUDOper()
+	crud.Store().(*storage.ErrMockCRUD).Activate("StartKey")
+	handlers := Handlers{FsckHandler: NewFsckHandle(fsck.NewChecker(cnt, crud.Store(), 10), cnt)}
+
+	_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/admin/fsck", "", nil, nil)
+	err := handlers.FsckHandler.Check(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code)
+	}
+}
+
+func newFsckHandlerFaked(t *testing.T) (*runtime.Container, Handlers, storage.Integration) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	hands := Handlers{FsckHandler: NewFsckHandle(fsck.NewChecker(cnt, crud.Store(), 10), cnt)}
+	return cnt, hands, mng
+}
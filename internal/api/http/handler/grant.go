@@ -0,0 +1,111 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/http/convert"
+	"github.com/carisa/internal/api/rbac"
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+)
+
+const (
+	locGrant       = "http.grant"
+	principalParam = "principal"
+)
+
+// Grant hands the http request of the roles granted over the instance.Instance or space.Space
+type Grant struct {
+	srv rbac.Grants
+	cnt *runtime.Container
+}
+
+// NewGrantHandle creates handler
+func NewGrantHandle(srv rbac.Grants, cnt *runtime.Container) Grant {
+	return Grant{
+		srv: srv,
+		cnt: cnt,
+	}
+}
+
+// Put grants the role of the body to the principal param over the entity with the scheme and ID param.
+// The principal of the request is recorded as grantor
+func (g *Grant) Put(c httpc.Context, scheme string) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+	principal := c.Param(principalParam)
+	if len(principal) == 0 {
+		return c.HTTPError(nethttp.StatusBadRequest, "the param path:principal not found")
+	}
+	req := struct {
+		Role rbac.Role `json:"role"`
+	}{}
+	if err := c.Bind(&req); err != nil {
+		return c.HTTPErrorLog(nethttp.StatusBadRequest, "cannot recover the role", err, g.cnt.Log, locGrant)
+	}
+	if !req.Role.Valid() {
+		return c.HTTPError(nethttp.StatusBadRequest, "the property: 'role' must be viewer, modeler or admin")
+	}
+
+	grant := rbac.Grant{
+		Scope:     entity.Key(scheme, id),
+		Principal: principal,
+		Role:      req.Role,
+		GrantedBy: c.Principal().ID,
+	}
+	found, err := g.srv.Put(c.Context(), &grant)
+	if err := errCRUDSrv(c, err, "it was impossible to grant the role", "entity not found", found); err != nil {
+		return err
+	}
+	return c.JSON(nethttp.StatusOK, grant)
+}
+
+// List lists the roles granted over the entity with the scheme and ID param
+func (g *Grant) List(c httpc.Context, scheme string) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+	grants, err := g.srv.List(c.Context(), entity.Key(scheme, id))
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the roles granted")
+	}
+	return c.JSON(nethttp.StatusOK, grants)
+}
+
+// Revoke removes the role granted to the principal param over the entity with the scheme and ID param
+func (g *Grant) Revoke(c httpc.Context, scheme string) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+	principal := c.Param(principalParam)
+	if len(principal) == 0 {
+		return c.HTTPError(nethttp.StatusBadRequest, "the param path:principal not found")
+	}
+
+	found, err := g.srv.Revoke(c.Context(), entity.Key(scheme, id), principal)
+	if err := errCRUDSrv(c, err, "it was impossible to revoke the role", "role not granted", found); err != nil {
+		return err
+	}
+	return c.JSON(nethttp.StatusOK, rbac.Grant{Scope: entity.Key(scheme, id), Principal: principal})
+}
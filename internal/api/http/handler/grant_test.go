@@ -0,0 +1,145 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+	"testing"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance/samples"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/rbac"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantHandler(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, mng := newGrantHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	inst, err := samples.CreateInstance(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	params := map[string]string{"id": inst.ID.String(), "principal": "user"}
+
+	tests := []struct {
+		name   string
+		method string
+		params map[string]string
+		body   string
+		status int
+	}{
+		{
+			name:   "Granting role.",
+			method: nethttp.MethodPut,
+			params: params,
+			body:   `{"role":"modeler"}`,
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Granting role. Role not valid.",
+			method: nethttp.MethodPut,
+			params: params,
+			body:   `{"role":"owner"}`,
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Granting role. Without principal.",
+			method: nethttp.MethodPut,
+			params: map[string]string{"id": inst.ID.String()},
+			body:   `{"role":"viewer"}`,
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Granting role. Instance not found.",
+			method: nethttp.MethodPut,
+			params: map[string]string{"id": xid.New().String(), "principal": "user"},
+			body:   `{"role":"viewer"}`,
+			status: nethttp.StatusNotFound,
+		},
+		{
+			name:   "Listing roles.",
+			method: nethttp.MethodGet,
+			params: params,
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Revoking role.",
+			method: nethttp.MethodDelete,
+			params: params,
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Revoking role. Not found.",
+			method: nethttp.MethodDelete,
+			params: params,
+			status: nethttp.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(tt.method, "/api/instances/:id/grants/:principal", tt.body, tt.params, nil)
+		switch tt.method {
+		case nethttp.MethodPut:
+			err = handlers.GrantHandler.Put(ctx, entity.SchInstance)
+		case nethttp.MethodGet:
+			err = handlers.GrantHandler.List(ctx, entity.SchInstance)
+		default:
+			err = handlers.GrantHandler.Revoke(ctx, entity.SchInstance)
+		}
+		if tt.status != nethttp.StatusOK {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, tt.name)
+			if tt.method == nethttp.MethodGet {
+				assert.Contains(t, rec.Body.String(), `"role":"modeler"`, tt.name)
+			}
+		}
+	}
+}
+
+func TestGrantHandler_ListWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	crud := storage.NewErrMockCRUDOper()
+	crud.Store().(*storage.ErrMockCRUD).Activate("StartKey")
+	handlers := Handlers{GrantHandler: NewGrantHandle(rbac.NewGrants(cnt, crud), cnt)}
+
+	_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/spaces/:id/grants", "", map[string]string{"id": xid.New().String()}, nil)
+	err := handlers.GrantHandler.List(ctx, entity.SchSpace)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code)
+	}
+}
+
+func newGrantHandlerFaked(t *testing.T) (*runtime.Container, Handlers, storage.Integration) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	hands := Handlers{GrantHandler: NewGrantHandle(rbac.NewGrants(cnt, crud), cnt)}
+	return cnt, hands, mng
+}
@@ -19,6 +19,7 @@ package handler
 import (
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/plugin"
+	"github.com/carisa/internal/api/rbac"
 	echoc "github.com/carisa/pkg/http/echo"
 	"github.com/labstack/echo/v4"
 )
@@ -34,6 +35,16 @@ type Handlers struct {
 	CategoryHandler Category
 	PluginHandler   Plugin
 	ObjectHandler   Object
+	TrashHandler    Trash
+	FsckHandler     Fsck
+	APIKeyHandler   APIKey
+	GrantHandler    Grant
+	// SplitterHandler is only configured without tenant because the splitters are shared by all tenants
+	SplitterHandler Splitter
+	// TenantHandler is only configured without tenant because it registers the tenants
+	TenantHandler Tenant
+	// Authorizer checks the roles of the requests. See Tenants.Handle
+	Authorizer rbac.Authorizer
 }
 
 // Instance
@@ -53,6 +64,34 @@ func (h *Handlers) InstListSpaces(ctx echo.Context) error {
 	return h.InstHandler.ListSpaces(echoc.NewContext(ctx))
 }
 
+func (h *Handlers) InstListTrash(ctx echo.Context) error {
+	return h.TrashHandler.List(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) InstRestoreTrash(ctx echo.Context) error {
+	return h.TrashHandler.Restore(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) InstGetQuotas(ctx echo.Context) error {
+	return h.InstHandler.GetQuotas(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) InstPutQuotas(ctx echo.Context) error {
+	return h.InstHandler.PutQuotas(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) InstPutGrant(ctx echo.Context) error {
+	return h.GrantHandler.Put(echoc.NewContext(ctx), entity.SchInstance)
+}
+
+func (h *Handlers) InstListGrants(ctx echo.Context) error {
+	return h.GrantHandler.List(echoc.NewContext(ctx), entity.SchInstance)
+}
+
+func (h *Handlers) InstRevokeGrant(ctx echo.Context) error {
+	return h.GrantHandler.Revoke(echoc.NewContext(ctx), entity.SchInstance)
+}
+
 // Space
 func (h *Handlers) SpaceCreate(ctx echo.Context) error {
 	return h.SpaceHandler.Create(echoc.NewContext(ctx))
@@ -66,6 +105,10 @@ func (h *Handlers) SpaceGet(ctx echo.Context) error {
 	return h.SpaceHandler.Get(echoc.NewContext(ctx))
 }
 
+func (h *Handlers) SpaceDelete(ctx echo.Context) error {
+	return h.TrashHandler.Delete(echoc.NewContext(ctx), entity.SchSpace)
+}
+
 func (h *Handlers) SpcListEntes(ctx echo.Context) error {
 	return h.SpaceHandler.ListEntes(echoc.NewContext(ctx))
 }
@@ -74,6 +117,18 @@ func (h *Handlers) SpcListCategories(ctx echo.Context) error {
 	return h.SpaceHandler.ListCategories(echoc.NewContext(ctx))
 }
 
+func (h *Handlers) SpacePutGrant(ctx echo.Context) error {
+	return h.GrantHandler.Put(echoc.NewContext(ctx), entity.SchSpace)
+}
+
+func (h *Handlers) SpaceListGrants(ctx echo.Context) error {
+	return h.GrantHandler.List(echoc.NewContext(ctx), entity.SchSpace)
+}
+
+func (h *Handlers) SpaceRevokeGrant(ctx echo.Context) error {
+	return h.GrantHandler.Revoke(echoc.NewContext(ctx), entity.SchSpace)
+}
+
 // Ente
 func (h *Handlers) EnteCreate(ctx echo.Context) error {
 	return h.EnteHandler.Create(echoc.NewContext(ctx))
@@ -87,6 +142,10 @@ func (h *Handlers) EnteGet(ctx echo.Context) error {
 	return h.EnteHandler.Get(echoc.NewContext(ctx))
 }
 
+func (h *Handlers) EnteDelete(ctx echo.Context) error {
+	return h.TrashHandler.Delete(echoc.NewContext(ctx), entity.SchEnte)
+}
+
 func (h *Handlers) EnteListProps(ctx echo.Context) error {
 	return h.EnteHandler.ListProps(echoc.NewContext(ctx))
 }
@@ -132,6 +191,10 @@ func (h *Handlers) CatGet(ctx echo.Context) error {
 	return h.CategoryHandler.Get(echoc.NewContext(ctx))
 }
 
+func (h *Handlers) CatDelete(ctx echo.Context) error {
+	return h.TrashHandler.Delete(echoc.NewContext(ctx), entity.SchCategory)
+}
+
 func (h *Handlers) CatListCategories(ctx echo.Context) error {
 	return h.CategoryHandler.ListCategories(echoc.NewContext(ctx))
 }
@@ -189,3 +252,40 @@ func (h *Handlers) PluginQryListPlugins(ctx echo.Context) error {
 func (h *Handlers) InstQryGet(ctx echo.Context) error {
 	return h.ObjectHandler.Get(echoc.NewContext(ctx))
 }
+
+// Admin
+func (h *Handlers) AdminFsck(ctx echo.Context) error {
+	return h.FsckHandler.Check(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminFsckRepair(ctx echo.Context) error {
+	return h.FsckHandler.Repair(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminCreateAPIKey(ctx echo.Context) error {
+	return h.APIKeyHandler.Create(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminListAPIKeys(ctx echo.Context) error {
+	return h.APIKeyHandler.List(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminRevokeAPIKey(ctx echo.Context) error {
+	return h.APIKeyHandler.Revoke(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminCreateTenant(ctx echo.Context) error {
+	return h.TenantHandler.Create(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminListSplitters(ctx echo.Context) error {
+	return h.SplitterHandler.List(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminAssignWork(ctx echo.Context) error {
+	return h.SplitterHandler.Assign(echoc.NewContext(ctx))
+}
+
+func (h *Handlers) AdminUnassignWork(ctx echo.Context) error {
+	return h.SplitterHandler.Unassign(echoc.NewContext(ctx))
+}
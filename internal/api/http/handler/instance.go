@@ -30,6 +30,12 @@ import (
 
 const locInstance = "http.instance"
 
+// quotas are the limits of the instance.Instance and the number of entities counted
+type quotas struct {
+	Limits instance.Quotas `json:"limits"`
+	Usage  instance.Quotas `json:"usage"`
+}
+
 // Instance hands the http request of the instance.Instance
 type Instance struct {
 	srv instance.Service
@@ -50,8 +56,11 @@ func (i *Instance) Create(c httpc.Context) error {
 	if err := bind(c, locInstance, i.cnt.Log, &inst); err != nil {
 		return err
 	}
+	if !inst.Quotas.Valid() {
+		return c.HTTPError(nethttp.StatusBadRequest, "the quotas can not be negative")
+	}
 
-	created, err := i.srv.Create(&inst)
+	created, err := i.srv.Create(c.Context(), &inst)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to create the instance")
 	}
@@ -70,9 +79,12 @@ func (i *Instance) Put(c httpc.Context) error {
 	if err := bind(c, locInstance, i.cnt.Log, &inst); err != nil {
 		return err
 	}
+	if !inst.Quotas.Valid() {
+		return c.HTTPError(nethttp.StatusBadRequest, "the quotas can not be negative")
+	}
 
 	inst.ID = id
-	updated, err := i.srv.Put(&inst)
+	updated, err := i.srv.Put(c.Context(), &inst)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to create or update the instance")
 	}
@@ -89,7 +101,7 @@ func (i *Instance) Get(c httpc.Context) error {
 		return err
 	}
 
-	found, err := i.srv.Get(id, &inst)
+	found, err := i.srv.Get(c.Context(), id, &inst)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the instance")
 	}
@@ -106,10 +118,53 @@ func (i *Instance) ListSpaces(c httpc.Context) error {
 		return err
 	}
 
-	spaces, err := i.srv.ListSpaces(id, name, ranges, top)
+	spaces, err := i.srv.ListSpaces(c.Context(), id, name, ranges, top)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the spaces")
 	}
 
 	return c.JSON(nethttp.StatusOK, spaces)
 }
+
+// GetQuotas gets the quotas of the instance.Instance and the number of entities counted
+func (i *Instance) GetQuotas(c httpc.Context) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+
+	var inst instance.Instance
+	found, err := i.srv.Get(c.Context(), id, &inst)
+	if err := errCRUDSrv(c, err, "it was impossible to get the instance", "instance not found", found); err != nil {
+		return err
+	}
+	usage, err := i.srv.Usage(c.Context(), id)
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the usage of the quotas")
+	}
+
+	return c.JSON(nethttp.StatusOK, quotas{Limits: inst.Quotas, Usage: usage})
+}
+
+// PutQuotas changes the quotas of the instance.Instance
+func (i *Instance) PutQuotas(c httpc.Context) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+
+	var q instance.Quotas
+	if err := c.Bind(&q); err != nil {
+		return c.HTTPErrorLog(nethttp.StatusBadRequest, "cannot recover the quotas", err, i.cnt.Log, locInstance)
+	}
+	if !q.Valid() {
+		return c.HTTPError(nethttp.StatusBadRequest, "the quotas can not be negative")
+	}
+
+	found, err := i.srv.PutQuotas(c.Context(), id, q)
+	if err := errCRUDSrv(c, err, "it was impossible to change the quotas", "instance not found", found); err != nil {
+		return err
+	}
+
+	return c.JSON(nethttp.StatusOK, q)
+}
@@ -17,13 +17,16 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	nethttp "net/http"
 	"testing"
 
 	"github.com/carisa/internal/api/service"
+	"github.com/carisa/internal/api/space"
 	spacesmpl "github.com/carisa/internal/api/space/samples"
+	httpc "github.com/carisa/pkg/http"
 
 	"github.com/rs/xid"
 
@@ -156,7 +159,7 @@ func TestInstanceHandler_Get(t *testing.T) {
 	inst.Name = "name"
 	inst.Desc = "desc"
 
-	created, err := srv.Create(&inst)
+	created, err := srv.Create(context.Background(), &inst)
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Instance created")
 
@@ -261,6 +264,141 @@ func TestInstanceHandler_GetListSpacesError(t *testing.T) {
 	}
 }
 
+func TestInstanceHandler_Quotas(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, mng := newQuotaHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	inst := instance.New()
+	inst.Name = "name"
+	if _, err := handlers.InstHandler.srv.Create(context.Background(), &inst); !assert.NoError(t, err) {
+		return
+	}
+	params := map[string]string{"id": inst.ID.String()}
+	space := strings.Concat(`{"name":"name","description":"desc","instanceId":"`, inst.ID.String(), `"}`)
+
+	tests := []struct {
+		name    string
+		method  string
+		handler func(c httpc.Context) error
+		params  map[string]string
+		body    string
+		status  int
+		res     string
+	}{
+		{
+			name:    "Changing quotas.",
+			method:  nethttp.MethodPut,
+			handler: handlers.InstHandler.PutQuotas,
+			params:  params,
+			body:    `{"spaces":1}`,
+			status:  nethttp.StatusOK,
+		},
+		{
+			name:    "Changing quotas. Negative.",
+			method:  nethttp.MethodPut,
+			handler: handlers.InstHandler.PutQuotas,
+			params:  params,
+			body:    `{"spaces":-1}`,
+			status:  nethttp.StatusBadRequest,
+		},
+		{
+			name:    "Changing quotas. Body wrong.",
+			method:  nethttp.MethodPut,
+			handler: handlers.InstHandler.PutQuotas,
+			params:  params,
+			body:    `{df`,
+			status:  nethttp.StatusBadRequest,
+		},
+		{
+			name:    "Changing quotas. Instance not found.",
+			method:  nethttp.MethodPut,
+			handler: handlers.InstHandler.PutQuotas,
+			params:  map[string]string{"id": xid.New().String()},
+			body:    `{"spaces":1}`,
+			status:  nethttp.StatusNotFound,
+		},
+		{
+			name:    "Creating space.",
+			method:  nethttp.MethodPost,
+			handler: handlers.SpaceHandler.Create,
+			body:    space,
+			status:  nethttp.StatusCreated,
+		},
+		{
+			name:    "Creating space. Limit reached.",
+			method:  nethttp.MethodPost,
+			handler: handlers.SpaceHandler.Create,
+			body:    space,
+			status:  nethttp.StatusForbidden,
+		},
+		{
+			name:    "Getting quotas.",
+			method:  nethttp.MethodGet,
+			handler: handlers.InstHandler.GetQuotas,
+			params:  params,
+			status:  nethttp.StatusOK,
+			res:     `"limits":{"spaces":1,"entes":0,"categories":0,"properties":0,"queries":0},"usage":{"spaces":1,`,
+		},
+		{
+			name:    "Getting quotas. Instance not found.",
+			method:  nethttp.MethodGet,
+			handler: handlers.InstHandler.GetQuotas,
+			params:  map[string]string{"id": xid.New().String()},
+			status:  nethttp.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(tt.method, "/api/instances/:id/quotas", tt.body, tt.params, nil)
+		err := tt.handler(ctx)
+		if tt.status >= nethttp.StatusBadRequest {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, tt.name)
+			assert.Contains(t, rec.Body.String(), tt.res, tt.name)
+		}
+	}
+}
+
+func TestInstanceHandler_QuotasWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, crud := newInstHandlerMocked()
+	defer h.Close(cnt.Log)
+	params := map[string]string{"id": xid.New().String()}
+
+	crud.Store().(*storage.ErrMockCRUD).Activate("Get")
+	_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/instances/:id/quotas", "", params, nil)
+	err := handlers.InstHandler.GetQuotas(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code, "Getting the instance")
+	}
+
+	crud.Activate("Update")
+	_, ctx = h.NewHTTP(nethttp.MethodPut, "/api/instances/:id/quotas", `{"spaces":1}`, params, nil)
+	err = handlers.InstHandler.PutQuotas(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code, "Changing the quotas")
+	}
+}
+
+func newQuotaHandlerFaked(t *testing.T) (*runtime.Container, Handlers, storage.Integration) {
+	mng := mock.NewStorageFake(t)
+	cnt := mock.NewContainerFake()
+	ext := service.NewExt(cnt, mng.Store())
+	crud := storage.NewCountedCrudOperation(mng.Store(), cnt.Log, storage.NewTxn, instance.NewQuota(cnt, ext, mng.Store()).Counters)
+	hands := Handlers{
+		InstHandler:  NewInstanceHandle(instance.NewService(cnt, ext, crud), cnt),
+		SpaceHandler: NewSpaceHandle(space.NewService(cnt, ext, crud), cnt),
+	}
+	return cnt, hands, mng
+}
+
 func newInstHandlerFaked(t *testing.T) (*runtime.Container, Handlers, instance.Service, storage.Integration) {
 	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
 	ext := service.NewExt(cnt, crud.Store())
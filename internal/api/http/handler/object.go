@@ -63,15 +63,9 @@ func (o *Object) Create(c httpc.Context, cntParam string, schContainer string, c
 		return err
 	}
 
-	created, foundp, foundc, err := o.srv.Create(&inst)
-	if err != nil {
-		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to create the instance")
-	}
-	if !foundp {
-		return c.HTTPError(nethttp.StatusNotFound, "the plugin prototype not found")
-	}
-	if !foundc {
-		return c.HTTPError(nethttp.StatusNotFound, "container not found")
+	created, err := o.srv.Create(c.Context(), &inst)
+	if err := errSrv(c, err, "it was impossible to create the instance"); err != nil {
+		return err
 	}
 	return c.JSON(http.CreateStatus(created), inst)
 }
@@ -93,12 +87,8 @@ func (o *Object) Put(c httpc.Context, cntParam string, schContainer string, cate
 	}
 	inst.ID = id
 
-	updated, foundp, foundc, err := o.srv.Put(&inst)
-	if !foundp {
-		return c.HTTPError(nethttp.StatusNotFound, "the plugin prototype not found")
-	}
-	if err = errCRUDSrv(
-		c, err, "it was impossible to create or update the object instance", "container not found", foundc); err != nil {
+	updated, err := o.srv.Put(c.Context(), &inst)
+	if err := errSrv(c, err, "it was impossible to create or update the object instance"); err != nil {
 		return err
 	}
 
@@ -130,7 +120,7 @@ func (o *Object) Get(c httpc.Context) error {
 		return err
 	}
 
-	found, err := o.srv.Get(id, &inst)
+	found, err := o.srv.Get(c.Context(), id, &inst)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the object instance")
 	}
@@ -147,7 +137,7 @@ func (o *Object) ListInstances(ctx httpc.Context, schContainer string, category
 		return err
 	}
 
-	props, err := o.srv.ListInstances(schContainer, id, category, name, ranges, top)
+	props, err := o.srv.ListInstances(ctx.Context(), schContainer, id, category, name, ranges, top)
 	if err != nil {
 		return ctx.HTTPError(
 			nethttp.StatusInternalServerError,
@@ -17,6 +17,7 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 	nethttp "net/http"
 	"testing"
@@ -84,7 +85,7 @@ func TestObjectHandler_Create(t *testing.T) {
 				container.ID.String(),
 				xid.NilID()),
 			status: nethttp.StatusNotFound,
-			errmsg: "code=404, message=[the plugin prototype not found]",
+			errmsg: "code=404, message=the plugin prototype not found",
 		},
 		{
 			name:   "Creating object instance. Container not found.",
@@ -94,7 +95,7 @@ func TestObjectHandler_Create(t *testing.T) {
 				xid.NilID(),
 				protoID.String()),
 			status: nethttp.StatusNotFound,
-			errmsg: "code=404, message=[container not found]",
+			errmsg: "code=404, message=container not found",
 		},
 	}
 
@@ -212,7 +213,7 @@ func TestObjectHandler_Put(t *testing.T) {
 				container.ID.String(),
 				xid.NilID()),
 			status: nethttp.StatusNotFound,
-			errmsg: "code=404, message=[the plugin prototype not found]",
+			errmsg: "code=404, message=the plugin prototype not found",
 		},
 		{
 			name:   "Creating object instance. Container not found.",
@@ -222,7 +223,7 @@ func TestObjectHandler_Put(t *testing.T) {
 				xid.NilID(),
 				protoID.String()),
 			status: nethttp.StatusNotFound,
-			errmsg: "code=404, message=[container not found]",
+			errmsg: "code=404, message=container not found",
 		},
 		{
 			name:   "Creating object instance.",
@@ -383,7 +384,7 @@ func TestObjectHandler_Get(t *testing.T) {
 		inst.SchContainer = entity.SchCategory
 		inst.ContainerID = container.ID
 		inst.ProtoID = protoID
-		_, _, _, err = srv.Put(&inst)
+		_, err = srv.Put(context.Background(), &inst)
 		if err != nil {
 			assert.Error(t, err, "Creating instance")
 			return
@@ -55,7 +55,7 @@ func (p *Plugin) Create(c httpc.Context, category plugin.Category) error {
 	}
 	proto.Category = category
 
-	created, err := p.srv.Create(&proto)
+	created, err := p.srv.Create(c.Context(), &proto)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to create the plugin prototype")
 	}
@@ -77,7 +77,7 @@ func (p *Plugin) Put(c httpc.Context, category plugin.Category) error {
 	proto.Category = category
 
 	proto.ID = id
-	updated, err := p.srv.Put(&proto)
+	updated, err := p.srv.Put(c.Context(), &proto)
 	if err != nil {
 		return c.HTTPError(
 			nethttp.StatusInternalServerError,
@@ -96,7 +96,7 @@ func (p *Plugin) Get(c httpc.Context) error {
 		return err
 	}
 
-	found, err := p.srv.Get(id, &proto)
+	found, err := p.srv.Get(c.Context(), id, &proto)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the plugin prototype")
 	}
@@ -113,7 +113,7 @@ func (p *Plugin) ListPlugins(c httpc.Context, cat plugin.Category) error {
 		return err
 	}
 
-	props, err := p.srv.ListPlugins(cat, name, ranges, top)
+	props, err := p.srv.ListPlugins(c.Context(), cat, name, ranges, top)
 	if err != nil {
 		return c.HTTPError(
 			nethttp.StatusInternalServerError,
@@ -17,6 +17,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	nethttp "net/http"
@@ -191,7 +192,7 @@ func TestPluginHandler_Get(t *testing.T) {
 	proto := plugin.New()
 	proto.Name = "pname"
 	proto.Desc = "pdesc"
-	created, err := srv.Create(&proto)
+	created, err := srv.Create(context.Background(), &proto)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Plugin created")
@@ -52,9 +52,8 @@ func (s *Space) Create(c httpc.Context) error {
 		return err
 	}
 
-	created, found, err := s.srv.Create(&spc)
-	if err := errCRUDSrv(
-		c, err, "it was impossible to create the space", "instance not found", found); err != nil {
+	created, err := s.srv.Create(c.Context(), &spc)
+	if err := errSrv(c, err, "it was impossible to create the space"); err != nil {
 		return err
 	}
 
@@ -74,9 +73,8 @@ func (s *Space) Put(c httpc.Context) error {
 	}
 
 	spc.ID = id
-	updated, found, err := s.srv.Put(&spc)
-	if err := errCRUDSrv(
-		c, err, "it was impossible to create or update the space", "instance not found", found); err != nil {
+	updated, err := s.srv.Put(c.Context(), &spc)
+	if err := errSrv(c, err, "it was impossible to create or update the space"); err != nil {
 		return err
 	}
 
@@ -92,7 +90,7 @@ func (s *Space) Get(c httpc.Context) error {
 		return err
 	}
 
-	found, err := s.srv.Get(id, &space)
+	found, err := s.srv.Get(c.Context(), id, &space)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to get the space")
 	}
@@ -109,7 +107,7 @@ func (s *Space) ListEntes(c httpc.Context) error {
 		return err
 	}
 
-	entes, err := s.srv.ListEntes(id, name, ranges, top)
+	entes, err := s.srv.ListEntes(c.Context(), id, name, ranges, top)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the entes")
 	}
@@ -126,7 +124,7 @@ func (s *Space) ListCategories(c httpc.Context) error {
 		return err
 	}
 
-	categories, err := s.srv.ListCategories(id, name, ranges, top)
+	categories, err := s.srv.ListCategories(c.Context(), id, name, ranges, top)
 	if err != nil {
 		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the categories")
 	}
@@ -17,6 +17,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	nethttp "net/http"
@@ -27,6 +28,7 @@ import (
 	"github.com/carisa/internal/api/runtime"
 	"github.com/carisa/internal/api/service"
 	"github.com/carisa/internal/api/space"
+	httpc "github.com/carisa/pkg/http"
 
 	entesmpl "github.com/carisa/internal/api/ente/samples"
 
@@ -94,6 +96,27 @@ func TestSpaceHandler_Create(t *testing.T) {
 	}
 }
 
+func TestSpaceHandler_CreateInstanceNotFound(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, _, mng := newSpcHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	body := fmt.Sprintf(`{"name":"name","description":"desc","instanceId":"%s"}`, xid.New())
+	_, ctx := h.NewHTTP(nethttp.MethodPost, "/api/spaces", body, nil, nil)
+	err := handlers.SpaceHandler.Create(ctx)
+
+	if assert.Error(t, err) {
+		he := err.(*echo.HTTPError)
+		assert.Equal(t, nethttp.StatusNotFound, he.Code, "Http status")
+		assert.Equal(
+			t,
+			httpc.ErrorBody{Code: service.CodeParentNotFound, Message: "instance not found"},
+			he.Message,
+			"Error body")
+	}
+}
+
 func TestSpaceHandler_CreateWithError(t *testing.T) {
 	tests := tsamples.TestCreateWithError("CreateWithRel")
 
@@ -205,7 +228,7 @@ func TestSpaceHandler_Get(t *testing.T) {
 	space.Name = "name"
 	space.Desc = "desc"
 	space.InstID = inst.ID
-	created, _, err := srv.Create(&space)
+	created, err := srv.Create(context.Background(), &space)
 
 	if assert.NoError(t, err) {
 		assert.True(t, created, "Space created")
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/cluster"
+	httpc "github.com/carisa/pkg/http"
+)
+
+const workParam = "work"
+
+// Splitter hands the http request of the splitters of the cluster
+type Splitter struct {
+	registry cluster.Registry
+	assigner cluster.Assigner
+	cnt      *runtime.Container
+}
+
+// assignment is the response of the assignment of a work
+type assignment struct {
+	Work     string `json:"work"`
+	Splitter string `json:"splitter,omitempty"`
+}
+
+// NewSplitterHandle creates handler
+func NewSplitterHandle(registry cluster.Registry, assigner cluster.Assigner, cnt *runtime.Container) Splitter {
+	return Splitter{
+		registry: registry,
+		assigner: assigner,
+		cnt:      cnt,
+	}
+}
+
+// List lists the splitters with their last heartbeat, consumption, start time and status
+func (s *Splitter) List(c httpc.Context) error {
+	splitters, err := s.registry.List(c.Context())
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the splitters")
+	}
+	return c.JSON(nethttp.StatusOK, splitters)
+}
+
+// Assign assigns the work to the least-loaded alive splitter. If the work is already assigned
+// returns its splitter. See cluster.SpaceWork and cluster.EnteWork
+func (s *Splitter) Assign(c httpc.Context) error {
+	work := c.Param(workParam)
+	if err := c.NoEmpty(workParam, work); err != nil {
+		return err
+	}
+	if err := c.MaxLen(workParam, work, 100); err != nil {
+		return err
+	}
+
+	id, assigned, err := s.assigner.Assign(c.Context(), work)
+	if err == cluster.ErrNoSplitters {
+		return c.HTTPError(nethttp.StatusServiceUnavailable, "there is no alive splitter to assign the work")
+	}
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to assign the work")
+	}
+	status := nethttp.StatusOK
+	if assigned {
+		status = nethttp.StatusCreated
+	}
+	return c.JSON(status, assignment{Work: work, Splitter: id})
+}
+
+// Unassign removes the assignment of the work, so the splitter stops to process it
+func (s *Splitter) Unassign(c httpc.Context) error {
+	work := c.Param(workParam)
+	found, err := s.assigner.Unassign(c.Context(), work)
+	if err := errCRUDSrv(c, err, "it was impossible to unassign the work", "work not assigned", found); err != nil {
+		return err
+	}
+	return c.JSON(nethttp.StatusOK, assignment{Work: work})
+}
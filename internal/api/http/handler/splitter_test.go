@@ -0,0 +1,152 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/cluster"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitterHandler_List(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+	store := mng.Store()
+
+	id := "c0ob7bbu5ln0vjkdm7l0"
+	txn := storage.NewTxn(store)
+	txn.Find(cluster.InfoKey(id))
+	txn.DoNotFound(store.PutRaw(cluster.InfoKey(id), cluster.FormatStarted(time.Now())))
+	txn.DoNotFound(store.PutRaw(cluster.TickKey(cluster.FormatTick(time.Now()), id), id))
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+	handlers := Handlers{SplitterHandler: newSplitterHandle(cnt, store)}
+
+	rec, ctx := h.NewHTTP(nethttp.MethodGet, "/api/admin/splitters", "", nil, nil)
+	err := handlers.SplitterHandler.List(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, nethttp.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":"c0ob7bbu5ln0vjkdm7l0"`, "Splitter")
+		assert.Contains(t, rec.Body.String(), `"status":"alive"`, "Status")
+	}
+}
+
+func TestSplitterHandler_ListWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	store := &storage.ErrMockCRUD{}
+	store.Activate("StartKey")
+	handlers := Handlers{SplitterHandler: newSplitterHandle(cnt, store)}
+
+	_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/admin/splitters", "", nil, nil)
+	err := handlers.SplitterHandler.List(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code)
+	}
+}
+
+func TestSplitterHandler_AssignAndUnassign(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+	store := mng.Store()
+	handlers := Handlers{SplitterHandler: newSplitterHandle(cnt, store)}
+	params := map[string]string{"work": "space:c0ob7bbu5ln0vjkdm7l1"}
+
+	_, ctx := h.NewHTTP(nethttp.MethodPut, "/api/admin/splitters/assignments/:work", "", params, nil)
+	err := handlers.SplitterHandler.Assign(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusServiceUnavailable, err.(*echo.HTTPError).Code, "Without splitters")
+	}
+
+	id := "c0ob7bbu5ln0vjkdm7l0"
+	txn := storage.NewTxn(store)
+	txn.Find(cluster.InfoKey(id))
+	txn.DoNotFound(store.PutRaw(cluster.InfoKey(id), cluster.FormatStarted(time.Now())))
+	txn.DoNotFound(store.PutRaw(cluster.TickKey(cluster.FormatTick(time.Now()), id), id))
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+	for _, status := range []int{nethttp.StatusCreated, nethttp.StatusOK} {
+		rec, ctx := h.NewHTTP(nethttp.MethodPut, "/api/admin/splitters/assignments/:work", "", params, nil)
+		if assert.NoError(t, handlers.SplitterHandler.Assign(ctx)) {
+			assert.Equal(t, status, rec.Code, "Assign")
+			assert.Contains(t, rec.Body.String(), `"splitter":"c0ob7bbu5ln0vjkdm7l0"`, "Splitter")
+		}
+	}
+
+	for _, status := range []int{nethttp.StatusOK, nethttp.StatusNotFound} {
+		rec, ctx := h.NewHTTP(nethttp.MethodDelete, "/api/admin/splitters/assignments/:work", "", params, nil)
+		err := handlers.SplitterHandler.Unassign(ctx)
+		if status == nethttp.StatusNotFound {
+			if assert.Error(t, err) {
+				assert.Equal(t, status, err.(*echo.HTTPError).Code, "Not assigned")
+			}
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, status, rec.Code, "Unassign")
+		}
+	}
+}
+
+func TestSplitterHandler_AssignWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	store := &storage.ErrMockCRUD{}
+	store.Activate("GetRaw")
+	handlers := Handlers{SplitterHandler: newSplitterHandle(cnt, store)}
+
+	tests := []struct {
+		name   string
+		work   string
+		status int
+	}{
+		{name: "Empty work", work: "", status: nethttp.StatusBadRequest},
+		{name: "Store error", work: "space1", status: nethttp.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		_, ctx := h.NewHTTP(nethttp.MethodPut, "/api/admin/splitters/assignments/:work", "", map[string]string{"work": tt.work}, nil)
+		err := handlers.SplitterHandler.Assign(ctx)
+		if assert.Error(t, err, tt.name) {
+			assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+		}
+	}
+}
+
+func newSplitterHandle(cnt *runtime.Container, store storage.CRUD) Splitter {
+	return NewSplitterHandle(
+		cluster.NewRegistry(cnt.Cluster, store, cnt.StoreWithTimeout, 10),
+		cluster.NewAssigner(cnt.Cluster, store, cnt.StoreWithTimeout, 10),
+		cnt)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/rbac"
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	locTenant   = "http.tenant"
+	tenantParam = "name"
+)
+
+// Tenants resolves the tenant of each request from the header runtime.Tenancy.Header
+// and hands the request to the handlers of the tenant.
+// The handlers of each tenant only reach the keys of its namespace. See storage.NewNamespace
+type Tenants struct {
+	cnt  *runtime.Container
+	root Handlers
+	of   func(tenant string) (*Handlers, bool, error)
+}
+
+// NewTenants creates the resolver. root handles the requests without tenant
+// and 'of' gets the handlers of the tenant, if the tenant is not registered returns false
+func NewTenants(cnt *runtime.Container, root Handlers, of func(tenant string) (*Handlers, bool, error)) Tenants {
+	return Tenants{
+		cnt:  cnt,
+		root: root,
+		of:   of,
+	}
+}
+
+// Handle builds the echo handler that calls fn with the handlers of the tenant of the request
+// if the principal belongs to the tenant and the request has the permission into the tenant. See rbac.Authorizer
+func (t *Tenants) Handle(perm rbac.Permission, fn func(h *Handlers, ctx echo.Context) error) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		tenant := ctx.Request().Header.Get(t.cnt.Tenancy.Header)
+		h, err := t.resolve(tenant)
+		if err != nil {
+			return err
+		}
+		if err := t.bound(ctx, tenant); err != nil {
+			return err
+		}
+		if err := h.Authorizer.Authorize(ctx, perm); err != nil {
+			return err
+		}
+		return fn(h, ctx)
+	}
+}
+
+// Root builds the echo handler that calls fn with the handlers without tenant if the request has the permission.
+// It is used by the resources that are shared by all tenants
+func (t *Tenants) Root(perm rbac.Permission, fn func(h *Handlers, ctx echo.Context) error) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		if err := t.root.Authorizer.Authorize(ctx, perm); err != nil {
+			return err
+		}
+		return fn(&t.root, ctx)
+	}
+}
+
+func (t *Tenants) resolve(tenant string) (*Handlers, error) {
+	if len(tenant) == 0 {
+		if t.cnt.Tenancy.Required {
+			return nil, echo.NewHTTPError(
+				nethttp.StatusBadRequest,
+				strings.Concat("the header: '", t.cnt.Tenancy.Header, "' can not be empty"))
+		}
+		return &t.root, nil
+	}
+	if !storage.ValidNamespace(tenant) {
+		return nil, echo.NewHTTPError(
+			nethttp.StatusBadRequest,
+			strings.Concat("the tenant: '", tenant, "' only can contain lowercase letters, digits, '-' or '_'"))
+	}
+	h, found, err := t.of(tenant)
+	if err != nil {
+		_ = t.cnt.Log.ErrWrap(err, strings.Concat("resolving the tenant: ", tenant), locTenant)
+		return nil, echo.NewHTTPError(nethttp.StatusInternalServerError, "it was impossible to resolve the tenant")
+	}
+	if !found {
+		return nil, echo.NewHTTPError(nethttp.StatusNotFound, strings.Concat("the tenant: '", tenant, "' is not registered"))
+	}
+	return h, nil
+}
+
+// bound checks that the principal belongs to the tenant. The admins reach all tenants
+// and the anonymous principals are rejected by the authorizer
+func (t *Tenants) bound(ctx echo.Context, tenant string) error {
+	if !t.cnt.Auth.Enabled {
+		return nil
+	}
+	p, _ := ctx.Get(httpc.PrincipalKey).(httpc.Principal)
+	if !p.Authenticated() || t.cnt.Auth.Admin(p.ID) || p.Tenant == tenant {
+		return nil
+	}
+	return echo.NewHTTPError(nethttp.StatusForbidden, "the principal does not belong to the tenant")
+}
+
+// Tenant hands the http request of the tenants
+type Tenant struct {
+	cnt    *runtime.Container
+	create func(tenant string) (bool, error)
+}
+
+// NewTenantHandle creates handler. create registers the tenant, if the tenant is already registered returns false
+func NewTenantHandle(cnt *runtime.Container, create func(tenant string) (bool, error)) Tenant {
+	return Tenant{
+		cnt:    cnt,
+		create: create,
+	}
+}
+
+// Create registers the tenant. The requests only can reach the tenants registered. See Tenants.Handle
+func (t *Tenant) Create(c httpc.Context) error {
+	name := c.Param(tenantParam)
+	if !storage.ValidNamespace(name) {
+		return c.HTTPError(nethttp.StatusBadRequest, "the tenant only can contain lowercase letters, digits, '-' or '_'")
+	}
+
+	created, err := t.create(name)
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to register the tenant")
+	}
+	status := nethttp.StatusOK
+	if created {
+		status = nethttp.StatusCreated
+	}
+	return c.JSON(status, struct {
+		Name string `json:"name"`
+	}{Name: name})
+}
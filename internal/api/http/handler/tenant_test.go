@@ -0,0 +1,219 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/rbac"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenants_Handle(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	authz := rbac.NewAuthorizer(cnt, nil, rbac.Grants{})
+	root := Handlers{Authorizer: authz}
+	acme := Handlers{Authorizer: authz}
+	cnt.Auth.Admins = []string{"admin"}
+	tenants := NewTenants(cnt, root, func(tenant string) (*Handlers, bool, error) {
+		switch tenant {
+		case "fail":
+			return nil, false, errors.New("of")
+		case "unknown":
+			return nil, false, nil
+		}
+		return &acme, true, nil
+	})
+
+	tests := []struct {
+		name      string
+		tenant    string
+		required  bool
+		principal *httpc.Principal
+		handlers  *Handlers
+		status    int
+	}{
+		{
+			name:     "Without tenant.",
+			handlers: &tenants.root,
+		},
+		{
+			name:     "With tenant.",
+			tenant:   "acme",
+			handlers: &acme,
+		},
+		{
+			name:     "Tenant required.",
+			required: true,
+			status:   nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Tenant not valid.",
+			tenant: "Acme#",
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Tenant not registered.",
+			tenant: "unknown",
+			status: nethttp.StatusNotFound,
+		},
+		{
+			name:   "Error resolving the tenant.",
+			tenant: "fail",
+			status: nethttp.StatusInternalServerError,
+		},
+		{
+			name:      "Principal of the tenant.",
+			tenant:    "acme",
+			principal: &httpc.Principal{ID: "user", Tenant: "acme"},
+			handlers:  &acme,
+		},
+		{
+			name:      "Principal of other tenant.",
+			tenant:    "acme",
+			principal: &httpc.Principal{ID: "user", Tenant: "other"},
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Principal without tenant.",
+			tenant:    "acme",
+			principal: &httpc.Principal{ID: "user"},
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Principal of a tenant without tenant.",
+			principal: &httpc.Principal{ID: "user", Tenant: "acme"},
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Admin reaches all tenants.",
+			tenant:    "acme",
+			principal: &httpc.Principal{ID: "admin"},
+			handlers:  &acme,
+		},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		cnt.Tenancy.Required = tt.required
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.Header.Set(cnt.Tenancy.Header, tt.tenant)
+		ctx := e.NewContext(req, httptest.NewRecorder())
+		cnt.Auth.Enabled = tt.principal != nil
+		if tt.principal != nil {
+			ctx.Set(httpc.PrincipalKey, *tt.principal)
+		}
+
+		var handlers *Handlers
+		err := tenants.Handle(rbac.Platform().Viewer(), func(h *Handlers, _ echo.Context) error {
+			handlers = h
+			return nil
+		})(ctx)
+
+		if tt.status != 0 {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Same(t, tt.handlers, handlers, tt.name)
+		}
+	}
+}
+
+func TestTenants_Root(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	root := Handlers{Authorizer: rbac.NewAuthorizer(cnt, nil, rbac.Grants{})}
+	tenants := NewTenants(cnt, root, func(tenant string) (*Handlers, bool, error) {
+		return nil, false, errors.New("of")
+	})
+
+	req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+	req.Header.Set(cnt.Tenancy.Header, "acme")
+	ctx := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var handlers *Handlers
+	err := tenants.Root(rbac.Platform().Viewer(), func(h *Handlers, _ echo.Context) error {
+		handlers = h
+		return nil
+	})(ctx)
+	if assert.NoError(t, err) {
+		assert.Same(t, &tenants.root, handlers, "The tenant is ignored")
+	}
+}
+
+func TestTenant_Create(t *testing.T) {
+	h := mock.HTTP()
+	cnt := mock.NewContainerFake()
+	defer h.Close(cnt.Log)
+	registered := map[string]bool{"acme": true}
+	handle := NewTenantHandle(cnt, func(tenant string) (bool, error) {
+		if tenant == "fail" {
+			return false, errors.New("create")
+		}
+		created := !registered[tenant]
+		registered[tenant] = true
+		return created, nil
+	})
+
+	tests := []struct {
+		name   string
+		tenant string
+		status int
+	}{
+		{
+			name:   "Registering tenant.",
+			tenant: "other",
+			status: nethttp.StatusCreated,
+		},
+		{
+			name:   "Tenant already registered.",
+			tenant: "acme",
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Tenant not valid.",
+			tenant: "Acme#",
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Error registering the tenant.",
+			tenant: "fail",
+			status: nethttp.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(nethttp.MethodPut, "/api/admin/tenants/:name", "", map[string]string{"name": tt.tenant}, nil)
+		err := handle.Create(ctx)
+		if tt.status >= nethttp.StatusBadRequest {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, tt.name)
+		}
+	}
+}
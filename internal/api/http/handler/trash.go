@@ -0,0 +1,110 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/internal/api/http/convert"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/trash"
+	httpc "github.com/carisa/pkg/http"
+)
+
+const keyParam = "key"
+
+// Trash hands the http request of the trash of the instance.Instance
+type Trash struct {
+	srv trash.Service
+	cnt *runtime.Container
+}
+
+// NewTrashHandle creates handler
+func NewTrashHandle(srv trash.Service, cnt *runtime.Container) Trash {
+	return Trash{
+		srv: srv,
+		cnt: cnt,
+	}
+}
+
+// Delete moves the entity with the scheme and ID param to the trash
+func (t *Trash) Delete(c httpc.Context, scheme string) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+
+	found, item, err := t.srv.Delete(c.Context(), scheme, id)
+	if err := errCRUDSrv(c, err, "it was impossible to delete the entity", "entity not found", found); err != nil {
+		return err
+	}
+
+	item.Values = nil // The raw values are internal
+	return c.JSON(nethttp.StatusOK, item)
+}
+
+// List lists the items of the trash by instance.Instance ID and return top items.
+// If sname query param is not empty, is filtered by items which key starts by name parameter.
+// The scheme of the key allows to filter by type of entity.
+// If gtname query param is not empty, is filtered by items which key is greater than name parameter
+func (t *Trash) List(c httpc.Context) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+	top, err := convert.Top(c)
+	if err != nil {
+		return err
+	}
+
+	name := c.QueryParam("sname")
+	ranges := false
+	if gtname := c.QueryParam("gtname"); len(gtname) != 0 {
+		name = gtname
+		ranges = true
+	}
+
+	items, err := t.srv.List(c.Context(), id, name, ranges, top)
+	if err != nil {
+		return c.HTTPError(nethttp.StatusInternalServerError, "it was impossible to list the trash")
+	}
+	for _, item := range items {
+		item.(*trash.Item).Values = nil
+	}
+
+	return c.JSON(nethttp.StatusOK, items)
+}
+
+// Restore restores the entity with the key param from the trash of the instance.Instance
+func (t *Trash) Restore(c httpc.Context) error {
+	id, err := convert.ParamID(c)
+	if err != nil {
+		return err
+	}
+	key := c.Param(keyParam)
+	if len(key) == 0 {
+		return c.HTTPError(nethttp.StatusBadRequest, "the param path:key not found")
+	}
+
+	item, err := t.srv.Restore(c.Context(), id, key)
+	if err := errSrv(c, err, "it was impossible to restore the entity"); err != nil {
+		return err
+	}
+
+	item.Values = nil
+	return c.JSON(nethttp.StatusOK, item)
+}
@@ -0,0 +1,309 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package handler
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"testing"
+
+	entesmpl "github.com/carisa/internal/api/ente/samples"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	tsamples "github.com/carisa/internal/api/samples"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/internal/api/trash"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrashHandler_Delete(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, _, mng := newTrashHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	e, err := entesmpl.CreateEnte(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{
+			name:   "Deleting ente.",
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Deleting ente. Not found.",
+			status: nethttp.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(nethttp.MethodDelete, "/api/entes/:id", "", map[string]string{"id": e.ID.String()}, nil)
+		err := handlers.TrashHandler.Delete(ctx, entity.SchEnte)
+
+		if err != nil && tt.status == err.(*echo.HTTPError).Code {
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, strings.Concat(tt.name, "Http status"))
+			assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"key":"%s"`, e.Key()), strings.Concat(tt.name, "Deleted"))
+			assert.NotContains(t, rec.Body.String(), `"values"`, strings.Concat(tt.name, "Raw values"))
+		}
+	}
+}
+
+func TestTrashHandler_DeleteWithError(t *testing.T) {
+	tests := tsamples.TestGetWithError()
+
+	h := mock.HTTP()
+	cnt, handlers, crud := newTrashHandlerMocked()
+	defer h.Close(cnt.Log)
+
+	for _, tt := range tests {
+		if tt.MockOper != nil {
+			tt.MockOper(crud)
+		}
+		_, ctx := h.NewHTTP(nethttp.MethodDelete, "/api/entes/:id", "", tt.Param, nil)
+		err := handlers.TrashHandler.Delete(ctx, entity.SchEnte)
+
+		assert.Equal(t, tt.Status, err.(*echo.HTTPError).Code, tt.Name)
+		assert.Error(t, err, tt.Name)
+	}
+}
+
+func TestTrashHandler_List(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, srv, mng := newTrashHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	e, err := entesmpl.CreateEnte(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		qparam map[string]string
+		count  int
+	}{
+		{
+			name:  "Listing all items.",
+			count: 1,
+		},
+		{
+			name:   "Listing entes.",
+			qparam: map[string]string{"sname": entity.SchEnte},
+			count:  1,
+		},
+		{
+			name:   "Listing spaces.",
+			qparam: map[string]string{"sname": entity.SchSpace},
+			count:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(
+			nethttp.MethodGet,
+			"/api/instances/:id/trash",
+			"",
+			map[string]string{"id": item.InstID.String()},
+			tt.qparam)
+		err := handlers.TrashHandler.List(ctx)
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, nethttp.StatusOK, rec.Code, strings.Concat(tt.name, "Http status"))
+			key := fmt.Sprintf(`"key":"%s"`, e.Key())
+			if tt.count == 0 {
+				assert.NotContains(t, rec.Body.String(), key, tt.name)
+			} else {
+				assert.Contains(t, rec.Body.String(), key, tt.name)
+			}
+		}
+	}
+}
+
+func TestTrashHandler_ListError(t *testing.T) {
+	tests := tsamples.TestListError()
+
+	h := mock.HTTP()
+	cnt, handlers, crud := newTrashHandlerMocked()
+	defer h.Close(cnt.Log)
+
+	for _, tt := range tests {
+		if tt.MockOper != nil {
+			tt.MockOper(crud)
+		}
+		_, ctx := h.NewHTTP(nethttp.MethodGet, "/api/instances/:id/trash", "", tt.Param, tt.QParam)
+		err := handlers.TrashHandler.List(ctx)
+
+		assert.Equal(t, tt.Status, err.(*echo.HTTPError).Code, tt.Name)
+		assert.Error(t, err, tt.Name)
+	}
+}
+
+func TestTrashHandler_Restore(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, srv, mng := newTrashHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	e, err := entesmpl.CreateEnte(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{
+			name:   "Restoring ente.",
+			status: nethttp.StatusOK,
+		},
+		{
+			name:   "Restoring ente. Not found.",
+			status: nethttp.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		rec, ctx := h.NewHTTP(
+			nethttp.MethodPost,
+			"/api/instances/:id/trash/:key/restore",
+			"",
+			map[string]string{"id": item.InstID.String(), "key": e.Key()},
+			nil)
+		err := handlers.TrashHandler.Restore(ctx)
+
+		if err != nil && tt.status == err.(*echo.HTTPError).Code {
+			continue
+		}
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.status, rec.Code, strings.Concat(tt.name, "Http status"))
+			assert.Contains(t, rec.Body.String(), fmt.Sprintf(`"key":"%s"`, e.Key()), strings.Concat(tt.name, "Restored"))
+		}
+	}
+}
+
+func TestTrashHandler_RestoreConflict(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, srv, mng := newTrashHandlerFaked(t)
+	defer mng.Close()
+	defer h.Close(cnt.Log)
+
+	e, err := entesmpl.CreateEnte(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// The key of the entity is in use again
+	_, crudOper := mock.NewCrudOperFaked(mng)
+	if _, err := crudOper.Put("loc", cnt.StoreTimeout(context.Background()), &e); !assert.NoError(t, err) {
+		return
+	}
+
+	_, ctx := h.NewHTTP(
+		nethttp.MethodPost,
+		"/api/instances/:id/trash/:key/restore",
+		"",
+		map[string]string{"id": item.InstID.String(), "key": e.Key()},
+		nil)
+	err = handlers.TrashHandler.Restore(ctx)
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusConflict, err.(*echo.HTTPError).Code, "Conflict")
+	}
+}
+
+func TestTrashHandler_RestoreWithError(t *testing.T) {
+	h := mock.HTTP()
+	cnt, handlers, crud := newTrashHandlerMocked()
+	defer h.Close(cnt.Log)
+
+	tests := []struct {
+		name     string
+		param    map[string]string
+		mockOper func(txn *storage.ErrMockCRUDOper)
+		status   int
+	}{
+		{
+			name:   "Param not found. Bad request",
+			param:  map[string]string{"i": ""},
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:   "Key param not found. Bad request",
+			param:  map[string]string{"id": xid.NilID().String()},
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name:     "Get error. Internal server error",
+			param:    map[string]string{"id": xid.NilID().String(), "key": "key"},
+			mockOper: func(s *storage.ErrMockCRUDOper) { s.Store().(*storage.ErrMockCRUD).Activate("Get") },
+			status:   nethttp.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.mockOper != nil {
+			tt.mockOper(crud)
+		}
+		_, ctx := h.NewHTTP(nethttp.MethodPost, "/api/instances/:id/trash/:key/restore", "", tt.param, nil)
+		err := handlers.TrashHandler.Restore(ctx)
+
+		assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+		assert.Error(t, err, tt.name)
+	}
+}
+
+func newTrashHandlerFaked(t *testing.T) (*runtime.Container, Handlers, trash.Service, storage.Integration) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	ext := service.NewExt(cnt, crud.Store())
+	srv := trash.NewService(cnt, ext, crud)
+	hands := Handlers{TrashHandler: NewTrashHandle(srv, cnt)}
+	return cnt, hands, srv, mng
+}
+
+func newTrashHandlerMocked() (*runtime.Container, Handlers, *storage.ErrMockCRUDOper) {
+	cnt := mock.NewContainerFake()
+	crud := storage.NewErrMockCRUDOper()
+	ext := service.NewExt(cnt, crud.Store())
+	srv := trash.NewService(cnt, ext, crud)
+	hands := Handlers{TrashHandler: NewTrashHandle(srv, cnt)}
+	return cnt, hands, crud
+}
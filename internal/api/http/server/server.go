@@ -22,10 +22,16 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/http/handler"
+	"github.com/carisa/internal/api/rbac"
 	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/health"
+	loge "github.com/carisa/pkg/http/echo"
+	"github.com/carisa/pkg/metrics"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // Start starts the graceful http server
@@ -50,59 +56,140 @@ func Start(e *echo.Echo, cnf runtime.Config) {
 	}
 }
 
-// Middleware configure security and behaviour of http
-func Middleware(e *echo.Echo) {
+// Middleware configure security and behaviour of http.
+// The middlewares are installed after recovering middleware, for example the authentication. See auth.Middleware.
+// The probes and the metrics skip the middlewares because the orchestrator and the scrapers
+// don't send credentials and they must not be limited. See Probes and Metrics
+func Middleware(e *echo.Echo, cnf metrics.Config, mws ...echo.MiddlewareFunc) {
 	e.Use(middleware.Recover())
+	for _, mw := range mws {
+		e.Use(skipUnguarded(mw, cnf))
+	}
+}
+
+// skipUnguarded decorates the middleware to call the handler of the probes and the metrics directly
+func skipUnguarded(mw echo.MiddlewareFunc, cnf metrics.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		h := mw(next)
+		return func(ctx echo.Context) error {
+			if loge.IsProbe(ctx.Path()) || (cnf.Enabled && ctx.Path() == cnf.Route()) {
+				return next(ctx)
+			}
+			return h(ctx)
+		}
+	}
+}
+
+// Probes adds the liveness and readiness probes. See health.Probe
+func Probes(e *echo.Echo, probe *health.Probe) {
+	loge.Probes(e, probe)
 }
 
-// Router defines all http route for API
-func Router(e *echo.Echo, h handler.Handlers) {
+// Router defines all http route for API. Each request is handled by the handlers of its tenant
+// and each route declares the role that the request needs over the entity. See rbac.Permission
+func Router(e *echo.Echo, t handler.Tenants) {
+	platform := rbac.Platform()
+
 	// Instance
-	e.POST("/api/instances", h.InstCreate)
-	e.PUT("/api/instances/:id", h.InstPut)
-	e.GET("/api/instances/:id", h.InstGet)
-	e.GET("/api/instances/:id/spaces", h.InstListSpaces)
+	inst := rbac.Param("id", entity.SchInstance)
+	e.POST("/api/instances", t.Handle(platform.Admin(), (*handler.Handlers).InstCreate))
+	e.PUT("/api/instances/:id", t.Handle(inst.Admin(), (*handler.Handlers).InstPut))
+	e.GET("/api/instances/:id", t.Handle(inst.Viewer(), (*handler.Handlers).InstGet))
+	e.GET("/api/instances/:id/spaces", t.Handle(inst.Viewer(), (*handler.Handlers).InstListSpaces))
+	e.GET("/api/instances/:id/trash", t.Handle(inst.Viewer(), (*handler.Handlers).InstListTrash))
+	e.POST("/api/instances/:id/trash/:key/restore", t.Handle(inst.Modeler(), (*handler.Handlers).InstRestoreTrash))
+	e.GET("/api/instances/:id/quotas", t.Handle(inst.Viewer(), (*handler.Handlers).InstGetQuotas))
+	e.PUT("/api/instances/:id/quotas", t.Handle(platform.Admin(), (*handler.Handlers).InstPutQuotas))
+	e.PUT("/api/instances/:id/grants/:principal", t.Handle(inst.Admin(), (*handler.Handlers).InstPutGrant))
+	e.GET("/api/instances/:id/grants", t.Handle(inst.Admin(), (*handler.Handlers).InstListGrants))
+	e.DELETE("/api/instances/:id/grants/:principal", t.Handle(inst.Admin(), (*handler.Handlers).InstRevokeGrant))
 
 	// Space
-	e.POST("/api/spaces", h.SpaceCreate)
-	e.PUT("/api/spaces/:id", h.SpacePut)
-	e.GET("/api/spaces/:id", h.SpaceGet)
-	e.GET("/api/spaces/:id/entes", h.SpcListEntes)
-	e.GET("/api/spaces/:id/categories", h.SpcListCategories)
+	spc := rbac.Param("id", entity.SchSpace)
+	spcParent := rbac.Body("instanceId", entity.SchInstance)
+	e.POST("/api/spaces", t.Handle(spcParent.Modeler(), (*handler.Handlers).SpaceCreate))
+	e.PUT("/api/spaces/:id", t.Handle(spc.Or(spcParent).Modeler(), (*handler.Handlers).SpacePut))
+	e.GET("/api/spaces/:id", t.Handle(spc.Viewer(), (*handler.Handlers).SpaceGet))
+	e.DELETE("/api/spaces/:id", t.Handle(spc.Modeler(), (*handler.Handlers).SpaceDelete))
+	e.GET("/api/spaces/:id/entes", t.Handle(spc.Viewer(), (*handler.Handlers).SpcListEntes))
+	e.GET("/api/spaces/:id/categories", t.Handle(spc.Viewer(), (*handler.Handlers).SpcListCategories))
+	e.PUT("/api/spaces/:id/grants/:principal", t.Handle(spc.Admin(), (*handler.Handlers).SpacePutGrant))
+	e.GET("/api/spaces/:id/grants", t.Handle(spc.Admin(), (*handler.Handlers).SpaceListGrants))
+	e.DELETE("/api/spaces/:id/grants/:principal", t.Handle(spc.Admin(), (*handler.Handlers).SpaceRevokeGrant))
 
 	// Ente
-	e.POST("/api/entes", h.EnteCreate)
-	e.PUT("/api/entes/:id", h.EntePut)
-	e.GET("/api/entes/:id", h.EnteGet)
-	e.GET("/api/entes/:id/properties", h.EnteListProps)
-	e.POST("/api/entes/:id/queries", h.EnteQryCreate)
-	e.PUT("/api/entes/:enteid/queries/:id", h.EnteQryPut)
-	e.GET("/api/entes/:id/queries", h.EnteListQueries)
-	e.PUT("/api/entes/:enteid/linktocategories/:categoryid", h.EnteLinkToCat)
-	e.POST("/api/entesproperties", h.EnteCreateProp)
-	e.PUT("/api/entesproperties/:id", h.EntePutProp)
-	e.GET("/api/entesproperties/:id", h.EnteGetProp)
+	ente := rbac.Param("id", entity.SchEnte)
+	enteParent := rbac.Body("spaceId", entity.SchSpace)
+	enteQry := rbac.Param("id", entity.SchObject).Or(rbac.Param("enteid", entity.SchEnte))
+	enteProp := rbac.Param("id", entity.SchEnteProp)
+	entePropParent := rbac.Body("enteId", entity.SchEnte)
+	e.POST("/api/entes", t.Handle(enteParent.Modeler(), (*handler.Handlers).EnteCreate))
+	e.PUT("/api/entes/:id", t.Handle(ente.Or(enteParent).Modeler(), (*handler.Handlers).EntePut))
+	e.GET("/api/entes/:id", t.Handle(ente.Viewer(), (*handler.Handlers).EnteGet))
+	e.DELETE("/api/entes/:id", t.Handle(ente.Modeler(), (*handler.Handlers).EnteDelete))
+	e.GET("/api/entes/:id/properties", t.Handle(ente.Viewer(), (*handler.Handlers).EnteListProps))
+	e.POST("/api/entes/:id/queries", t.Handle(ente.Modeler(), (*handler.Handlers).EnteQryCreate))
+	e.PUT("/api/entes/:enteid/queries/:id", t.Handle(enteQry.Modeler(), (*handler.Handlers).EnteQryPut))
+	e.GET("/api/entes/:id/queries", t.Handle(ente.Viewer(), (*handler.Handlers).EnteListQueries))
+	e.PUT(
+		"/api/entes/:enteid/linktocategories/:categoryid",
+		t.Handle(
+			rbac.Param("enteid", entity.SchEnte).Modeler().And(rbac.Param("categoryid", entity.SchCategory)),
+			(*handler.Handlers).EnteLinkToCat))
+	e.POST("/api/entesproperties", t.Handle(entePropParent.Modeler(), (*handler.Handlers).EnteCreateProp))
+	e.PUT("/api/entesproperties/:id", t.Handle(enteProp.Or(entePropParent).Modeler(), (*handler.Handlers).EntePutProp))
+	e.GET("/api/entesproperties/:id", t.Handle(enteProp.Viewer(), (*handler.Handlers).EnteGetProp))
 
 	// Category
-	e.POST("/api/categories", h.CatCreate)
-	e.PUT("/api/categories/:id", h.CatPut)
-	e.GET("/api/categories/:id", h.CatGet)
-	e.GET("/api/categories/:id/child", h.CatListCategories)
-	e.GET("/api/categories/:id/properties", h.CatListProps)
-	e.POST("/api/categories/:id/queries", h.CatQryCreate)
-	e.PUT("/api/categories/:categoryid/queries/:id", h.CatQryPut)
-	e.GET("/api/categories/:id/queries", h.CatListQueries)
-	e.POST("/api/categoriesproperties", h.CatCreateProp)
-	e.PUT("/api/categoriesproperties/:id", h.CatPutProp)
-	e.GET("/api/categoriesproperties/:id", h.CatGetProp)
-	e.PUT("/api/categoriesproperties/:catpropid/linkto/:propid", h.CatPropLinkTo)
+	cat := rbac.Param("id", entity.SchCategory)
+	catParent := rbac.Body("parentId", entity.SchSpace, entity.SchCategory)
+	catQry := rbac.Param("id", entity.SchObject).Or(rbac.Param("categoryid", entity.SchCategory))
+	catProp := rbac.Param("id", entity.SchCatProp)
+	catPropParent := rbac.Body("categoryId", entity.SchCategory)
+	e.POST("/api/categories", t.Handle(catParent.Modeler(), (*handler.Handlers).CatCreate))
+	e.PUT("/api/categories/:id", t.Handle(cat.Or(catParent).Modeler(), (*handler.Handlers).CatPut))
+	e.GET("/api/categories/:id", t.Handle(cat.Viewer(), (*handler.Handlers).CatGet))
+	e.DELETE("/api/categories/:id", t.Handle(cat.Modeler(), (*handler.Handlers).CatDelete))
+	e.GET("/api/categories/:id/child", t.Handle(cat.Viewer(), (*handler.Handlers).CatListCategories))
+	e.GET("/api/categories/:id/properties", t.Handle(cat.Viewer(), (*handler.Handlers).CatListProps))
+	e.POST("/api/categories/:id/queries", t.Handle(cat.Modeler(), (*handler.Handlers).CatQryCreate))
+	e.PUT("/api/categories/:categoryid/queries/:id", t.Handle(catQry.Modeler(), (*handler.Handlers).CatQryPut))
+	e.GET("/api/categories/:id/queries", t.Handle(cat.Viewer(), (*handler.Handlers).CatListQueries))
+	e.POST("/api/categoriesproperties", t.Handle(catPropParent.Modeler(), (*handler.Handlers).CatCreateProp))
+	e.PUT("/api/categoriesproperties/:id", t.Handle(catProp.Or(catPropParent).Modeler(), (*handler.Handlers).CatPutProp))
+	e.GET("/api/categoriesproperties/:id", t.Handle(catProp.Viewer(), (*handler.Handlers).CatGetProp))
+	e.PUT(
+		"/api/categoriesproperties/:catpropid/linkto/:propid",
+		t.Handle(
+			rbac.Param("catpropid", entity.SchCatProp).Modeler().And(rbac.Param("propid", entity.SchEnteProp, entity.SchCatProp)),
+			(*handler.Handlers).CatPropLinkTo))
 
 	// Query plugin Prototype
-	e.POST("/api/plugins/queries", h.PluginQryCreate)
-	e.PUT("/api/plugins/queries/:id", h.PluginQryPut)
-	e.GET("/api/plugins/queries/:id", h.PluginQryGet)
-	e.GET("/api/plugins/queries", h.PluginQryListPlugins)
+	e.POST("/api/plugins/queries", t.Handle(platform.Admin(), (*handler.Handlers).PluginQryCreate))
+	e.PUT("/api/plugins/queries/:id", t.Handle(platform.Admin(), (*handler.Handlers).PluginQryPut))
+	e.GET("/api/plugins/queries/:id", t.Handle(platform.Viewer(), (*handler.Handlers).PluginQryGet))
+	e.GET("/api/plugins/queries", t.Handle(platform.Viewer(), (*handler.Handlers).PluginQryListPlugins))
 
 	// Query object Instance
-	e.GET("/api/queries/:id", h.InstQryGet)
+	e.GET("/api/queries/:id", t.Handle(rbac.Param("id", entity.SchObject).Viewer(), (*handler.Handlers).InstQryGet))
+
+	// Admin
+	e.GET("/api/admin/fsck", t.Handle(platform.Admin(), (*handler.Handlers).AdminFsck))
+	e.POST("/api/admin/fsck/repair", t.Handle(platform.Admin(), (*handler.Handlers).AdminFsckRepair))
+	e.POST("/api/admin/apikeys", t.Root(platform.Admin(), (*handler.Handlers).AdminCreateAPIKey))
+	e.GET("/api/admin/apikeys", t.Root(platform.Admin(), (*handler.Handlers).AdminListAPIKeys))
+	e.DELETE("/api/admin/apikeys/:id", t.Root(platform.Admin(), (*handler.Handlers).AdminRevokeAPIKey))
+	e.PUT("/api/admin/tenants/:name", t.Root(platform.Admin(), (*handler.Handlers).AdminCreateTenant))
+	e.GET("/api/admin/splitters", t.Root(platform.Admin(), (*handler.Handlers).AdminListSplitters))
+	e.PUT("/api/admin/splitters/assignments/:work", t.Root(platform.Admin(), (*handler.Handlers).AdminAssignWork))
+	e.DELETE("/api/admin/splitters/assignments/:work", t.Root(platform.Admin(), (*handler.Handlers).AdminUnassignWork))
+}
+
+// Metrics exposes the metrics of the registry into the path of the configuration.
+// If the registry is nil the metrics are disabled and the route is not added
+func Metrics(e *echo.Echo, cnf metrics.Config, reg *prometheus.Registry) {
+	if reg == nil {
+		return
+	}
+	e.GET(cnf.Route(), loge.MetricsHandler(reg))
 }
@@ -17,25 +17,91 @@
 package server
 
 import (
+	nethttp "net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/carisa/internal/api/auth"
 	"github.com/carisa/internal/api/http/handler"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/pkg/health"
+	"github.com/carisa/pkg/metrics"
 
 	"github.com/labstack/echo/v4"
 )
 
 func TestServer_Middleware(t *testing.T) {
 	e := echo.New()
-	Middleware(e)
+	Middleware(e, metrics.Config{})
+
+	e = echo.New()
+	called := false
+	Middleware(e, metrics.Config{}, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			called = true
+			return next(ctx)
+		}
+	})
+	e.GET("/", func(ctx echo.Context) error { return nil })
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(nethttp.MethodGet, "/", nil))
+	assert.True(t, called, "Middleware installed")
 }
 
 func TestServer_Router(t *testing.T) {
 	e := echo.New()
-	h := handler.Handlers{}
+	h := handler.NewTenants(mock.NewContainerFake(), handler.Handlers{}, nil)
 
 	Router(e, h)
 
-	assert.Equal(t, 37, len(e.Routes()))
+	assert.Equal(t, 59, len(e.Routes()))
+}
+
+func TestServer_Metrics(t *testing.T) {
+	e := echo.New()
+	Metrics(e, metrics.Config{}, nil)
+	assert.Empty(t, e.Routes(), "Metrics disabled")
+
+	Metrics(e, metrics.Config{Enabled: true}, metrics.NewRegistry())
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, metrics.DefaultPath, nil))
+	assert.Equal(t, nethttp.StatusOK, rec.Code, "Metrics exposed")
+}
+
+func TestServer_Probes(t *testing.T) {
+	e := echo.New()
+	calls := 0
+	Middleware(e, metrics.Config{}, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			calls++
+			return echo.NewHTTPError(nethttp.StatusUnauthorized)
+		}
+	})
+	Probes(e, health.NewProbe(""))
+
+	for _, path := range []string{health.LivePath, health.ReadyPath} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, path, nil))
+		assert.Equal(t, nethttp.StatusOK, rec.Code, path)
+	}
+	assert.Zero(t, calls, "The probes skip the middlewares")
+}
+
+func TestServer_MetricsWithAuth(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.Auth.Enabled = true
+	cnf := metrics.Config{Enabled: true}
+	e := echo.New()
+	Middleware(e, cnf, auth.Middleware(cnt))
+	Metrics(e, cnf, metrics.NewRegistry())
+	e.GET("/api/other", func(ctx echo.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, metrics.DefaultPath, nil))
+	assert.Equal(t, nethttp.StatusOK, rec.Code, "The metrics skip the authentication")
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/api/other", nil))
+	assert.Equal(t, nethttp.StatusUnauthorized, rec.Code, "Other routes are authenticated")
 }
@@ -41,7 +41,7 @@ func TestValid_ValidDescriptor(t *testing.T) {
 				Name: "",
 				Desc: "desc",
 			},
-			message: "code=400, message=[the property: 'name' can not be empty]",
+			message: "code=400, message=the property: 'name' can not be empty",
 		},
 		{
 			name: "Description empty",
@@ -49,7 +49,7 @@ func TestValid_ValidDescriptor(t *testing.T) {
 				Name: "name",
 				Desc: "",
 			},
-			message: "code=400, message=[the property: 'description' can not be empty]",
+			message: "code=400, message=the property: 'description' can not be empty",
 		},
 		{
 			name: "Name > 50",
@@ -57,7 +57,7 @@ func TestValid_ValidDescriptor(t *testing.T) {
 				Name: strings.Repeat("n", 51),
 				Desc: "desc",
 			},
-			message: "code=400, message=[the property: 'name' can not be more than 50]",
+			message: "code=400, message=the property: 'name' can not be more than 50",
 		},
 		{
 			name: "Description > 500",
@@ -65,7 +65,7 @@ func TestValid_ValidDescriptor(t *testing.T) {
 				Name: "name",
 				Desc: strings.Repeat("d", 501),
 			},
-			message: "code=400, message=[the property: 'description' can not be more than 500]",
+			message: "code=400, message=the property: 'description' can not be more than 500",
 		},
 		{
 			name: "Descriptor validator. Ok",
@@ -97,7 +97,7 @@ func TestValid_ValidID(t *testing.T) {
 	}{
 		{
 			name:    "ID empty",
-			message: "code=400, message=[the property: 'ID' can not be empty]",
+			message: "code=400, message=the property: 'ID' can not be empty",
 		},
 		{
 			name:    "ID validation. Ok",
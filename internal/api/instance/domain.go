@@ -25,6 +25,17 @@ import (
 // Each Instance is independently of another Instance in all system
 type Instance struct {
 	entity.Descriptor
+	Quotas Quotas `json:"quotas"`
+}
+
+// Quotas limits the number of entities of the Instance. Zero is unlimited.
+// The queries are the object instances of the entes and categories
+type Quotas struct {
+	Spaces     int `json:"spaces"`
+	Entes      int `json:"entes"`
+	Categories int `json:"categories"`
+	Properties int `json:"properties"`
+	Queries    int `json:"queries"`
 }
 
 func New() Instance {
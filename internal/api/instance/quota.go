@@ -0,0 +1,244 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package instance
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+)
+
+const (
+	locQuota = "instance.quota"
+	// recountBatch is the number of keys read by request recounting the entities
+	recountBatch = 100
+)
+
+// errRecount is returned when the counters are changed by other process while they are recounted
+var errRecount = errors.New("the counters have been changed by other process during the recount")
+
+// The names of the entities counted into the Instance. See Quotas
+const (
+	QuotaSpaces     = "spaces"
+	QuotaEntes      = "entes"
+	QuotaCategories = "categories"
+	QuotaProperties = "properties"
+	QuotaQueries    = "queries"
+)
+
+// names are the names of the entities counted
+var names = []string{QuotaSpaces, QuotaEntes, QuotaCategories, QuotaProperties, QuotaQueries}
+
+// counted gets the name of the counted entities by scheme
+var counted = map[string]string{
+	entity.SchSpace:    QuotaSpaces,
+	entity.SchEnte:     QuotaEntes,
+	entity.SchCategory: QuotaCategories,
+	entity.SchEnteProp: QuotaProperties,
+	entity.SchCatProp:  QuotaProperties,
+	entity.SchObject:   QuotaQueries,
+}
+
+// limit gets the limit of the entities by name
+func (q *Quotas) limit(name string) *int {
+	switch name {
+	case QuotaSpaces:
+		return &q.Spaces
+	case QuotaEntes:
+		return &q.Entes
+	case QuotaCategories:
+		return &q.Categories
+	case QuotaProperties:
+		return &q.Properties
+	default:
+		return &q.Queries
+	}
+}
+
+// Valid checks that the limits are not negative
+func (q *Quotas) Valid() bool {
+	return q.Spaces >= 0 && q.Entes >= 0 && q.Categories >= 0 && q.Properties >= 0 && q.Queries >= 0
+}
+
+// Quota resolves the counters of the entities into the Instance that contains them. See storage.Counters
+type Quota struct {
+	cnt   *runtime.Container
+	ext   *service.Extension
+	store storage.CRUD
+}
+
+// NewQuota builds the resolver of the counters
+func NewQuota(cnt *runtime.Container, ext *service.Extension, store storage.CRUD) Quota {
+	return Quota{
+		cnt:   cnt,
+		ext:   ext,
+		store: store,
+	}
+}
+
+// Counters implements storage.Counters. The entity is counted into the Instance that contains the parent,
+// the entities out of any Instance are not counted
+func (q Quota) Counters(ctx context.Context, key string, parentKey string) ([]storage.Counter, error) {
+	name, ok := counted[scheme(key)]
+	if !ok {
+		return nil, nil
+	}
+	id, found, err := q.ext.Instance(ctx, parentKey)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	var inst Instance
+	sctx, cancel := q.cnt.StoreWithTimeout(ctx)
+	found, err = q.store.Get(sctx, entity.InstKey(id), &inst)
+	cancel()
+	if err != nil || !found {
+		return nil, err
+	}
+	return []storage.Counter{{Key: entity.QuotaKey(id, name), Name: name, Limit: *inst.Quotas.limit(name)}}, nil
+}
+
+// Recount sets the counters of all instances to the number of entities that they contain.
+// The entities created before the counters existed are not counted, see storage.Count.
+// If dryRun is true the counters are not written. Returns the number of counters changed.
+// The counters must not be changed by other process during the recount, otherwise returns error
+func (q Quota) Recount(dryRun bool) (int, error) {
+	counts := make(map[xid.ID]map[string]int)
+	storeTimeout := q.cnt.StoreTimeout(context.Background())
+	err := storage.ScanRaw(storeTimeout, q.store, entity.SchInstance, recountBatch, func(keys []string, _ map[string]string) error {
+		for _, key := range keys {
+			if scheme(key) != entity.SchInstance {
+				continue
+			}
+			id, err := xid.FromString(key[len(entity.SchInstance):])
+			if err != nil {
+				return err
+			}
+			counts[id] = make(map[string]int)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, q.cnt.Log.ErrWrap(err, "listing the instances", locQuota)
+	}
+
+	// The schemes of the properties start by the schemes of their parents
+	for _, prefix := range []string{entity.SchSpace, entity.SchEnte, entity.SchCategory, entity.SchObject} {
+		err := storage.ScanRaw(storeTimeout, q.store, prefix, recountBatch, func(keys []string, _ map[string]string) error {
+			for _, key := range keys {
+				name, ok := counted[scheme(key)]
+				if !ok {
+					continue
+				}
+				ctx, cancel := q.cnt.StoreWithTimeout(context.Background())
+				id, found, err := q.ext.Instance(ctx, key)
+				cancel()
+				if err != nil {
+					return err
+				}
+				if c, ok := counts[id]; found && ok {
+					c[name]++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, q.cnt.Log.ErrWrap1(err, "counting the entities", locQuota, logging.String("prefix", prefix))
+		}
+	}
+
+	changed := 0
+	for id, c := range counts {
+		for _, name := range names {
+			ok, err := q.recount(entity.QuotaKey(id, name), c[name], dryRun)
+			if err != nil {
+				return changed, err
+			}
+			if ok {
+				changed++
+			}
+		}
+	}
+	return changed, nil
+}
+
+// recount sets the counter to n if it is different. Returns true if it is changed
+func (q Quota) recount(key string, n int, dryRun bool) (bool, error) {
+	ctx, cancel := q.cnt.StoreWithTimeout(context.Background())
+	_, old, err := q.store.GetRaw(ctx, key)
+	cancel()
+	if err != nil {
+		return false, q.cnt.Log.ErrWrap1(err, "getting the counter", locQuota, logging.String("key", key))
+	}
+	value := strconv.Itoa(n)
+	if old == value || (len(old) == 0 && n == 0) {
+		return false, nil
+	}
+	if dryRun {
+		return true, nil
+	}
+	txn := storage.NewTxn(q.store)
+	txn.Match(key, old)
+	txn.DoFound(q.store.PutRaw(key, value))
+	ctx, cancel = q.cnt.StoreWithTimeout(context.Background())
+	ok, err := txn.Commit(ctx)
+	cancel()
+	if err != nil {
+		return false, q.cnt.Log.ErrWrap1(err, "commit recounting", locQuota, logging.String("key", key))
+	}
+	if !ok {
+		return false, q.cnt.Log.ErrWrap1(errRecount, "recounting", locQuota, logging.String("key", key))
+	}
+	return true, nil
+}
+
+// usage reads the counters of the Instance
+func usage(ctx context.Context, cnt *runtime.Container, store storage.CRUD, id xid.ID) (Quotas, error) {
+	var u Quotas
+	for _, name := range names {
+		sctx, cancel := cnt.StoreWithTimeout(ctx)
+		_, value, err := store.GetRaw(sctx, entity.QuotaKey(id, name))
+		cancel()
+		if err != nil {
+			return Quotas{}, err
+		}
+		if len(value) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return Quotas{}, err
+		}
+		*u.limit(name) = n
+	}
+	return u, nil
+}
+
+// scheme gets the scheme of the entity key. The entity key is the scheme and the ID. See entity.Key
+func scheme(key string) string {
+	if l := len(key) - len(xid.NilID().String()); l > 0 {
+		return key[:l]
+	}
+	return ""
+}
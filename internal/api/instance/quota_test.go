@@ -0,0 +1,216 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package instance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carisa/internal/api/ente"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/mock"
+	srv "github.com/carisa/internal/api/service"
+	"github.com/carisa/internal/api/space"
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota_Counters(t *testing.T) {
+	s, q, crud, mng := newQuotaFaked(t)
+	defer mng.Close()
+
+	inst := instance()
+	inst.Quotas = Quotas{Spaces: 1, Entes: 2}
+	if _, err := s.Create(context.Background(), &inst); !assert.NoError(t, err) {
+		return
+	}
+	spc := space.New()
+	spc.Name = "space"
+	spc.InstID = inst.ID
+	if _, _, err := crud.CreateWithRel("loc", s.cnt.StoreTimeout(context.Background()), &spc); !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		parentKey string
+		counters  []storage.Counter
+	}{
+		{
+			name:      "Counting space.",
+			key:       entity.SpaceKey(xid.New()),
+			parentKey: inst.Key(),
+			counters:  []storage.Counter{{Key: entity.QuotaKey(inst.ID, QuotaSpaces), Name: QuotaSpaces, Limit: 1}},
+		},
+		{
+			name:      "Counting ente into the space.",
+			key:       entity.EnteKey(xid.New()),
+			parentKey: spc.Key(),
+			counters:  []storage.Counter{{Key: entity.QuotaKey(inst.ID, QuotaEntes), Name: QuotaEntes, Limit: 2}},
+		},
+		{
+			name:      "Counting property. Unlimited.",
+			key:       entity.EntePropKey(xid.New()),
+			parentKey: spc.Key(),
+			counters:  []storage.Counter{{Key: entity.QuotaKey(inst.ID, QuotaProperties), Name: QuotaProperties}},
+		},
+		{
+			name:      "Counting plugin. Not counted.",
+			key:       entity.PluginKey(xid.New()),
+			parentKey: storage.Virtual,
+		},
+		{
+			name:      "Counting ente out of instance.",
+			key:       entity.EnteKey(xid.New()),
+			parentKey: entity.SpaceKey(xid.New()),
+		},
+		{
+			name:      "Counting space. Instance not found.",
+			key:       entity.SpaceKey(xid.New()),
+			parentKey: entity.InstKey(xid.New()),
+		},
+	}
+
+	for _, tt := range tests {
+		counters, err := q.Counters(context.Background(), tt.key, tt.parentKey)
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.counters, counters, tt.name)
+		}
+	}
+}
+
+func TestQuota_Limit(t *testing.T) {
+	s, _, crud, mng := newQuotaFaked(t)
+	defer mng.Close()
+
+	inst := instance()
+	inst.Quotas = Quotas{Spaces: 1}
+	if _, err := s.Create(context.Background(), &inst); !assert.NoError(t, err) {
+		return
+	}
+
+	create := func(name string) error {
+		spc := space.New()
+		spc.Name = name
+		spc.InstID = inst.ID
+		_, _, err := crud.CreateWithRel("loc", s.cnt.StoreTimeout(context.Background()), &spc)
+		return err
+	}
+	assert.NoError(t, create("space1"), "Creating space")
+	err := create("space2")
+	if assert.Error(t, err, "Limit reached") {
+		assert.IsType(t, &storage.LimitError{}, err)
+	}
+
+	e := ente.New()
+	e.Name = "ente"
+	e.SpaceID = xid.New()
+	_, _, err = crud.CreateWithRel("loc", s.cnt.StoreTimeout(context.Background()), &e)
+	assert.NoError(t, err, "Space not found")
+
+	found, err := s.PutQuotas(context.Background(), inst.ID, Quotas{Spaces: 2})
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Quotas changed")
+	}
+	assert.NoError(t, create("space2"), "Creating space after change the quotas")
+
+	usage, err := s.Usage(context.Background(), inst.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Quotas{Spaces: 2}, usage, "Usage")
+	}
+
+	found, err = s.PutQuotas(context.Background(), xid.New(), Quotas{})
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Instance not found")
+	}
+}
+
+func TestQuota_Recount(t *testing.T) {
+	s, q, _, mng := newQuotaFaked(t)
+	defer mng.Close()
+
+	inst := instance()
+	if _, err := s.Create(context.Background(), &inst); !assert.NoError(t, err) {
+		return
+	}
+	empty := instance()
+	empty.Name = "empty"
+	if _, err := s.Create(context.Background(), &empty); !assert.NoError(t, err) {
+		return
+	}
+
+	// Entities created before the counters existed
+	crud := storage.NewCrudOperation(mng.Store(), s.cnt.Log, storage.NewTxn)
+	storeTimeout := s.cnt.StoreTimeout(context.Background())
+	spc := space.New()
+	spc.Name = "space"
+	spc.InstID = inst.ID
+	if _, _, err := crud.CreateWithRel("loc", storeTimeout, &spc); !assert.NoError(t, err) {
+		return
+	}
+	for _, name := range []string{"ente1", "ente2"} {
+		e := ente.New()
+		e.Name = name
+		e.SpaceID = spc.ID
+		if _, _, err := crud.CreateWithRel("loc", storeTimeout, &e); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	changed, err := q.Recount(true)
+	if assert.NoError(t, err, "Dry run") {
+		assert.Equal(t, 2, changed, "Dry run")
+		usage, err := s.Usage(context.Background(), inst.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Quotas{}, usage, "Dry run usage")
+		}
+	}
+
+	changed, err = q.Recount(false)
+	if assert.NoError(t, err, "Recount") {
+		assert.Equal(t, 2, changed, "Counters changed")
+		usage, err := s.Usage(context.Background(), inst.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Quotas{Spaces: 1, Entes: 2}, usage, "Usage")
+		}
+		usage, err = s.Usage(context.Background(), empty.ID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Quotas{}, usage, "Usage of the empty instance")
+		}
+	}
+
+	changed, err = q.Recount(false)
+	if assert.NoError(t, err, "Recount again") {
+		assert.Zero(t, changed, "Counters not changed")
+	}
+}
+
+func TestQuotas_Valid(t *testing.T) {
+	assert.True(t, (&Quotas{Spaces: 1}).Valid(), "Valid")
+	assert.False(t, (&Quotas{Queries: -1}).Valid(), "Negative")
+}
+
+func newQuotaFaked(t *testing.T) (Service, Quota, storage.CrudOperation, storage.Integration) {
+	mng := mock.NewStorageFake(t)
+	cnt := mock.NewContainerFake()
+	ext := srv.NewExt(cnt, mng.Store())
+	q := NewQuota(cnt, ext, mng.Store())
+	crud := storage.NewCountedCrudOperation(mng.Store(), cnt.Log, storage.NewTxn, q.Counters)
+	return NewService(cnt, ext, crud), q, crud, mng
+}
@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/instance"
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/service"
@@ -30,6 +32,6 @@ func CreateInstance(mng storage.Integration) (instance.Instance, error) {
 	inst := instance.New()
 	inst.Name = "Name"
 	inst.Desc = "desc"
-	_, err := srv.Create(&inst)
+	_, err := srv.Create(context.Background(), &inst)
 	return inst, err
 }
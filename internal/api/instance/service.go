@@ -17,10 +17,13 @@
 package instance
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/relation"
 	"github.com/carisa/internal/api/runtime"
 	"github.com/carisa/internal/api/service"
+	"github.com/carisa/pkg/logging"
 	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
 	"github.com/rs/xid"
@@ -46,29 +49,57 @@ func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.Cru
 
 // Create creates a Instance into of the repository
 // If the instance exists returns false
-func (s *Service) Create(inst *Instance) (bool, error) {
+func (s *Service) Create(ctx context.Context, inst *Instance) (bool, error) {
 	inst.AutoID()
-	return s.crud.Create(locService, s.cnt.StoreWithTimeout, inst)
+	return s.crud.Create(locService, s.cnt.StoreTimeout(ctx), inst)
 }
 
 // Put creates or updates depending of if exists the Instance into storage
-// If the Instance is updated return true
-func (s *Service) Put(inst *Instance) (bool, error) {
-	return s.crud.Put(locService, s.cnt.StoreWithTimeout, inst)
+// If the Instance is updated return true.
+// The quotas of the Instance are only changed when it is created, see PutQuotas
+func (s *Service) Put(ctx context.Context, inst *Instance) (bool, error) {
+	var stored Instance
+	found, err := s.Get(ctx, inst.ID, &stored)
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "getting the quotas", locService, logging.String("id", inst.ID.String()))
+	}
+	if found {
+		inst.Quotas = stored.Quotas
+	}
+	return s.crud.Put(locService, s.cnt.StoreTimeout(ctx), inst)
+}
+
+// PutQuotas changes the quotas of the Instance. The entities created before are kept although they exceed the quotas.
+// If the Instance doesn't exist return false
+func (s *Service) PutQuotas(ctx context.Context, id xid.ID, quotas Quotas) (bool, error) {
+	inst := Instance{Descriptor: entity.Descriptor{ID: id}}
+	return s.crud.Update(locService, s.cnt.StoreTimeout(ctx), &inst, func(e storage.Entity) {
+		e.(*Instance).Quotas = quotas
+	})
+}
+
+// Usage gets the number of entities of the Instance counted for the quotas
+func (s *Service) Usage(ctx context.Context, id xid.ID) (Quotas, error) {
+	u, err := usage(ctx, s.cnt, s.crud.Store(), id)
+	if err != nil {
+		return Quotas{}, s.cnt.Log.ErrWrap1(err, "getting the usage of the quotas", locService, logging.String("id", id.String()))
+	}
+	return u, nil
 }
 
 // Get gets the Instance from storage
-func (s *Service) Get(id xid.ID, inst *Instance) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.InstKey(id), inst)
+func (s *Service) Get(ctx context.Context, id xid.ID, inst *Instance) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.InstKey(id), inst)
 	cancel()
 	return ok, err
 }
 
 // ListSpaces lists spaces depending ranges parameter.
 // Look at service.List
-func (s *Service) ListSpaces(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListSpaces(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		entity.InstKey(id),
 		strings.Concat(relation.InstSpaceLn, name),
 		ranges,
@@ -17,6 +17,7 @@
 package instance
 
 import (
+	"context"
 	"testing"
 
 	srv "github.com/carisa/internal/api/service"
@@ -38,7 +39,7 @@ func TestInstanceService_Create(t *testing.T) {
 	s, mng := newServiceFaked(t)
 	defer mng.Close()
 
-	ok, err := s.Create(&i)
+	ok, err := s.Create(context.Background(), &i)
 
 	if assert.NoError(t, err) {
 		assert.True(t, ok, "Created")
@@ -52,7 +53,7 @@ func TestInstanceService_Put(t *testing.T) {
 	defer mng.Close()
 
 	i.AutoID()
-	ok, err := s.Put(&i)
+	ok, err := s.Put(context.Background(), &i)
 
 	if assert.NoError(t, err) {
 		assert.False(t, ok, "Created")
@@ -60,9 +61,27 @@ func TestInstanceService_Put(t *testing.T) {
 	}
 }
 
+func TestInstanceService_PutKeepsQuotas(t *testing.T) {
+	i := instance()
+	i.Quotas = Quotas{Spaces: 1}
+	s, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	_, err := s.Create(context.Background(), &i)
+	if assert.NoError(t, err) {
+		i.Quotas = Quotas{Spaces: 10}
+		ok, err := s.Put(context.Background(), &i)
+		if assert.NoError(t, err) {
+			assert.True(t, ok, "Updated")
+			assert.Equal(t, Quotas{Spaces: 1}, i.Quotas, "Quotas kept")
+			checkInstance(t, s, i)
+		}
+	}
+}
+
 func checkInstance(t *testing.T, s Service, i Instance) {
 	var ir Instance
-	_, err := s.Get(i.ID, &ir)
+	_, err := s.Get(context.Background(), i.ID, &ir)
 	if assert.NoError(t, err) {
 		assert.Equal(t, i, ir, "Getting instance")
 	}
@@ -73,10 +92,10 @@ func TestInstanceService_Get(t *testing.T) {
 	s, mng := newServiceFaked(t)
 	defer mng.Close()
 
-	_, err := s.Create(&i)
+	_, err := s.Create(context.Background(), &i)
 	if assert.NoError(t, err) {
 		var geti Instance
-		ok, err := s.Get(i.ID, &geti)
+		ok, err := s.Get(context.Background(), i.ID, &geti)
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Get ok")
 			assert.Equal(t, i, geti, "Instance returned")
@@ -95,7 +114,7 @@ func TestInstanceService_ListSpaces(t *testing.T) {
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListSpaces(id, "name", tt.Ranges, 1)
+			list, err := s.ListSpaces(context.Background(), id, "name", tt.Ranges, 1)
 			if assert.NoError(t, err) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
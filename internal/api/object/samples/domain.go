@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/object"
 	"github.com/carisa/internal/api/plugin"
@@ -38,6 +40,6 @@ func CreateLink(
 	o.ContainerID = cntID
 	o.Category = cat
 	link := o.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, o, err
 }
@@ -17,6 +17,8 @@
 package object
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/plugin"
 	"github.com/carisa/internal/api/relation"
@@ -24,6 +26,7 @@ import (
 	"github.com/carisa/internal/api/service"
 	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
+	"github.com/carisa/pkg/tracing"
 	"github.com/rs/xid"
 )
 
@@ -48,57 +51,62 @@ func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.Cru
 }
 
 // Create creates a Instance into of the repository.
-// If the Instance exists return false in the first param returned.
-// If the plugin.Prototype doesn't exist return false in the second param returned.
-// If the container doesn't exist return false in the third param returned.
-func (s *Service) Create(inst *Instance) (bool, bool, bool, error) {
+// If the Instance exists return false.
+// If the plugin.Prototype doesn't exist returns service.ErrNotFound.
+// If the container doesn't exist returns service.ErrParentNotFound.
+func (s *Service) Create(ctx context.Context, inst *Instance) (bool, error) {
 	inst.AutoID()
+	ctx, span := tracing.Start(ctx, "object.create", tracing.String("key", inst.Key()))
+	defer span.End()
 
-	found, err := s.plugin.Exists(inst.ProtoID)
+	found, err := s.plugin.Exists(ctx, inst.ProtoID)
 	if err != nil {
-		return false, false, false, err
+		return false, err
 	}
 	if !found {
-		return false, false, false, nil
+		return false, protoNotFound
 	}
 
-	created, foundc, err := s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, inst)
-	return created, true, foundc, err
+	created, foundc, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), inst)
+	return service.WithParent(created, foundc, err, "container not found")
 }
 
 // Put creates or updates a Instance into of the repository.
-// If the Instance exists return true in the first param returned otherwise return false.
-// If the plugin.Prototype doesn't exist return false in the second param returned.
+// If the Instance exists return true otherwise return false.
+// If the plugin.Prototype doesn't exist returns service.ErrNotFound.
 // The plugin is only checked when the instance exists.
-// If the container doesn't exist return false in the third param returned.
-func (s *Service) Put(inst *Instance) (bool, bool, bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	foundi, err := s.crud.Store().Exists(ctx, entity.ObjectKey(inst.ID))
+// If the container doesn't exist returns service.ErrParentNotFound.
+func (s *Service) Put(ctx context.Context, inst *Instance) (bool, error) {
+	ctx, span := tracing.Start(ctx, "object.put", tracing.String("key", inst.Key()))
+	defer span.End()
+
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	foundi, err := s.crud.Store().Exists(sctx, entity.ObjectKey(inst.ID))
 	cancel()
 	if err != nil {
-		return false, false, false, err
+		return false, err
 	}
 	if !foundi { // The plugin is only checked when the instance exists
-		foundp, err := s.plugin.Exists(inst.ProtoID)
+		foundp, err := s.plugin.Exists(ctx, inst.ProtoID)
 		if err != nil {
-			return false, false, false, err
+			return false, err
 		}
 		if !foundp {
-			return false, false, false, nil
+			return false, protoNotFound
 		}
 	}
 
-	updated, foundc, err := s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, inst)
-	if err != nil {
-		return false, true, false, err
-	}
-	return updated, true, foundc, nil
+	updated, foundc, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), inst)
+	return service.WithParent(updated, foundc, err, "container not found")
 }
 
+// protoNotFound is returned when the plugin prototype of the instance doesn't exist
+var protoNotFound = service.ErrNotFound.With("the plugin prototype not found")
+
 // Get gets the Instance from storage
-func (s *Service) Get(id xid.ID, inst *Instance) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.ObjectKey(id), inst)
+func (s *Service) Get(ctx context.Context, id xid.ID, inst *Instance) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.ObjectKey(id), inst)
 	cancel()
 	return ok, err
 }
@@ -106,6 +114,7 @@ func (s *Service) Get(id xid.ID, inst *Instance) (bool, error) {
 // ListInstances lists queries depending ranges parameter.
 // Look at service.List
 func (s *Service) ListInstances(
+	ctx context.Context,
 	scheme string,
 	id xid.ID,
 	cat plugin.Category,
@@ -114,6 +123,7 @@ func (s *Service) ListInstances(
 	top int) ([]storage.Entity, error) {
 	//
 	return s.ext.List(
+		ctx,
 		entity.Key(scheme, id),
 		strings.Concat(string(cat), name),
 		ranges,
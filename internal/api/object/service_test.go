@@ -17,6 +17,7 @@
 package object
 
 import (
+	"context"
 	"testing"
 
 	"github.com/carisa/internal/api/entity"
@@ -55,12 +56,10 @@ func TestObjectService_Create(t *testing.T) {
 		return
 	}
 
-	ok, foundp, foundc, err := srv.Create(inst)
+	ok, err := srv.Create(context.Background(), inst)
 
 	if assert.NoError(t, err) {
 		assert.True(t, ok, "Created")
-		assert.True(t, foundp, "Checking plugin prototype")
-		assert.True(t, foundc, "Checking container")
 		checkInst(t, srv, "Checking relations", *inst)
 	}
 }
@@ -116,11 +115,9 @@ func TestObjectService_Put(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, foundp, foundc, err := srv.Put(tt.inst)
+		updated, err := srv.Put(context.Background(), tt.inst)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Instance updated"))
-			assert.True(t, foundp, strings.Concat(tt.name, "Seeking plugin"))
-			assert.True(t, foundc, strings.Concat(tt.name, "Seeking container"))
 			checkInst(t, srv, tt.name, *tt.inst)
 		}
 	}
@@ -128,7 +125,7 @@ func TestObjectService_Put(t *testing.T) {
 
 func checkInst(t *testing.T, srv Service, name string, inst Instance) {
 	var ir Instance
-	_, err := srv.Get(inst.ID, &ir)
+	_, err := srv.Get(context.Background(), inst.ID, &ir)
 	if assert.NoError(t, err) {
 		assert.Equal(t, inst, ir, "Getting instance")
 	}
@@ -149,10 +146,10 @@ func TestObjectService_Get(t *testing.T) {
 	inst, err := instance(mng, proto)
 
 	if assert.NoError(t, err) {
-		_, _, _, err := srv.Create(inst)
+		_, err := srv.Create(context.Background(), inst)
 		if assert.NoError(t, err) {
 			var get Instance
-			ok, err := srv.Get(inst.ID, &get)
+			ok, err := srv.Get(context.Background(), inst.ID, &get)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, inst, &get, "Instance returned")
@@ -175,14 +172,14 @@ func TestObjectService_ListQueries(t *testing.T) {
 	inst.Name = "namei"
 	link := inst.Link()
 
-	_, err := s.crud.Create("", s.cnt.StoreWithTimeout, link)
+	_, err := s.crud.Create("", s.cnt.StoreTimeout(context.Background()), link)
 	if err != nil {
 		assert.Error(t, err, "Create query link")
 		return
 	}
 
 	for _, tt := range tests {
-		list, err := s.ListInstances(inst.SchContainer, id, plugin.Query, "namei", tt.Ranges, 2)
+		list, err := s.ListInstances(context.Background(), inst.SchContainer, id, plugin.Query, "namei", tt.Ranges, 2)
 		if assert.NoError(t, err, tt.Name) {
 			assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 		}
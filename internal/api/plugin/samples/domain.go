@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/plugin"
 	"github.com/carisa/pkg/storage"
@@ -30,7 +32,7 @@ func CreatePlugin(mng storage.Integration, cat plugin.Category, id xid.ID) (plug
 	proto.Category = cat
 	proto.Name = "nameproto"
 	proto.Desc = "descproto"
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, &proto)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), &proto)
 	return proto, err
 }
 
@@ -41,6 +43,6 @@ func CreateLinkPlugin(mng storage.Integration, cat plugin.Category) (storage.Ent
 	proto.Name = "nameproto"
 	proto.Desc = "descproto"
 	link := proto.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, proto, err
 }
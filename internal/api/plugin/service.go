@@ -17,6 +17,8 @@
 package plugin
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/relation"
 	"github.com/carisa/internal/api/runtime"
@@ -47,31 +49,31 @@ func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.Cru
 
 // Create creates a plugin.Prototype into of the repository and links plugin.Prototype and platform.
 // If the plugin.Prototype exists return false in the first param returned.
-func (s *Service) Create(proto *Prototype) (bool, error) {
+func (s *Service) Create(ctx context.Context, proto *Prototype) (bool, error) {
 	proto.AutoID()
-	created, _, err := s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, proto)
+	created, _, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), proto)
 	return created, err
 }
 
 // Put creates or updates a plugin.Prototype into of the repository.
 // If the plugin.Prototype exists return true in the first param returned otherwise return false.
-func (s *Service) Put(proto *Prototype) (bool, error) {
-	updated, _, err := s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, proto)
+func (s *Service) Put(ctx context.Context, proto *Prototype) (bool, error) {
+	updated, _, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), proto)
 	return updated, err
 }
 
 // Get gets the plugin.Prototype from storage
-func (s *Service) Get(id xid.ID, proto *Prototype) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.PluginKey(id), proto)
+func (s *Service) Get(ctx context.Context, id xid.ID, proto *Prototype) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.PluginKey(id), proto)
 	cancel()
 	return ok, err
 }
 
 // Exists checks if the plugin.Prototype exists
-func (s *Service) Exists(id xid.ID) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	found, err := s.crud.Store().Exists(ctx, entity.PluginKey(id))
+func (s *Service) Exists(ctx context.Context, id xid.ID) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, err := s.crud.Store().Exists(sctx, entity.PluginKey(id))
 	if err != nil {
 		return false,
 			s.cnt.Log.ErrWrap1(
@@ -86,8 +88,9 @@ func (s *Service) Exists(id xid.ID) (bool, error) {
 
 // ListPlugins lists the plugin.Prototype depending 'ranges' parameter.
 // Look at service.List
-func (s *Service) ListPlugins(cat Category, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListPlugins(ctx context.Context, cat Category, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		storage.Virtual,
 		strings.Concat(string(cat), name),
 		ranges,
@@ -17,6 +17,7 @@
 package plugin
 
 import (
+	"context"
 	"testing"
 
 	"github.com/carisa/internal/api/samples"
@@ -40,7 +41,7 @@ func TestPluginService_Create(t *testing.T) {
 
 	proto := proto()
 
-	ok, err := srv.Create(proto)
+	ok, err := srv.Create(context.Background(), proto)
 
 	if assert.NoError(t, err) {
 		assert.True(t, ok, "Created")
@@ -84,7 +85,7 @@ func TestPluginService_Put(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, err := srv.Put(tt.proto)
+		updated, err := srv.Put(context.Background(), tt.proto)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Plugin updated"))
 			checkProto(t, srv, tt.name, *tt.proto)
@@ -94,7 +95,7 @@ func TestPluginService_Put(t *testing.T) {
 
 func checkProto(t *testing.T, srv Service, name string, proto Prototype) {
 	var pr Prototype
-	_, err := srv.Get(proto.ID, &pr)
+	_, err := srv.Get(context.Background(), proto.ID, &pr)
 	if assert.NoError(t, err) {
 		assert.Equal(t, proto, pr, "Getting plugin")
 	}
@@ -107,10 +108,10 @@ func TestPluginService_Get(t *testing.T) {
 
 	proto := proto()
 
-	_, err := srv.Create(proto)
+	_, err := srv.Create(context.Background(), proto)
 	if assert.NoError(t, err) {
 		var get Prototype
-		ok, err := srv.Get(proto.ID, &get)
+		ok, err := srv.Get(context.Background(), proto.ID, &get)
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Get ok")
 			assert.Equal(t, proto, &get, "Plugin returned")
@@ -128,11 +129,11 @@ func TestPluginService_ListPlugins(t *testing.T) {
 	proto.Name = "nameproto"
 	link := proto.Link()
 
-	_, err := s.crud.Create("", s.cnt.StoreWithTimeout, link)
+	_, err := s.crud.Create("", s.cnt.StoreTimeout(context.Background()), link)
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListPlugins(Query, "nameproto", tt.Ranges, 1)
+			list, err := s.ListPlugins(context.Background(), Query, "nameproto", tt.Ranges, 1)
 			if assert.NoError(t, err, tt.Name) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
@@ -146,9 +147,9 @@ func TestPluginService_Exists(t *testing.T) {
 
 	proto := proto()
 
-	_, err := srv.Create(proto)
+	_, err := srv.Create(context.Background(), proto)
 	if assert.NoError(t, err) {
-		found, err := srv.Exists(proto.ID)
+		found, err := srv.Exists(context.Background(), proto.ID)
 		if assert.NoError(t, err) {
 			assert.True(t, found, "Exists")
 		}
@@ -159,7 +160,7 @@ func TestPluginService_ExistsWithError(t *testing.T) {
 	srv, crud := newServiceMocked()
 	crud.Store().(*storage.ErrMockCRUD).Activate("Exists")
 
-	_, err := srv.Exists(xid.New())
+	_, err := srv.Exists(context.Background(), xid.New())
 	assert.Error(t, err)
 }
 
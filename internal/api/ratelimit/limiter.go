@@ -0,0 +1,223 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package ratelimit limits the requests of each client with a token bucket.
+// The buckets are kept into memory or into the store to share them among the replicas of the API
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net"
+	nethttp "net/http"
+	"strconv"
+	strs "strings"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/strings"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	locLimit = "ratelimit.middleware"
+	// headerRetryAfter is the http header with the seconds to wait until the request is accepted
+	headerRetryAfter = "Retry-After"
+)
+
+// Limiter takes the tokens from the buckets of the clients
+type Limiter interface {
+	// Take takes the cost from the bucket of the client. If the bucket has not enough tokens
+	// returns false and the time to wait until the bucket has them
+	Take(ctx context.Context, client string, cost int) (bool, time.Duration, error)
+	// Wait gets the time to wait until the bucket of the client has the cost without taking it
+	Wait(ctx context.Context, client string, cost int) (time.Duration, error)
+}
+
+// bucket is the token bucket of a client
+type bucket struct {
+	tokens float64
+	last   time.Time // Last refill
+}
+
+// newBucket creates a full bucket
+func newBucket(cnf runtime.RateLimit, now time.Time) bucket {
+	return bucket{tokens: cnf.Burst, last: now}
+}
+
+// refill adds the tokens generated since the last refill up to the burst
+func (b *bucket) refill(cnf runtime.RateLimit, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(cnf.Burst, b.tokens+elapsed.Seconds()*cnf.Rate)
+		b.last = now
+	}
+}
+
+// take refills the bucket and takes the cost. If there are not enough tokens returns false and the time to wait.
+// The cost greater than the burst is limited to the burst, otherwise the request would never be accepted
+func (b *bucket) take(cnf runtime.RateLimit, now time.Time, cost int) (bool, time.Duration) {
+	if wait := b.wait(cnf, now, cost); wait > 0 {
+		return false, wait
+	}
+	b.tokens -= math.Min(float64(cost), cnf.Burst)
+	return true, 0
+}
+
+// wait refills the bucket and gets the time to wait until the bucket has the cost
+func (b *bucket) wait(cnf runtime.RateLimit, now time.Time, cost int) time.Duration {
+	b.refill(cnf, now)
+	c := math.Min(float64(cost), cnf.Burst)
+	if b.tokens >= c {
+		return 0
+	}
+	return time.Duration((c - b.tokens) / cnf.Rate * float64(time.Second))
+}
+
+// full returns true if the bucket is full at now
+func (b *bucket) full(cnf runtime.RateLimit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*cnf.Rate >= cnf.Burst
+}
+
+// Proxies are the networks of the proxies trusted to forward the address of the client
+type Proxies []*net.IPNet
+
+// NewProxies parses the addresses or CIDR blocks of the trusted proxies. See runtime.RateLimit.TrustedProxies
+func NewProxies(cidrs []string) (Proxies, error) {
+	proxies := make(Proxies, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strs.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr = strings.Concat(cidr, "/32")
+			} else {
+				cidr = strings.Concat(cidr, "/128")
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusted returns true if the address is a trusted proxy
+func (p Proxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware limits the requests of each client. The rejected requests receive the status 429
+// and the header Retry-After with the seconds to wait.
+// If the limiter fails the request is accepted, the limit should not stop the API.
+// It must be installed after the authentication middleware because the client is the principal
+func Middleware(cnt *runtime.Container, limiter Limiter, proxies Proxies) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ok, wait, err := limiter.Take(
+				ctx.Request().Context(), Client(ctx, proxies), Cost(cnt.RateLimit, ctx.Request().Method, ctx.Path()))
+			if err != nil {
+				_ = cnt.Log.ErrWrap(err, "limiting the request", locLimit)
+				return next(ctx)
+			}
+			if !ok {
+				ctx.Response().Header().Set(headerRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return echo.NewHTTPError(nethttp.StatusTooManyRequests, "too many requests")
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// Failures limits the failed authentications of each address, so the clients with credentials not valid
+// don't reach the authenticators. Each request rejected with the status 401 takes a token from the bucket of
+// the address and the requests are rejected with the status 429 while the bucket is empty.
+// If the limiter fails the request is accepted.
+// It must be installed before the authentication middleware because the client is the address
+func Failures(cnt *runtime.Container, limiter Limiter, proxies Proxies) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			client := Client(ctx, proxies)
+			wait, err := limiter.Wait(ctx.Request().Context(), client, 1)
+			if err != nil {
+				_ = cnt.Log.ErrWrap(err, "limiting the authentication", locLimit)
+			}
+			if wait > 0 {
+				ctx.Response().Header().Set(headerRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return echo.NewHTTPError(nethttp.StatusTooManyRequests, "too many failed authentications")
+			}
+			err = next(ctx)
+			if he, ok := err.(*echo.HTTPError); ok && he.Code == nethttp.StatusUnauthorized {
+				if _, _, err := limiter.Take(ctx.Request().Context(), client, 1); err != nil {
+					_ = cnt.Log.ErrWrap(err, "limiting the authentication", locLimit)
+				}
+			}
+			return err
+		}
+	}
+}
+
+// Client gets the client of the request. It is the principal if it is authenticated or else the IP address.
+// The address is the remote address of the connection. If the connection comes from a trusted proxy
+// the address is the last address of the header X-Forwarded-For that is not a trusted proxy,
+// the previous addresses are set by the client and they can not be trusted
+func Client(ctx echo.Context, proxies Proxies) string {
+	if p, ok := ctx.Get(httpc.PrincipalKey).(httpc.Principal); ok && p.Authenticated() {
+		return strings.Concat("principal#", p.ID)
+	}
+	r := ctx.Request()
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !proxies.trusted(ip) {
+		return strings.Concat("ip#", ip)
+	}
+	forwarded := strs.Split(strs.Join(r.Header.Values(echo.HeaderXForwardedFor), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strs.TrimSpace(forwarded[i])
+		if len(addr) == 0 {
+			continue
+		}
+		ip = addr
+		if !proxies.trusted(addr) {
+			break
+		}
+	}
+	return strings.Concat("ip#", ip)
+}
+
+// Cost gets the cost of the route. The costs of the configuration have priority,
+// then the routes that list entities cost runtime.RateLimit.ListCost and the rest costs 1.
+// The routes that list are the GET routes ended without parameter, for example /api/spaces/:id/entes
+func Cost(cnf runtime.RateLimit, method string, path string) int {
+	if cost, ok := cnf.Costs[strings.Concat(method, " ", path)]; ok {
+		return cost
+	}
+	if method == nethttp.MethodGet && len(path) > 0 && !strs.HasPrefix(path[strs.LastIndex(path, "/")+1:], ":") {
+		return cnf.ListCost
+	}
+	return 1
+}
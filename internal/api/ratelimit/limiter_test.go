@@ -0,0 +1,258 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucket_Take(t *testing.T) {
+	cnf := runtime.RateLimit{Rate: 2, Burst: 4}
+	now := time.Now()
+	b := newBucket(cnf, now)
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		cost    int
+		allowed bool
+		wait    time.Duration
+	}{
+		{
+			name:    "Taking from full bucket.",
+			cost:    3,
+			allowed: true,
+		},
+		{
+			name:    "Taking without tokens.",
+			cost:    3,
+			allowed: false,
+			wait:    time.Second,
+		},
+		{
+			name:    "Taking after refill.",
+			elapsed: time.Second,
+			cost:    3,
+			allowed: true,
+		},
+		{
+			name:    "Taking greater than burst.",
+			elapsed: time.Hour,
+			cost:    10,
+			allowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.elapsed)
+		allowed, wait := b.take(cnf, now, tt.cost)
+		assert.Equal(t, tt.allowed, allowed, tt.name)
+		assert.Equal(t, tt.wait, wait, tt.name)
+	}
+	assert.False(t, b.full(cnf, now), "Empty")
+	assert.True(t, b.full(cnf, now.Add(2*time.Second)), "Refilled")
+}
+
+func TestLimiter_Cost(t *testing.T) {
+	cnf := runtime.RateLimit{ListCost: 5, Costs: map[string]int{"GET /api/admin/fsck": 50}}
+
+	tests := []struct {
+		method string
+		path   string
+		cost   int
+	}{
+		{method: nethttp.MethodGet, path: "/api/admin/fsck", cost: 50},
+		{method: nethttp.MethodGet, path: "/api/spaces/:id/entes", cost: 5},
+		{method: nethttp.MethodGet, path: "/api/categories/:id/child", cost: 5},
+		{method: nethttp.MethodGet, path: "/api/spaces/:id", cost: 1},
+		{method: nethttp.MethodPost, path: "/api/spaces", cost: 1},
+		{method: nethttp.MethodGet, path: "", cost: 1},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.cost, Cost(cnf, tt.method, tt.path), tt.path)
+	}
+}
+
+func TestLimiter_Client(t *testing.T) {
+	proxies, err := NewProxies([]string{"10.0.0.1", "192.168.0.0/16"})
+	if !assert.NoError(t, err, "Parsing proxies") {
+		return
+	}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		client    string
+	}{
+		{
+			name:   "Anonymous.",
+			remote: "10.0.0.2:1234",
+			client: "ip#10.0.0.2",
+		},
+		{
+			name:      "Header of a client not trusted.",
+			remote:    "10.0.0.2:1234",
+			forwarded: "1.1.1.1",
+			client:    "ip#10.0.0.2",
+		},
+		{
+			name:      "Header of a trusted proxy.",
+			remote:    "10.0.0.1:1234",
+			forwarded: "1.1.1.1",
+			client:    "ip#1.1.1.1",
+		},
+		{
+			name:      "Header of several proxies with address set by the client.",
+			remote:    "10.0.0.1:1234",
+			forwarded: "2.2.2.2, 1.1.1.1, 192.168.1.1",
+			client:    "ip#1.1.1.1",
+		},
+		{
+			name:   "Trusted proxy without header.",
+			remote: "10.0.0.1:1234",
+			client: "ip#10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(nethttp.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		if len(tt.forwarded) > 0 {
+			req.Header.Set(echo.HeaderXForwardedFor, tt.forwarded)
+		}
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		assert.Equal(t, tt.client, Client(ctx, proxies), tt.name)
+	}
+
+	ctx := echo.New().NewContext(httptest.NewRequest(nethttp.MethodGet, "/", nil), httptest.NewRecorder())
+	ctx.Set(httpc.PrincipalKey, httpc.Principal{ID: "user", Method: "apikey"})
+	assert.Equal(t, "principal#user", Client(ctx, nil), "Authenticated")
+
+	_, err = NewProxies([]string{"proxy"})
+	assert.Error(t, err, "Proxy not valid")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 2, ListCost: 2}
+
+	tests := []struct {
+		name    string
+		limiter Limiter
+		status  int
+		retry   string
+	}{
+		{
+			name:    "Request allowed.",
+			limiter: limiterFunc(func(context.Context, string, int) (bool, time.Duration, error) { return true, 0, nil }),
+			status:  nethttp.StatusOK,
+		},
+		{
+			name: "Request rejected.",
+			limiter: limiterFunc(func(context.Context, string, int) (bool, time.Duration, error) {
+				return false, 1500 * time.Millisecond, nil
+			}),
+			status: nethttp.StatusTooManyRequests,
+			retry:  "2",
+		},
+		{
+			name: "Limiter with error.",
+			limiter: limiterFunc(func(context.Context, string, int) (bool, time.Duration, error) {
+				return false, 0, errors.New("limiter")
+			}),
+			status: nethttp.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		e := echo.New()
+		e.Use(Middleware(cnt, tt.limiter, nil))
+		e.GET("/api/spaces/:id", func(ctx echo.Context) error { return ctx.NoContent(nethttp.StatusOK) })
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/api/spaces/id", nil))
+		assert.Equal(t, tt.status, rec.Code, tt.name)
+		assert.Equal(t, tt.retry, rec.Header().Get(headerRetryAfter), tt.name)
+	}
+}
+
+func TestLimiter_MiddlewareLocal(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 5, ListCost: 5}
+	e := echo.New()
+	e.Use(Middleware(cnt, NewLocal(cnt.RateLimit), nil))
+	e.GET("/api/spaces/:id/entes", func(ctx echo.Context) error { return ctx.NoContent(nethttp.StatusOK) })
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(nethttp.MethodGet, "/api/spaces/id/entes", nil))
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(t, []int{nethttp.StatusOK, nethttp.StatusTooManyRequests}, codes, "The list costs the burst")
+}
+
+func TestLimiter_Failures(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 2}
+	e := echo.New()
+	e.Use(Failures(cnt, NewLocal(cnt.RateLimit), nil))
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if ctx.Request().Header.Get(echo.HeaderAuthorization) != "valid" {
+				return echo.NewHTTPError(nethttp.StatusUnauthorized, "not valid")
+			}
+			return next(ctx)
+		}
+	})
+	e.GET("/api/spaces/:id", func(ctx echo.Context) error { return ctx.NoContent(nethttp.StatusOK) })
+
+	codes := make([]int, 0, 4)
+	for _, credentials := range []string{"valid", "other", "other", "valid"} {
+		req := httptest.NewRequest(nethttp.MethodGet, "/api/spaces/id", nil)
+		req.Header.Set(echo.HeaderAuthorization, credentials)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(
+		t,
+		[]int{nethttp.StatusOK, nethttp.StatusUnauthorized, nethttp.StatusUnauthorized, nethttp.StatusTooManyRequests},
+		codes,
+		"The failed authentications empty the bucket of the address")
+}
+
+type limiterFunc func(ctx context.Context, client string, cost int) (bool, time.Duration, error)
+
+func (f limiterFunc) Take(ctx context.Context, client string, cost int) (bool, time.Duration, error) {
+	return f(ctx, client, cost)
+}
+
+func (f limiterFunc) Wait(context.Context, string, int) (time.Duration, error) {
+	return 0, nil
+}
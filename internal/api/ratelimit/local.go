@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+)
+
+// sweepInterval is the interval to remove the full buckets from memory
+const sweepInterval = time.Minute
+
+// Local keeps the buckets into memory. Each replica of the API limits its own requests
+type Local struct {
+	cnf     runtime.RateLimit
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewLocal creates the limiter into memory
+func NewLocal(cnf runtime.RateLimit) *Local {
+	return &Local{
+		cnf:     cnf,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		swept:   time.Now(),
+	}
+}
+
+// Take implements Limiter.Take
+func (l *Local) Take(_ context.Context, client string, cost int) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[client]
+	if !ok {
+		nb := newBucket(l.cnf, now)
+		b = &nb
+		l.buckets[client] = b
+	}
+	allowed, wait := b.take(l.cnf, now, cost)
+	return allowed, wait, nil
+}
+
+// Wait implements Limiter.Wait
+func (l *Local) Wait(_ context.Context, client string, cost int) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[client]
+	if !ok {
+		return 0, nil
+	}
+	c := *b
+	return c.wait(l.cnf, l.now(), cost), nil
+}
+
+// sweep removes the full buckets because they are the same that a new bucket
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	for client, b := range l.buckets {
+		if b.full(l.cnf, now) {
+			delete(l.buckets, client)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal_Take(t *testing.T) {
+	now := time.Now()
+	l := NewLocal(runtime.RateLimit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := l.Take(context.Background(), "c1", 1)
+		if assert.NoError(t, err) {
+			assert.True(t, allowed, "Burst")
+		}
+	}
+	allowed, wait, err := l.Take(context.Background(), "c1", 1)
+	if assert.NoError(t, err) {
+		assert.False(t, allowed, "Bucket empty")
+		assert.Equal(t, time.Second, wait, "Wait")
+	}
+	wait, err = l.Wait(context.Background(), "c1", 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Second, wait, "Wait without taking")
+	}
+	wait, err = l.Wait(context.Background(), "c2", 1)
+	if assert.NoError(t, err) {
+		assert.Zero(t, wait, "Wait of a new bucket")
+	}
+	allowed, _, err = l.Take(context.Background(), "c2", 1)
+	if assert.NoError(t, err) {
+		assert.True(t, allowed, "Other client")
+	}
+
+	now = now.Add(time.Second)
+	allowed, _, err = l.Take(context.Background(), "c1", 1)
+	if assert.NoError(t, err) {
+		assert.True(t, allowed, "Refilled")
+	}
+}
+
+func TestLocal_Sweep(t *testing.T) {
+	now := time.Now()
+	l := NewLocal(runtime.RateLimit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+	l.swept = now
+
+	_, _, _ = l.Take(context.Background(), "c1", 2)
+	_, _, _ = l.Take(context.Background(), "c2", 1)
+	now = now.Add(sweepInterval - time.Second)
+	_, _, _ = l.Take(context.Background(), "c1", 2)
+	assert.Len(t, l.buckets, 2, "Not swept before the interval")
+
+	now = now.Add(time.Second)
+	_, _, _ = l.Take(context.Background(), "c3", 1)
+	assert.Len(t, l.buckets, 2, "Full buckets removed")
+	assert.Contains(t, l.buckets, "c1", "Bucket taken one second ago")
+	assert.Contains(t, l.buckets, "c3", "New bucket")
+}
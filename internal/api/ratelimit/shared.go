@@ -0,0 +1,182 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	strs "strings"
+	"sync"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+const (
+	locShared = "ratelimit.shared"
+	// retries is the number of attempts to update the bucket when other replica updates it at the same time
+	retries = 5
+	sep     = ":"
+)
+
+// errConflict is returned when the bucket is updated by other replicas in all attempts
+var errConflict = errors.New("the bucket has been changed by other replicas")
+
+// Shared keeps the buckets into the store to share them among the replicas of the API.
+// The bucket is updated with compare-and-swap, so the limit is exact although there are several replicas.
+// See storage.Txn.Match.
+// The buckets are attached to a lease, so the buckets of the clients that stop sending requests are removed
+// when they are full again, see Shared.lease
+type Shared struct {
+	cnt    *runtime.Container
+	store  storage.CRUD
+	now    func() time.Time
+	window *window
+}
+
+// window is the lease attached to the buckets updated into the refill window
+type window struct {
+	mu    sync.Mutex
+	id    storage.LeaseID
+	renew time.Time // Time to grant a new lease
+}
+
+// NewShared creates the limiter into the store. The store must not be a namespace
+// because the clients are shared by all tenants
+func NewShared(cnt *runtime.Container, store storage.CRUD) Shared {
+	return Shared{
+		cnt:    cnt,
+		store:  store,
+		now:    time.Now,
+		window: &window{},
+	}
+}
+
+// Take implements Limiter.Take
+func (s Shared) Take(ctx context.Context, client string, cost int) (bool, time.Duration, error) {
+	key := entity.RateLimitKey(client)
+	cnf := s.cnt.RateLimit
+	for i := 0; i < retries; i++ {
+		sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+		found, old, err := s.store.GetRaw(sctx, key)
+		cancel()
+		if err != nil {
+			return false, 0, s.cnt.Log.ErrWrap1(err, "getting the bucket", locShared, logging.String("client", client))
+		}
+
+		now := s.now()
+		b := newBucket(cnf, now)
+		if found {
+			if b, err = decode(old); err != nil {
+				return false, 0, s.cnt.Log.ErrWrap1(err, "decoding the bucket", locShared, logging.String("client", client))
+			}
+		}
+		allowed, wait := b.take(cnf, now, cost)
+		if !allowed {
+			return false, wait, nil
+		}
+		lease, err := s.lease(ctx, now)
+		if err != nil {
+			return false, 0, s.cnt.Log.ErrWrap1(err, "granting the lease of the bucket", locShared, logging.String("client", client))
+		}
+
+		txn := storage.NewTxn(s.store)
+		txn.Match(key, old)
+		txn.DoFound(s.store.PutRawLease(key, encode(b), lease))
+		sctx, cancel = s.cnt.StoreWithTimeout(ctx)
+		swapped, err := txn.Commit(sctx)
+		cancel()
+		if err != nil {
+			return false, 0, s.cnt.Log.ErrWrap1(err, "updating the bucket", locShared, logging.String("client", client))
+		}
+		if swapped {
+			return true, 0, nil
+		}
+	}
+	return false, 0, s.cnt.Log.ErrWrap1(errConflict, "updating the bucket", locShared, logging.String("client", client))
+}
+
+// Wait implements Limiter.Wait
+func (s Shared) Wait(ctx context.Context, client string, cost int) (time.Duration, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, value, err := s.store.GetRaw(sctx, entity.RateLimitKey(client))
+	cancel()
+	if err != nil {
+		return 0, s.cnt.Log.ErrWrap1(err, "getting the bucket", locShared, logging.String("client", client))
+	}
+	if !found {
+		return 0, nil
+	}
+	b, err := decode(value)
+	if err != nil {
+		return 0, s.cnt.Log.ErrWrap1(err, "decoding the bucket", locShared, logging.String("client", client))
+	}
+	return b.wait(s.cnt.RateLimit, s.now(), cost), nil
+}
+
+// lease gets the lease attached to the buckets. The lease lives two refill windows and a new lease
+// is granted every window, so a bucket lives at least a refill window since its last update.
+// After a refill window the bucket is full, the same as a new bucket.
+// The refill window is the time to fill an empty bucket and it is one second at least
+func (s Shared) lease(ctx context.Context, now time.Time) (storage.LeaseID, error) {
+	s.window.mu.Lock()
+	defer s.window.mu.Unlock()
+	if s.window.id != 0 && now.Before(s.window.renew) {
+		return s.window.id, nil
+	}
+
+	cnf := s.cnt.RateLimit
+	refill := time.Duration(cnf.Burst / cnf.Rate * float64(time.Second))
+	if refill < time.Second {
+		refill = time.Second
+	}
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	id, err := s.store.Grant(sctx, 2*refill)
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	s.window.id = id
+	s.window.renew = now.Add(refill)
+	return id, nil
+}
+
+// encode encodes the bucket as 'tokens:last' where last is the unix time in nanoseconds
+func encode(b bucket) string {
+	return strings.Concat(strconv.FormatFloat(b.tokens, 'g', -1, 64), sep, strconv.FormatInt(b.last.UnixNano(), 10))
+}
+
+func decode(value string) (bucket, error) {
+	i := strs.Index(value, sep)
+	if i < 0 {
+		return bucket{}, errors.New("the bucket has not the format 'tokens:last'")
+	}
+	tokens, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil {
+		return bucket{}, err
+	}
+	last, err := strconv.ParseInt(value[i+1:], 10, 64)
+	if err != nil {
+		return bucket{}, err
+	}
+	return bucket{tokens: tokens, last: time.Unix(0, last)}, nil
+}
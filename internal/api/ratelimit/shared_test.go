@@ -0,0 +1,134 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShared_Take(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 3}
+
+	now := time.Now()
+	replicas := []Shared{NewShared(cnt, mng.Store()), NewShared(cnt, mng.Store())}
+	for i := range replicas {
+		replicas[i].now = func() time.Time { return now }
+	}
+
+	for i := 0; i < 3; i++ {
+		allowed, _, err := replicas[i%2].Take(context.Background(), "client", 1)
+		if assert.NoError(t, err) {
+			assert.True(t, allowed, "Burst shared by replicas")
+		}
+	}
+	allowed, wait, err := replicas[1].Take(context.Background(), "client", 1)
+	if assert.NoError(t, err) {
+		assert.False(t, allowed, "Bucket empty")
+		assert.Equal(t, time.Second, wait, "Wait")
+	}
+	wait, err = replicas[0].Wait(context.Background(), "client", 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Second, wait, "Wait without taking")
+	}
+	wait, err = replicas[0].Wait(context.Background(), "other", 1)
+	if assert.NoError(t, err) {
+		assert.Zero(t, wait, "Wait of a new bucket")
+	}
+
+	now = now.Add(time.Second)
+	allowed, _, err = replicas[0].Take(context.Background(), "client", 1)
+	if assert.NoError(t, err) {
+		assert.True(t, allowed, "Refilled")
+	}
+
+	// The bucket is removed with its lease
+	lease := replicas[0].window.id
+	if assert.NoError(t, mng.Store().Revoke(context.Background(), lease), "Revoking the lease") {
+		found, _, err := mng.Store().GetRaw(context.Background(), entity.RateLimitKey("client"))
+		if assert.NoError(t, err) {
+			assert.False(t, found, "Bucket removed")
+		}
+	}
+
+	// A new lease is granted every refill window
+	now = now.Add(3 * time.Second)
+	if _, _, err = replicas[0].Take(context.Background(), "client", 1); assert.NoError(t, err) {
+		assert.NotEqual(t, lease, replicas[0].window.id, "New lease")
+	}
+}
+
+func TestShared_Conflict(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 1000}
+
+	// Other replica changes the bucket between the read and the swap
+	var last time.Time
+	s := NewShared(cnt, mng.Store())
+	s.now = func() time.Time {
+		last = last.Add(time.Millisecond)
+		ctx, cancel := cnt.StoreWithTimeout(context.Background())
+		defer cancel()
+		txn := storage.NewTxn(mng.Store())
+		txn.Find("none")
+		txn.DoNotFound(mng.Store().PutRaw(entity.RateLimitKey("client"), encode(bucket{tokens: 10, last: last})))
+		_, _ = txn.Commit(ctx)
+		return last
+	}
+	_, _, err := s.Take(context.Background(), "client", 1)
+	assert.Error(t, err)
+}
+
+func TestShared_TakeWithError(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.RateLimit = runtime.RateLimit{Rate: 1, Burst: 1}
+
+	store := &storage.ErrMockCRUD{}
+	store.Activate("Get")
+	_, _, err := NewShared(cnt, store).Take(context.Background(), "client", 1)
+	assert.Error(t, err, "Getting the bucket")
+
+	store.Clear()
+	_, _, err = NewShared(cnt, store).Take(context.Background(), "client", 1)
+	assert.Error(t, err, "Decoding the bucket")
+}
+
+func TestShared_Decode(t *testing.T) {
+	b := bucket{tokens: 1.5, last: time.Unix(0, 1600000000000000000)}
+	d, err := decode(encode(b))
+	if assert.NoError(t, err) {
+		assert.Equal(t, b.tokens, d.tokens, "Tokens")
+		assert.True(t, b.last.Equal(d.last), "Last")
+	}
+
+	for _, value := range []string{"1.5", "a:1", "1:a"} {
+		_, err = decode(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	nethttp "net/http"
+	strs "strings"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/service"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+)
+
+const locAuthz = "rbac.authorizer"
+
+var errBodyTooLarge = errors.New("the body is too large")
+
+// Authorizer checks that the principal of the request has the role that the request needs.
+// The roles are only checked if the authentication is enabled. See runtime.Auth
+type Authorizer struct {
+	cnt    *runtime.Container
+	ext    *service.Extension
+	grants Grants
+}
+
+// NewAuthorizer builds the authorizer
+func NewAuthorizer(cnt *runtime.Container, ext *service.Extension, grants Grants) Authorizer {
+	return Authorizer{
+		cnt:    cnt,
+		ext:    ext,
+		grants: grants,
+	}
+}
+
+// Authorize checks the permission of the request. The entity of each target is resolved and the roles
+// granted over its space.Space and instance.Instance are checked. It returns the http error if the request is rejected
+func (a *Authorizer) Authorize(ctx echo.Context, perm Permission) error {
+	if !a.cnt.Auth.Enabled {
+		return nil
+	}
+	p, _ := ctx.Get(httpc.PrincipalKey).(httpc.Principal)
+	if !p.Authenticated() {
+		return echo.NewHTTPError(nethttp.StatusUnauthorized, "the request has not credentials")
+	}
+	if a.cnt.Auth.Admin(p.ID) {
+		return nil
+	}
+
+	for _, target := range perm.targets() {
+		if err := a.authorize(ctx, p, perm.role, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// authorize checks that the principal has the role over the target
+func (a *Authorizer) authorize(ctx echo.Context, p httpc.Principal, role Role, target Target) error {
+	key, err := a.target(ctx, target)
+	if err == errBodyTooLarge {
+		return echo.NewHTTPError(nethttp.StatusRequestEntityTooLarge, "the body of the request is too large")
+	}
+	if err != nil {
+		_ = a.cnt.Log.ErrWrap(err, "resolving the target of the request", locAuthz)
+		return echo.NewHTTPError(nethttp.StatusInternalServerError, "it was impossible to authorize the request")
+	}
+	if len(key) == 0 {
+		if target.platform && role == Viewer {
+			return nil
+		}
+		return forbidden(role)
+	}
+
+	ok, err := a.granted(ctx.Request().Context(), key, p.ID, role)
+	if err != nil {
+		_ = a.cnt.Log.ErrWrap(err, "checking the roles granted", locAuthz)
+		return echo.NewHTTPError(nethttp.StatusInternalServerError, "it was impossible to authorize the request")
+	}
+	if !ok {
+		return forbidden(role)
+	}
+	return nil
+}
+
+func forbidden(role Role) error {
+	return echo.NewHTTPError(nethttp.StatusForbidden, "the request needs the role: "+string(role))
+}
+
+// target gets the key of the first entity of the target that exists. If no one exists return empty
+func (a *Authorizer) target(ctx echo.Context, target Target) (string, error) {
+	var body map[string]interface{}
+	for _, src := range target.sources {
+		var value string
+		if len(src.param) > 0 {
+			value = ctx.Param(src.param)
+		} else {
+			if body == nil {
+				var err error
+				if body, err = readBody(ctx.Request(), a.cnt.Auth.MaxBodyBytes); err != nil {
+					if err == errBodyTooLarge {
+						return "", err
+					}
+					return "", nil // The body is not valid, so the target is not found
+				}
+			}
+			value, _ = body[src.field].(string)
+		}
+		// Only the identifiers are allowed to avoid reaching other keys
+		id, err := xid.FromString(value)
+		if err != nil {
+			continue
+		}
+		for _, scheme := range src.schemes {
+			key := entity.Key(scheme, id)
+			reqCtx, cancel := a.cnt.StoreWithTimeout(ctx.Request().Context())
+			found, err := a.grants.crud.Store().Exists(reqCtx, key)
+			cancel()
+			if err != nil {
+				return "", err
+			}
+			if found {
+				return key, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// readBody decodes the body and restores it for the handler.
+// If the body is bigger than limit bytes returns errBodyTooLarge
+func readBody(r *nethttp.Request, limit int64) (map[string]interface{}, error) {
+	body := make(map[string]interface{})
+	if r.Body == nil {
+		return body, nil
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errBodyTooLarge
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if len(data) == 0 {
+		return body, nil
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// granted checks the roles granted over the entity and her spaces and instances
+func (a *Authorizer) granted(ctx context.Context, key string, principal string, role Role) (bool, error) {
+	ancestors, err := a.ext.Ancestors(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	for _, scope := range append([]string{key}, ancestors...) {
+		if !scoped(scope) {
+			continue
+		}
+		granted, found, err := a.grants.Role(ctx, scope, principal)
+		if err != nil {
+			return false, err
+		}
+		if found && granted.Includes(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// scoped returns true if the roles can be granted over the entity
+func scoped(key string) bool {
+	return len(key) == len(entity.SchInstance)+len(xid.NilID().String()) &&
+		(strs.HasPrefix(key, entity.SchInstance) || strs.HasPrefix(key, entity.SchSpace))
+}
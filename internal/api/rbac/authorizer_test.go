@@ -0,0 +1,310 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package rbac
+
+import (
+	"context"
+	"io/ioutil"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carisa/internal/api/ente"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance/samples"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/internal/api/space"
+	httpc "github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizer_Authorize(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+	cnt.Auth.Enabled = true
+	cnt.Auth.Admins = []string{"admin"}
+	grants := NewGrants(cnt, crud)
+	authz := NewAuthorizer(cnt, service.NewExt(cnt, crud.Store()), grants)
+
+	inst, err := samples.CreateInstance(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	spc := createSpace(t, cnt.StoreTimeout(context.Background()), crud, inst.ID)
+	other := createSpace(t, cnt.StoreTimeout(context.Background()), crud, inst.ID)
+	e := ente.New()
+	e.Name = "ente"
+	e.Desc = "desc"
+	e.SpaceID = spc.ID
+	if _, _, err := crud.CreateWithRel("loc", cnt.StoreTimeout(context.Background()), &e); !assert.NoError(t, err) {
+		return
+	}
+
+	for _, g := range []Grant{
+		{Scope: inst.Key(), Principal: "viewer", Role: Viewer},
+		{Scope: spc.Key(), Principal: "modeler", Role: Modeler},
+	} {
+		g := g
+		if _, err := grants.Put(context.Background(), &g); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	enteTarget := Param("id", entity.SchEnte)
+	tests := []struct {
+		name      string
+		principal string
+		perm      Permission
+		id        string
+		body      string
+		status    int
+	}{
+		{
+			name:   "Anonymous.",
+			perm:   Platform().Viewer(),
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:      "Admin of the platform.",
+			principal: "admin",
+			perm:      Platform().Admin(),
+		},
+		{
+			name:      "Viewer of the platform.",
+			principal: "user",
+			perm:      Platform().Viewer(),
+		},
+		{
+			name:      "Admin of the platform. Forbidden.",
+			principal: "viewer",
+			perm:      Platform().Admin(),
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Viewer of the instance reads the ente.",
+			principal: "viewer",
+			perm:      enteTarget.Viewer(),
+			id:        e.ID.String(),
+		},
+		{
+			name:      "Viewer of the instance modifies the ente.",
+			principal: "viewer",
+			perm:      enteTarget.Modeler(),
+			id:        e.ID.String(),
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Modeler of the space modifies the ente of the space.",
+			principal: "modeler",
+			perm:      enteTarget.Modeler(),
+			id:        e.ID.String(),
+		},
+		{
+			name:      "Modeler of the space reads other space.",
+			principal: "modeler",
+			perm:      Param("id", entity.SchSpace).Viewer(),
+			id:        other.ID.String(),
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Modeler of the space creates an ente into the space.",
+			principal: "modeler",
+			perm:      Body("spaceId", entity.SchSpace).Modeler(),
+			body:      `{"name":"name","spaceId":"` + spc.ID.String() + `"}`,
+		},
+		{
+			name:      "Modeler of the space creates a space into the instance.",
+			principal: "modeler",
+			perm:      Body("instanceId", entity.SchInstance).Modeler(),
+			body:      `{"name":"name","instanceId":"` + inst.ID.String() + `"}`,
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Modeler of the space puts a new ente into the space.",
+			principal: "modeler",
+			perm:      enteTarget.Or(Body("spaceId", entity.SchSpace)).Modeler(),
+			id:        xid.New().String(),
+			body:      `{"spaceId":"` + spc.ID.String() + `"}`,
+		},
+		{
+			name:      "Target not found.",
+			principal: "viewer",
+			perm:      enteTarget.Viewer(),
+			id:        xid.New().String(),
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Identifier not valid.",
+			principal: "viewer",
+			perm:      enteTarget.Viewer(),
+			id:        "#R#",
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Body not valid.",
+			principal: "modeler",
+			perm:      Body("spaceId", entity.SchSpace).Modeler(),
+			body:      `{"spaceId":`,
+			status:    nethttp.StatusForbidden,
+		},
+		{
+			name:      "Body too large.",
+			principal: "modeler",
+			perm:      Body("spaceId", entity.SchSpace).Modeler(),
+			body:      `{"spaceId":"` + spc.ID.String() + `","desc":"` + strings.Repeat("d", 100) + `"}`,
+			status:    nethttp.StatusRequestEntityTooLarge,
+		},
+	}
+	cnt.Auth.MaxBodyBytes = 100
+
+	ec := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest(nethttp.MethodPost, "/", strings.NewReader(tt.body))
+		ctx := ec.NewContext(req, httptest.NewRecorder())
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(tt.id)
+		if len(tt.principal) > 0 {
+			ctx.Set(httpc.PrincipalKey, httpc.Principal{ID: tt.principal, Method: "apikey"})
+		}
+
+		err := authz.Authorize(ctx, tt.perm)
+		if tt.status != 0 {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.name) && len(tt.body) > 0 {
+			body, _ := ioutil.ReadAll(ctx.Request().Body)
+			assert.Equal(t, tt.body, string(body), "The body is restored. "+tt.name)
+		}
+	}
+
+	cnt.Auth.Enabled = false
+	assert.NoError(t, authz.Authorize(ec.NewContext(httptest.NewRequest(nethttp.MethodGet, "/", nil), nil), Platform().Admin()),
+		"Authentication disabled")
+}
+
+func TestAuthorizer_AuthorizeBoth(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+	cnt.Auth.Enabled = true
+	grants := NewGrants(cnt, crud)
+	authz := NewAuthorizer(cnt, service.NewExt(cnt, crud.Store()), grants)
+
+	inst, err := samples.CreateInstance(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+	spc := createSpace(t, cnt.StoreTimeout(context.Background()), crud, inst.ID)
+	other := createSpace(t, cnt.StoreTimeout(context.Background()), crud, inst.ID)
+	var entes [3]ente.Ente
+	for i, spcID := range []xid.ID{spc.ID, spc.ID, other.ID} {
+		entes[i] = ente.New()
+		entes[i].Name = xid.New().String()
+		entes[i].Desc = "desc"
+		entes[i].SpaceID = spcID
+		if _, _, err := crud.CreateWithRel("loc", cnt.StoreTimeout(context.Background()), &entes[i]); !assert.NoError(t, err) {
+			return
+		}
+	}
+	g := Grant{Scope: spc.Key(), Principal: "modeler", Role: Modeler}
+	if _, err := grants.Put(context.Background(), &g); !assert.NoError(t, err) {
+		return
+	}
+
+	perm := Param("id", entity.SchEnte).Modeler().And(Param("linkid", entity.SchEnte))
+	tests := []struct {
+		name   string
+		id     xid.ID
+		linkID xid.ID
+		status int
+	}{
+		{
+			name:   "Both allowed.",
+			id:     entes[0].ID,
+			linkID: entes[1].ID,
+		},
+		{
+			name:   "Only the first allowed.",
+			id:     entes[0].ID,
+			linkID: entes[2].ID,
+			status: nethttp.StatusForbidden,
+		},
+		{
+			name:   "Only the second allowed.",
+			id:     entes[2].ID,
+			linkID: entes[0].ID,
+			status: nethttp.StatusForbidden,
+		},
+		{
+			name:   "Second not found.",
+			id:     entes[0].ID,
+			linkID: xid.New(),
+			status: nethttp.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		ctx := echo.New().NewContext(httptest.NewRequest(nethttp.MethodPut, "/", nil), httptest.NewRecorder())
+		ctx.SetParamNames("id", "linkid")
+		ctx.SetParamValues(tt.id.String(), tt.linkID.String())
+		ctx.Set(httpc.PrincipalKey, httpc.Principal{ID: "modeler", Method: "apikey"})
+
+		err := authz.Authorize(ctx, perm)
+		if tt.status == 0 {
+			assert.NoError(t, err, tt.name)
+			continue
+		}
+		if assert.Error(t, err, tt.name) {
+			assert.Equal(t, tt.status, err.(*echo.HTTPError).Code, tt.name)
+		}
+	}
+}
+
+func TestAuthorizer_AuthorizeWithError(t *testing.T) {
+	cnt := mock.NewContainerFake()
+	cnt.Auth.Enabled = true
+	crud := storage.NewErrMockCRUDOper()
+	crud.Store().(*storage.ErrMockCRUD).Activate("Exists")
+	authz := NewAuthorizer(cnt, service.NewExt(cnt, crud.Store()), NewGrants(cnt, crud))
+
+	ctx := echo.New().NewContext(httptest.NewRequest(nethttp.MethodGet, "/", nil), httptest.NewRecorder())
+	ctx.SetParamNames("id")
+	ctx.SetParamValues(xid.New().String())
+	ctx.Set(httpc.PrincipalKey, httpc.Principal{ID: "user", Method: "apikey"})
+
+	err := authz.Authorize(ctx, Param("id", entity.SchEnte).Viewer())
+	if assert.Error(t, err) {
+		assert.Equal(t, nethttp.StatusInternalServerError, err.(*echo.HTTPError).Code)
+	}
+}
+
+func createSpace(t *testing.T, timeout storage.StoreWithTimeout, crud storage.CrudOperation, instID xid.ID) space.Space {
+	spc := space.New()
+	spc.Name = xid.New().String()
+	spc.Desc = "desc"
+	spc.InstID = instID
+	_, _, err := crud.CreateWithRel("loc", timeout, &spc)
+	assert.NoError(t, err)
+	return spc
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package rbac
+
+import (
+	"context"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+const locGrant = "rbac.grant"
+
+// Grant is a role granted to the principal over the scope (instance.Instance or space.Space key)
+type Grant struct {
+	Scope     string `json:"scope"`
+	Principal string `json:"principal"`
+	Role      Role   `json:"role"`
+	GrantedBy string `json:"grantedBy,omitempty"`
+}
+
+func (g *Grant) ToString() string {
+	return strings.Concat("grant: scope:", g.Scope, ", principal:", g.Principal, ", role:", string(g.Role))
+}
+
+func (g *Grant) Key() string {
+	return entity.GrantKey(g.Scope, g.Principal)
+}
+
+// Grants manages the roles granted
+type Grants struct {
+	cnt  *runtime.Container
+	crud storage.CrudOperation
+}
+
+// NewGrants builds the grant service
+func NewGrants(cnt *runtime.Container, crud storage.CrudOperation) Grants {
+	return Grants{
+		cnt:  cnt,
+		crud: crud,
+	}
+}
+
+// Put grants the role to the principal over the scope replacing the role granted before.
+// If the scope doesn't exist return false
+func (g *Grants) Put(ctx context.Context, grant *Grant) (bool, error) {
+	put, err := g.crud.Store().Put(grant)
+	if err != nil {
+		return false, g.cnt.Log.ErrWrap1(err, "encoding the grant", locGrant, logging.String("grant", grant.ToString()))
+	}
+	txn := storage.NewTxn(g.crud.Store())
+	txn.Find(grant.Scope)
+	txn.DoFound(put)
+	sctx, cancel := g.cnt.StoreWithTimeout(ctx)
+	found, err := txn.Commit(sctx)
+	cancel()
+	if err != nil {
+		return false, g.cnt.Log.ErrWrap1(err, "granting the role", locGrant, logging.String("grant", grant.ToString()))
+	}
+	return found, nil
+}
+
+// Revoke removes the role granted to the principal over the scope. If the grant doesn't exist return false
+func (g *Grants) Revoke(ctx context.Context, scope string, principal string) (bool, error) {
+	key := entity.GrantKey(scope, principal)
+	txn := storage.NewTxn(g.crud.Store())
+	txn.Find(key)
+	txn.DoFound(g.crud.Store().Remove(key))
+	sctx, cancel := g.cnt.StoreWithTimeout(ctx)
+	found, err := txn.Commit(sctx)
+	cancel()
+	if err != nil {
+		return false, g.cnt.Log.ErrWrap1(err, "revoking the role", locGrant, logging.String("key", key))
+	}
+	return found, nil
+}
+
+// List lists the roles granted over the scope
+func (g *Grants) List(ctx context.Context, scope string) ([]storage.Entity, error) {
+	sctx, cancel := g.cnt.StoreWithTimeout(ctx)
+	grants, err := g.crud.Store().StartKey(sctx, entity.GrantKey(scope, ""), 0, func() storage.Entity { return &Grant{} })
+	cancel()
+	if err != nil {
+		return nil, g.cnt.Log.ErrWrap1(err, "listing the roles granted", locGrant, logging.String("scope", scope))
+	}
+	return grants, nil
+}
+
+// Role gets the role granted to the principal over the scope. If it isn't granted return false
+func (g *Grants) Role(ctx context.Context, scope string, principal string) (Role, bool, error) {
+	var grant Grant
+	sctx, cancel := g.cnt.StoreWithTimeout(ctx)
+	found, err := g.crud.Store().Get(sctx, entity.GrantKey(scope, principal), &grant)
+	cancel()
+	if err != nil || !found {
+		return "", false, err
+	}
+	return grant.Role, true, nil
+}
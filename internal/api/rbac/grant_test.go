@@ -0,0 +1,74 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carisa/internal/api/instance/samples"
+	"github.com/carisa/internal/api/mock"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrants(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+	grants := NewGrants(cnt, crud)
+
+	inst, err := samples.CreateInstance(mng)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	found, err := grants.Put(context.Background(), &Grant{Scope: inst.Key(), Principal: "user", Role: Viewer})
+	if assert.NoError(t, err, "Granting") {
+		assert.True(t, found, "Granted")
+	}
+	found, err = grants.Put(context.Background(), &Grant{Scope: inst.Key(), Principal: "user", Role: Modeler})
+	if assert.NoError(t, err, "Replacing") {
+		assert.True(t, found, "Replaced")
+	}
+	found, err = grants.Put(context.Background(), &Grant{Scope: "I" + xid.New().String(), Principal: "user", Role: Viewer})
+	if assert.NoError(t, err, "Granting over scope not found") {
+		assert.False(t, found, "Scope not found")
+	}
+
+	role, found, err := grants.Role(context.Background(), inst.Key(), "user")
+	if assert.NoError(t, err, "Role") && assert.True(t, found, "Role found") {
+		assert.Equal(t, Modeler, role, "Role")
+	}
+	_, found, err = grants.Role(context.Background(), inst.Key(), "other")
+	if assert.NoError(t, err, "Role of other") {
+		assert.False(t, found, "Role of other not found")
+	}
+
+	list, err := grants.List(context.Background(), inst.Key())
+	if assert.NoError(t, err, "Listing") && assert.Len(t, list, 1, "Grants") {
+		assert.Equal(t, "user", list[0].(*Grant).Principal, "Principal")
+	}
+
+	found, err = grants.Revoke(context.Background(), inst.Key(), "user")
+	if assert.NoError(t, err, "Revoking") {
+		assert.True(t, found, "Revoked")
+	}
+	found, err = grants.Revoke(context.Background(), inst.Key(), "user")
+	if assert.NoError(t, err, "Revoking again") {
+		assert.False(t, found, "Not found")
+	}
+}
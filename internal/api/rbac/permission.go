@@ -0,0 +1,129 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package rbac authorizes the requests with roles granted to the principals by instance.Instance or space.Space.
+// A role granted over an instance applies to all entities of the instance and a role granted
+// over a space applies to all entities of the space
+package rbac
+
+// Role is a set of permissions. Each role includes the permissions of the lower roles
+type Role string
+
+const (
+	// Viewer can read the entities
+	Viewer Role = "viewer"
+	// Modeler can read and write the entities
+	Modeler Role = "modeler"
+	// Admin can read and write the entities and grant roles
+	Admin Role = "admin"
+)
+
+// level gets the order of the role. The role not valid is 0
+func (r Role) level() int {
+	switch r {
+	case Viewer:
+		return 1
+	case Modeler:
+		return 2
+	case Admin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Valid returns true if the role exists
+func (r Role) Valid() bool {
+	return r.level() > 0
+}
+
+// Includes returns true if the role has the permissions of the other role
+func (r Role) Includes(other Role) bool {
+	return r.level() >= other.level()
+}
+
+// source locates the key of the target entity into the path param or into the field of the body.
+// The identifier is combined with each scheme until the key exists
+type source struct {
+	param   string
+	field   string
+	schemes []string
+}
+
+// Target locates the entity that the request reaches. The sources are tried in order
+// until some entity exists. For example: the entity to update or else the parent to create it
+type Target struct {
+	sources  []source
+	platform bool
+}
+
+// Param locates the target by the identifier of the path param
+func Param(name string, schemes ...string) Target {
+	return Target{sources: []source{{param: name, schemes: schemes}}}
+}
+
+// Body locates the target by the identifier of the field of the body
+func Body(field string, schemes ...string) Target {
+	return Target{sources: []source{{field: field, schemes: schemes}}}
+}
+
+// Platform is the target of the resources that don't belong to any instance.
+// The role Viewer is granted to all principals and the role Admin to runtime.Auth.Admins
+func Platform() Target {
+	return Target{platform: true}
+}
+
+// Or tries the other target if this target is not found
+func (t Target) Or(other Target) Target {
+	sources := make([]source, 0, len(t.sources)+len(other.sources))
+	sources = append(sources, t.sources...)
+	return Target{sources: append(sources, other.sources...), platform: t.platform || other.platform}
+}
+
+// Viewer needs the role Viewer over the target
+func (t Target) Viewer() Permission {
+	return Permission{role: Viewer, target: t}
+}
+
+// Modeler needs the role Modeler over the target
+func (t Target) Modeler() Permission {
+	return Permission{role: Modeler, target: t}
+}
+
+// Admin needs the role Admin over the target
+func (t Target) Admin() Permission {
+	return Permission{role: Admin, target: t}
+}
+
+// Permission is the role that the request needs over the target
+type Permission struct {
+	role   Role
+	target Target
+	also   []Target
+}
+
+// And needs the same role over the other target too.
+// For example: the entity linked and the entity where it is linked
+func (p Permission) And(other Target) Permission {
+	also := make([]Target, 0, len(p.also)+1)
+	also = append(also, p.also...)
+	return Permission{role: p.role, target: p.target, also: append(also, other)}
+}
+
+// targets gets all targets that must be allowed
+func (p Permission) targets() []Target {
+	return append([]Target{p.target}, p.also...)
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole_Includes(t *testing.T) {
+	assert.True(t, Admin.Includes(Modeler), "Admin includes modeler")
+	assert.True(t, Modeler.Includes(Viewer), "Modeler includes viewer")
+	assert.True(t, Viewer.Includes(Viewer), "Viewer includes viewer")
+	assert.False(t, Viewer.Includes(Modeler), "Viewer doesn't include modeler")
+	assert.False(t, Role("owner").Includes(Viewer), "Role not valid")
+}
+
+func TestRole_Valid(t *testing.T) {
+	for _, r := range []Role{Viewer, Modeler, Admin} {
+		assert.True(t, r.Valid(), string(r))
+	}
+	assert.False(t, Role("").Valid(), "Empty")
+	assert.False(t, Role("owner").Valid(), "Owner")
+}
+
+func TestTarget_Or(t *testing.T) {
+	first := Param("id", "S")
+	target := first.Or(Body("instanceId", "I")).Or(Platform())
+
+	assert.Len(t, first.sources, 1, "The first target is not changed")
+	assert.Equal(t, []source{{param: "id", schemes: []string{"S"}}, {field: "instanceId", schemes: []string{"I"}}}, target.sources)
+	assert.True(t, target.platform, "Platform")
+	assert.Equal(t, Permission{role: Modeler, target: target}, target.Modeler())
+}
+
+func TestPermission_And(t *testing.T) {
+	first := Param("id", "E").Modeler()
+	perm := first.And(Param("categoryid", "C"))
+
+	assert.Len(t, first.targets(), 1, "The first permission is not changed")
+	assert.Equal(t, []Target{Param("id", "E"), Param("categoryid", "C")}, perm.targets())
+	assert.Equal(t, Modeler, perm.role)
+}
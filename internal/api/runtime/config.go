@@ -18,7 +18,9 @@ package runtime
 
 import (
 	"strconv"
+	"time"
 
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/pkg/runtime"
 	"github.com/carisa/pkg/strings"
 )
@@ -35,9 +37,99 @@ func (s *Server) Address() string {
 	return strings.Concat(":", strconv.Itoa(s.Port))
 }
 
+// Trash describes the retention of the deleted entities
+type Trash struct {
+	// RetentionInHours is the time that the deleted entities are kept into the trash
+	RetentionInHours time.Duration `json:"retentionInHours,omitempty"`
+	// PurgeInSecs is the interval to remove the expired entities of the trash
+	PurgeInSecs time.Duration `json:"purgeInSecs,omitempty"`
+	// LeaderTTLInSecs is the time that a replica keeps the leadership of the purge after it dies.
+	// Only the leader among the replicas of the API purges the trash
+	LeaderTTLInSecs time.Duration `json:"leaderTTLInSecs,omitempty"`
+}
+
+// Tenancy describes how the tenant of each request is resolved.
+// The keys of each tenant are isolated into their own namespace. See storage.NewNamespace
+type Tenancy struct {
+	// Header is the http header with the name of the tenant
+	Header string `json:"header,omitempty"`
+	// Required rejects the requests without tenant. If it is false the requests without tenant
+	// reach the keys out of any namespace
+	Required bool `json:"required,omitempty"`
+}
+
+// Auth describes the authentication of the requests
+type Auth struct {
+	// Enabled rejects the requests without a valid API key or bearer token
+	Enabled bool `json:"enabled,omitempty"`
+	// KeyHeader is the http header with the API key
+	KeyHeader string `json:"keyHeader,omitempty"`
+	// JWT describes the validation of the bearer tokens
+	JWT JWT `json:"jwt,omitempty"`
+	// Admins are the principals with the role admin over the platform and all instances.
+	// The roles of the rest of principals are granted by instance or space
+	Admins []string `json:"admins,omitempty"`
+	// MaxBodyBytes is the maximum size of the body read to authorize the request.
+	// The requests with bigger bodies are rejected
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+}
+
+// Admin returns true if the principal is an admin. See Auth.Admins
+func (a *Auth) Admin(principal string) bool {
+	for _, admin := range a.Admins {
+		if admin == principal {
+			return true
+		}
+	}
+	return false
+}
+
+// JWT describes the validation of the bearer tokens.
+// The bearer tokens are not validated if there are not keys. The tokens must have expiration (exp)
+type JWT struct {
+	// Keys are the paths of the PEM or JWKS files with the public keys that sign the tokens
+	Keys []string `json:"keys,omitempty"`
+	// Issuer is the issuer of the tokens. If it is empty the issuer is not validated
+	Issuer string `json:"issuer,omitempty"`
+	// Audience is the audience of the tokens. If it is empty the audience is not validated
+	Audience string `json:"audience,omitempty"`
+}
+
+// RateLimit describes the token bucket of each client. The client is the principal of the request
+// or the IP address if the request is anonymous. Each request takes the tokens of its cost,
+// the requests are rejected when the bucket has not enough tokens.
+// If the authentication is enabled, each failed authentication takes a token from the bucket of the address
+// and the requests of the address are rejected before the authentication while the bucket is empty
+type RateLimit struct {
+	// Enabled limits the requests of each client
+	Enabled bool `json:"enabled,omitempty"`
+	// Rate is the number of tokens refilled by second
+	Rate float64 `json:"rate,omitempty"`
+	// Burst is the capacity of the bucket
+	Burst float64 `json:"burst,omitempty"`
+	// ListCost is the cost of the requests that list entities or go through the trees.
+	// The rest of requests cost 1
+	ListCost int `json:"listCost,omitempty"`
+	// Costs overrides the cost by route. The key is the method and the path of the route,
+	// for example "GET /api/spaces/:id/entes"
+	Costs map[string]int `json:"costs,omitempty"`
+	// Shared keeps the buckets into the store to share them among the replicas of the API.
+	// If it is false each replica limits its own requests
+	Shared bool `json:"shared,omitempty"`
+	// TrustedProxies are the addresses or CIDR blocks of the proxies trusted to forward the address
+	// of the client into the header X-Forwarded-For. Without them the address is the remote address
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
 // Config defines the global information
 type Config struct {
-	Server `json:"server,omitempty"`
+	Server    `json:"server,omitempty"`
+	Trash     `json:"trash,omitempty"`
+	Tenancy   `json:"tenancy,omitempty"`
+	Auth      `json:"auth,omitempty"`
+	RateLimit `json:"rateLimit,omitempty"`
+	// Cluster describes the heartbeat of the splitters. See GET /api/admin/splitters
+	Cluster cluster.Config `json:"cluster,omitempty"`
 	runtime.CommonConfig
 }
 
@@ -51,6 +143,27 @@ func LoadConfig() Config {
 		Server: Server{
 			Port: 8080,
 		},
+		Trash: Trash{
+			RetentionInHours: 168,
+			PurgeInSecs:      3600,
+			LeaderTTLInSecs:  30,
+		},
+		Tenancy: Tenancy{
+			Header: "X-Carisa-Tenant",
+		},
+		Auth: Auth{
+			KeyHeader:    "X-Carisa-Key",
+			MaxBodyBytes: 1 << 20,
+		},
+		RateLimit: RateLimit{
+			Rate:     50,
+			Burst:    100,
+			ListCost: 5,
+		},
+		Cluster: cluster.Config{
+			HeartbeatInSecs: 15,
+			DeadAfter:       3,
+		},
 	}
 	runtime.LoadConfig(envConfig, &cnf)
 	return cnf
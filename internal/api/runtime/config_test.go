@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/pkg/runtime"
 	"github.com/carisa/pkg/storage"
 
@@ -46,6 +47,24 @@ func TestRuntime_LoadConfig(t *testing.T) {
 				Server: Server{
 					Port: 8080,
 				},
+				Trash: Trash{
+					RetentionInHours: 168,
+					PurgeInSecs:      3600,
+					LeaderTTLInSecs:  30,
+				},
+				Tenancy: Tenancy{
+					Header: "X-Carisa-Tenant",
+				},
+				Auth: Auth{
+					KeyHeader:    "X-Carisa-Key",
+					MaxBodyBytes: 1 << 20,
+				},
+				RateLimit: RateLimit{
+					Rate:     50,
+					Burst:    100,
+					ListCost: 5,
+				},
+				Cluster: cluster.Config{HeartbeatInSecs: 15, DeadAfter: 3},
 				CommonConfig: runtime.CommonConfig{
 					EtcdConfig: storage.EtcdConfig{RequestTimeout: 10},
 				},
@@ -56,12 +75,58 @@ func TestRuntime_LoadConfig(t *testing.T) {
 			envC: `{
   "server": {
     "port": 1212
+  },
+  "trash": {
+    "retentionInHours": 24
+  },
+  "tenancy": {
+    "required": true
+  },
+  "auth": {
+    "enabled": true,
+    "jwt": {
+      "keys": ["key.pem"],
+      "issuer": "issuer"
+    }
+  },
+  "rateLimit": {
+    "enabled": true,
+    "rate": 10,
+    "costs": {"GET /api/admin/fsck": 100},
+    "shared": true
+  },
+  "cluster": {
+    "deadAfter": 5
   }
 }`,
 			cnf: Config{
 				Server: Server{
 					Port: 1212,
 				},
+				Trash: Trash{
+					RetentionInHours: 24,
+					PurgeInSecs:      3600,
+					LeaderTTLInSecs:  30,
+				},
+				Tenancy: Tenancy{
+					Header:   "X-Carisa-Tenant",
+					Required: true,
+				},
+				Auth: Auth{
+					Enabled:      true,
+					KeyHeader:    "X-Carisa-Key",
+					JWT:          JWT{Keys: []string{"key.pem"}, Issuer: "issuer"},
+					MaxBodyBytes: 1 << 20,
+				},
+				RateLimit: RateLimit{
+					Enabled:  true,
+					Rate:     10,
+					Burst:    100,
+					ListCost: 5,
+					Costs:    map[string]int{"GET /api/admin/fsck": 100},
+					Shared:   true,
+				},
+				Cluster: cluster.Config{HeartbeatInSecs: 15, DeadAfter: 5},
 				CommonConfig: runtime.CommonConfig{
 					EtcdConfig: storage.EtcdConfig{RequestTimeout: 10},
 				},
@@ -29,6 +29,7 @@ import (
 func TestRuntime_NewContainer(t *testing.T) {
 	cnf := Config{
 		Server: Server{Port: 8080},
+		Trash:  Trash{RetentionInHours: 168, PurgeInSecs: 3600, LeaderTTLInSecs: 30},
 		CommonConfig: runtime.CommonConfig{
 			EtcdConfig: storage.EtcdConfig{RequestTimeout: 10},
 		},
@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/pkg/storage"
@@ -48,6 +50,6 @@ func CreateEntityMock(mng storage.Integration, scheme string) (EntityMock, error
 	e.scheme = scheme
 	e.Name = "name"
 	e.Desc = "desc"
-	_, err := crudOper.Put("loc", cnt.StoreWithTimeout, &e)
+	_, err := crudOper.Put("loc", cnt.StoreTimeout(context.Background()), &e)
 	return e, err
 }
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+// Kind classifies the errors of the services so that the handlers can map them
+type Kind int
+
+const (
+	// Internal is an unexpected error, for example of the storage
+	Internal Kind = iota
+	// NotFound is returned when an entity that the operation needs doesn't exist
+	NotFound
+	// Conflict is returned when the operation is not allowed by the state of the entities
+	Conflict
+	// Invalid is returned when the request is not valid
+	Invalid
+)
+
+// The codes are stable and they are sent to the clients
+const (
+	CodeNotFound       = "not_found"
+	CodeParentNotFound = "parent_not_found"
+	CodeTypeMismatch   = "type_mismatch"
+	CodeNotChild       = "not_child"
+	CodeConflict       = "conflict"
+)
+
+// The sentinel errors of the services. The services return them with a message
+// that describes the entity, so they must be checked with errors.Is. See Error.Is
+var (
+	// ErrNotFound is returned when the entity of the operation doesn't exist
+	ErrNotFound = NewError(NotFound, CodeNotFound, "entity not found")
+	// ErrParentNotFound is returned when the parent of the entity doesn't exist
+	ErrParentNotFound = NewError(NotFound, CodeParentNotFound, "parent not found")
+	// ErrTypeMismatch is returned when the entities to link have not the same type
+	ErrTypeMismatch = NewError(Conflict, CodeTypeMismatch, "the entities have not the same type")
+	// ErrNotChild is returned when the entity to link is not child of the parent
+	ErrNotChild = NewError(Invalid, CodeNotChild, "the entity is not child of the parent")
+	// ErrConflict is returned when the state of the entities doesn't allow the operation
+	ErrConflict = NewError(Conflict, CodeConflict, "the operation is not allowed by the state of the entities")
+)
+
+// Error is the typed error returned by the services
+type Error struct {
+	Kind Kind
+	// Code is the machine-readable code of the error
+	Code string
+	// Msg is the human-readable description of the error
+	Msg string
+	// Field is the property of the entity that causes the error
+	Field string
+}
+
+// NewError builds a typed error of the service
+func NewError(kind Kind, code string, msg string) *Error {
+	return &Error{
+		Kind: kind,
+		Code: code,
+		Msg:  msg,
+	}
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Is returns true if the target is an Error with the same code. See errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// With returns a copy of the error with other message
+func (e *Error) With(msg string) *Error {
+	err := *e
+	err.Msg = msg
+	return &err
+}
+
+// ParentNotFound builds the error returned when the parent of the entity doesn't exist. See ErrParentNotFound
+func ParentNotFound(msg string) *Error {
+	return ErrParentNotFound.With(msg)
+}
+
+// WithParent converts the result of the operations that create or update the entity with relation into
+// the result of the service. If the parent doesn't exist it returns ParentNotFound. See storage.CrudOperation
+func WithParent(ok bool, found bool, err error, msg string) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, ParentNotFound(msg)
+	}
+	return ok, nil
+}
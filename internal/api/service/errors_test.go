@@ -0,0 +1,51 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors_Is(t *testing.T) {
+	err := ErrNotFound.With("ente not found")
+
+	assert.Equal(t, "ente not found", err.Error(), "Message")
+	assert.Equal(t, "entity not found", ErrNotFound.Error(), "Sentinel not changed")
+	assert.True(t, errors.Is(err, ErrNotFound), "Same code")
+	assert.False(t, errors.Is(err, ErrParentNotFound), "Other code")
+	assert.False(t, errors.Is(errors.New("ente not found"), ErrNotFound), "Untyped error")
+	assert.True(t, errors.Is(ParentNotFound("space not found"), ErrParentNotFound), "Parent not found")
+}
+
+func TestErrors_WithParent(t *testing.T) {
+	ok, err := WithParent(true, true, nil, "instance not found")
+	if assert.NoError(t, err) {
+		assert.True(t, ok, "Found")
+	}
+
+	_, err = WithParent(false, false, nil, "instance not found")
+	if assert.True(t, errors.Is(err, ErrParentNotFound), "Parent not found") {
+		assert.Equal(t, "instance not found", err.Error())
+	}
+
+	e := errors.New("store")
+	_, err = WithParent(true, false, e, "instance not found")
+	assert.Equal(t, e, err, "Store error")
+}
@@ -16,11 +16,19 @@
 package service
 
 import (
+	"context"
+	strs "strings"
+
+	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/runtime"
 	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
 )
 
+// maxDepth avoids infinite loops navigating the relations
+const maxDepth = 100
+
 type Extension struct {
 	cnt  *runtime.Container
 	crud storage.CRUD
@@ -37,17 +45,82 @@ func NewExt(cnt *runtime.Container, crud storage.CRUD) *Extension {
 // List lists entities depending ranges parameter.
 // If ranges is equal to true is filtered by entity which name is greater than name parameter
 // If ranges is equal to false is filtered by entity which name starts by name parameter
-func (e *Extension) List(id string, name string, ranges bool, top int, empty func() storage.Entity) ([]storage.Entity, error) {
+func (e *Extension) List(
+	ctx context.Context,
+	id string,
+	name string,
+	ranges bool,
+	top int,
+	empty func() storage.Entity) ([]storage.Entity, error) {
+	//
 	var list []storage.Entity
 	var err error
 
-	ctx, cancel := e.cnt.StoreWithTimeout()
+	sctx, cancel := e.cnt.StoreWithTimeout(ctx)
 	if ranges {
-		list, err = e.crud.Range(ctx, strings.Concat(id, name), id, top, empty)
+		list, err = e.crud.Range(sctx, strings.Concat(id, name), id, top, empty)
 	} else {
-		list, err = e.crud.StartKey(ctx, strings.Concat(id, name), top, empty)
+		list, err = e.crud.StartKey(sctx, strings.Concat(id, name), top, empty)
 	}
 	cancel()
 
 	return list, err
 }
+
+// Instance resolves the instance.Instance that contains the entity. It navigates through the
+// doubly linked relations (DLRel) from the entity to her parents until it finds the instance.
+// If the entity doesn't belong to any instance returns false in the second param returned.
+func (e *Extension) Instance(ctx context.Context, key string) (xid.ID, bool, error) {
+	for i := 0; i < maxDepth; i++ {
+		if strs.HasPrefix(key, entity.SchInstance) {
+			id, err := xid.FromString(key[len(entity.SchInstance):])
+			if err != nil {
+				return xid.NilID(), false, err
+			}
+			return id, true, nil
+		}
+
+		sctx, cancel := e.cnt.StoreWithTimeout(ctx)
+		dlrs, err := e.crud.StartKey(sctx, storage.DLRPrefix(key), 1, func() storage.Entity { return &storage.DLRel{} })
+		cancel()
+		if err != nil {
+			return xid.NilID(), false, err
+		}
+		if len(dlrs) == 0 {
+			return xid.NilID(), false, nil
+		}
+		key = dlrs[0].(*storage.DLRel).ParentID
+	}
+	return xid.NilID(), false, nil
+}
+
+// Ancestors gets the keys of all parents of the entity. It navigates through all doubly linked relations (DLRel)
+// from the entity to her parents, so an entity linked to several parents has several branches.
+// The parents of the platform are ignored. See storage.Virtual
+func (e *Extension) Ancestors(ctx context.Context, key string) ([]string, error) {
+	visited := map[string]bool{key: true}
+	var ancestors []string
+	next := []string{key}
+	for i := 0; i < maxDepth && len(next) > 0; i++ {
+		var parents []string
+		for _, k := range next {
+			sctx, cancel := e.cnt.StoreWithTimeout(ctx)
+			dlrs, err := e.crud.StartKey(sctx, storage.DLRPrefix(k), 0, func() storage.Entity { return &storage.DLRel{} })
+			cancel()
+			if err != nil {
+				return nil, err
+			}
+			for _, dlr := range dlrs {
+				parent := dlr.(*storage.DLRel).ParentID
+				if visited[parent] || strs.HasPrefix(parent, storage.Virtual) {
+					continue
+				}
+				visited[parent] = true
+				ancestors = append(ancestors, parent)
+				parents = append(parents, parent)
+			}
+		}
+		next = parents
+	}
+	return ancestors, nil
+}
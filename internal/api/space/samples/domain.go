@@ -17,6 +17,8 @@
 package samples
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/mock"
 	"github.com/carisa/internal/api/space"
 	"github.com/carisa/pkg/storage"
@@ -28,7 +30,7 @@ func CreateSpace(mng storage.Integration) (space.Space, error) {
 	space := space.New()
 	space.Name = "Name"
 	space.Desc = "desc"
-	_, err := crudOper.Put("loc", cnt.StoreWithTimeout, &space)
+	_, err := crudOper.Put("loc", cnt.StoreTimeout(context.Background()), &space)
 	return space, err
 }
 
@@ -39,6 +41,6 @@ func CreateLink(mng storage.Integration, instanceID xid.ID) (storage.Entity, spa
 	s.Desc = "desc"
 	s.InstID = instanceID
 	link := s.Link()
-	_, err := crudOper.Create("", cnt.StoreWithTimeout, link)
+	_, err := crudOper.Create("", cnt.StoreTimeout(context.Background()), link)
 	return link, s, err
 }
@@ -17,6 +17,8 @@
 package space
 
 import (
+	"context"
+
 	"github.com/carisa/internal/api/entity"
 	"github.com/carisa/internal/api/relation"
 	"github.com/carisa/internal/api/runtime"
@@ -46,31 +48,34 @@ func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.Cru
 
 // Create creates a space into of the repository and links instance.Instance and space.Space.
 // If the space.Space exists return false in the first param returned.
-// If the instance.Instance doesn't exist return false in the second param returned.
-func (s *Service) Create(space *Space) (bool, bool, error) {
+// If the instance.Instance doesn't exist return service.ParentNotFound.
+func (s *Service) Create(ctx context.Context, space *Space) (bool, error) {
 	space.AutoID()
-	return s.crud.CreateWithRel(locService, s.cnt.StoreWithTimeout, space)
+	ok, found, err := s.crud.CreateWithRel(locService, s.cnt.StoreTimeout(ctx), space)
+	return service.WithParent(ok, found, err, "instance not found")
 }
 
 // Put creates or updates a space.Space into of the repository.
 // If the space exists return true in the first param returned otherwise return false.
-// If the instance.Instance doesn't exist return false in the second param returned.
-func (s *Service) Put(space *Space) (bool, bool, error) {
-	return s.crud.PutWithRel(locService, s.cnt.StoreWithTimeout, space)
+// If the instance.Instance doesn't exist return service.ParentNotFound.
+func (s *Service) Put(ctx context.Context, space *Space) (bool, error) {
+	ok, found, err := s.crud.PutWithRel(locService, s.cnt.StoreTimeout(ctx), space)
+	return service.WithParent(ok, found, err, "instance not found")
 }
 
 // Get gets the space.Space from storage
-func (s *Service) Get(id xid.ID, space *Space) (bool, error) {
-	ctx, cancel := s.cnt.StoreWithTimeout()
-	ok, err := s.crud.Store().Get(ctx, entity.SpaceKey(id), space)
+func (s *Service) Get(ctx context.Context, id xid.ID, space *Space) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	ok, err := s.crud.Store().Get(sctx, entity.SpaceKey(id), space)
 	cancel()
 	return ok, err
 }
 
 // ListEntes lists entes depending 'ranges' parameter.
 // Look at service.List
-func (s *Service) ListEntes(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListEntes(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		entity.SpaceKey(id),
 		strings.Concat(relation.SpaceEnteLn, name),
 		ranges,
@@ -80,8 +85,9 @@ func (s *Service) ListEntes(id xid.ID, name string, ranges bool, top int) ([]sto
 
 // ListCategories lists categories depending 'ranges' parameter.
 // Look at service.List
-func (s *Service) ListCategories(id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+func (s *Service) ListCategories(ctx context.Context, id xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
 	return s.ext.List(
+		ctx,
 		entity.SpaceKey(id),
 		strings.Concat(relation.SpaceCatLn, name),
 		ranges,
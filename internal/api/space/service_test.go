@@ -17,6 +17,7 @@
 package space
 
 import (
+	"context"
 	"testing"
 
 	"github.com/carisa/internal/api/test"
@@ -47,11 +48,10 @@ func TestSpaceService_Create(t *testing.T) {
 	s, err := space(mng)
 
 	if assert.NoError(t, err) {
-		ok, found, err := srv.Create(s)
+		ok, err := srv.Create(context.Background(), s)
 
 		if assert.NoError(t, err) {
 			assert.True(t, ok, "Created")
-			assert.True(t, found, "Instance found")
 			checkSpace(t, srv, "Checking relations.", *s)
 		}
 	}
@@ -98,10 +98,9 @@ func TestSpaceService_Put(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		updated, found, err := srv.Put(tt.space)
+		updated, err := srv.Put(context.Background(), tt.space)
 		if assert.NoError(t, err) {
 			assert.Equal(t, updated, tt.updated, strings.Concat(tt.name, "Space updated"))
-			assert.True(t, found, strings.Concat(tt.name, "Instance found"))
 			checkSpace(t, srv, tt.name, *tt.space)
 		}
 	}
@@ -109,7 +108,7 @@ func TestSpaceService_Put(t *testing.T) {
 
 func checkSpace(t *testing.T, srv Service, name string, s Space) {
 	var sr Space
-	_, err := srv.Get(s.ID, &sr)
+	_, err := srv.Get(context.Background(), s.ID, &sr)
 	if assert.NoError(t, err) {
 		assert.Equal(t, s, sr, "Getting space")
 	}
@@ -123,10 +122,10 @@ func TestSpaceService_Get(t *testing.T) {
 	s, err := space(mng)
 
 	if assert.NoError(t, err) {
-		_, _, err := srv.Create(s)
+		_, err := srv.Create(context.Background(), s)
 		if assert.NoError(t, err) {
 			var gets Space
-			ok, err := srv.Get(s.ID, &gets)
+			ok, err := srv.Get(context.Background(), s.ID, &gets)
 			if assert.NoError(t, err) {
 				assert.True(t, ok, "Get ok")
 				assert.Equal(t, s, &gets, "Space returned")
@@ -146,7 +145,7 @@ func TestSpaceService_ListEntes(t *testing.T) {
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListEntes(id, "name", tt.Ranges, 1)
+			list, err := s.ListEntes(context.Background(), id, "name", tt.Ranges, 1)
 			if assert.NoError(t, err) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
@@ -165,7 +164,7 @@ func TestSpaceService_ListCategories(t *testing.T) {
 
 	if assert.NoError(t, err) {
 		for _, tt := range tests {
-			list, err := s.ListCategories(id, "name", tt.Ranges, 1)
+			list, err := s.ListCategories(context.Background(), id, "name", tt.Ranges, 1)
 			if assert.NoError(t, err) {
 				assert.Equalf(t, link, list[0], "Ranges: %v", tt.Name)
 			}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package trash
+
+import (
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+)
+
+// Item is a deleted entity into the trash of the instance.Instance.
+// The Item keeps the raw values of the entity, her links and her doubly linked relations (DLRel)
+// so that they can be restored as they were. The links are removed from the parents
+// when the entity is deleted, therefore the entity is hidden in all lists.
+type Item struct {
+	entity.Descriptor
+	InstID  xid.ID            `json:"instanceId"`
+	EntKey  string            `json:"key"` // Key of the deleted entity
+	Deleted time.Time         `json:"deleted"`
+	Expires time.Time         `json:"expires"`
+	Values  map[string][]byte `json:"values,omitempty"` // Raw values by key of the entity, links and DLRel
+}
+
+func (i *Item) ToString() string {
+	return strings.Concat("trash-item: ID:", i.Key(), ", name:", i.Name)
+}
+
+func (i *Item) Key() string {
+	return entity.TrashKey(i.InstID, i.EntKey)
+}
+
+// Expired checks if the retention period of the Item is over
+func (i *Item) Expired(now time.Time) bool {
+	return now.After(i.Expires)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package trash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestItem_ToString(t *testing.T) {
+	i := Item{InstID: xid.New(), EntKey: entity.EnteKey(xid.New())}
+	assert.Equal(t, strings.Concat("trash-item: ID:", i.Key(), ", name:", i.Name), i.ToString())
+}
+
+func TestItem_Key(t *testing.T) {
+	i := Item{InstID: xid.New(), EntKey: entity.EnteKey(xid.New())}
+	assert.Equal(t, entity.TrashKey(i.InstID, i.EntKey), i.Key())
+}
+
+func TestItem_Expired(t *testing.T) {
+	now := time.Now()
+	i := Item{Expires: now}
+	assert.False(t, i.Expired(now), "Same time")
+	assert.True(t, i.Expired(now.Add(time.Second)), "After")
+	assert.False(t, i.Expired(now.Add(-time.Second)), "Before")
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package trash
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/cluster"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const (
+	locPurger = "trash.purger"
+	// purgerJob is the name of the election of the purger
+	purgerJob = "trash-purger"
+)
+
+// Purger removes in background the items of the trash whose retention period is over.
+// The items are purged each runtime.Trash.PurgeInSecs seconds by a single leader among the replicas
+// of the API that start the purger. See cluster.Singleton
+type Purger struct {
+	cnt  *runtime.Container
+	srvs func() []*Service
+	job  *cluster.Singleton
+}
+
+// NewPurger builds a Purger. srvs gets the services of the trashes to purge, one for each tenant.
+// The holder identifies the replica into the election
+func NewPurger(cnt *runtime.Container, srvs func() []*Service, holder string, elections storage.Elections) *Purger {
+	p := &Purger{
+		cnt:  cnt,
+		srvs: srvs,
+	}
+	p.job = cluster.NewSingleton(
+		purgerJob,
+		locPurger,
+		holder,
+		cnt.PurgeInSecs*time.Second,
+		elections,
+		cnt.StoreWithTimeout,
+		cnt.Log,
+		p.purge)
+	return p
+}
+
+// Start starts the purge in background
+func (p *Purger) Start() {
+	p.job.Start()
+}
+
+// Stop stops the purge, waits until the actual purge ends and resigns the leadership
+func (p *Purger) Stop() {
+	p.job.Stop()
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	now := time.Now()
+	purged := 0
+	for _, srv := range p.srvs() {
+		n, err := srv.Purge(ctx, now)
+		purged += n
+		if err != nil {
+			p.cnt.Log.ErrorE(err, locPurger)
+		}
+	}
+	if purged > 0 {
+		p.cnt.Log.Info1("purged the trash", locPurger, logging.String("items", strconv.Itoa(purged)))
+	}
+}
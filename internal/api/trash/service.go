@@ -0,0 +1,427 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package trash
+
+import (
+	"context"
+	"errors"
+	strs "strings"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/tracing"
+	"github.com/rs/xid"
+)
+
+const (
+	locService = "trash.service"
+	// childBatch is the number of keys read by request to find the children of an entity
+	childBatch = 10
+)
+
+// nominative allows to decode the descriptor of any entity.Domain
+type nominative struct {
+	entity.Descriptor
+}
+
+// Service implements the operations of the trash
+type Service struct {
+	cnt  *runtime.Container
+	ext  *service.Extension
+	crud storage.CrudOperation
+}
+
+// NewService builds a trash service
+func NewService(cnt *runtime.Container, ext *service.Extension, crud storage.CrudOperation) Service {
+	return Service{
+		cnt:  cnt,
+		ext:  ext,
+		crud: crud,
+	}
+}
+
+// Delete moves the entity, her links and her doubly linked relations (DLRel) to the trash
+// of the instance.Instance that contains the entity. The move is done in the same transaction
+// and the counters of the entity are decreased in the same transaction (see storage.Counters).
+// The entity key is composed by the scheme and the ID (see entity.Key).
+// If the entity doesn't exist return false in the first param returned.
+// If the entity has children returns notEmpty, the children must be deleted before so they are not orphaned.
+func (s *Service) Delete(ctx context.Context, scheme string, id xid.ID) (bool, Item, error) {
+	key := entity.Key(scheme, id)
+	ctx, span := tracing.Start(ctx, "trash.delete", tracing.String("key", key))
+	defer span.End()
+
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, value, err := s.crud.Store().GetRaw(sctx, key)
+	cancel()
+	if err != nil {
+		return false, Item{}, s.cnt.Log.ErrWrap1(err, "getting the entity to delete", locService, logging.String("key", key))
+	}
+	if !found {
+		return false, Item{}, nil
+	}
+	parent, err := s.parent(ctx, key)
+	if err != nil {
+		return false, Item{}, err
+	}
+	if parent {
+		return false, Item{}, notEmpty
+	}
+
+	item, err := s.item(ctx, key, value)
+	if err != nil {
+		return false, Item{}, err
+	}
+	counters, err := s.counters(ctx, item)
+	if err != nil {
+		return false, Item{}, err
+	}
+
+	for i := 0; i < storage.CountRetries; i++ {
+		deleted, err := s.delete(ctx, item, counters)
+		if err != nil || deleted || len(counters) == 0 {
+			return deleted, item, err
+		}
+		// The entity has been deleted or the counters have been changed by other process
+		found, err := s.exists(ctx, key)
+		if err != nil || !found {
+			return false, item, err
+		}
+	}
+	return false, Item{}, s.cnt.Log.ErrWrap1(storage.ErrCountConflict, "deleting", locService, logging.String("key", key))
+}
+
+// delete moves the entity to the trash and decreases her counters in the same transaction
+func (s *Service) delete(ctx context.Context, item Item, counters []storage.Counter) (bool, error) {
+	txn := storage.NewTxn(s.crud.Store())
+	txn.Find(item.EntKey)
+	for k := range item.Values {
+		txn.DoFound(s.crud.Store().Remove(k))
+	}
+	put, err := s.crud.Store().Put(&item)
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "moving the entity to the trash", locService, logging.String("key", item.EntKey))
+	}
+	txn.DoFound(put)
+
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	defer cancel()
+	if err := storage.Count(sctx, s.crud.Store(), txn, counters, -1, true); err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "counting the entity to delete", locService, logging.String("key", item.EntKey))
+	}
+	deleted, err := txn.Commit(sctx)
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "commit deleting", locService, logging.String("key", item.EntKey))
+	}
+	return deleted, nil
+}
+
+// counters gets the counters of the entity into the trash. The parent of the entity is gotten from her DLRels
+func (s *Service) counters(ctx context.Context, item Item) ([]storage.Counter, error) {
+	prefix := storage.DLRPrefix(item.EntKey)
+	parent := ""
+	for k, v := range item.Values {
+		if !strs.HasPrefix(k, prefix) {
+			continue
+		}
+		var dlr storage.DLRel
+		if err := encoding.DecodeByte(v, &dlr); err != nil {
+			return nil, s.cnt.Log.ErrWrap1(err, "decoding the dlr of the entity", locService, logging.String("dlr", k))
+		}
+		if dlr.ParentID != storage.Virtual {
+			parent = dlr.ParentID
+			break
+		}
+	}
+	if len(parent) == 0 {
+		return nil, nil
+	}
+	counters, err := s.crud.Counters(ctx, item.EntKey, parent)
+	if err != nil {
+		return nil, s.cnt.Log.ErrWrap1(err, "getting the counters of the entity", locService, logging.String("key", item.EntKey))
+	}
+	return counters, nil
+}
+
+// errChild stops the scan when a child is found
+var errChild = errors.New("child found")
+
+// parent checks if the entity has children. The links to the children start by the key of the entity
+// and the DLRels of the entity start by storage.DLRPrefix
+func (s *Service) parent(ctx context.Context, key string) (bool, error) {
+	dlrs := storage.DLRPrefix(key)
+	storeTimeout := func() (context.Context, context.CancelFunc) { return s.cnt.StoreWithTimeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, s.crud.Store(), key, childBatch, func(keys []string, _ map[string]string) error {
+		for _, k := range keys {
+			if k != key && !strs.HasPrefix(k, dlrs) {
+				return errChild
+			}
+		}
+		return nil
+	})
+	if err == errChild {
+		return true, nil
+	}
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "finding the children of the entity", locService, logging.String("key", key))
+	}
+	return false, nil
+}
+
+// exists checks if the entity exists
+func (s *Service) exists(ctx context.Context, key string) (bool, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, err := s.crud.Store().Exists(sctx, key)
+	cancel()
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "finding the entity", locService, logging.String("key", key))
+	}
+	return found, nil
+}
+
+// taken checks if some key of the entity, her links or her DLRels is in use
+func (s *Service) taken(ctx context.Context, item Item) (bool, error) {
+	for k := range item.Values {
+		found, err := s.exists(ctx, k)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// item collects the entity, her links and her DLRels for the trash
+func (s *Service) item(ctx context.Context, key string, value string) (Item, error) {
+	values := map[string][]byte{key: []byte(value)}
+
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	dlrs, err := s.crud.Store().RangeRaw(sctx, storage.DLRPrefix(key), storage.DLRPrefix(key), 0)
+	cancel()
+	if err != nil {
+		return Item{}, s.cnt.Log.ErrWrap1(err, "listing the dlr of the entity to delete", locService, logging.String("key", key))
+	}
+
+	for dlrKey, dlrValue := range dlrs {
+		values[dlrKey] = []byte(dlrValue)
+
+		var dlr storage.DLRel
+		if err := encoding.Decode(dlrValue, &dlr); err != nil {
+			return Item{}, s.cnt.Log.ErrWrap1(err, "decoding the dlr of the entity to delete", locService, logging.String("dlr", dlrKey))
+		}
+		sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+		found, link, err := s.crud.Store().GetRaw(sctx, dlr.Pointer)
+		cancel()
+		if err != nil {
+			return Item{}, s.cnt.Log.ErrWrap1(
+				err,
+				"getting the link of the entity to delete",
+				locService,
+				logging.String("link", dlr.Pointer))
+		}
+		if found {
+			values[dlr.Pointer] = []byte(link)
+		}
+	}
+
+	instID, _, err := s.ext.Instance(ctx, key)
+	if err != nil {
+		return Item{}, s.cnt.Log.ErrWrap1(err, "resolving the instance of the entity to delete", locService, logging.String("key", key))
+	}
+
+	var nom nominative
+	if err := encoding.Decode(value, &nom); err != nil {
+		return Item{}, s.cnt.Log.ErrWrap1(err, "decoding the entity to delete", locService, logging.String("key", key))
+	}
+
+	now := time.Now()
+	return Item{
+		Descriptor: nom.Descriptor,
+		InstID:     instID,
+		EntKey:     key,
+		Deleted:    now,
+		Expires:    now.Add(s.cnt.RetentionInHours * time.Hour),
+		Values:     values,
+	}, nil
+}
+
+// List lists the items of the trash of the instance.Instance depending 'ranges' parameter.
+// The name parameter filters by the key of the deleted entity, so the scheme filters by type of entity.
+// Look at service.Extension
+func (s *Service) List(ctx context.Context, instID xid.ID, name string, ranges bool, top int) ([]storage.Entity, error) {
+	return s.ext.List(ctx, entity.TrashKey(instID, ""), name, ranges, top, func() storage.Entity { return &Item{} })
+}
+
+// Restore restores the entity, her links and her DLRels from the trash of the instance.Instance.
+// The restore is done in the same transaction and the counters of the entity are increased in the same transaction.
+// If the entity is not into the trash returns service.ErrNotFound.
+// If the entity key is in use or some parent of the entity doesn't exist returns service.ErrConflict.
+// If some counter has reached her limit returns storage.LimitError
+func (s *Service) Restore(ctx context.Context, instID xid.ID, key string) (Item, error) {
+	ctx, span := tracing.Start(ctx, "trash.restore", tracing.String("key", key))
+	defer span.End()
+
+	var item Item
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	found, err := s.crud.Store().Get(sctx, entity.TrashKey(instID, key), &item)
+	cancel()
+	if err != nil {
+		return Item{}, s.cnt.Log.ErrWrap1(
+			err,
+			"getting the entity from the trash",
+			locService,
+			logging.String("key", key))
+	}
+	if !found {
+		return Item{}, service.ErrNotFound.With("entity not found into the trash")
+	}
+
+	found, err = s.parents(ctx, item)
+	if err != nil {
+		return Item{}, err
+	}
+	if !found {
+		return Item{}, notRestored
+	}
+	counters, err := s.counters(ctx, item)
+	if err != nil {
+		return Item{}, err
+	}
+
+	for i := 0; i < storage.CountRetries; i++ {
+		restored, err := s.restore(ctx, item, counters)
+		if err != nil {
+			return Item{}, err
+		}
+		if restored {
+			return item, nil
+		}
+		// The keys of the entity are in use or the counters have been changed by other process
+		taken, err := s.taken(ctx, item)
+		if err != nil {
+			return Item{}, err
+		}
+		if taken || len(counters) == 0 {
+			return Item{}, notRestored
+		}
+	}
+	return Item{}, s.cnt.Log.ErrWrap1(storage.ErrCountConflict, "restoring", locService, logging.String("key", key))
+}
+
+// notEmpty is returned when the entity to delete has children
+var notEmpty = service.ErrConflict.With("the entity has children, they must be deleted before")
+
+// notRestored is returned when the entity of the trash can not be restored
+var notRestored = service.ErrConflict.With(
+	"the entity or some of her links exist or some parent of the entity doesn't exist")
+
+// restore restores the entity from the trash and increases her counters in the same transaction.
+// The links and the DLRels must not exist, a link of other entity with the same name is never overwritten
+func (s *Service) restore(ctx context.Context, item Item, counters []storage.Counter) (bool, error) {
+	txn := storage.NewTxn(s.crud.Store())
+	txn.Find(item.EntKey)
+	for k, v := range item.Values {
+		if k != item.EntKey {
+			txn.Match(k, "")
+		}
+		txn.DoNotFound(s.crud.Store().PutRaw(k, string(v)))
+	}
+	txn.DoNotFound(s.crud.Store().Remove(item.Key()))
+
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	defer cancel()
+	err := storage.Count(sctx, s.crud.Store(), txn, counters, 1, false)
+	if _, ok := err.(*storage.LimitError); ok {
+		return false, err
+	}
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "counting the entity to restore", locService, logging.String("key", item.EntKey))
+	}
+	restored, err := txn.Commit(sctx)
+	if err != nil {
+		return false, s.cnt.Log.ErrWrap1(err, "commit restoring", locService, logging.String("key", item.EntKey))
+	}
+	return restored, nil
+}
+
+// parents checks if all parents of the entity into the trash exist
+func (s *Service) parents(ctx context.Context, item Item) (bool, error) {
+	prefix := storage.DLRPrefix(item.EntKey)
+	for k, v := range item.Values {
+		if !strs.HasPrefix(k, prefix) {
+			continue
+		}
+		var dlr storage.DLRel
+		if err := encoding.DecodeByte(v, &dlr); err != nil {
+			return false, s.cnt.Log.ErrWrap1(err, "decoding the dlr of the entity to restore", locService, logging.String("dlr", k))
+		}
+		if dlr.ParentID == storage.Virtual {
+			continue
+		}
+		sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+		found, err := s.crud.Store().Exists(sctx, dlr.ParentID)
+		cancel()
+		if err != nil {
+			return false, s.cnt.Log.ErrWrap1(
+				err,
+				"finding the parent of the entity to restore",
+				locService,
+				logging.String("parent", dlr.ParentID))
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Purge removes the items of all trashes whose retention period is over.
+// Return the number of the items removed
+func (s *Service) Purge(ctx context.Context, now time.Time) (int, error) {
+	sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+	items, err := s.crud.Store().StartKey(sctx, entity.SchTrash, 0, func() storage.Entity { return &Item{} })
+	cancel()
+	if err != nil {
+		return 0, s.cnt.Log.ErrWrap(err, "listing the items of the trash for purging", locService)
+	}
+
+	purged := 0
+	txn := storage.NewTxn(s.crud.Store())
+	for _, e := range items {
+		item := e.(*Item)
+		if !item.Expired(now) {
+			continue
+		}
+		txn.Find(item.Key())
+		txn.DoFound(s.crud.Store().Remove(item.Key()))
+		sctx, cancel := s.cnt.StoreWithTimeout(ctx)
+		removed, err := txn.Commit(sctx)
+		cancel()
+		txn.Clear()
+		if err != nil {
+			return purged, s.cnt.Log.ErrWrap1(err, "commit purging", locService, logging.String("key", item.Key()))
+		}
+		if removed {
+			purged++
+		}
+	}
+	return purged, nil
+}
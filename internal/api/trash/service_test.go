@@ -0,0 +1,371 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package trash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/category"
+	"github.com/carisa/internal/api/ente"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance"
+	"github.com/carisa/internal/api/instance/samples"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/relation"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/internal/api/space"
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+// Verify the crud integration. For all rest test look at http.handler.trash_test
+
+func TestTrashService_Delete(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	inst, spc, e, cat, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+
+	found, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Ente found")
+		assert.Equal(t, inst.ID, item.InstID, "Instance")
+		assert.Equal(t, e.Name, item.Name, "Name")
+		assert.Equal(t, 5, len(item.Values), "Entity, links and DLRs")
+
+		for k := range item.Values {
+			found, err := srv.crud.Store().Exists(context.TODO(), k)
+			if assert.NoError(t, err) {
+				assert.False(t, found, k)
+			}
+		}
+		found, err = srv.crud.Store().Exists(context.TODO(), item.Key())
+		if assert.NoError(t, err) {
+			assert.True(t, found, "Trash item")
+		}
+
+		list, err := srv.ext.List(
+			context.Background(),
+			spc.Key(),
+			relation.SpaceEnteLn,
+			false,
+			0,
+			func() storage.Entity { return &relation.SpaceEnte{} })
+		if assert.NoError(t, err) {
+			assert.Empty(t, list, "Hidden from the space")
+		}
+		list, err = srv.ext.List(
+			context.Background(),
+			cat.Key(),
+			e.Name,
+			false,
+			0,
+			func() storage.Entity { return &relation.Hierarchy{} })
+		if assert.NoError(t, err) {
+			assert.Empty(t, list, "Hidden from the category")
+		}
+	}
+
+	found, _, err = srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Ente not found")
+	}
+}
+
+func TestTrashService_DeleteParent(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	_, spc, e, cat, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+
+	_, _, err = srv.Delete(context.Background(), entity.SchSpace, spc.ID)
+	assert.True(t, errors.Is(err, service.ErrConflict), "The space has the category and the ente")
+	_, _, err = srv.Delete(context.Background(), entity.SchCategory, cat.ID)
+	assert.True(t, errors.Is(err, service.ErrConflict), "The category has the ente")
+	found, err := srv.crud.Store().Exists(context.TODO(), spc.Key())
+	if assert.NoError(t, err) {
+		assert.True(t, found, "The space is not deleted")
+	}
+
+	for _, del := range []struct {
+		scheme string
+		id     xid.ID
+	}{{entity.SchEnte, e.ID}, {entity.SchCategory, cat.ID}, {entity.SchSpace, spc.ID}} {
+		found, _, err := srv.Delete(context.Background(), del.scheme, del.id)
+		if assert.NoError(t, err, del.scheme) {
+			assert.True(t, found, del.scheme)
+		}
+	}
+}
+
+func TestTrashService_List(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	inst, _, e, cat, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchCategory, cat.ID)
+	if !assert.NoError(t, err, "Deleting category") {
+		return
+	}
+
+	list, err := srv.List(context.Background(), inst.ID, "", false, 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, len(list), "All items")
+	}
+	list, err = srv.List(context.Background(), inst.ID, entity.SchEnte, false, 10)
+	if assert.NoError(t, err) {
+		if assert.Equal(t, 1, len(list), "Entes") {
+			assert.Equal(t, e.Key(), list[0].(*Item).EntKey)
+		}
+	}
+}
+
+func TestTrashService_Restore(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	inst, _, e, _, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+
+	_, err = srv.Restore(context.Background(), inst.ID, e.Key())
+	if assert.NoError(t, err) {
+		for k, v := range item.Values {
+			_, value, err := srv.crud.Store().GetRaw(context.TODO(), k)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(v), value, k)
+			}
+		}
+		found, err := srv.crud.Store().Exists(context.TODO(), item.Key())
+		if assert.NoError(t, err) {
+			assert.False(t, found, "Trash item")
+		}
+	}
+
+	_, err = srv.Restore(context.Background(), inst.ID, e.Key())
+	assert.True(t, errors.Is(err, service.ErrNotFound), "Not found into the trash")
+}
+
+func TestTrashService_RestoreLinkTaken(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	inst, _, e, _, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+
+	// Other entity takes the link of the ente into the space meanwhile
+	link := e.Link().Key()
+	if _, ok := item.Values[link]; !assert.True(t, ok, "Link into the trash") {
+		return
+	}
+	store := srv.crud.Store()
+	txn := storage.NewTxn(store)
+	txn.Find(link)
+	txn.DoNotFound(store.PutRaw(link, "other"))
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = srv.Restore(context.Background(), inst.ID, e.Key())
+	assert.True(t, errors.Is(err, service.ErrConflict), "The link is taken")
+	_, value, err := store.GetRaw(context.TODO(), link)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "other", value, "The link is not overwritten")
+	}
+	found, err := store.Exists(context.TODO(), e.Key())
+	if assert.NoError(t, err) {
+		assert.False(t, found, "The ente is not restored")
+	}
+}
+
+func TestTrashService_RestoreWithoutParent(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	inst, spc, e, cat, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchCategory, cat.ID)
+	if !assert.NoError(t, err, "Deleting category") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchSpace, spc.ID)
+	if !assert.NoError(t, err, "Deleting space") {
+		return
+	}
+
+	_, err = srv.Restore(context.Background(), inst.ID, cat.Key())
+	assert.True(t, errors.Is(err, service.ErrConflict), "The space doesn't exist")
+}
+
+func TestTrashService_Purge(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+
+	_, _, e, cat, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+	_, _, err = srv.Delete(context.Background(), entity.SchCategory, cat.ID)
+	if !assert.NoError(t, err, "Deleting category") {
+		return
+	}
+
+	purged, err := srv.Purge(context.Background(), time.Now())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, purged, "Retention period not over")
+	}
+
+	purged, err = srv.Purge(context.Background(), item.Expires.Add(time.Hour))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, purged, "Retention period over")
+	}
+}
+
+func TestPurger_Start(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+	srv.cnt.RetentionInHours = 0
+	srv.cnt.PurgeInSecs = 1
+
+	_, _, e, _, err := sampling(srv, mng)
+	if !assert.NoError(t, err, "Sampling") {
+		return
+	}
+	_, item, err := srv.Delete(context.Background(), entity.SchEnte, e.ID)
+	if !assert.NoError(t, err, "Deleting ente") {
+		return
+	}
+
+	elections := storage.NewLocalElections()
+	p := NewPurger(srv.cnt, func() []*Service { return []*Service{&srv} }, "replica1", elections)
+	followed := 0
+	follower := NewPurger(srv.cnt, func() []*Service { followed++; return nil }, "replica2", elections)
+	p.Start()
+	time.Sleep(100 * time.Millisecond)
+	follower.Start()
+	time.Sleep(2 * time.Second)
+	follower.Stop()
+	p.Stop()
+
+	assert.Equal(t, 0, followed, "The follower does not purge")
+
+	found, err := srv.crud.Store().Exists(context.TODO(), item.Key())
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Trash item purged")
+	}
+}
+
+func TestPurger_StopWithoutStart(t *testing.T) {
+	srv, mng := newServiceFaked(t)
+	defer mng.Close()
+	p := NewPurger(srv.cnt, func() []*Service { return []*Service{&srv} }, "replica1", storage.NewLocalElections())
+
+	p.Stop()
+	p.Start()
+	p.Stop()
+	p.Stop()
+}
+
+// sampling creates instance -> space -> category -> ente. The ente is linked to the space and the category
+func sampling(srv Service, mng storage.Integration) (
+	inst instance.Instance,
+	spc space.Space,
+	e ente.Ente,
+	cat category.Category,
+	err error) {
+	//
+	if inst, err = samples.CreateInstance(mng); err != nil {
+		return
+	}
+
+	spc = space.New()
+	spc.Name = "space"
+	spc.Desc = "desc"
+	spc.InstID = inst.ID
+	if _, _, err = srv.crud.CreateWithRel("loc", srv.cnt.StoreTimeout(context.Background()), &spc); err != nil {
+		return
+	}
+
+	cat = category.New()
+	cat.Name = "category"
+	cat.Desc = "desc"
+	cat.Root = true
+	cat.ParentID = spc.ID
+	if _, _, err = srv.crud.CreateWithRel("loc", srv.cnt.StoreTimeout(context.Background()), &cat); err != nil {
+		return
+	}
+
+	e = ente.New()
+	e.Name = "ente"
+	e.Desc = "desc"
+	e.SpaceID = spc.ID
+	if _, _, err = srv.crud.CreateWithRel("loc", srv.cnt.StoreTimeout(context.Background()), &e); err != nil {
+		return
+	}
+	_, _, _, err = srv.crud.LinkTo(
+		"loc",
+		srv.cnt.StoreTimeout(context.Background()),
+		nil,
+		&e,
+		cat.Key(),
+		func(child storage.Entity) { child.(*ente.Ente).CatID = cat.ID })
+	return
+}
+
+func newServiceFaked(t *testing.T) (Service, storage.Integration) {
+	mng, cnt, crudOper := mock.NewFullCrudOperFaked(t)
+	ext := service.NewExt(cnt, crudOper.Store())
+	return NewService(cnt, ext, crudOper), mng
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package backup streams the keys of Carisa to an archive and restores them into an empty store.
+// The archive is compressed with gzip and has the format:
+//
+//	magic | header length | header (json) | (key length | key | value length | value)* | 0 | count | sha256
+//
+// The lengths and the count are uvarints. The checksum is computed over all between the magic and the checksum,
+// the lengths included, so the boundaries between the keys and the values are protected
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+// Format is the version of the format of the archive
+const Format = 1
+
+// maxLen is the max length of a key or a value. It protects the restore of the corrupted archives
+const maxLen = 1 << 26
+
+var magic = []byte("CRSB")
+
+// Header describes the archive
+type Header struct {
+	// Format is the version of the format of the archive
+	Format int `json:"format"`
+	// Schema is the schema version of the store. Look at migrate.VersionKey
+	Schema int `json:"schema"`
+	// Codec is the name of the codec configured when the archive was written.
+	// Each value keeps the tag of its codec
+	Codec string `json:"codec"`
+	// Created is the creation time of the archive
+	Created time.Time `json:"created"`
+}
+
+// writer writes the archive
+type writer struct {
+	gz    *gzip.Writer
+	w     *bufio.Writer
+	sum   hash.Hash
+	count uint64
+}
+
+func newWriter(w io.Writer, header Header) (*writer, error) {
+	gz := gzip.NewWriter(w)
+	aw := &writer{
+		gz:  gz,
+		w:   bufio.NewWriter(gz),
+		sum: sha256.New(),
+	}
+	if _, err := aw.w.Write(magic); err != nil {
+		return nil, err
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := aw.write(h); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+// record writes the key and the value
+func (w *writer) record(key string, value string) error {
+	if len(key) == 0 {
+		return errors.New("the key is empty")
+	}
+	if err := w.write([]byte(key)); err != nil {
+		return err
+	}
+	w.count++
+	return w.write([]byte(value))
+}
+
+// close writes the end of records, the count and the checksum
+func (w *writer) close() error {
+	if err := w.uvarint(0); err != nil {
+		return err
+	}
+	if err := w.uvarint(w.count); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(w.sum.Sum(nil)); err != nil {
+		return err
+	}
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+func (w *writer) write(b []byte) error {
+	if err := w.uvarint(uint64(len(b))); err != nil {
+		return err
+	}
+	w.sum.Write(b)
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *writer) uvarint(u uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], u)
+	w.sum.Write(buf[:n])
+	_, err := w.w.Write(buf[:n])
+	return err
+}
+
+// reader reads the archive
+type reader struct {
+	r      *bufio.Reader
+	sum    hash.Hash
+	count  uint64
+	header Header
+}
+
+func newReader(r io.Reader) (*reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	ar := &reader{
+		r:   bufio.NewReader(gz),
+		sum: sha256.New(),
+	}
+
+	m := make([]byte, len(magic))
+	if _, err := io.ReadFull(ar.r, m); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(m, magic) {
+		return nil, errors.New("the file is not a carisa archive")
+	}
+	h, err := ar.read()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(h, &ar.header); err != nil {
+		return nil, err
+	}
+	if ar.header.Format != Format {
+		return nil, fmt.Errorf("the format %d of the archive is not supported", ar.header.Format)
+	}
+	return ar, nil
+}
+
+// record reads the next key and value. If there are no more records returns false.
+// At the end of records verifies the count and the checksum
+func (r *reader) record() (string, string, bool, error) {
+	key, err := r.read()
+	if err != nil {
+		return "", "", false, err
+	}
+	if len(key) == 0 {
+		return "", "", false, r.verify()
+	}
+	value, err := r.read()
+	if err != nil {
+		return "", "", false, err
+	}
+	r.count++
+	return string(key), string(value), true, nil
+}
+
+func (r *reader) verify() error {
+	count, err := r.uvarint()
+	if err != nil {
+		return err
+	}
+	if count != r.count {
+		return fmt.Errorf("the archive is corrupted. Records expected: %d, read: %d", count, r.count)
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r.r, sum); err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, r.sum.Sum(nil)) {
+		return errors.New("the archive is corrupted. The checksum doesn't match")
+	}
+	return nil
+}
+
+func (r *reader) read() ([]byte, error) {
+	l, err := r.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > maxLen {
+		return nil, errors.New("the archive is corrupted. Length out of range")
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return nil, err
+	}
+	r.sum.Write(b)
+	return b, nil
+}
+
+// uvarint reads an uvarint and adds it to the checksum as it was written
+func (r *reader) uvarint() (uint64, error) {
+	u, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return 0, err
+	}
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], u)
+	r.sum.Write(buf[:n])
+	return u, nil
+}
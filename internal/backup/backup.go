@@ -0,0 +1,219 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/migrate"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const loc = "backup"
+
+// ErrNotEmpty is returned when the store of the restore has keys of Carisa
+var ErrNotEmpty = errors.New("the store is not empty")
+
+// Prefixes gets the prefixes of all keys of Carisa, including the keys of the tenants
+func Prefixes() []string {
+	return append(entity.Prefixes(), entity.SchMeta, storage.Namespace)
+}
+
+// Backup writes and restores the archives of the keys of Carisa
+type Backup struct {
+	cnt   *runtime.Container
+	store storage.CRUD
+	batch int
+}
+
+// NewBackup builds a Backup. The batch is the number of keys read or written by request
+func NewBackup(cnt *runtime.Container, store storage.CRUD, batch int) Backup {
+	return Backup{
+		cnt:   cnt,
+		store: store,
+		batch: batch,
+	}
+}
+
+// Write streams all keys of Carisa to the archive. Return the header and the number of keys written
+func (b *Backup) Write(w io.Writer) (Header, int, error) {
+	m := migrate.NewMigrator(b.cnt, b.store, b.batch)
+	schema, err := m.Version()
+	if err != nil {
+		return Header{}, 0, err
+	}
+	codec := b.cnt.Codec
+	if len(codec) == 0 {
+		codec = encoding.NameGob
+	}
+	header := Header{
+		Format:  Format,
+		Schema:  schema,
+		Codec:   codec,
+		Created: time.Now().UTC(),
+	}
+
+	aw, err := newWriter(w, header)
+	if err != nil {
+		return Header{}, 0, b.cnt.Log.ErrWrap(err, "writing the header of the archive", loc)
+	}
+	storeTimeout := b.cnt.StoreTimeout(context.Background())
+	for _, prefix := range Prefixes() {
+		err := storage.ScanRaw(storeTimeout, b.store, prefix, b.batch, func(keys []string, values map[string]string) error {
+			for _, k := range keys {
+				if err := aw.record(k, values[k]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return Header{}, 0, b.cnt.Log.ErrWrap1(err, "writing the keys to the archive", loc, logging.String("prefix", prefix))
+		}
+	}
+	if err := aw.close(); err != nil {
+		return Header{}, 0, b.cnt.Log.ErrWrap(err, "closing the archive", loc)
+	}
+	return header, int(aw.count), nil
+}
+
+// Verify reads all archive and verifies the integrity. Return the header and the number of keys
+func (b *Backup) Verify(r io.Reader) (Header, int, error) {
+	return b.read(r, nil)
+}
+
+// Restore verifies the integrity of the archive and writes the keys into the store.
+// The store must be empty and the schema of the archive must be supported by the migration steps.
+// Return the header and the number of keys written
+func (b *Backup) Restore(r io.ReadSeeker) (Header, int, error) {
+	header, _, err := b.Verify(r)
+	if err != nil {
+		return Header{}, 0, err
+	}
+	if err := b.checkSchema(header); err != nil {
+		return Header{}, 0, err
+	}
+	if err := b.checkEmpty(); err != nil {
+		return Header{}, 0, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Header{}, 0, b.cnt.Log.ErrWrap(err, "rewinding the archive", loc)
+	}
+
+	keys := make(map[string]string, b.batch)
+	header, count, err := b.read(r, func(key string, value string) error {
+		keys[key] = value
+		if len(keys) < b.batch {
+			return nil
+		}
+		err := b.put(keys)
+		keys = make(map[string]string, b.batch)
+		return err
+	})
+	if err != nil {
+		return Header{}, 0, err
+	}
+	if err := b.put(keys); err != nil {
+		return Header{}, 0, err
+	}
+	return header, count, nil
+}
+
+// read reads the archive calling to the function by each key
+func (b *Backup) read(r io.Reader, fn func(key string, value string) error) (Header, int, error) {
+	ar, err := newReader(r)
+	if err != nil {
+		return Header{}, 0, b.cnt.Log.ErrWrap(err, "reading the header of the archive", loc)
+	}
+	for {
+		key, value, ok, err := ar.record()
+		if err != nil {
+			return Header{}, 0, b.cnt.Log.ErrWrap(err, "reading the archive", loc)
+		}
+		if !ok {
+			return ar.header, int(ar.count), nil
+		}
+		if fn != nil {
+			if err := fn(key, value); err != nil {
+				return Header{}, 0, err
+			}
+		}
+	}
+}
+
+// checkSchema checks that the schema of the archive is known
+func (b *Backup) checkSchema(header Header) error {
+	steps := migrate.Steps()
+	if len(steps) > 0 && header.Schema > steps[len(steps)-1].Version {
+		return b.cnt.Log.ErrWrap1(
+			errors.New("the schema of the archive is newer than the schema supported"),
+			"checking the schema of the archive",
+			loc,
+			logging.String("schema", strconv.Itoa(header.Schema)))
+	}
+	return nil
+}
+
+// checkEmpty checks that the store has not keys of Carisa
+func (b *Backup) checkEmpty() error {
+	for _, prefix := range Prefixes() {
+		ctx, cancel := b.cnt.StoreWithTimeout(context.Background())
+		values, err := b.store.RangeRaw(ctx, prefix, prefix, 1)
+		cancel()
+		if err != nil {
+			return b.cnt.Log.ErrWrap1(err, "checking if the store is empty", loc, logging.String("prefix", prefix))
+		}
+		if len(values) != 0 {
+			return ErrNotEmpty
+		}
+	}
+	return nil
+}
+
+// put writes the keys in the same transaction. The transaction fails if the first key exists
+func (b *Backup) put(keys map[string]string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	txn := storage.NewTxn(b.store)
+	first := true
+	for k, v := range keys {
+		if first {
+			txn.Find(k)
+			first = false
+		}
+		txn.DoNotFound(b.store.PutRaw(k, v))
+	}
+	ctx, cancel := b.cnt.StoreWithTimeout(context.Background())
+	put, err := txn.Commit(ctx)
+	cancel()
+	if err != nil {
+		return b.cnt.Log.ErrWrap(err, "commit restoring the keys", loc)
+	}
+	if !put {
+		return ErrNotEmpty
+	}
+	return nil
+}
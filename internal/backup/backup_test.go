@@ -0,0 +1,185 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/migrate"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup_WriteRestore(t *testing.T) {
+	source := mock.NewStorageFake(t)
+	defer source.Close()
+	cnt := mock.NewContainerFake()
+
+	values, ok := sampling(t, source.Store())
+	if !ok {
+		return
+	}
+
+	b := NewBackup(cnt, source.Store(), 2)
+	var archive bytes.Buffer
+	header, count, err := b.Write(&archive)
+	if !assert.NoError(t, err, "Write") {
+		return
+	}
+	assert.Equal(t, Format, header.Format, "Format")
+	assert.Equal(t, 1, header.Schema, "Schema")
+	assert.Equal(t, encoding.NameGob, header.Codec, "Codec")
+	assert.Equal(t, len(values), count, "Count")
+
+	target := mock.NewStorageFake(t)
+	defer target.Close()
+	r := NewBackup(cnt, target.Store(), 2)
+	rheader, count, err := r.Restore(bytes.NewReader(archive.Bytes()))
+	if assert.NoError(t, err, "Restore") {
+		assert.Equal(t, header.Schema, rheader.Schema, "Header")
+		assert.Equal(t, len(values), count, "Count")
+		for k, v := range values {
+			_, value, err := target.Store().GetRaw(context.TODO(), k)
+			if assert.NoError(t, err) {
+				assert.Equal(t, v, value, k)
+			}
+		}
+		found, err := target.Store().Exists(context.TODO(), "Zother")
+		if assert.NoError(t, err) {
+			assert.False(t, found, "Key out of Carisa")
+		}
+	}
+
+	_, _, err = r.Restore(bytes.NewReader(archive.Bytes()))
+	assert.Equal(t, ErrNotEmpty, err, "Restore into not empty store")
+}
+
+func TestBackup_Verify(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+
+	if _, ok := sampling(t, mng.Store()); !ok {
+		return
+	}
+
+	b := NewBackup(cnt, mng.Store(), 10)
+	var archive bytes.Buffer
+	if _, _, err := b.Write(&archive); !assert.NoError(t, err, "Write") {
+		return
+	}
+
+	_, _, err := b.Verify(bytes.NewReader(archive.Bytes()))
+	assert.NoError(t, err, "Archive right")
+
+	_, _, err = b.Verify(bytes.NewReader([]byte("not archive")))
+	assert.Error(t, err, "Not archive")
+
+	// Changing the last byte of the value of the last key
+	gz, err := gzip.NewReader(bytes.NewReader(archive.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	raw, err := ioutil.ReadAll(gz)
+	if !assert.NoError(t, err) {
+		return
+	}
+	raw[len(raw)-34]++
+	var corrupted bytes.Buffer
+	w := gzip.NewWriter(&corrupted)
+	_, _ = w.Write(raw)
+	_ = w.Close()
+
+	_, _, err = b.Verify(bytes.NewReader(corrupted.Bytes()))
+	assert.Error(t, err, "Archive corrupted")
+}
+
+func TestArchive_TamperedLength(t *testing.T) {
+	var archive bytes.Buffer
+	w, err := newWriter(&archive, Header{Format: Format})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, w.record("key", "value")) || !assert.NoError(t, w.close()) {
+		return
+	}
+
+	// Moving the first byte of the value to the key
+	gz, err := gzip.NewReader(bytes.NewReader(archive.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	raw, err := ioutil.ReadAll(gz)
+	if !assert.NoError(t, err) {
+		return
+	}
+	raw = bytes.Replace(raw, []byte("\x03key\x05value"), []byte("\x04keyv\x04alue"), 1)
+	var tampered bytes.Buffer
+	gw := gzip.NewWriter(&tampered)
+	_, _ = gw.Write(raw)
+	_ = gw.Close()
+
+	r, err := newReader(bytes.NewReader(tampered.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	key, value, ok, err := r.record()
+	if assert.NoError(t, err) && assert.True(t, ok) {
+		assert.Equal(t, "keyv", key, "Key tampered")
+		assert.Equal(t, "alue", value, "Value tampered")
+	}
+	_, _, ok, err = r.record()
+	assert.False(t, ok, "End of records")
+	assert.EqualError(t, err, "the archive is corrupted. The checksum doesn't match")
+}
+
+// sampling stores keys of Carisa, the schema version and a key out of Carisa
+func sampling(t *testing.T, store storage.CRUD) (map[string]string, bool) {
+	values := map[string]string{
+		entity.InstKey(entity.NewDescriptor().ID):       "instance",
+		entity.SpaceKey(entity.NewDescriptor().ID):      "space",
+		entity.EnteKey(entity.NewDescriptor().ID):       "ente",
+		entity.CategoryKey(entity.NewDescriptor().ID):   "",
+		entity.TrashKey(entity.NewDescriptor().ID, "E"): "trash",
+	}
+	all := map[string]string{"Zother": "other"}
+	for k, v := range values {
+		all[k] = v
+	}
+	for k, v := range all {
+		txn := storage.NewTxn(store)
+		txn.Find(k)
+		txn.DoNotFound(store.PutRaw(k, v))
+		if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+			return nil, false
+		}
+	}
+
+	m := migrate.NewMigrator(mock.NewContainerFake(), store, 10, migrate.Step{Version: 1})
+	if _, err := m.Run(false); !assert.NoError(t, err) {
+		return nil, false
+	}
+	values[migrate.VersionKey] = "1"
+	return values, true
+}
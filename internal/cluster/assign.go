@@ -0,0 +1,430 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+// Pending is the owner of the works released that wait for a splitter. See WorkKey
+const Pending = "pending"
+
+// assignAttempts is the number of attempts to assign a work when other process changes the splitters meanwhile
+const assignAttempts = 3
+
+var (
+	// ErrNoSplitters is returned when there is no alive splitter to assign the work
+	ErrNoSplitters = errors.New("there is no alive splitter")
+	// errConflict is returned when the work can not be assigned after several attempts
+	errConflict = errors.New("the splitters changed during the assignment")
+)
+
+// SpaceWork returns the work of the space
+func SpaceWork(space string) string {
+	return strings.Concat("space:", space)
+}
+
+// EnteWork returns the work of the partition of the ente
+func EnteWork(ente string, partition int) string {
+	return strings.Concat("ente:", ente, ":", strconv.Itoa(partition))
+}
+
+// Assignment is a work assigned to a splitter
+type Assignment struct {
+	Work string
+	// Assigned is the time of the assignment
+	Assigned time.Time
+}
+
+// Assigner assigns the works, spaces or ente partitions, to the splitters. Each assignment is saved
+// as the owner of the work, see WorkKey, and as the work of the splitter, see AssignKey,
+// so each splitter watches its own works. The value of the AssignKey is the time of the assignment.
+// The works move between splitters in two phases, so a work is never processed by two splitters:
+// Handoff releases the work from its splitter and the splitter acquires the work for the other splitter
+// when it stops processing it. See Acquire
+type Assigner struct {
+	registry Registry
+	store    storage.CRUD
+	timeout  func(parent context.Context) (context.Context, context.CancelFunc)
+	batch    int
+	// fence adds the leadership of the job that runs the assigner to the transactions. See Singleton.Fence
+	fence func(txn storage.Txn)
+}
+
+// NewAssigner builds an assigner. The timeout is the timeout of the calls to the store,
+// see runtime.CommonConfig.StoreWithTimeout. The batch is the number of keys read by request
+func NewAssigner(
+	cnf Config,
+	store storage.CRUD,
+	timeout func(parent context.Context) (context.Context, context.CancelFunc),
+	batch int) Assigner {
+	//
+	return Assigner{
+		registry: NewRegistry(cnf, store, timeout, batch),
+		store:    store,
+		timeout:  timeout,
+		batch:    batch,
+	}
+}
+
+// Owner returns the splitter of the work. The owner is Pending if the work waits for a splitter.
+// Returns false if the work is not assigned
+func (a *Assigner) Owner(ctx context.Context, work string) (string, bool, error) {
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	found, owner, err := a.store.GetRaw(sctx, WorkKey(work))
+	return owner, found, err
+}
+
+// Assign assigns the work to the least-loaded alive splitter, the splitter with the lowest consumption measure.
+// If the work is already assigned returns its splitter. Returns true if the work is assigned now
+func (a *Assigner) Assign(ctx context.Context, work string) (string, bool, error) {
+	for i := 0; i < assignAttempts; i++ {
+		owner, found, err := a.Owner(ctx, work)
+		if err != nil {
+			return "", false, err
+		}
+		if found && owner != Pending {
+			return owner, false, nil
+		}
+		alive, err := a.alive(ctx)
+		if err != nil {
+			return "", false, err
+		}
+		if len(alive) == 0 {
+			return "", false, ErrNoSplitters
+		}
+		assigned, err := a.assign(ctx, work, owner, alive[0])
+		if err != nil {
+			return "", false, err
+		}
+		if assigned {
+			return alive[0].ID, true, nil
+		}
+	}
+	return "", false, errConflict
+}
+
+// AssignPending assigns the works released to the alive splitters. The works are spread among the
+// splitters sorted by consumption, so the least-loaded splitters receive the first works.
+// Returns the number of works assigned
+func (a *Assigner) AssignPending(ctx context.Context) (int, error) {
+	var pending []string
+	storeTimeout := func() (context.Context, context.CancelFunc) { return a.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, a.store, WorkPrefix, a.batch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			if values[key] == Pending {
+				pending = append(pending, key[len(WorkPrefix):])
+			}
+		}
+		return nil
+	})
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	alive, err := a.alive(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(alive) == 0 {
+		return 0, ErrNoSplitters
+	}
+
+	assigned := 0
+	for i, work := range pending {
+		ok, err := a.assign(ctx, work, Pending, alive[i%len(alive)])
+		if err != nil {
+			return assigned, err
+		}
+		if ok {
+			assigned++
+		}
+	}
+	return assigned, nil
+}
+
+// Unassign removes the assignment of the work. Returns false if the work is not assigned
+func (a *Assigner) Unassign(ctx context.Context, work string) (bool, error) {
+	owner, found, err := a.Owner(ctx, work)
+	if err != nil || !found {
+		return false, err
+	}
+	txn := a.newTxn()
+	txn.Match(WorkKey(work), owner)
+	txn.DoFound(a.store.Remove(WorkKey(work)))
+	if owner != Pending {
+		txn.DoFound(a.store.Remove(AssignKey(owner, work)))
+		txn.DoFound(a.store.Remove(HandoffKey(owner, work)))
+	}
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	return txn.Commit(sctx)
+}
+
+// Works returns the works assigned to the splitter
+func (a *Assigner) Works(ctx context.Context, id string) ([]string, error) {
+	assignments, err := a.Assignments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	works := make([]string, len(assignments))
+	for i, as := range assignments {
+		works[i] = as.Work
+	}
+	return works, nil
+}
+
+// Assignments returns the works assigned to the splitter with the time of their assignment
+func (a *Assigner) Assignments(ctx context.Context, id string) ([]Assignment, error) {
+	var assignments []Assignment
+	prefix := AssignKey(id, "")
+	storeTimeout := func() (context.Context, context.CancelFunc) { return a.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, a.store, prefix, a.batch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			assigned, _ := time.Parse(time.RFC3339, values[key])
+			assignments = append(assignments, Assignment{Work: key[len(prefix):], Assigned: assigned})
+		}
+		return nil
+	})
+	return assignments, err
+}
+
+// Handoff releases the work of the splitter from to move it to the splitter to.
+// The work is acquired by the splitter to when the splitter from stops processing it. See Acquire.
+// Returns false if the work is not assigned to the splitter from
+func (a *Assigner) Handoff(ctx context.Context, work string, from string, to Splitter) (bool, error) {
+	txn := a.newTxn()
+	txn.Find(AssignKey(from, work))
+	txn.Match(WorkKey(work), from)
+	txn.DoFound(a.store.Remove(AssignKey(from, work)))
+	txn.DoFound(a.store.PutRaw(HandoffKey(from, work), strings.Concat(to.ID, "#", FormatStarted(to.Started))))
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	return txn.Commit(sctx)
+}
+
+// Acquire completes the handoff of the work released by the splitter from. The work is assigned to
+// the splitter of the handoff or it waits for a splitter if that splitter is removed meanwhile.
+// Returns false if the work is not released by a handoff
+func (a *Assigner) Acquire(ctx context.Context, from string, work string) (bool, error) {
+	sctx, cancel := a.timeout(ctx)
+	found, value, err := a.store.GetRaw(sctx, HandoffKey(from, work))
+	cancel()
+	if err != nil || !found {
+		return false, err
+	}
+
+	if len(value) > idLen+1 && value[idLen] == '#' {
+		to, started := value[:idLen], value[idLen+1:]
+		txn := a.newTxn()
+		txn.Find(HandoffKey(from, work))
+		txn.Match(WorkKey(work), from)
+		txn.Match(InfoKey(to), started)
+		txn.DoFound(a.store.Remove(HandoffKey(from, work)))
+		txn.DoFound(a.store.PutRaw(WorkKey(work), to))
+		txn.DoFound(a.store.PutRaw(AssignKey(to, work), FormatStarted(time.Now())))
+		sctx, cancel := a.timeout(ctx)
+		acquired, err := txn.Commit(sctx)
+		cancel()
+		if err != nil || acquired {
+			return acquired, err
+		}
+	}
+
+	// The splitter that acquires is removed, so the work waits for other splitter
+	txn := a.newTxn()
+	txn.Find(HandoffKey(from, work))
+	txn.Match(WorkKey(work), from)
+	txn.DoFound(a.store.Remove(HandoffKey(from, work)))
+	txn.DoFound(a.store.PutRaw(WorkKey(work), Pending))
+	sctx, cancel = a.timeout(ctx)
+	defer cancel()
+	return txn.Commit(sctx)
+}
+
+// Release releases the works of the splitter, so they wait for other splitter. See AssignPending.
+// The handoffs of the splitter are completed because it does not process any work.
+// A work is released only if the splitter is still its owner, the assignments of the works
+// that have other owner are removed.
+// Returns the number of works released
+func (a *Assigner) Release(ctx context.Context, id string) (int, error) {
+	if err := a.acquireAll(ctx, id); err != nil {
+		return 0, err
+	}
+
+	released := 0
+	prefix := AssignKey(id, "")
+	storeTimeout := func() (context.Context, context.CancelFunc) { return a.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, a.store, prefix, a.batch, func(keys []string, _ map[string]string) error {
+		txn := a.newTxn()
+		txn.Find(keys[0])
+		for _, key := range keys {
+			work := key[len(prefix):]
+			txn.Match(WorkKey(work), id)
+			txn.DoFound(a.store.Remove(key))
+			txn.DoFound(a.store.PutRaw(WorkKey(work), Pending))
+		}
+		sctx, cancel := a.timeout(ctx)
+		ok, err := txn.Commit(sctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if ok {
+			released += len(keys)
+			return nil
+		}
+		// Some work of the batch has other owner, so the works are released one by one
+		for _, key := range keys {
+			ok, err := a.releaseWork(ctx, id, key[len(prefix):])
+			if err != nil {
+				return err
+			}
+			if ok {
+				released++
+				continue
+			}
+			if err := a.removeAssign(ctx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return released, err
+}
+
+// removeAssign removes the assignment of a work whose owner is other splitter
+func (a *Assigner) removeAssign(ctx context.Context, key string) error {
+	txn := a.newTxn()
+	txn.Find(key)
+	txn.DoFound(a.store.Remove(key))
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	_, err := txn.Commit(sctx)
+	return err
+}
+
+// releaseWork releases the work if the splitter is still its owner. Returns false if the owner changed
+func (a *Assigner) releaseWork(ctx context.Context, id string, work string) (bool, error) {
+	txn := a.newTxn()
+	txn.Match(WorkKey(work), id)
+	txn.DoFound(a.store.Remove(AssignKey(id, work)))
+	txn.DoFound(a.store.PutRaw(WorkKey(work), Pending))
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	return txn.Commit(sctx)
+}
+
+// Handoffs returns the works released by the splitter that are not acquired yet
+func (a *Assigner) Handoffs(ctx context.Context, from string) ([]string, error) {
+	var works []string
+	prefix := HandoffKey(from, "")
+	storeTimeout := func() (context.Context, context.CancelFunc) { return a.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, a.store, prefix, a.batch, func(keys []string, _ map[string]string) error {
+		for _, key := range keys {
+			works = append(works, key[len(prefix):])
+		}
+		return nil
+	})
+	return works, err
+}
+
+// acquireAll completes the handoffs of the works released by the splitter
+func (a *Assigner) acquireAll(ctx context.Context, from string) error {
+	works, err := a.Handoffs(ctx, from)
+	if err != nil {
+		return err
+	}
+	for _, work := range works {
+		if _, err := a.Acquire(ctx, from, work); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign assigns the work to the splitter if the owner of the work is not changed and
+// the splitter is not removed meanwhile. The owner is empty if the work is not assigned
+func (a *Assigner) assign(ctx context.Context, work string, owner string, s Splitter) (bool, error) {
+	txn := a.newTxn()
+	txn.Match(InfoKey(s.ID), FormatStarted(s.Started))
+	put := []storage.OpeWrap{
+		a.store.PutRaw(WorkKey(work), s.ID),
+		a.store.PutRaw(AssignKey(s.ID, work), FormatStarted(time.Now())),
+	}
+	if len(owner) == 0 {
+		txn.Find(WorkKey(work))
+		for _, ope := range put {
+			txn.DoNotFound(ope)
+		}
+	} else {
+		txn.Match(WorkKey(work), owner)
+		for _, ope := range put {
+			txn.DoFound(ope)
+		}
+	}
+	sctx, cancel := a.timeout(ctx)
+	defer cancel()
+	return txn.Commit(sctx)
+}
+
+// newTxn builds a transaction fenced by the leadership of the job if the assigner runs into a job
+func (a *Assigner) newTxn() storage.Txn {
+	txn := storage.NewTxn(a.store)
+	if a.fence != nil {
+		a.fence(txn)
+	}
+	return txn
+}
+
+// alive returns the alive splitters sorted by consumption. The consumptions are scanned in the order of
+// their keys, see ConsumptionKey, and the splitters without consumption go first
+func (a *Assigner) alive(ctx context.Context) ([]Splitter, error) {
+	splitters, err := a.registry.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unmeasured := make(map[string]Splitter, len(splitters))
+	for _, s := range splitters {
+		if s.Status == Alive && !s.Started.IsZero() {
+			unmeasured[s.ID] = s
+		}
+	}
+	var measured []Splitter
+	err = a.registry.scan(ctx, ConsumptionPrefix, func(_ string, id string, _ string) {
+		if s, ok := unmeasured[id]; ok {
+			measured = append(measured, s)
+			delete(unmeasured, id)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	alive := make([]Splitter, 0, len(unmeasured)+len(measured))
+	for _, s := range splitters {
+		if _, ok := unmeasured[s.ID]; ok {
+			alive = append(alive, s)
+		}
+	}
+	return append(alive, measured...), nil
+}
@@ -0,0 +1,312 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	cold = "c0ob7bbu5ln0vjkdm7l0"
+	hot  = "c0ob7bbu5ln0vjkdm7l1"
+)
+
+func TestAssigner_Assign(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+
+	_, _, err := a.Assign(ctx, "space1")
+	assert.Equal(t, ErrNoSplitters, err, "Without splitters")
+
+	if !putSplitters(t, store) {
+		return
+	}
+	id, assigned, err := a.Assign(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.True(t, assigned, "Assigned")
+		assert.Equal(t, cold, id, "Least-loaded")
+	}
+	id, assigned, err = a.Assign(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.False(t, assigned, "Already assigned")
+		assert.Equal(t, cold, id, "Owner")
+	}
+	works, err := a.Works(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"space1"}, works, "Works")
+	}
+
+	owner, found, err := a.Owner(ctx, "space1")
+	if assert.NoError(t, err) && assert.True(t, found) {
+		assert.Equal(t, cold, owner, "Owner")
+	}
+	removed, err := a.Unassign(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.True(t, removed, "Unassigned")
+	}
+	removed, err = a.Unassign(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.False(t, removed, "Not assigned")
+	}
+	works, err = a.Works(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Empty(t, works, "Without works")
+	}
+}
+
+func TestAssigner_AssignRemoved(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) {
+		return
+	}
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	splitters, err := a.alive(ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, splitters, 2) {
+		return
+	}
+
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(cold))
+	txn.DoFound(store.Remove(InfoKey(cold)))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	assigned, err := a.assign(ctx, "space1", "", splitters[0])
+	if assert.NoError(t, err) {
+		assert.False(t, assigned, "The splitter is removed meanwhile")
+	}
+	id, _, err := a.Assign(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hot, id, "The alive splitter")
+	}
+}
+
+func TestAssigner_ReleasePending(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) {
+		return
+	}
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	works := []string{"space1", "space2", "space3"}
+	for _, work := range works {
+		if _, _, err := a.Assign(ctx, work); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	released, err := a.Release(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, released, "Released")
+	}
+	owner, _, err := a.Owner(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Pending, owner, "Pending")
+	}
+
+	assigned, err := a.AssignPending(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, assigned, "Assigned")
+	}
+	coldWorks, err := a.Works(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"space1", "space3"}, coldWorks, "Spread into the least-loaded first")
+	}
+	hotWorks, err := a.Works(ctx, hot)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"space2"}, hotWorks, "Spread")
+	}
+	assigned, err = a.AssignPending(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, assigned, "Without pending works")
+	}
+}
+
+func TestAssigner_ReleaseOtherOwner(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) {
+		return
+	}
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	for _, work := range []string{"space1", "space2", "space3"} {
+		if _, _, err := a.Assign(ctx, work); !assert.NoError(t, err) {
+			return
+		}
+	}
+	txn := storage.NewTxn(store)
+	txn.Find(WorkKey("space2"))
+	txn.DoFound(store.PutRaw(WorkKey("space2"), hot))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	released, err := a.Release(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, released, "Released the works of the splitter")
+	}
+	owner, _, err := a.Owner(ctx, "space2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hot, owner, "The other owner is kept")
+	}
+	owner, _, err = a.Owner(ctx, "space3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Pending, owner, "Pending")
+	}
+	works, err := a.Works(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Empty(t, works, "Without assignments")
+	}
+}
+
+func TestAssigner_AssignWithError(t *testing.T) {
+	store := &storage.ErrMockCRUD{}
+	store.Activate("GetRaw")
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+
+	_, _, err := a.Assign(context.TODO(), "space1")
+	assert.Error(t, err)
+}
+
+// putSplitters puts two alive splitters. The cold splitter has a lower consumption with less digits
+func putSplitters(t *testing.T, store storage.CRUD) bool {
+	now := time.Now()
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(cold))
+	for id, measure := range map[string]int{cold: 999, hot: 1998} {
+		txn.DoNotFound(store.PutRaw(InfoKey(id), FormatStarted(now)))
+		txn.DoNotFound(store.PutRaw(TickKey(FormatTick(now), id), id))
+		txn.DoNotFound(store.PutRaw(ConsumptionKey(measure, id), "1024"))
+	}
+	_, err := txn.Commit(context.TODO())
+	return assert.NoError(t, err)
+}
+
+func TestAssigner_Handoff(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) {
+		return
+	}
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	alive, err := a.alive(ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, alive, 2) {
+		return
+	}
+	if _, _, err := a.Assign(ctx, "space1"); !assert.NoError(t, err) {
+		return
+	}
+
+	moved, err := a.Handoff(ctx, "space1", cold, alive[1])
+	if assert.NoError(t, err) {
+		assert.True(t, moved, "Released")
+	}
+	moved, err = a.Handoff(ctx, "space1", cold, alive[1])
+	if assert.NoError(t, err) {
+		assert.False(t, moved, "Already released")
+	}
+	works, err := a.Works(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Empty(t, works, "The splitter stops processing the work")
+	}
+	owner, _, err := a.Owner(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, cold, owner, "The owner until the work is acquired")
+	}
+
+	acquired, err := a.Acquire(ctx, cold, "space1")
+	if assert.NoError(t, err) {
+		assert.True(t, acquired, "Acquired")
+	}
+	acquired, err = a.Acquire(ctx, cold, "space1")
+	if assert.NoError(t, err) {
+		assert.False(t, acquired, "Without handoff")
+	}
+	works, err = a.Works(ctx, hot)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"space1"}, works, "Moved")
+	}
+	owner, _, err = a.Owner(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hot, owner, "New owner")
+	}
+}
+
+func TestAssigner_AcquireRemoved(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) {
+		return
+	}
+	a := NewAssigner(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	alive, err := a.alive(ctx)
+	if !assert.NoError(t, err) || !assert.Len(t, alive, 2) {
+		return
+	}
+	for _, work := range []string{"space1", "space2"} {
+		if _, _, err := a.Assign(ctx, work); !assert.NoError(t, err) {
+			return
+		}
+		if _, err := a.Handoff(ctx, work, cold, alive[1]); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(hot))
+	txn.DoFound(store.Remove(InfoKey(hot)))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	acquired, err := a.Acquire(ctx, cold, "space1")
+	if assert.NoError(t, err) {
+		assert.True(t, acquired, "Acquired")
+	}
+	owner, _, err := a.Owner(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Pending, owner, "The splitter that acquires is removed")
+	}
+
+	released, err := a.Release(ctx, cold)
+	if assert.NoError(t, err) {
+		assert.Zero(t, released, "Without works")
+	}
+	owner, _, err = a.Owner(ctx, "space2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, Pending, owner, "The release completes the handoffs")
+	}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package cluster describes the splitters of the cluster. Each splitter registers its start time,
+// renews its tick each heartbeat and saves its consumption into the store. See the splitter service.Controller.
+// The keys of each splitter are attached to its lease, so they are removed by the store when the splitter dies.
+// The keys of the splitters are out of any namespace
+package cluster
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+const (
+	// Prefix is the prefix of all keys of the splitters
+	Prefix = "splitter#"
+	// TickPrefix is the prefix of the ticks. The key is the tick and the splitter ID, so the ticks are sorted by time.
+	// The value is the lease of the splitter
+	TickPrefix = "splitter#tick#"
+	// ConsumptionPrefix is the prefix of the consumptions. The key is the measure padded with zeros and
+	// the splitter ID, so a range scan returns the least-loaded splitter first
+	ConsumptionPrefix = "splitter#cons#"
+	// InfoPrefix is the prefix of the information of each splitter. The value is the start time
+	InfoPrefix = "splitter#info#"
+	// AssignPrefix is the prefix of the work assigned to each splitter. The key is the splitter ID and the work
+	AssignPrefix = "splitter#assign#"
+	// WorkPrefix is the prefix of the owner of each work. The key is the work and the value is the splitter ID
+	WorkPrefix = "splitter#work#"
+	// HandoffPrefix is the prefix of the works that move between splitters. The key is the splitter ID that releases
+	// the work and the work. The value is the splitter ID that acquires the work and its start time
+	HandoffPrefix = "splitter#handoff#"
+
+	// TickLayout is the layout of the ticks. The ticks are in UTC
+	TickLayout = "20060102150405"
+
+	// idLen is the length of the identifier of the splitters
+	idLen = 20
+	// measureLen is the length of the consumption measure into the key, the digits of the max int64
+	measureLen = 19
+)
+
+// Config describes the heartbeat of the splitters seen by the rest of the cluster
+type Config struct {
+	// HeartbeatInSecs is the interval of the heartbeat of the splitters.
+	// It should be the same as the splitter runtime.Config.RenewHeartbeatInSecs
+	HeartbeatInSecs time.Duration `json:"heartbeatInSecs,omitempty"`
+	// DeadAfter is the number of heartbeats without renewing the tick after which the splitter is dead
+	DeadAfter int `json:"deadAfter,omitempty"`
+}
+
+// Deadline returns the time since the last tick after which the splitter is dead
+func (c Config) Deadline() time.Duration {
+	return time.Duration(c.DeadAfter) * c.HeartbeatInSecs * time.Second
+}
+
+// FormatTick formats the time as tick
+func FormatTick(t time.Time) string {
+	return t.UTC().Format(TickLayout)
+}
+
+// TickKey returns the key of the tick of the splitter
+func TickKey(tick string, id string) string {
+	return strings.Concat(TickPrefix, tick, id)
+}
+
+// ConsumptionKey returns the key of the consumption measure of the splitter
+func ConsumptionKey(measure int, id string) string {
+	return strings.Concat(ConsumptionPrefix, fmt.Sprintf("%0*d", measureLen, measure), id)
+}
+
+// InfoKey returns the key of the information of the splitter
+func InfoKey(id string) string {
+	return strings.Concat(InfoPrefix, id)
+}
+
+// AssignKey returns the key of the work assigned to the splitter
+func AssignKey(id string, work string) string {
+	return strings.Concat(AssignPrefix, id, "#", work)
+}
+
+// WorkKey returns the key of the owner of the work
+func WorkKey(work string) string {
+	return strings.Concat(WorkPrefix, work)
+}
+
+// HandoffKey returns the key of the work that the splitter releases to other splitter
+func HandoffKey(id string, work string) string {
+	return strings.Concat(HandoffPrefix, id, "#", work)
+}
+
+// FormatStarted formats the start time as value of the InfoKey
+func FormatStarted(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// FormatLease formats the lease of the splitter as value of the tick
+func FormatLease(id storage.LeaseID) string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// parseLease parses the value of the tick. If it is not valid returns false
+func parseLease(value string) (storage.LeaseID, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return storage.LeaseID(id), true
+}
+
+// split splits the key of the prefix into its value and the splitter ID.
+// If the key is not valid returns false
+func split(key string, prefix string) (string, string, bool) {
+	if len(key) < len(prefix)+idLen {
+		return "", "", false
+	}
+	value := key[len(prefix) : len(key)-idLen]
+	return value, key[len(key)-idLen:], true
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const id = "c0ob7bbu5ln0vjkdm7l0"
+
+func TestCluster_Keys(t *testing.T) {
+	tick := time.Date(2021, time.November, 15, 23, 59, 59, 0, time.FixedZone("CET", 3600))
+
+	assert.Equal(t, "20211115225959", FormatTick(tick), "Tick into UTC")
+	assert.Equal(t, "splitter#tick#20211115225959c0ob7bbu5ln0vjkdm7l0", TickKey(FormatTick(tick), id), "Tick")
+	assert.Equal(t, "splitter#cons#0000000000001000512c0ob7bbu5ln0vjkdm7l0", ConsumptionKey(1000512, id), "Consumption")
+	assert.Equal(t, "splitter#info#c0ob7bbu5ln0vjkdm7l0", InfoKey(id), "Information")
+	assert.Equal(t, "splitter#assign#c0ob7bbu5ln0vjkdm7l0#space1", AssignKey(id, "space1"), "Assignment")
+	assert.Equal(t, "splitter#work#space:c0ob7bbu5ln0vjkdm7l1", WorkKey(SpaceWork("c0ob7bbu5ln0vjkdm7l1")), "Space work")
+	assert.Equal(t, "ente:c0ob7bbu5ln0vjkdm7l1:3", EnteWork("c0ob7bbu5ln0vjkdm7l1", 3), "Ente work")
+	assert.Equal(t, "2021-11-15T22:59:59Z", FormatStarted(tick), "Started")
+}
+
+func TestCluster_Split(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		prefix string
+		value  string
+		id     string
+		ok     bool
+	}{
+		{
+			name:   "Tick",
+			key:    TickKey("20211115225959", id),
+			prefix: TickPrefix,
+			value:  "20211115225959",
+			id:     id,
+			ok:     true,
+		},
+		{
+			name:   "Information",
+			key:    InfoKey(id),
+			prefix: InfoPrefix,
+			id:     id,
+			ok:     true,
+		},
+		{
+			name:   "Key without ID",
+			key:    "splitter#tick#2021",
+			prefix: TickPrefix,
+		},
+	}
+	for _, tt := range tests {
+		value, id, ok := split(tt.key, tt.prefix)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		assert.Equal(t, tt.value, value, tt.name)
+		assert.Equal(t, tt.id, id, tt.name)
+	}
+}
+
+func TestCluster_Deadline(t *testing.T) {
+	cnf := Config{HeartbeatInSecs: 15, DeadAfter: 3}
+	assert.Equal(t, 45*time.Second, cnf.Deadline())
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+// Singleton runs a job on a single leader among the processes that start it. The processes campaign
+// for the leadership and the leader runs a round of the job each interval, or when the job is triggered,
+// until it stops or it loses the leadership
+type Singleton struct {
+	name     string
+	loc      string
+	holder   string
+	every    time.Duration
+	election storage.Election
+	timeout  func(parent context.Context) (context.Context, context.CancelFunc)
+	log      logging.Logger
+	// round runs a round of the job. The errors are logged by the round
+	round func(ctx context.Context)
+
+	mu         sync.Mutex
+	leadership *storage.Leadership
+	trigger    chan struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSingleton builds the job. The name is the name of the election and the holder identifies the process
+// into the election. The timeout is the timeout of the calls to the store, see runtime.CommonConfig.StoreWithTimeout
+func NewSingleton(
+	name string,
+	loc string,
+	holder string,
+	every time.Duration,
+	elections storage.Elections,
+	timeout func(parent context.Context) (context.Context, context.CancelFunc),
+	log logging.Logger,
+	round func(ctx context.Context)) *Singleton {
+	//
+	return &Singleton{
+		name:     name,
+		loc:      loc,
+		holder:   holder,
+		every:    every,
+		election: elections.Election(name),
+		timeout:  timeout,
+		log:      log,
+		round:    round,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Start starts the job in background
+func (s *Singleton) Start() {
+	s.log.Info(strings.Concat("starting the ", s.name), s.loc)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// Stop stops the job, waits until the actual round ends and resigns the leadership
+func (s *Singleton) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	ctx, cancel := s.timeout(context.Background())
+	defer cancel()
+	if err := s.election.Resign(ctx); err != nil {
+		_ = s.log.ErrWrap(err, strings.Concat("resigning the leadership of the ", s.name), s.loc)
+	}
+	s.log.Info(strings.Concat("stopped the ", s.name), s.loc)
+}
+
+// Notify requests a round without waiting for the interval. The requests are merged until the round runs
+func (s *Singleton) Notify() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Leader returns true if the process is the leader of the job
+func (s *Singleton) Leader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leadership != nil
+}
+
+// Fence adds to the transaction the condition that the process is still the leader of the job,
+// so the transactions of a round are not applied after the leadership is lost. See storage.Leadership.Fence
+func (s *Singleton) Fence(txn storage.Txn) {
+	s.mu.Lock()
+	l := s.leadership
+	s.mu.Unlock()
+	if l != nil {
+		l.Fence(txn)
+	}
+}
+
+// run campaigns until the process is elected and runs the rounds while it is the leader
+func (s *Singleton) run(ctx context.Context) {
+	defer close(s.done)
+	for {
+		l, err := s.campaign(ctx)
+		if ctx.Err() != nil { // The stop requests to terminate
+			return
+		}
+		if err != nil {
+			_ = s.log.ErrWrap(err, strings.Concat("campaigning for the leadership of the ", s.name), s.loc)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.every):
+			}
+			continue
+		}
+		s.lead(ctx, l)
+	}
+}
+
+// campaign blocks until the process is the leader of the job
+func (s *Singleton) campaign(ctx context.Context) (storage.Leadership, error) {
+	l, err := s.election.Campaign(ctx, s.holder)
+	if err != nil {
+		return l, err
+	}
+	s.log.Info2(
+		strings.Concat("elected leader of the ", s.name),
+		s.loc,
+		logging.String("holder", s.holder),
+		logging.String("token", strconv.FormatInt(l.Token, 10)))
+	s.setLeadership(&l)
+	return l, nil
+}
+
+// lead runs the rounds until the stop or the loss of the leadership
+func (s *Singleton) lead(ctx context.Context, l storage.Leadership) {
+	defer s.setLeadership(nil)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-l.Done:
+			s.log.Warn1(strings.Concat("the leadership of the ", s.name, " is lost"), s.loc, logging.String("holder", s.holder))
+			return
+		case <-s.trigger:
+			s.round(ctx)
+		case <-time.After(s.every):
+			s.round(ctx)
+		}
+	}
+}
+
+func (s *Singleton) setLeadership(l *storage.Leadership) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leadership = l
+}
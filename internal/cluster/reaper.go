@@ -0,0 +1,351 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+const (
+	locReaper = "cluster.reaper"
+	// reaperJob is the name of the election of the reaper
+	reaperJob = "reaper"
+	// reapBatch is the number of keys read by request
+	reapBatch = 50
+)
+
+// EventKind is the type of the event of the cluster
+type EventKind string
+
+// DeadEvent is emitted when a dead splitter is removed from the cluster
+const DeadEvent EventKind = "dead"
+
+// Event is a change of the splitters of the cluster
+type Event struct {
+	Kind     EventKind `json:"kind"`
+	Splitter string    `json:"splitter"`
+	// Heartbeat is the time of the last tick of the splitter
+	Heartbeat time.Time `json:"heartbeat"`
+	// Released is the number of works assigned to the splitter that are released
+	Released int `json:"released"`
+}
+
+// Reaper finds the splitters whose last tick is older than Config.Deadline, removes their ticks,
+// information and consumptions, revokes their leases, releases their assignments and emits a DeadEvent
+// for each splitter. The splitter that is only late can not renew its keys without lease, so it registers again.
+// The assignments are not attached to the lease, so the reaper also releases the works of the splitters
+// whose keys were removed by the expiration of their leases before any round saw their ticks.
+// Each round the released works are assigned to the alive splitters. See Assigner.AssignPending.
+// The reaper runs on a single leader among all processes that start it. The processes campaign for the
+// leadership and the leader reaps each round until it stops or it loses the leadership.
+// The transactions of the round are fenced by the leadership, so an old leader can not apply them
+type Reaper struct {
+	cnf      Config
+	job      *Singleton
+	assigner Assigner
+	store    storage.CRUD
+	timeout  func(parent context.Context) (context.Context, context.CancelFunc)
+	log      logging.Logger
+
+	mu          sync.Mutex
+	subscribers []func(Event)
+}
+
+// NewReaper builds a Reaper that reaps every interval. The holder identifies the process into the election.
+// The timeout is the timeout of the calls to the store, see runtime.CommonConfig.StoreWithTimeout
+func NewReaper(
+	cnf Config,
+	every time.Duration,
+	holder string,
+	elections storage.Elections,
+	store storage.CRUD,
+	timeout func(parent context.Context) (context.Context, context.CancelFunc),
+	log logging.Logger) *Reaper {
+	//
+	r := &Reaper{
+		cnf:      cnf,
+		assigner: NewAssigner(cnf, store, timeout, reapBatch),
+		store:    store,
+		timeout:  timeout,
+		log:      log,
+	}
+	r.job = NewSingleton(reaperJob, locReaper, holder, every, elections, timeout, log, func(ctx context.Context) {
+		_, _ = r.Round(ctx) // The errors are logged by the round
+	})
+	r.assigner.fence = r.job.Fence
+	return r
+}
+
+// Subscribe adds the function that receives the events of the cluster
+func (r *Reaper) Subscribe(fn func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Start starts the reaper in background
+func (r *Reaper) Start() {
+	r.job.Start()
+}
+
+// Stop stops the reaper, waits until the actual round ends and resigns the leadership
+func (r *Reaper) Stop() {
+	r.job.Stop()
+}
+
+// Round reaps the dead splitters if the process is the leader. Returns the events emitted
+func (r *Reaper) Round(ctx context.Context) ([]Event, error) {
+	if !r.job.Leader() {
+		return nil, nil
+	}
+	events, err := r.reap(ctx, time.Now().Add(-r.cnf.Deadline()))
+	if err != nil {
+		return events, err
+	}
+	orphans, err := r.reapOrphans(ctx)
+	events = append(events, orphans...)
+	if err != nil {
+		return events, err
+	}
+
+	assigned, err := r.assigner.AssignPending(ctx)
+	if err == ErrNoSplitters {
+		r.log.Warn("the pending works wait for an alive splitter", locReaper)
+		return events, nil
+	}
+	if err != nil {
+		return events, r.log.ErrWrap(err, "assigning the pending works", locReaper)
+	}
+	if assigned > 0 {
+		r.log.Info1("assigned the pending works", locReaper, logging.String("works", strconv.Itoa(assigned)))
+	}
+	return events, nil
+}
+
+// reap removes the splitters whose ticks are not after the deadline.
+// The ticks are sorted by time, so they are scanned until the tick of the deadline
+func (r *Reaper) reap(ctx context.Context, deadline time.Time) ([]Event, error) {
+	dead := make(map[string]string)   // The tick key by splitter
+	leases := make(map[string]string) // The lease by splitter
+	var ids []string
+	storeTimeout := func() (context.Context, context.CancelFunc) { return r.timeout(ctx) }
+	end := strings.Concat(TickPrefix, FormatTick(deadline))
+	err := storage.ScanRangeRaw(storeTimeout, r.store, TickPrefix, end, reapBatch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			if _, id, ok := split(key, TickPrefix); ok {
+				if _, ok := dead[id]; !ok {
+					ids = append(ids, id)
+				}
+				dead[id] = key
+				leases[id] = values[key]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, r.log.ErrWrap(err, "scanning the ticks", locReaper)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	consumptions, err := r.consumptions(ctx, dead)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(ids))
+	for _, id := range ids {
+		event, removed, err := r.remove(ctx, id, dead[id], leases[id], consumptions[id])
+		if err != nil {
+			return events, err
+		}
+		if removed {
+			events = append(events, event)
+			r.emit(event)
+		}
+	}
+	return events, nil
+}
+
+// consumptions returns the consumption keys of the splitters
+func (r *Reaper) consumptions(ctx context.Context, splitters map[string]string) (map[string][]string, error) {
+	keys := make(map[string][]string)
+	storeTimeout := func() (context.Context, context.CancelFunc) { return r.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, r.store, ConsumptionPrefix, reapBatch, func(ks []string, _ map[string]string) error {
+		for _, key := range ks {
+			if _, id, ok := split(key, ConsumptionPrefix); ok {
+				if _, dead := splitters[id]; dead {
+					keys[id] = append(keys[id], key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, r.log.ErrWrap(err, "scanning the consumptions", locReaper)
+	}
+	return keys, nil
+}
+
+// remove removes the keys of the splitter if its tick is not renewed meanwhile, revokes its lease
+// and releases its assignments
+func (r *Reaper) remove(ctx context.Context, id string, tick string, lease string, consumptions []string) (Event, bool, error) {
+	txn := storage.NewTxn(r.store)
+	r.job.Fence(txn)
+	txn.Find(tick)
+	txn.DoFound(r.store.Remove(tick))
+	txn.DoFound(r.store.Remove(InfoKey(id)))
+	for _, key := range consumptions {
+		txn.DoFound(r.store.Remove(key))
+	}
+	sctx, cancel := r.timeout(ctx)
+	removed, err := txn.Commit(sctx)
+	cancel()
+	if err != nil {
+		return Event{}, false, r.log.ErrWrap1(err, "removing the dead splitter", locReaper, logging.String("splitter", id))
+	}
+	if !removed {
+		return Event{}, false, nil // The splitter renewed its tick
+	}
+	r.revoke(ctx, id, lease)
+
+	released, err := r.assigner.Release(ctx, id)
+	if err != nil {
+		return Event{}, false, r.log.ErrWrap1(err, "releasing the assignments", locReaper, logging.String("splitter", id))
+	}
+	event := Event{Kind: DeadEvent, Splitter: id, Released: released}
+	if value, _, ok := split(tick, TickPrefix); ok {
+		event.Heartbeat, _ = time.ParseInLocation(TickLayout, value, time.UTC)
+	}
+	return event, true, nil
+}
+
+// reapOrphans releases the works of the splitters without information. Their leases expired and the store
+// removed their ticks, information and consumptions, but the assignments and the owners of the works remain
+func (r *Reaper) reapOrphans(ctx context.Context) ([]Event, error) {
+	owned, ids, err := r.owners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, id := range ids {
+		sctx, cancel := r.timeout(ctx)
+		found, err := r.store.Exists(sctx, InfoKey(id))
+		cancel()
+		if err != nil {
+			return events, r.log.ErrWrap1(err, "finding the information of the splitter", locReaper, logging.String("splitter", id))
+		}
+		if found {
+			continue
+		}
+
+		released, err := r.assigner.Release(ctx, id)
+		if err != nil {
+			return events, r.log.ErrWrap1(err, "releasing the assignments", locReaper, logging.String("splitter", id))
+		}
+		// The works whose assignments are lost, for example a handoff removed meanwhile
+		for _, work := range owned[id] {
+			ok, err := r.assigner.releaseWork(ctx, id, work)
+			if err != nil {
+				return events, r.log.ErrWrap1(err, "releasing the work", locReaper, logging.String("splitter", id))
+			}
+			if ok {
+				released++
+			}
+		}
+		event := Event{Kind: DeadEvent, Splitter: id, Released: released}
+		events = append(events, event)
+		r.emit(event)
+	}
+	return events, nil
+}
+
+// owners returns the works by owner and the splitters that own works, assignments or handoffs
+func (r *Reaper) owners(ctx context.Context) (map[string][]string, []string, error) {
+	owned := make(map[string][]string)
+	var ids []string
+	add := func(id string) {
+		if _, ok := owned[id]; !ok {
+			owned[id] = nil
+			ids = append(ids, id)
+		}
+	}
+	storeTimeout := func() (context.Context, context.CancelFunc) { return r.timeout(ctx) }
+	err := storage.ScanRaw(storeTimeout, r.store, WorkPrefix, reapBatch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			if owner := values[key]; len(owner) == idLen {
+				add(owner)
+				owned[owner] = append(owned[owner], key[len(WorkPrefix):])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, r.log.ErrWrap(err, "scanning the owners of the works", locReaper)
+	}
+	for _, prefix := range []string{AssignPrefix, HandoffPrefix} {
+		prefix := prefix
+		err := storage.ScanRaw(storeTimeout, r.store, prefix, reapBatch, func(keys []string, _ map[string]string) error {
+			for _, key := range keys {
+				if len(key) > len(prefix)+idLen {
+					add(key[len(prefix) : len(prefix)+idLen])
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, r.log.ErrWrap1(err, "scanning the assignments", locReaper, logging.String("prefix", prefix))
+		}
+	}
+	return owned, ids, nil
+}
+
+// revoke revokes the lease of the splitter removed. If it fails the lease expires
+// because the splitter is late to keep it alive
+func (r *Reaper) revoke(ctx context.Context, id string, lease string) {
+	leaseID, ok := parseLease(lease)
+	if !ok {
+		return
+	}
+	sctx, cancel := r.timeout(ctx)
+	err := r.store.Revoke(sctx, leaseID)
+	cancel()
+	if err != nil && err != storage.ErrLeaseNotFound {
+		_ = r.log.ErrWrap1(err, "revoking the lease of the dead splitter", locReaper, logging.String("splitter", id))
+	}
+}
+
+func (r *Reaper) emit(event Event) {
+	r.log.Info2(
+		"the splitter is dead",
+		locReaper,
+		logging.String("splitter", event.Splitter),
+		logging.String("heartbeat", event.Heartbeat.String()))
+	r.mu.Lock()
+	subscribers := r.subscribers
+	r.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
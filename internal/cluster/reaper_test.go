@@ -0,0 +1,286 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaper_Round(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+
+	now := time.Now()
+	dead, alive := "c0ob7bbu5ln0vjkdm7l0", "c0ob7bbu5ln0vjkdm7l1"
+	old := now.Add(-time.Minute)
+	deadKeys := []string{
+		TickKey(FormatTick(old), dead),
+		InfoKey(dead),
+		ConsumptionKey(1000512, dead),
+		AssignKey(dead, "space1"),
+		AssignKey(dead, "space2"),
+	}
+	aliveKeys := []string{
+		TickKey(FormatTick(now), alive),
+		InfoKey(alive),
+		ConsumptionKey(1000256, alive),
+		AssignKey(alive, "space3"),
+	}
+	lease, err := store.Grant(ctx, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(dead))
+	for _, key := range append(deadKeys, aliveKeys...) {
+		value := ""
+		switch key {
+		case InfoKey(alive):
+			value = FormatStarted(now)
+		case deadKeys[0]:
+			value = FormatLease(lease)
+		}
+		txn.DoNotFound(store.PutRaw(key, value))
+	}
+	txn.DoNotFound(store.PutRaw(WorkKey("space1"), dead))
+	txn.DoNotFound(store.PutRaw(WorkKey("space2"), dead))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	log, _ := logging.NewZapWrapDev()
+	cnf := Config{HeartbeatInSecs: 15, DeadAfter: 3}
+	elections := storage.NewLocalElections()
+	leader := NewReaper(cnf, time.Second, "leader", elections, store, timeout, log)
+	follower := NewReaper(cnf, time.Second, "follower", elections, store, timeout, log)
+	var emitted []Event
+	leader.Subscribe(func(e Event) { emitted = append(emitted, e) })
+	if _, err := leader.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	events, err := leader.Round(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected := []Event{{Kind: DeadEvent, Splitter: dead, Heartbeat: old.UTC().Truncate(time.Second), Released: 2}}
+	assert.Equal(t, expected, events, "Events")
+	assert.Equal(t, expected, emitted, "Emitted")
+	for _, key := range deadKeys {
+		found, err := store.Exists(ctx, key)
+		if assert.NoError(t, err) {
+			assert.False(t, found, key)
+		}
+	}
+	for _, key := range aliveKeys {
+		found, err := store.Exists(ctx, key)
+		if assert.NoError(t, err) {
+			assert.True(t, found, key)
+		}
+	}
+	assert.Equal(t, storage.ErrLeaseNotFound, store.KeepAlive(ctx, lease), "The lease of the dead splitter is revoked")
+
+	for _, work := range []string{"space1", "space2"} {
+		found, err := store.Exists(ctx, AssignKey(alive, work))
+		if assert.NoError(t, err) {
+			assert.True(t, found, "The released work is assigned to the alive splitter")
+		}
+	}
+
+	events, err = leader.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, events, "Without dead splitters")
+	}
+	events, err = follower.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Nil(t, events, "Only the leader reaps")
+	}
+}
+
+func TestReaper_RoundLeaseExpired(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+
+	now := time.Now()
+	dead, alive := "c0ob7bbu5ln0vjkdm7l0", "c0ob7bbu5ln0vjkdm7l1"
+	lease, err := store.Grant(ctx, time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(dead))
+	txn.DoNotFound(store.PutRawLease(TickKey(FormatTick(now), dead), FormatLease(lease), lease))
+	txn.DoNotFound(store.PutRawLease(InfoKey(dead), FormatStarted(now), lease))
+	txn.DoNotFound(store.PutRaw(AssignKey(dead, "space1"), FormatStarted(now)))
+	txn.DoNotFound(store.PutRaw(WorkKey("space1"), dead))
+	txn.DoNotFound(store.PutRaw(HandoffKey(dead, "space2"), strings.Concat(alive, "#", FormatStarted(now))))
+	txn.DoNotFound(store.PutRaw(WorkKey("space2"), dead))
+	txn.DoNotFound(store.PutRaw(WorkKey("space3"), dead)) // Its assignment is lost
+	txn.DoNotFound(store.PutRaw(TickKey(FormatTick(now), alive), ""))
+	txn.DoNotFound(store.PutRaw(InfoKey(alive), FormatStarted(now)))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	// The splitter crashes and its lease expires before any round sees its tick
+	expired := false
+	for i := 0; i < 50 && !expired; i++ {
+		time.Sleep(100 * time.Millisecond)
+		found, err := store.Exists(ctx, InfoKey(dead))
+		if !assert.NoError(t, err) {
+			return
+		}
+		expired = !found
+	}
+	if !assert.True(t, expired, "The lease expires") {
+		return
+	}
+
+	log, _ := logging.NewZapWrapDev()
+	r := NewReaper(Config{HeartbeatInSecs: 15, DeadAfter: 3}, time.Second, "leader", storage.NewLocalElections(), store, timeout, log)
+	if _, err := r.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+	events, err := r.Round(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Event{{Kind: DeadEvent, Splitter: dead, Released: 2}}, events, "Events")
+
+	for _, work := range []string{"space1", "space2", "space3"} {
+		owner, found, err := r.assigner.Owner(ctx, work)
+		if assert.NoError(t, err) && assert.True(t, found, work) {
+			assert.Equal(t, alive, owner, "The work is assigned to the alive splitter")
+		}
+		found, err = store.Exists(ctx, AssignKey(dead, work))
+		if assert.NoError(t, err) {
+			assert.False(t, found, "Assignment of the dead splitter")
+		}
+	}
+	found, err := store.Exists(ctx, HandoffKey(dead, "space2"))
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Handoff of the dead splitter")
+	}
+
+	events, err = r.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, events, "Without dead splitters")
+	}
+}
+
+func TestReaper_RoundLeadershipLost(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+
+	dead := "c0ob7bbu5ln0vjkdm7l0"
+	tick := TickKey(FormatTick(time.Now().Add(-time.Minute)), dead)
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(dead))
+	txn.DoNotFound(store.PutRaw(tick, ""))
+	txn.DoNotFound(store.PutRaw(InfoKey(dead), ""))
+	txn.DoNotFound(store.PutRaw(AssignKey(dead, "space1"), ""))
+	txn.DoNotFound(store.PutRaw(WorkKey("space1"), dead))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	log, _ := logging.NewZapWrapDev()
+	elections, err := storage.NewElections(store, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer elections.Close()
+	r := NewReaper(Config{HeartbeatInSecs: 15, DeadAfter: 3}, time.Second, "leader", elections, store, timeout, log)
+	if _, err := r.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+	// The leadership is lost but the round has not seen it yet
+	if !assert.NoError(t, r.job.election.Resign(ctx)) {
+		return
+	}
+
+	events, err := r.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Empty(t, events, "The old leader does not reap")
+	}
+	for _, key := range []string{tick, InfoKey(dead), AssignKey(dead, "space1")} {
+		found, err := store.Exists(ctx, key)
+		if assert.NoError(t, err) {
+			assert.True(t, found, key)
+		}
+	}
+	owner, _, err := r.assigner.Owner(ctx, "space1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, dead, owner, "The work is not released")
+	}
+}
+
+func TestReaper_StartStop(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	log, _ := logging.NewZapWrapDev()
+	cnf := Config{HeartbeatInSecs: 15, DeadAfter: 3}
+	elections, err := storage.NewElections(store, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer elections.Close()
+	election := elections.Election(reaperJob)
+	ctx := context.TODO()
+
+	r := NewReaper(cnf, 100*time.Millisecond, "leader", elections, store, timeout, log)
+	r.Start()
+	time.Sleep(300 * time.Millisecond)
+	holder, err := election.Leader(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "leader", holder, "Elected")
+	}
+	r.Stop()
+
+	_, err = election.Leader(ctx)
+	assert.Equal(t, storage.ErrNoLeader, err, "The leadership is resigned")
+}
+
+func TestReaper_RoundWithError(t *testing.T) {
+	store := &storage.ErrMockCRUD{}
+	store.Activate("StartKey")
+	log, _ := logging.NewZapWrapDev()
+	ctx := context.TODO()
+
+	elections := storage.NewLocalElections()
+	r := NewReaper(Config{HeartbeatInSecs: 15, DeadAfter: 3}, time.Second, "leader", elections, store, timeout, log)
+	if _, err := r.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+	_, err := r.Round(ctx)
+	assert.Error(t, err)
+}
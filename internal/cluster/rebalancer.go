@@ -0,0 +1,207 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const (
+	locRebalancer = "cluster.rebalancer"
+	// rebalancerJob is the name of the election of the rebalancer
+	rebalancerJob = "rebalancer"
+	// balanceBatch is the number of keys read by request
+	balanceBatch = 50
+)
+
+// Balance describes the rebalancing of the works among the splitters
+type Balance struct {
+	// EveryInSecs is the interval of the rounds of the rebalancer
+	EveryInSecs time.Duration `json:"everyInSecs,omitempty"`
+	// Threshold is the hysteresis in percent. A work moves from a hot splitter to a cold one only if the
+	// consumption of the hot splitter exceeds the consumption of the cold one by more than the threshold
+	Threshold int `json:"threshold,omitempty"`
+	// MaxMoves is the maximum number of works moved by round
+	MaxMoves int `json:"maxMoves,omitempty"`
+	// SettleInSecs is the time that a work stays into its splitter before it can move again
+	SettleInSecs time.Duration `json:"settleInSecs,omitempty"`
+}
+
+// Rebalancer moves the works from the hot splitters to the cold ones. The alive splitters are sorted by
+// consumption and paired, the hottest with the coldest, and each round moves the oldest work of each
+// hot splitter to its cold pair. The works move with a handoff, see Assigner.Handoff.
+// The measures of consumption are renewed after several heartbeats, so the moves are limited by round,
+// a splitter does not move other work until its previous move ends and a work is not moved again until it settles.
+// The interval should be longer than the renewal of the consumptions. The rebalancer runs each interval and when a splitter
+// joins or leaves the cluster, on a single leader among all processes that start it
+type Rebalancer struct {
+	cnf      Balance
+	job      *Singleton
+	assigner Assigner
+	watcher  storage.Watcher
+	log      logging.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRebalancer builds a Rebalancer. The holder identifies the process into the election.
+// The timeout is the timeout of the calls to the store, see runtime.CommonConfig.StoreWithTimeout
+func NewRebalancer(
+	cnf Config,
+	balance Balance,
+	holder string,
+	elections storage.Elections,
+	store storage.CRUD,
+	timeout func(parent context.Context) (context.Context, context.CancelFunc),
+	log logging.Logger) *Rebalancer {
+	//
+	r := &Rebalancer{
+		cnf:      balance,
+		assigner: NewAssigner(cnf, store, timeout, balanceBatch),
+		watcher:  storage.NewWatcher(store),
+		log:      log,
+	}
+	r.job = NewSingleton(rebalancerJob, locRebalancer, holder, balance.EveryInSecs*time.Second, elections, timeout, log,
+		func(ctx context.Context) {
+			_, _ = r.Round(ctx) // The errors are logged by the round
+		})
+	r.assigner.fence = r.job.Fence
+	return r
+}
+
+// Start starts the rebalancer and the watch of the splitters in background
+func (r *Rebalancer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.members(ctx)
+	r.job.Start()
+}
+
+// Stop stops the rebalancer, waits until the actual round ends and resigns the leadership
+func (r *Rebalancer) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	r.job.Stop()
+}
+
+// members requests a round when a splitter joins or leaves the cluster.
+// The information of the splitters is created when they start and removed when they stop or die
+func (r *Rebalancer) members(ctx context.Context) {
+	defer close(r.done)
+	for {
+		first := true
+		for resp := range r.watcher.Watch(ctx, InfoPrefix) {
+			if resp.Err != nil {
+				if ctx.Err() == nil { // The watch is not canceled by Stop
+					_ = r.log.ErrWrap(resp.Err, "watching the splitters", locRebalancer)
+				}
+				break
+			}
+			if !first {
+				r.job.Notify()
+			}
+			first = false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.job.every):
+		}
+	}
+}
+
+// Round moves the works from the hot splitters to the cold ones if the process is the leader.
+// Returns the number of works moved
+func (r *Rebalancer) Round(ctx context.Context) (int, error) {
+	if !r.job.Leader() {
+		return 0, nil
+	}
+	alive, err := r.assigner.alive(ctx)
+	if err != nil {
+		return 0, r.log.ErrWrap(err, "listing the splitters", locRebalancer)
+	}
+
+	moves := 0
+	for hot, cold := len(alive)-1, 0; cold < hot && moves < r.cnf.MaxMoves; hot, cold = hot-1, cold+1 {
+		if !r.unbalanced(alive[hot], alive[cold]) {
+			break // The next pairs are closer
+		}
+		work, ok, err := r.candidate(ctx, alive[hot].ID)
+		if err != nil {
+			return moves, r.log.ErrWrap1(err, "reading the works", locRebalancer, logging.String("splitter", alive[hot].ID))
+		}
+		if !ok {
+			continue
+		}
+		moved, err := r.assigner.Handoff(ctx, work, alive[hot].ID, alive[cold])
+		if err != nil {
+			return moves, r.log.ErrWrap1(err, "moving the work", locRebalancer, logging.String("work", work))
+		}
+		if moved {
+			moves++
+			r.log.Info3(
+				"moving the work",
+				locRebalancer,
+				logging.String("work", work),
+				logging.String("from", alive[hot].ID),
+				logging.String("to", alive[cold].ID))
+		}
+	}
+	return moves, nil
+}
+
+// unbalanced returns true if the consumption of the hot splitter exceeds the consumption of the cold one
+// by more than the threshold
+func (r *Rebalancer) unbalanced(hot Splitter, cold Splitter) bool {
+	return hot.Consumption*100 > cold.Consumption*(100+r.cnf.Threshold)
+}
+
+// candidate returns the oldest work of the splitter that is settled. The splitter keeps at least one work,
+// otherwise the move only moves the consumption to the other splitter.
+// The splitter has no candidate until its previous moves end
+func (r *Rebalancer) candidate(ctx context.Context, id string) (string, bool, error) {
+	handoffs, err := r.assigner.Handoffs(ctx, id)
+	if err != nil || len(handoffs) > 0 {
+		return "", false, err
+	}
+	assignments, err := r.assigner.Assignments(ctx, id)
+	if err != nil || len(assignments) < 2 {
+		return "", false, err
+	}
+	settled := time.Now().Add(-r.cnf.SettleInSecs * time.Second)
+	var oldest *Assignment
+	for i, as := range assignments {
+		if as.Assigned.After(settled) {
+			continue
+		}
+		if oldest == nil || as.Assigned.Before(oldest.Assigned) {
+			oldest = &assignments[i]
+		}
+	}
+	if oldest == nil {
+		return "", false, nil
+	}
+	return oldest.Work, true, nil
+}
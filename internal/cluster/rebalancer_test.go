@@ -0,0 +1,129 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebalancer_Round(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) || !putWorks(t, store) {
+		return
+	}
+
+	log, _ := logging.NewZapWrapDev()
+	cnf := Config{HeartbeatInSecs: 15, DeadAfter: 3}
+	balance := Balance{EveryInSecs: 60, Threshold: 20, MaxMoves: 5, SettleInSecs: 300}
+	elections := storage.NewLocalElections()
+	leader := NewRebalancer(cnf, balance, "leader", elections, store, timeout, log)
+	follower := NewRebalancer(cnf, balance, "follower", elections, store, timeout, log)
+	if _, err := leader.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+
+	moves, err := follower.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, moves, "Only the leader moves")
+	}
+	moves, err = leader.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, moves, "A move by pair")
+	}
+	found, err := store.Exists(ctx, HandoffKey(hot, "space1"))
+	if assert.NoError(t, err) {
+		assert.True(t, found, "The oldest work")
+	}
+	moves, err = leader.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, moves, "The move is in progress")
+	}
+	if _, err := leader.assigner.Acquire(ctx, hot, "space1"); !assert.NoError(t, err) {
+		return
+	}
+	moves, err = leader.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, moves, "The rest of works are not settled")
+	}
+
+	threshold := Balance{Threshold: 200, MaxMoves: 5}
+	balanced := NewRebalancer(cnf, threshold, "balanced", storage.NewLocalElections(), store, timeout, log)
+	if _, err := balanced.job.campaign(ctx); !assert.NoError(t, err) {
+		return
+	}
+	moves, err = balanced.Round(ctx)
+	if assert.NoError(t, err) {
+		assert.Zero(t, moves, "Into the threshold")
+	}
+}
+
+func TestRebalancer_Members(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !putSplitters(t, store) || !putWorks(t, store) {
+		return
+	}
+	log, _ := logging.NewZapWrapDev()
+	elections, err := storage.NewElections(store, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer elections.Close()
+	balance := Balance{EveryInSecs: 60, Threshold: 20, MaxMoves: 5, SettleInSecs: 300}
+
+	r := NewRebalancer(Config{HeartbeatInSecs: 15, DeadAfter: 3}, balance, "leader", elections, store, timeout, log)
+	r.Start()
+	defer r.Stop()
+	assert.Eventually(t, r.job.Leader, 2*time.Second, 10*time.Millisecond, "Elected")
+
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey("c0ob7bbu5ln0vjkdm7l2"))
+	txn.DoNotFound(store.PutRaw(InfoKey("c0ob7bbu5ln0vjkdm7l2"), FormatStarted(time.Now())))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool {
+		found, err := store.Exists(ctx, HandoffKey(hot, "space1"))
+		return err == nil && found
+	}, 2*time.Second, 10*time.Millisecond, "The splitter that joins triggers a round")
+}
+
+// putWorks assigns three works to the hot splitter. The first work is the only one settled
+func putWorks(t *testing.T, store storage.CRUD) bool {
+	now := time.Now()
+	assigned := []time.Time{now.Add(-time.Hour), now, now}
+	txn := storage.NewTxn(store)
+	txn.Find(WorkKey("space1"))
+	for i, work := range []string{"space1", "space2", "space3"} {
+		txn.DoNotFound(store.PutRaw(WorkKey(work), hot))
+		txn.DoNotFound(store.PutRaw(AssignKey(hot, work), FormatStarted(assigned[i])))
+	}
+	_, err := txn.Commit(context.TODO())
+	return assert.NoError(t, err)
+}
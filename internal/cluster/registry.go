@@ -0,0 +1,139 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+)
+
+// Status is the status of the splitter
+type Status string
+
+const (
+	// Alive is the splitter that renews its tick
+	Alive Status = "alive"
+	// Dead is the splitter that doesn't renew its tick since Config.Deadline
+	Dead Status = "dead"
+)
+
+// Splitter is the information of a splitter of the cluster
+type Splitter struct {
+	ID string `json:"id"`
+	// Started is the time that the splitter started. It is zero if the splitter didn't register it
+	Started time.Time `json:"started"`
+	// Heartbeat is the time of the last tick. It is zero if the splitter has not tick
+	Heartbeat time.Time `json:"heartbeat"`
+	// Consumption is the last consumption measure (cpu + memory). See the splitter service.consumption
+	Consumption int    `json:"consumption"`
+	Status      Status `json:"status"`
+}
+
+// Registry reads the splitters of the cluster
+type Registry struct {
+	cnf     Config
+	store   storage.CRUD
+	timeout func(parent context.Context) (context.Context, context.CancelFunc)
+	batch   int
+}
+
+// NewRegistry builds a registry. The timeout is the timeout of the calls to the store,
+// see runtime.CommonConfig.StoreWithTimeout. The batch is the number of keys read by request
+func NewRegistry(
+	cnf Config,
+	store storage.CRUD,
+	timeout func(parent context.Context) (context.Context, context.CancelFunc),
+	batch int) Registry {
+	//
+	return Registry{
+		cnf:     cnf,
+		store:   store,
+		timeout: timeout,
+		batch:   batch,
+	}
+}
+
+// List lists the splitters sorted by ID. The splitter is dead if its last tick is older than Config.Deadline
+func (r *Registry) List(ctx context.Context) ([]Splitter, error) {
+	splitters := make(map[string]*Splitter)
+	of := func(id string) *Splitter {
+		s, ok := splitters[id]
+		if !ok {
+			s = &Splitter{ID: id}
+			splitters[id] = s
+		}
+		return s
+	}
+
+	err := r.scan(ctx, InfoPrefix, func(value string, id string, raw string) {
+		if started, err := time.Parse(time.RFC3339, raw); err == nil {
+			of(id).Started = started
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = r.scan(ctx, TickPrefix, func(value string, id string, _ string) {
+		if tick, err := time.ParseInLocation(TickLayout, value, time.UTC); err == nil {
+			s := of(id)
+			if tick.After(s.Heartbeat) {
+				s.Heartbeat = tick
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = r.scan(ctx, ConsumptionPrefix, func(value string, id string, _ string) {
+		if measure, err := strconv.Atoi(value); err == nil {
+			of(id).Consumption = measure
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	list := make([]Splitter, 0, len(splitters))
+	for _, s := range splitters {
+		s.Status = Alive
+		if s.Heartbeat.IsZero() || now.Sub(s.Heartbeat) > r.cnf.Deadline() {
+			s.Status = Dead
+		}
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// scan scans the keys of the prefix and calls fn with the value of the key, the splitter ID
+// and the value stored. The keys that are not valid are skipped
+func (r *Registry) scan(ctx context.Context, prefix string, fn func(value string, id string, raw string)) error {
+	storeTimeout := func() (context.Context, context.CancelFunc) { return r.timeout(ctx) }
+	return storage.ScanRaw(storeTimeout, r.store, prefix, r.batch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			if value, id, ok := split(key, prefix); ok {
+				fn(value, id, values[key])
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_List(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+
+	now := time.Now()
+	alive, dead, orphan := "c0ob7bbu5ln0vjkdm7l0", "c0ob7bbu5ln0vjkdm7l1", "c0ob7bbu5ln0vjkdm7l2"
+	started := now.Add(-time.Hour)
+	txn := storage.NewTxn(store)
+	txn.Find(InfoKey(alive))
+	txn.DoNotFound(store.PutRaw(InfoKey(alive), FormatStarted(started)))
+	txn.DoNotFound(store.PutRaw(TickKey(FormatTick(now), alive), alive))
+	txn.DoNotFound(store.PutRaw(ConsumptionKey(2000512, alive), "1024"))
+	txn.DoNotFound(store.PutRaw(InfoKey(dead), FormatStarted(started)))
+	txn.DoNotFound(store.PutRaw(TickKey(FormatTick(now.Add(-time.Minute)), dead), dead))
+	txn.DoNotFound(store.PutRaw(ConsumptionKey(1000256, orphan), "1024"))
+	txn.DoNotFound(store.PutRaw("splitter#tick#bad", ""))
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+
+	r := NewRegistry(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	splitters, err := r.List(context.TODO())
+	if assert.NoError(t, err) && assert.Len(t, splitters, 3) {
+		tick := now.UTC().Truncate(time.Second)
+		assert.Equal(t, Splitter{
+			ID:          alive,
+			Started:     started.UTC().Truncate(time.Second),
+			Heartbeat:   tick,
+			Consumption: 2000512,
+			Status:      Alive,
+		}, splitters[0], "Alive")
+		assert.Equal(t, Dead, splitters[1].Status, "Without tick since the deadline")
+		assert.Equal(t, tick.Add(-time.Minute), splitters[1].Heartbeat, "Dead heartbeat")
+		assert.Equal(t, Splitter{ID: orphan, Consumption: 1000256, Status: Dead}, splitters[2], "Without tick")
+	}
+}
+
+func TestRegistry_ListWithError(t *testing.T) {
+	store := &storage.ErrMockCRUD{}
+	store.Activate("StartKey")
+
+	r := NewRegistry(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+	_, err := r.List(context.TODO())
+	assert.Error(t, err)
+}
+
+func timeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, 5*time.Second)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+)
+
+// DefaultVNodes is the number of virtual nodes of a splitter with the mean consumption of the ring
+const DefaultVNodes = 128
+
+// vnodeSteps bounds the virtual nodes of a splitter between VNodes / 2^steps and VNodes * 2^steps.
+// The virtual nodes are quantized into powers of two, so the ring only changes when the consumption
+// of a splitter doubles or halves and not with each small change of the measures
+const vnodeSteps = 2
+
+// Ring is a consistent-hash ring of the alive splitters. Each splitter owns a number of virtual nodes
+// that is inversely proportional to its consumption, so the cold splitters own more entes than the hot splitters.
+// The ring only depends on the IDs and the consumptions of the splitters, so any process that reads the same
+// splitters computes the same owner of an ente without asking to the cluster.
+// When a splitter joins or leaves the ring only the entes of its virtual nodes move.
+// The ring is a snapshot of the splitters, so the components that route the entes must build it again
+// each time they read the splitters, otherwise two components may route the same ente to different splitters
+type Ring struct {
+	points []point
+	vnodes map[string]int
+}
+
+type point struct {
+	hash uint64
+	id   string
+}
+
+// NewRing builds the ring of the alive splitters. The vnodes is the number of virtual nodes of a splitter
+// with the mean consumption, if it is not positive DefaultVNodes is used
+func NewRing(splitters []Splitter, vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVNodes
+	}
+	var alive []Splitter
+	total := 0
+	for _, s := range splitters {
+		if s.Status == Alive && !s.Started.IsZero() {
+			alive = append(alive, s)
+			total += s.Consumption
+		}
+	}
+
+	r := &Ring{vnodes: make(map[string]int, len(alive))}
+	for _, s := range alive {
+		n := weight(vnodes, s.Consumption, total, len(alive))
+		r.vnodes[s.ID] = n
+		for i := 0; i < n; i++ {
+			r.points = append(r.points, point{hash: hash(strings.Concat(s.ID, "#", strconv.Itoa(i))), id: s.ID})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash == r.points[j].hash {
+			return r.points[i].id < r.points[j].id
+		}
+		return r.points[i].hash < r.points[j].hash
+	})
+	return r
+}
+
+// Owner returns the splitter that owns the ente. Returns false if the ring is empty
+func (r *Ring) Owner(ente xid.ID) (string, bool) {
+	return r.OwnerOf(ente.String())
+}
+
+// OwnerOf returns the splitter that owns the key. Returns false if the ring is empty
+func (r *Ring) OwnerOf(key string) (string, bool) {
+	if len(r.points) == 0 {
+		return "", false
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].id, true
+}
+
+// Splitters returns the IDs of the splitters of the ring sorted by ID
+func (r *Ring) Splitters() []string {
+	ids := make([]string, 0, len(r.vnodes))
+	for id := range r.vnodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// VNodes returns the number of virtual nodes of the splitter. It is zero if the splitter is not into the ring
+func (r *Ring) VNodes(id string) int {
+	return r.vnodes[id]
+}
+
+// weight returns the virtual nodes of a splitter. The splitter with the mean consumption has vnodes,
+// the colder the splitter the more virtual nodes. The ratio to the mean is rounded to the nearest
+// power of two between the bounds of vnodeSteps
+func weight(vnodes int, consumption int, total int, splitters int) int {
+	if total == 0 {
+		return vnodes // Without measures all splitters weigh the same
+	}
+	step := vnodeSteps
+	if consumption > 0 {
+		ratio := float64(total) / (float64(consumption) * float64(splitters))
+		step = int(math.Round(math.Log2(ratio)))
+		switch {
+		case step < -vnodeSteps:
+			step = -vnodeSteps
+		case step > vnodeSteps:
+			step = vnodeSteps
+		}
+	}
+	if step >= 0 {
+		return vnodes << step
+	}
+	if n := vnodes >> -step; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// hash returns the FNV-1a hash of the key mixed to spread the near keys along the ring
+func hash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Ring builds the consistent-hash ring of the alive splitters. See NewRing
+func (r *Registry) Ring(ctx context.Context, vnodes int) (*Ring, error) {
+	splitters, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewRing(splitters, vnodes), nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package cluster
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_Owner(t *testing.T) {
+	started := time.Now()
+	splitters := []Splitter{
+		{ID: "s1", Started: started, Status: Alive, Consumption: 100},
+		{ID: "s2", Started: started, Status: Alive, Consumption: 100},
+		{ID: "s3", Started: started, Status: Alive, Consumption: 100},
+		{ID: "dead", Started: started, Status: Dead},
+		{ID: "unregistered", Status: Alive},
+	}
+	ring := NewRing(splitters, 0)
+	assert.Equal(t, []string{"s1", "s2", "s3"}, ring.Splitters(), "Alive splitters")
+	assert.Equal(t, DefaultVNodes, ring.VNodes("s1"), "Mean consumption")
+
+	reversed := NewRing([]Splitter{splitters[2], splitters[1], splitters[0]}, 0)
+	counts := make(map[string]int)
+	entes := make([]xid.ID, 3000)
+	for i := range entes {
+		entes[i] = ente(i)
+		owner, ok := ring.Owner(entes[i])
+		if !assert.True(t, ok) {
+			return
+		}
+		other, _ := reversed.Owner(entes[i])
+		assert.Equal(t, owner, other, "The ring does not depend on the order")
+		counts[owner]++
+	}
+	for id, count := range counts {
+		assert.InDelta(t, 1000, count, 250, id)
+	}
+
+	joined := NewRing(append(splitters, Splitter{ID: "s4", Started: started, Status: Alive, Consumption: 100}), 0)
+	moved := 0
+	for _, ente := range entes {
+		before, _ := ring.Owner(ente)
+		after, _ := joined.Owner(ente)
+		if before != after {
+			assert.Equal(t, "s4", after, "Only move to the new splitter")
+			moved++
+		}
+	}
+	assert.InDelta(t, 750, moved, 250, "A quarter of the entes")
+}
+
+func TestRing_Weight(t *testing.T) {
+	started := time.Now()
+	ring := NewRing([]Splitter{
+		{ID: "cold", Started: started, Status: Alive, Consumption: 100},
+		{ID: "hot", Started: started, Status: Alive, Consumption: 300},
+		{ID: "idle", Started: started, Status: Alive},
+	}, 100)
+	assert.Equal(t, 100, ring.VNodes("cold"), "Near the mean")
+	assert.Equal(t, 50, ring.VNodes("hot"), "Hotter than the mean")
+	assert.Equal(t, 400, ring.VNodes("idle"), "Max")
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		owner, _ := ring.Owner(ente(i))
+		counts[owner]++
+	}
+	assert.True(t, counts["idle"] > counts["cold"] && counts["cold"] > counts["hot"], "Weighted")
+
+	assert.Equal(t, 25, weight(100, 10000, 10100, 10), "Min")
+	assert.Equal(t, 100, weight(100, 0, 0, 2), "Without measures")
+	assert.Equal(t, 1, weight(2, 1000, 1001, 10), "At least one")
+	assert.Equal(t, 100, weight(100, 90, 1000, 10), "Quantized below the mean")
+	assert.Equal(t, 100, weight(100, 110, 1000, 10), "Quantized above the mean")
+	assert.Equal(t, 200, weight(100, 50, 1000, 10), "Half of the mean")
+}
+
+func TestRing_Empty(t *testing.T) {
+	ring := NewRing(nil, 0)
+	_, ok := ring.Owner(xid.New())
+	assert.False(t, ok)
+	assert.Empty(t, ring.Splitters())
+}
+
+func TestRegistry_Ring(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	store := mng.Store()
+	if !putSplitters(t, store) {
+		return
+	}
+	registry := NewRegistry(Config{HeartbeatInSecs: 15, DeadAfter: 3}, store, timeout, 2)
+
+	ring, err := registry.Ring(context.TODO(), 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{cold, hot}, ring.Splitters())
+		assert.True(t, ring.VNodes(cold) > ring.VNodes(hot), "Weighted by consumption")
+	}
+}
+
+// ente returns a deterministic ente ID so the distribution of the tests is stable
+func ente(i int) xid.ID {
+	var id xid.ID
+	binary.BigEndian.PutUint32(id[:4], uint32(1637017199+i))
+	binary.BigEndian.PutUint32(id[8:], uint32(i))
+	return id
+}
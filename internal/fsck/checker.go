@@ -0,0 +1,328 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package fsck checks the referential integrity of the entities, their links and their doubly linked relations.
+// Each DLRel must point to a child entity, a parent entity and a link that exist,
+// and each link must be pointed by a DLRel
+package fsck
+
+import (
+	"context"
+	"sort"
+	strs "strings"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/rs/xid"
+)
+
+const loc = "fsck"
+
+// idLen is the length of the identifier of the entities
+const idLen = 20
+
+// Kind is the type of the issue
+type Kind string
+
+const (
+	// OrphanDLR is a DLRel whose child doesn't exist
+	OrphanDLR Kind = "orphan-dlr"
+	// MissingParent is a DLRel whose parent doesn't exist
+	MissingParent Kind = "missing-parent"
+	// DanglingPointer is a DLRel whose link doesn't exist
+	DanglingPointer Kind = "dangling-pointer"
+	// OrphanLink is a link that is not pointed by any DLRel
+	OrphanLink Kind = "orphan-link"
+	// NameMismatch is a link whose name is different from the name of the child.
+	// It is not repaired because the name is part of the key of the link
+	NameMismatch Kind = "name-mismatch"
+)
+
+// Issue is an integrity error
+type Issue struct {
+	Kind Kind `json:"kind"`
+	// Key is the key with the error
+	Key string `json:"key"`
+	// Ref is the key referenced by Key that causes the error
+	Ref      string `json:"ref,omitempty"`
+	Repaired bool   `json:"repaired"`
+}
+
+// Report is the result of the check
+type Report struct {
+	Scanned  int     `json:"scanned"`
+	Issues   []Issue `json:"issues"`
+	Repaired int     `json:"repaired"`
+}
+
+// Pending returns the number of issues not repaired
+func (r *Report) Pending() int {
+	return len(r.Issues) - r.Repaired
+}
+
+// nominative allows to decode the descriptor of any entity.Domain
+type nominative struct {
+	entity.Descriptor
+}
+
+// named allows to decode the name of any link
+type named struct {
+	ID   string
+	Name string
+}
+
+// keyspace is the result of the scan
+type keyspace struct {
+	entities map[string]string // Name by key
+	links    map[string]string // Name by key
+	dlrs     map[string]storage.DLRel
+	values   map[string]string // Raw value by key of the links and DLRels
+}
+
+// Checker checks and repairs the keyspace
+type Checker struct {
+	cnt   *runtime.Container
+	store storage.CRUD
+	batch int
+}
+
+// NewChecker builds a checker. The batch is the number of keys read by request
+func NewChecker(cnt *runtime.Container, store storage.CRUD, batch int) Checker {
+	return Checker{
+		cnt:   cnt,
+		store: store,
+		batch: batch,
+	}
+}
+
+// Check scans the keyspace and reports the issues. If repair is true the orphan and dangling keys are removed.
+// The keyspace is not scanned atomically, so each issue is repaired only if the keys are the same as scanned
+// and the keys referenced are still missing. The orphan links are confirmed scanning the DLRels again
+func (c *Checker) Check(repair bool) (Report, error) {
+	ks, scanned, err := c.scan()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Scanned: scanned, Issues: c.issues(ks)}
+	if repair {
+		repaired, err := c.repair(ks, report.Issues)
+		report.Repaired = repaired
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// scan classifies the keys in entities, links and DLRels
+func (c *Checker) scan() (keyspace, int, error) {
+	ks := keyspace{
+		entities: make(map[string]string),
+		links:    make(map[string]string),
+		dlrs:     make(map[string]storage.DLRel),
+		values:   make(map[string]string),
+	}
+	scanned := 0
+	storeTimeout := c.cnt.StoreTimeout(context.Background())
+	for _, prefix := range prefixes() {
+		err := storage.ScanRaw(storeTimeout, c.store, prefix, c.batch, func(keys []string, values map[string]string) error {
+			for _, k := range keys {
+				scanned++
+				if err := ks.add(k, values[k]); err != nil {
+					return c.cnt.Log.ErrWrap1(err, "decoding the key", loc, logging.String("key", k))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return keyspace{}, 0, c.cnt.Log.ErrWrap1(err, "scanning the keys", loc, logging.String("prefix", prefix))
+		}
+	}
+	return ks, scanned, nil
+}
+
+func (ks *keyspace) add(key string, value string) error {
+	switch {
+	case strs.Contains(key, storage.DLRPrefix("")):
+		var dlr storage.DLRel
+		if err := encoding.Decode(value, &dlr); err != nil {
+			return err
+		}
+		ks.dlrs[key] = dlr
+		ks.values[key] = value
+	case isEntity(key):
+		var nom nominative
+		if err := encoding.Decode(value, &nom); err != nil {
+			return err
+		}
+		ks.entities[key] = nom.Name
+	default:
+		var link named
+		if err := encoding.Decode(value, &link); err != nil {
+			return err
+		}
+		ks.links[key] = link.Name
+		ks.values[key] = value
+	}
+	return nil
+}
+
+// issues checks the DLRels and the links
+func (c *Checker) issues(ks keyspace) []Issue {
+	var issues []Issue
+	pointed := make(map[string]bool, len(ks.links))
+
+	keys := make([]string, 0, len(ks.dlrs))
+	for k := range ks.dlrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		dlr := ks.dlrs[k]
+		pointed[dlr.Pointer] = true
+		name, child := ks.entities[dlr.ChildID]
+		if !child {
+			issues = append(issues, Issue{Kind: OrphanDLR, Key: k, Ref: dlr.ChildID})
+			continue
+		}
+		if _, parent := ks.entities[dlr.ParentID]; !parent && dlr.ParentID != storage.Virtual {
+			issues = append(issues, Issue{Kind: MissingParent, Key: k, Ref: dlr.ParentID})
+			continue
+		}
+		linkName, link := ks.links[dlr.Pointer]
+		if !link {
+			issues = append(issues, Issue{Kind: DanglingPointer, Key: k, Ref: dlr.Pointer})
+			continue
+		}
+		if linkName != name {
+			issues = append(issues, Issue{Kind: NameMismatch, Key: dlr.Pointer, Ref: dlr.ChildID})
+		}
+	}
+
+	links := make([]string, 0, len(ks.links))
+	for k := range ks.links {
+		if !pointed[k] {
+			links = append(links, k)
+		}
+	}
+	sort.Strings(links)
+	for _, k := range links {
+		issues = append(issues, Issue{Kind: OrphanLink, Key: k})
+	}
+	return issues
+}
+
+// repair removes the keys of each issue in a transaction. The link pointed by an orphan DLRel is removed too.
+// The issues are marked as repaired if their transactions are committed
+func (c *Checker) repair(ks keyspace, issues []Issue) (int, error) {
+	pointed, err := c.pointed()
+	if err != nil {
+		return 0, err
+	}
+	removed := make(map[string]bool)
+	repaired := 0
+	for i := range issues {
+		issue := &issues[i]
+		txn := storage.NewTxn(c.store)
+		keys := []string{issue.Key}
+		switch issue.Kind {
+		case OrphanDLR, MissingParent, DanglingPointer:
+			txn.Match(issue.Ref, "") // The child, the parent or the link are still missing
+			if pointer := ks.dlrs[issue.Key].Pointer; issue.Kind != DanglingPointer && !removed[pointer] {
+				if _, ok := ks.links[pointer]; ok {
+					keys = append(keys, pointer)
+				}
+			}
+		case OrphanLink:
+			if pointed[issue.Key] { // Linked after the scan
+				continue
+			}
+		default:
+			continue
+		}
+		for _, k := range keys {
+			txn.Match(k, ks.values[k])
+			txn.DoFound(c.store.Remove(k))
+		}
+
+		ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+		ok, err := txn.Commit(ctx)
+		cancel()
+		if err != nil {
+			return repaired, c.cnt.Log.ErrWrap1(err, "commit repairing", loc, logging.String("key", issue.Key))
+		}
+		if !ok { // Changed after the scan
+			continue
+		}
+		for _, k := range keys {
+			removed[k] = true
+		}
+		issue.Repaired = true
+		repaired++
+	}
+	return repaired, nil
+}
+
+// pointed scans the DLRels again and gets the links pointed.
+// A link created with its DLRel after the first scan of the DLRels is found here
+func (c *Checker) pointed() (map[string]bool, error) {
+	pointed := make(map[string]bool)
+	storeTimeout := c.cnt.StoreTimeout(context.Background())
+	for _, prefix := range prefixes() {
+		err := storage.ScanRaw(storeTimeout, c.store, prefix, c.batch, func(keys []string, values map[string]string) error {
+			for _, k := range keys {
+				if !strs.Contains(k, storage.DLRPrefix("")) {
+					continue
+				}
+				var dlr storage.DLRel
+				if err := encoding.Decode(values[k], &dlr); err != nil {
+					return c.cnt.Log.ErrWrap1(err, "decoding the key", loc, logging.String("key", k))
+				}
+				pointed[dlr.Pointer] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, c.cnt.Log.ErrWrap1(err, "scanning the DLRels", loc, logging.String("prefix", prefix))
+		}
+	}
+	return pointed, nil
+}
+
+// prefixes gets the prefixes of the keys checked. The trash keeps the raw values and it is not checked
+func prefixes() []string {
+	var ps []string
+	for _, p := range entity.Prefixes() {
+		if p != entity.SchTrash {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// isEntity checks if the key is the key of an entity. The key is composed by the scheme and the identifier
+func isEntity(key string) bool {
+	if len(key) <= idLen || len(key) > idLen+2 {
+		return false
+	}
+	_, err := xid.FromString(key[len(key)-idLen:])
+	return err == nil
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package fsck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/carisa/internal/api/category"
+	"github.com/carisa/internal/api/ente"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance/samples"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/relation"
+	"github.com/carisa/internal/api/space"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecker_Check(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+
+	if !assert.NoError(t, sampling(crud, mng), "Sampling") {
+		return
+	}
+
+	c := NewChecker(cnt, crud.Store(), 2)
+
+	report, err := c.Check(false)
+	if assert.NoError(t, err, "Check") {
+		assert.Equal(t, 0, report.Repaired, "Check without repair")
+		assert.Equal(
+			t,
+			map[Kind]int{OrphanDLR: 2, MissingParent: 1, DanglingPointer: 1, OrphanLink: 1, NameMismatch: 1},
+			kinds(report),
+			"Issues")
+	}
+
+	report, err = c.Check(true)
+	if assert.NoError(t, err, "Repair") {
+		assert.Equal(t, 5, report.Repaired, "Repaired")
+		assert.Equal(t, 1, report.Pending(), "Pending")
+	}
+
+	report, err = c.Check(false)
+	if assert.NoError(t, err, "Check after repairing") {
+		assert.Equal(t, map[Kind]int{NameMismatch: 1}, kinds(report), "Issues after repairing")
+	}
+}
+
+func TestChecker_RepairChangedAfterScan(t *testing.T) {
+	mng, cnt, crud := mock.NewFullCrudOperFaked(t)
+	defer mng.Close()
+
+	if !assert.NoError(t, sampling(crud, mng), "Sampling") {
+		return
+	}
+
+	c := NewChecker(cnt, crud.Store(), 2)
+	ks, _, err := c.scan()
+	if !assert.NoError(t, err, "Scan") {
+		return
+	}
+	issues := c.issues(ks)
+
+	// Other process writes the child of the orphan DLRels and a DLRel that points to the orphan link
+	store := crud.Store()
+	txn := storage.NewTxn(store)
+	children := make(map[string]bool)
+	for _, issue := range issues {
+		switch issue.Kind {
+		case OrphanDLR:
+			if !children[issue.Ref] { // The ente has two DLRels
+				children[issue.Ref] = true
+				txn.Match(issue.Ref, "")
+				txn.DoFound(store.PutRaw(issue.Ref, "child"))
+			}
+		case OrphanLink:
+			dlr, err := store.Put(&storage.DLRel{
+				ChildID:  entity.EnteKey(xid.New()),
+				ParentID: entity.SpaceKey(xid.New()),
+				Pointer:  issue.Key,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			txn.DoFound(dlr)
+		}
+	}
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+
+	repaired, err := c.repair(ks, issues)
+	if assert.NoError(t, err, "Repair") {
+		assert.Equal(t, 2, repaired, "Repaired")
+		for _, issue := range issues {
+			switch issue.Kind {
+			case OrphanDLR, OrphanLink:
+				assert.False(t, issue.Repaired, issue.Kind)
+				found, err := store.Exists(context.TODO(), issue.Key)
+				if assert.NoError(t, err) {
+					assert.True(t, found, "Key kept")
+				}
+			case MissingParent, DanglingPointer:
+				assert.True(t, issue.Repaired, issue.Kind)
+			}
+		}
+	}
+}
+
+func TestIsEntity(t *testing.T) {
+	id := xid.New()
+	assert.True(t, isEntity(entity.EnteKey(id)), "Ente")
+	assert.True(t, isEntity(entity.EntePropKey(id)), "Ente property")
+	assert.False(t, isEntity(strings.Concat(entity.SpaceKey(id), relation.SpaceEnteLn, "n", entity.EnteKey(id))), "Link")
+	assert.False(t, isEntity("Eshort"), "Short")
+}
+
+func kinds(r Report) map[Kind]int {
+	ks := make(map[Kind]int)
+	for _, i := range r.Issues {
+		ks[i.Kind]++
+	}
+	return ks
+}
+
+// sampling creates the entities and breaks the relations
+func sampling(crud storage.CrudOperation, mng storage.Integration) error {
+	cnt := mock.NewContainerFake()
+	store := crud.Store()
+
+	inst, err := samples.CreateInstance(mng)
+	if err != nil {
+		return err
+	}
+	spc := space.New()
+	spc.Name = "space"
+	spc.Desc = "desc"
+	spc.InstID = inst.ID
+	if _, _, err := crud.CreateWithRel("loc", cnt.StoreTimeout(context.Background()), &spc); err != nil {
+		return err
+	}
+	cat := category.New()
+	cat.Name = "category"
+	cat.Desc = "desc"
+	cat.Root = true
+	cat.ParentID = spc.ID
+	if _, _, err := crud.CreateWithRel("loc", cnt.StoreTimeout(context.Background()), &cat); err != nil {
+		return err
+	}
+
+	var entes [3]ente.Ente
+	for i := range entes {
+		entes[i] = ente.New()
+		entes[i].Name = strings.Concat("ente", string(rune('0'+i)))
+		entes[i].Desc = "desc"
+		entes[i].SpaceID = spc.ID
+		if _, _, err := crud.CreateWithRel("loc", cnt.StoreTimeout(context.Background()), &entes[i]); err != nil {
+			return err
+		}
+	}
+	_, _, _, err = crud.LinkTo(
+		"loc",
+		cnt.StoreTimeout(context.Background()),
+		nil,
+		&entes[0],
+		cat.Key(),
+		func(child storage.Entity) { child.(*ente.Ente).CatID = cat.ID })
+	if err != nil {
+		return err
+	}
+
+	link := entes[2].Link().(*relation.SpaceEnte)
+	link.Name = "other"
+	nameMismatch, err := store.Put(link)
+	if err != nil {
+		return err
+	}
+	orphan := &relation.SpaceEnte{
+		ID:     strings.Concat(spc.Key(), relation.SpaceEnteLn, "orphan", entity.EnteKey(xid.New())),
+		Name:   "orphan",
+		EnteID: xid.New().String(),
+	}
+	orphanLink, err := store.Put(orphan)
+	if err != nil {
+		return err
+	}
+	missing, err := store.Put(&storage.DLRel{
+		ChildID:  cat.Key(),
+		ParentID: entity.SpaceKey(xid.New()),
+		Type:     relation.SpaceCatLn,
+		Pointer:  "missing",
+	})
+	if err != nil {
+		return err
+	}
+
+	txn := storage.NewTxn(store)
+	txn.Find(entes[0].Key())
+	txn.DoFound(store.Remove(entes[0].Key()))        // The DLRels of the ente are orphan
+	txn.DoFound(store.Remove(entes[1].Link().Key())) // The DLRel of the ente points to nothing
+	txn.DoFound(nameMismatch)
+	txn.DoFound(orphanLink)
+	txn.DoFound(missing)
+	_, err = txn.Commit(context.TODO())
+	return err
+}
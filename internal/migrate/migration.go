@@ -0,0 +1,351 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+// Package migrate migrates the stored data when the key layout or the codec of the values change.
+// The migration is composed by versioned steps that are applied in order. The version of the schema
+// of the store is kept into the VersionKey key
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	strs "strings"
+
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+)
+
+const (
+	loc = "migrate"
+	// MaxBatch is the maximum number of keys rewritten by transaction. etcd limits the transactions
+	// to 128 operations by default and each key rewritten needs a compare, a remove and a put at most
+	MaxBatch = 128 / 3
+	// retries is the number of attempts to rewrite a batch when other process changes its keys at the same time
+	retries = 5
+)
+
+// errConflict is returned when the keys of a batch are changed by other process in all attempts
+var errConflict = errors.New("the keys have been changed by other process during the migration. Run the migration again")
+
+// errCollision is returned when a key is renamed to a key that already exists
+var errCollision = errors.New("the key renamed already exists")
+
+// VersionKey is the key of the schema version of the store
+var VersionKey = strings.Concat(entity.SchMeta, "schema-version")
+
+// Rewrite rewrites the key and the value of the store.
+// If the key returned is empty the key is removed.
+// If the key and the value don't change the key is skipped, so the rewrites must be idempotent
+// because the new keys can be scanned again in the same step.
+type Rewrite func(key string, value string) (string, string, error)
+
+// Apply migrates the store as a whole when the step can not be applied key by key,
+// for example to aggregate several keys. If dryRun is true the changes are not written.
+// Returns the number of keys rewritten
+type Apply func(cnt *runtime.Container, store storage.CRUD, dryRun bool) (int, error)
+
+// Step is a migration step
+type Step struct {
+	// Version is the schema version after the step
+	Version int
+	// Desc describes the migration
+	Desc string
+	// Prefixes are the prefixes of the keys scanned
+	Prefixes []string
+	// Rewrite is applied to each key and value scanned
+	Rewrite Rewrite
+	// Apply is applied to each store instead of Rewrite if it is not nil
+	Apply Apply
+}
+
+// Report summarizes the migration
+type Report struct {
+	From      int
+	To        int
+	Scanned   int
+	Rewritten int
+	Removed   int
+	DryRun    bool
+}
+
+func (r *Report) add(changes Report) {
+	r.Rewritten += changes.Rewritten
+	r.Removed += changes.Removed
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf(
+		"migration from version %d to %d. Scanned: %d, rewritten: %d, removed: %d, dry run: %t",
+		r.From,
+		r.To,
+		r.Scanned,
+		r.Rewritten,
+		r.Removed,
+		r.DryRun)
+}
+
+// Migrator applies the steps whose version is greater than the schema version of the store.
+// The steps are applied to the keys of the root and of each tenant registered, see entity.TenantKey.
+// The keys of the tenants are scanned and rewritten into their namespaces, see storage.NewNamespace,
+// so the steps receive the keys without the prefix of the namespace.
+// The keys are rewritten in batched transactions
+type Migrator struct {
+	cnt   *runtime.Container
+	store storage.CRUD
+	batch int
+	steps []Step
+}
+
+// NewMigrator builds a migrator. The batch is the number of keys rewritten by transaction,
+// it is capped to MaxBatch
+func NewMigrator(cnt *runtime.Container, store storage.CRUD, batch int, steps ...Step) Migrator {
+	if batch > MaxBatch {
+		batch = MaxBatch
+	}
+	return Migrator{
+		cnt:   cnt,
+		store: store,
+		batch: batch,
+		steps: steps,
+	}
+}
+
+// Version gets the schema version of the store. If the version doesn't exist the version is 0
+func (m *Migrator) Version() (int, error) {
+	ctx, cancel := m.cnt.StoreWithTimeout(context.Background())
+	found, value, err := m.store.GetRaw(ctx, VersionKey)
+	cancel()
+	if err != nil {
+		return 0, m.cnt.Log.ErrWrap(err, "getting the schema version", loc)
+	}
+	if !found {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, m.cnt.Log.ErrWrap1(err, "the schema version has a incorrect format", loc, logging.String("version", value))
+	}
+	return version, nil
+}
+
+// Run applies the pending steps. If dryRun is true the changes are reported but not written
+func (m *Migrator) Run(dryRun bool) (Report, error) {
+	if err := m.check(); err != nil {
+		return Report{}, err
+	}
+
+	version, err := m.Version()
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{From: version, To: version, DryRun: dryRun}
+	stores, err := m.namespaces()
+	if err != nil {
+		return report, err
+	}
+
+	for _, step := range m.steps {
+		if step.Version <= version {
+			continue
+		}
+		m.cnt.Log.Info2(
+			"applying migration step",
+			loc,
+			logging.String("version", strconv.Itoa(step.Version)),
+			logging.String("description", step.Desc))
+
+		storeTimeout := m.cnt.StoreTimeout(context.Background())
+		for _, store := range stores {
+			if step.Apply != nil {
+				rewritten, err := step.Apply(m.cnt, store, dryRun)
+				report.Rewritten += rewritten
+				if err != nil {
+					return report, m.cnt.Log.ErrWrap1(err, "migrating the store", loc, logging.String("version", strconv.Itoa(step.Version)))
+				}
+				continue
+			}
+			for _, prefix := range step.Prefixes {
+				err := storage.ScanRaw(storeTimeout, store, prefix, m.batch, func(keys []string, values map[string]string) error {
+					return m.rewrite(store, step, keys, values, dryRun, &report)
+				})
+				if err != nil {
+					return report, m.cnt.Log.ErrWrap1(err, "migrating the keys", loc, logging.String("prefix", prefix))
+				}
+			}
+		}
+		if !dryRun {
+			if err := m.setVersion(step.Version); err != nil {
+				return report, err
+			}
+		}
+		report.To = step.Version
+	}
+	return report, nil
+}
+
+// namespaces gets the store of the root and the stores of the namespaces of the tenants registered
+func (m *Migrator) namespaces() ([]storage.CRUD, error) {
+	stores := []storage.CRUD{m.store}
+	prefix := entity.TenantKey("")
+	storeTimeout := m.cnt.StoreTimeout(context.Background())
+	err := storage.ScanRaw(storeTimeout, m.store, prefix, m.batch, func(keys []string, _ map[string]string) error {
+		for _, key := range keys {
+			if name := strs.TrimPrefix(key, prefix); storage.ValidNamespace(name) {
+				stores = append(stores, storage.NewNamespace(m.store, name))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, m.cnt.Log.ErrWrap(err, "listing the tenants", loc)
+	}
+	return stores, nil
+}
+
+// check checks that the versions of the steps are ordered
+func (m *Migrator) check() error {
+	for i := 1; i < len(m.steps); i++ {
+		if m.steps[i].Version <= m.steps[i-1].Version {
+			return fmt.Errorf("the migration step %d is not ordered", m.steps[i].Version)
+		}
+	}
+	return nil
+}
+
+// rewrite rewrites a batch of keys of the store in the same transaction.
+// Each key rewritten is compared with the value scanned, so if other process changes or removes
+// some key of the batch the transaction fails and the batch is read and rewritten again.
+// The keys renamed must not exist, otherwise the collision is returned instead of overwriting them
+func (m *Migrator) rewrite(
+	store storage.CRUD,
+	step Step,
+	keys []string,
+	values map[string]string,
+	dryRun bool,
+	report *Report) error {
+	//
+	report.Scanned += len(keys)
+	for i := 0; i < retries; i++ {
+		txn := storage.NewTxn(store)
+		changes := Report{}
+		var renamed []string
+		for _, key := range keys {
+			value, ok := values[key]
+			if !ok { // Removed by other process
+				continue
+			}
+			newKey, newValue, err := step.Rewrite(key, value)
+			if err != nil {
+				return m.cnt.Log.ErrWrap1(err, "rewriting the key", loc, logging.String("key", key))
+			}
+			if newKey == key && newValue == value {
+				continue
+			}
+			txn.Match(key, value)
+			if len(newKey) == 0 {
+				changes.Removed++
+				txn.DoFound(store.Remove(key))
+				continue
+			}
+			changes.Rewritten++
+			if newKey != key {
+				renamed = append(renamed, newKey)
+				txn.Match(newKey, "")
+				txn.DoFound(store.Remove(key))
+			}
+			txn.DoFound(store.PutRaw(newKey, newValue))
+		}
+
+		if dryRun || changes.Rewritten+changes.Removed == 0 {
+			if err := m.collision(store, renamed); err != nil {
+				return err
+			}
+			report.add(changes)
+			return nil
+		}
+		ctx, cancel := m.cnt.StoreWithTimeout(context.Background())
+		swapped, err := txn.Commit(ctx)
+		cancel()
+		if err != nil {
+			return m.cnt.Log.ErrWrap1(err, "commit rewriting the keys", loc, logging.String("key", keys[0]))
+		}
+		if swapped {
+			report.add(changes)
+			return nil
+		}
+		if err := m.collision(store, renamed); err != nil {
+			return err
+		}
+		if values, err = m.read(store, keys); err != nil {
+			return err
+		}
+	}
+	return m.cnt.Log.ErrWrap1(errConflict, "rewriting the keys", loc, logging.String("key", keys[0]))
+}
+
+// collision checks that the keys renamed don't exist
+func (m *Migrator) collision(store storage.CRUD, renamed []string) error {
+	for _, key := range renamed {
+		ctx, cancel := m.cnt.StoreWithTimeout(context.Background())
+		found, _, err := store.GetRaw(ctx, key)
+		cancel()
+		if err != nil {
+			return m.cnt.Log.ErrWrap1(err, "reading the key renamed", loc, logging.String("key", key))
+		}
+		if found {
+			return m.cnt.Log.ErrWrap1(errCollision, "renaming the key", loc, logging.String("key", key))
+		}
+	}
+	return nil
+}
+
+// read reads the current values of the keys. The keys removed are not returned
+func (m *Migrator) read(store storage.CRUD, keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		ctx, cancel := m.cnt.StoreWithTimeout(context.Background())
+		found, value, err := store.GetRaw(ctx, key)
+		cancel()
+		if err != nil {
+			return nil, m.cnt.Log.ErrWrap1(err, "reading the key again", loc, logging.String("key", key))
+		}
+		if found {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// setVersion stores the schema version
+func (m *Migrator) setVersion(version int) error {
+	txn := storage.NewTxn(m.store)
+	txn.Find(VersionKey)
+	put := m.store.PutRaw(VersionKey, strconv.Itoa(version))
+	txn.DoFound(put)
+	txn.DoNotFound(put)
+	ctx, cancel := m.cnt.StoreWithTimeout(context.Background())
+	_, err := txn.Commit(ctx)
+	cancel()
+	if err != nil {
+		return m.cnt.Log.ErrWrap1(err, "storing the schema version", loc, logging.String("version", strconv.Itoa(version)))
+	}
+	return nil
+}
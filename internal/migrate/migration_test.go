@@ -0,0 +1,428 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	strs "strings"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/api/category"
+	"github.com/carisa/internal/api/ente"
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance"
+	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/api/object"
+	"github.com/carisa/internal/api/plugin"
+	"github.com/carisa/internal/api/relation"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/space"
+	"github.com/carisa/internal/api/trash"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/pkg/errors"
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/assert"
+)
+
+type value struct {
+	Name string
+}
+
+func TestMigrator_Run(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+
+	legacy := legacyValue(t, "legacy")
+	tagged, err := encoding.Encode(value{Name: "tagged"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	values := map[string]string{
+		strings.Concat(entity.SchEnte, "1"):     legacy,
+		strings.Concat(entity.SchEnte, "2"):     legacy,
+		strings.Concat(entity.SchCategory, "1"): legacy,
+		strings.Concat(entity.SchSpace, "1"):    tagged,
+	}
+	if !putRaw(t, mng.Store(), values) {
+		return
+	}
+
+	m := NewMigrator(cnt, mng.Store(), 2, Steps()...)
+
+	report, err := m.Run(true)
+	if assert.NoError(t, err, "Dry run") {
+		assert.Equal(t, Report{From: 0, To: 3, Scanned: 4, Rewritten: 3, DryRun: true}, report, "Dry run")
+		version, err := m.Version()
+		if assert.NoError(t, err) {
+			assert.Equal(t, 0, version, "Dry run version")
+		}
+		for k, v := range values {
+			_, stored, err := mng.Store().GetRaw(context.TODO(), k)
+			if assert.NoError(t, err) {
+				assert.Equal(t, v, stored, "Dry run value")
+			}
+		}
+	}
+
+	report, err = m.Run(false)
+	if assert.NoError(t, err, "Run") {
+		assert.Equal(t, Report{From: 0, To: 3, Scanned: 4, Rewritten: 3}, report, "Run")
+		version, err := m.Version()
+		if assert.NoError(t, err) {
+			assert.Equal(t, 3, version, "Version")
+		}
+		for k := range values {
+			_, stored, err := mng.Store().GetRaw(context.TODO(), k)
+			if assert.NoError(t, err) {
+				assert.True(t, encoding.Tagged(stored), k)
+				var v value
+				assert.NoError(t, encoding.Decode(stored, &v), k)
+			}
+		}
+	}
+
+	report, err = m.Run(false)
+	if assert.NoError(t, err, "Run again") {
+		assert.Equal(t, Report{From: 3, To: 3}, report, "Run again")
+	}
+}
+
+func TestMigrator_RunTenants(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+	store := mng.Store()
+	acme := storage.NewNamespace(store, "acme")
+
+	legacy := legacyValue(t, "legacy")
+	key := strings.Concat(entity.SchEnte, "1")
+	if !putRaw(t, store, map[string]string{entity.TenantKey("acme"): "acme", key: legacy}) {
+		return
+	}
+	if !putRaw(t, acme, map[string]string{key: legacy}) {
+		return
+	}
+
+	m := NewMigrator(cnt, store, 2, Steps()...)
+	report, err := m.Run(false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Report{From: 0, To: 3, Scanned: 2, Rewritten: 2}, report, "Root and tenant")
+		_, stored, err := acme.GetRaw(context.TODO(), key)
+		if assert.NoError(t, err) {
+			assert.True(t, encoding.Tagged(stored), "Tenant key migrated")
+		}
+	}
+}
+
+func TestMigrator_RunRekey(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	cnt := mock.NewContainerFake()
+
+	values := map[string]string{
+		"Xold1": "1",
+		"Xold2": "2",
+		"Xold3": "remove",
+		"Xold4": "4",
+		"Xold5": "5",
+	}
+	if !putRaw(t, mng.Store(), values) {
+		return
+	}
+
+	steps := []Step{
+		{
+			Version:  1,
+			Desc:     "rename",
+			Prefixes: []string{"X"},
+			Rewrite: func(key string, value string) (string, string, error) {
+				if key[1:4] == "old" {
+					return strings.Concat("Xnew", key[4:]), value, nil
+				}
+				return key, value, nil
+			},
+		},
+		{
+			Version:  3,
+			Desc:     "remove",
+			Prefixes: []string{"Xnew"},
+			Rewrite: func(key string, value string) (string, string, error) {
+				if value == "remove" {
+					return "", "", nil
+				}
+				return key, value, nil
+			},
+		},
+	}
+
+	m := NewMigrator(cnt, mng.Store(), 2, steps...)
+	report, err := m.Run(false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3, report.To, "Version")
+		assert.Equal(t, 5, report.Rewritten, "Rewritten")
+		assert.Equal(t, 1, report.Removed, "Removed")
+
+		ctx := context.TODO()
+		stored, err := mng.Store().RangeRaw(ctx, "X", "X", 0)
+		if assert.NoError(t, err) {
+			assert.Equal(
+				t,
+				map[string]string{"Xnew1": "1", "Xnew2": "2", "Xnew4": "4", "Xnew5": "5"},
+				stored,
+				"Keys migrated")
+		}
+	}
+}
+
+func TestMigrator_RunRekeyCollision(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	store := mng.Store()
+
+	if !putRaw(t, store, map[string]string{"Xold1": "1", "Ynew1": "other"}) {
+		return
+	}
+	step := Step{
+		Version:  1,
+		Desc:     "rename",
+		Prefixes: []string{"X"},
+		Rewrite: func(key string, value string) (string, string, error) {
+			return strings.Concat("Ynew", key[4:]), value, nil
+		},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		m := NewMigrator(mock.NewContainerFake(), store, 2, step)
+		_, err := m.Run(dryRun)
+		assert.Equal(t, errCollision, errors.Cause(err), "Collision")
+	}
+	stored, err := store.RangeRaw(context.TODO(), "", "", 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"Xold1": "1", "Ynew1": "other"}, stored, "Keys not overwritten")
+	}
+}
+
+func TestMigrator_RunConcurrentWrite(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	store := mng.Store()
+
+	if !putRaw(t, store, map[string]string{"X1": "1", "X2": "2"}) {
+		return
+	}
+
+	written := false
+	step := Step{
+		Version:  1,
+		Desc:     "mark",
+		Prefixes: []string{"X"},
+		Rewrite: func(key string, value string) (string, string, error) {
+			if !written {
+				// Other process changes the second key after the scan
+				written = true
+				txn := storage.NewTxn(store)
+				txn.Find("X2")
+				txn.DoFound(store.PutRaw("X2", "changed"))
+				_, err := txn.Commit(context.TODO())
+				assert.NoError(t, err)
+			}
+			if strs.HasSuffix(value, "!") {
+				return key, value, nil
+			}
+			return key, strings.Concat(value, "!"), nil
+		},
+	}
+
+	m := NewMigrator(mock.NewContainerFake(), store, 2, step)
+	report, err := m.Run(false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Report{From: 0, To: 1, Scanned: 2, Rewritten: 2}, report, "Rewritten once")
+		stored, err := store.RangeRaw(context.TODO(), "X", "X", 0)
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{"X1": "1!", "X2": "changed!"}, stored, "Concurrent write kept")
+		}
+	}
+}
+
+func TestMigrator_MaxBatch(t *testing.T) {
+	m := NewMigrator(mock.NewContainerFake(), nil, 1000)
+	assert.Equal(t, MaxBatch, m.batch)
+}
+
+func TestMigrator_RunApply(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	store := mng.Store()
+
+	if !putRaw(t, store, map[string]string{entity.TenantKey("acme"): "acme"}) {
+		return
+	}
+	var applied []bool
+	step := Step{
+		Version: 1,
+		Desc:    "apply",
+		Apply: func(_ *runtime.Container, _ storage.CRUD, dryRun bool) (int, error) {
+			applied = append(applied, dryRun)
+			return 2, nil
+		},
+	}
+
+	m := NewMigrator(mock.NewContainerFake(), store, 2, step)
+	report, err := m.Run(false)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Report{From: 0, To: 1, Rewritten: 4}, report, "Root and tenant")
+		assert.Equal(t, []bool{false, false}, applied, "Applied to each store")
+	}
+}
+
+func TestMigrator_RunUnordered(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+
+	m := NewMigrator(mock.NewContainerFake(), mng.Store(), 2, Step{Version: 2}, Step{Version: 1})
+	_, err := m.Run(false)
+	assert.Error(t, err)
+}
+
+func TestReport_String(t *testing.T) {
+	r := Report{From: 1, To: 2, Scanned: 3, Rewritten: 4, Removed: 5, DryRun: true}
+	assert.Equal(t, "migration from version 1 to 2. Scanned: 3, rewritten: 4, removed: 5, dry run: true", r.String())
+}
+
+func legacyValue(t *testing.T, name string) string {
+	var b bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&b).Encode(value{Name: name}))
+	return b.String()
+}
+
+func putRaw(t *testing.T, store storage.CRUD, values map[string]string) bool {
+	for k, v := range values {
+		txn := storage.NewTxn(store)
+		txn.Find(k)
+		txn.DoNotFound(store.PutRaw(k, v))
+		if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSteps_InstanceQuotas(t *testing.T) {
+	old := struct {
+		entity.Descriptor
+	}{Descriptor: entity.NewDescriptor()}
+	old.Name = "name"
+	legacy, err := encoding.EncodeWith(encoding.Binary(), &old)
+	if !assert.NoError(t, err) {
+		return
+	}
+	key := entity.InstKey(old.ID)
+
+	_, migrated, err := instanceQuotas(key, legacy)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, legacy, migrated, "Quotas added")
+		var inst instance.Instance
+		if assert.NoError(t, encoding.Decode(migrated, &inst)) {
+			assert.Equal(t, old.Descriptor, inst.Descriptor, "Descriptor")
+		}
+		_, again, err := instanceQuotas(key, migrated)
+		if assert.NoError(t, err) {
+			assert.Equal(t, migrated, again, "Idempotent")
+		}
+	}
+
+	_, value, err := instanceQuotas(strings.Concat(key, "link"), legacy)
+	if assert.NoError(t, err) {
+		assert.Equal(t, legacy, value, "Link skipped")
+	}
+	gob, err := encoding.Encode(&old)
+	if assert.NoError(t, err) {
+		_, value, err = instanceQuotas(key, gob)
+		if assert.NoError(t, err) {
+			assert.Equal(t, gob, value, "Gob skipped")
+		}
+	}
+}
+
+func TestCodecs_Domain(t *testing.T) {
+	inst := instance.New()
+	inst.Name = "instance"
+	inst.Quotas.Spaces = 2
+	spc := space.New()
+	spc.InstID = xid.New()
+	cat := category.New()
+	cat.ParentID = xid.New()
+	cat.Root = true
+	catProp := category.NewProp()
+	catProp.CatID = xid.New()
+	ent := ente.New()
+	ent.SpaceID = xid.New()
+	ent.CatID = xid.New()
+	entProp := ente.NewProp()
+	entProp.CatPropID = xid.New()
+	proto := plugin.New()
+	obj := object.New()
+	obj.SchContainer = entity.SchEnte
+	obj.ContainerID = xid.New()
+	item := trash.Item{
+		Descriptor: entity.NewDescriptor(),
+		EntKey:     "key",
+		Deleted:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Values:     map[string][]byte{"key": []byte("value")},
+	}
+
+	tests := []struct {
+		data  interface{}
+		empty func() interface{}
+	}{
+		{data: &inst, empty: func() interface{} { return &instance.Instance{} }},
+		{data: &spc, empty: func() interface{} { return &space.Space{} }},
+		{data: &cat, empty: func() interface{} { return &category.Category{} }},
+		{data: &catProp, empty: func() interface{} { return &category.Prop{} }},
+		{data: &ent, empty: func() interface{} { return &ente.Ente{} }},
+		{data: &entProp, empty: func() interface{} { return &ente.Prop{} }},
+		{data: &proto, empty: func() interface{} { return &plugin.Prototype{} }},
+		{data: &obj, empty: func() interface{} { return &object.Instance{} }},
+		{data: &item, empty: func() interface{} { return &trash.Item{} }},
+		{data: &relation.InstSpace{ID: "id", Name: "name", SpaceID: "spaceID"},
+			empty: func() interface{} { return &relation.InstSpace{} }},
+		{data: &relation.Hierarchy{ID: "id", Name: "name", LinkID: "linkID", Category: true},
+			empty: func() interface{} { return &relation.Hierarchy{} }},
+		{data: &relation.PlatformInstance{ID: "id", Name: "name", InstID: "instID", Category: "query"},
+			empty: func() interface{} { return &relation.PlatformInstance{} }},
+		{data: &storage.DLRel{ChildID: "childID", ParentID: "parentID", Type: "type", Pointer: "pointer"},
+			empty: func() interface{} { return &storage.DLRel{} }},
+	}
+
+	for _, codec := range []encoding.Codec{encoding.Gob(), encoding.JSON(), encoding.Binary()} {
+		for _, tt := range tests {
+			encode, err := encoding.EncodeWith(codec, tt.data)
+			if assert.NoError(t, err, codec.Name()) {
+				decode := tt.empty()
+				if assert.NoError(t, encoding.Decode(encode, decode), codec.Name()) {
+					assert.Equal(t, tt.data, decode, codec.Name())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package migrate
+
+import (
+	"github.com/carisa/internal/api/entity"
+	"github.com/carisa/internal/api/instance"
+	"github.com/carisa/internal/api/runtime"
+	"github.com/carisa/internal/api/service"
+	"github.com/carisa/pkg/encoding"
+	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/strings"
+	"github.com/rs/xid"
+)
+
+// Steps gets the ordered migration steps of the store. The new steps are added at the end
+func Steps() []Step {
+	return []Step{
+		{
+			Version:  1,
+			Desc:     "tag the values encoded before the codecs existed with the gob tag",
+			Prefixes: entity.Prefixes(),
+			Rewrite:  tagGob,
+		},
+		{
+			Version:  2,
+			Desc:     "re-encode the instances encoded with the binary codec with the quotas",
+			Prefixes: []string{entity.SchInstance},
+			Rewrite:  instanceQuotas,
+		},
+		{
+			Version: 3,
+			Desc:    "backfill the counters of the quotas of the instances with the entities that they contain",
+			Apply:   recountQuotas,
+		},
+	}
+}
+
+// tagGob tags the gob values without tag
+func tagGob(key string, value string) (string, string, error) {
+	if encoding.Tagged(value) {
+		return key, value, nil
+	}
+	return key, strings.Concat(string([]byte{encoding.TagGob}), value), nil
+}
+
+// instanceQuotas re-encodes the instances encoded with the binary codec before the quotas existed
+// so that the stored values have the quotas. The codecs decode the instances without quotas as empty quotas.
+// The links of the instances are skipped
+func instanceQuotas(key string, value string) (string, string, error) {
+	if len(key) != len(entity.SchInstance)+len(xid.NilID().String()) || len(value) == 0 || value[0] != encoding.TagBinary {
+		return key, value, nil
+	}
+	var inst instance.Instance
+	if err := encoding.Decode(value, &inst); err != nil {
+		return key, value, err
+	}
+	migrated, err := encoding.EncodeWith(encoding.Binary(), &inst)
+	return key, migrated, err
+}
+
+// recountQuotas sets the counters of the quotas to the entities contained into each instance,
+// the entities created before the quotas existed were not counted. See instance.Quota.Recount
+func recountQuotas(cnt *runtime.Container, store storage.CRUD, dryRun bool) (int, error) {
+	q := instance.NewQuota(cnt, service.NewExt(cnt, store), store)
+	return q.Recount(dryRun)
+}
@@ -17,17 +17,38 @@
 package factory
 
 import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"time"
+
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/internal/splitter/runtime"
 	"github.com/carisa/internal/splitter/service"
+	"github.com/carisa/pkg/health"
+	loge "github.com/carisa/pkg/http/echo"
 	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/metrics"
 	"github.com/carisa/pkg/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-const locBuild = "factory.build"
+const (
+	locBuild = "factory.build"
+	// HeartbeatPath is the route of the admin port to get the heartbeat state. See service.Heartbeat
+	HeartbeatPath = "/heartbeat"
+	// WorksPath is the route of the admin port to get the works that the splitter processes
+	WorksPath = "/works"
+)
 
 // Template builds the dependencies for the application
 type Template struct {
-	Controller service.Controller
+	Config     runtime.Config
+	Controller *service.Controller // Controller is shared with the admin routes
+	Reaper     *cluster.Reaper     // Reaper removes the dead splitters if this splitter is the leader
+	Rebalancer *cluster.Rebalancer // Rebalancer moves the works between splitters if this splitter is the leader
+	Admin      *echo.Echo          // Admin serves the probes, the heartbeat state and the metrics
 
 	store storage.CRUD
 	cnt   *runtime.Container
@@ -43,26 +64,126 @@ func (c *Template) Close() {
 	}
 }
 
+// Run starts the splitter and blocks until the ctx is done, usually by a signal, the admin server fails
+// or the splitter gives up, see service.Controller.Failed. Then it drains the works, deregisters the splitter
+// and closes the store. Returns the exit code of the process: 0 if the splitter stopped cleanly and 1 otherwise
+func (c *Template) Run(ctx context.Context) int {
+	const loc = "factory.run"
+	if err := c.Controller.Start(); err != nil { // The error is logged by the controller
+		c.Close()
+		return 1
+	}
+	c.Reaper.Start()
+	c.Rebalancer.Start()
+
+	failed := make(chan error, 1)
+	go func() {
+		if err := c.Admin.Start(c.Config.Admin.Address()); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	code := 0
+	select {
+	case <-ctx.Done():
+		c.cnt.Log.Info("received the signal to stop", loc)
+	case err := <-failed:
+		_ = c.cnt.Log.ErrWrap(err, "the admin server failed", loc)
+		code = 1
+	case <-c.Controller.Failed(): // The error is logged by the controller
+		code = 1
+	}
+	if !c.shutdown() {
+		code = 1
+	}
+	c.Close()
+	return code
+}
+
+// shutdown stops the admin server, the jobs and the controller before Config.DrainInSecs.
+// The controller drains the works and removes the keys of the splitter, see service.Controller.Stop.
+// Returns false if it is not clean
+func (c *Template) shutdown() bool {
+	const loc = "factory.shutdown"
+	ctx, cancel := context.WithTimeout(context.Background(), c.Config.DrainInSecs*time.Second)
+	defer cancel()
+	c.cnt.Log.Info("draining the splitter", loc)
+
+	stopped := make(chan bool, 1)
+	go func() {
+		clean := true
+		if err := c.Admin.Shutdown(ctx); err != nil {
+			_ = c.cnt.Log.ErrWrap(err, "shutting down the admin server", loc)
+			clean = false
+		}
+		c.Rebalancer.Stop()
+		c.Reaper.Stop()
+		c.Controller.Stop() // If the lease is expired its keys are already removed
+		stopped <- clean
+	}()
+
+	select {
+	case clean := <-stopped:
+		if clean {
+			c.cnt.Log.Info("drained the splitter", loc)
+		}
+		return clean
+	case <-ctx.Done():
+		_ = c.cnt.Log.ErrWrap(ctx.Err(), "draining the splitter. the lease expires the keys of the splitter", loc)
+		return false
+	}
+}
+
 // Build builds the controllers, store, log, etc..
 func Build() Template {
 	return build(nil)
 }
 
 func build(mng storage.Integration /*for test*/) Template {
-	cnt, store := servers(mng)
+	cnt, store, e := servers(mng)
+	reg, store := instrument(cnt, store)
+	elections, err := storage.NewElections(store, cnt.LeaseTTLInSecs*time.Second)
+	if err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "building the elections", locBuild), locBuild)
+	}
+	ctrl := service.NewController(cnt, store, elections)
+	admin(e, cnt, store, &ctrl, reg)
 
 	return Template{
-		Controller: service.NewController(cnt, store),
-		store:      store,
-		cnt:        cnt,
+		Config:     cnt.Config,
+		Controller: &ctrl,
+		Reaper: cluster.NewReaper(
+			cnt.Cluster(),
+			cnt.ReapInSecs*time.Second,
+			ctrl.ID(),
+			ctrl.Elections(),
+			store,
+			cnt.StoreWithTimeout,
+			cnt.Log),
+		Rebalancer: cluster.NewRebalancer(
+			cnt.Cluster(),
+			cnt.Balance,
+			ctrl.ID(),
+			ctrl.Elections(),
+			store,
+			cnt.StoreWithTimeout,
+			cnt.Log),
+		Admin: e,
+		store: store,
+		cnt:   cnt,
 	}
 }
 
-func servers(mng storage.Integration) (*runtime.Container, storage.CRUD) {
+func servers(mng storage.Integration) (*runtime.Container, storage.CRUD, *echo.Echo) {
 	cnf := runtime.LoadConfig()
-	log, _ := logging.NewZapLogger(cnf.ZapConfig)
+	log, zLog := logging.NewZapLogger(cnf.ZapConfig)
 	log.Info1("loaded configuration", locBuild, logging.String("config", cnf.String()))
 
+	log.Info1("initializing admin server", locBuild, logging.String("address", cnf.Admin.Address()))
+	e := echo.New()
+	e.Logger = loge.NewLogging("echo", loge.ConvertLevel(log.Level()), zLog)
+	e.HTTPErrorHandler = loge.ErrorHandler(log)
+
 	cnt := runtime.NewContainer(cnf, storage.NewTxn, log)
 
 	log.Info1("starting etcd client", locBuild, logging.String("endpoints", cnf.EPSString()))
@@ -72,5 +193,37 @@ func servers(mng storage.Integration) (*runtime.Container, storage.CRUD) {
 	} else {
 		store = storage.NewEtcdConfig(cnf.EtcdConfig)
 	}
-	return cnt, store
+	return cnt, store, e
+}
+
+// instrument decorates the store to collect its metrics if the metrics are enabled.
+// Returns the registry of the metrics or nil if they are disabled
+func instrument(cnt *runtime.Container, store storage.CRUD) (*prometheus.Registry, storage.CRUD) {
+	if !cnt.Metrics.Enabled {
+		return nil, store
+	}
+	reg := metrics.NewRegistry()
+	m, err := storage.NewMetrics(reg)
+	if err != nil {
+		cnt.Log.PanicE(cnt.Log.ErrWrap(err, "registering the metrics of the store", locBuild), locBuild)
+	}
+	return reg, storage.NewInstrumented(store, m)
+}
+
+// admin adds the routes of the admin port. The splitter is ready if the store answers
+// and the heartbeat is renewed on time
+func admin(e *echo.Echo, cnt *runtime.Container, store storage.CRUD, ctrl *service.Controller, reg *prometheus.Registry) {
+	p := health.NewProbe(cnt.Version)
+	p.Add("store", health.StoreCheck(store, cnt.StoreWithTimeout))
+	p.Add("heartbeat", ctrl.Check)
+	loge.Probes(e, p)
+	e.GET(HeartbeatPath, func(c echo.Context) error {
+		return c.JSON(nethttp.StatusOK, ctrl.Heartbeat())
+	})
+	e.GET(WorksPath, func(c echo.Context) error {
+		return c.JSON(nethttp.StatusOK, ctrl.Works())
+	})
+	if reg != nil {
+		e.GET(cnt.Metrics.Route(), loge.MetricsHandler(reg))
+	}
 }
@@ -17,9 +17,18 @@
 package factory
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/carisa/internal/api/mock"
+	"github.com/carisa/internal/cluster"
+	splitterMock "github.com/carisa/internal/splitter/mock"
+	"github.com/carisa/pkg/health"
+	"github.com/carisa/pkg/storage"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,4 +42,107 @@ func TestTemplate_Build(t *testing.T) {
 	assert.NotNil(t, factory.store, "Store")
 
 	assert.NotNil(t, factory.Controller, "Controller")
+	assert.Equal(t, 8081, factory.Config.Admin.Port, "Admin port")
+	assert.NotNil(t, factory.Admin, "Admin server")
+	assert.NotNil(t, factory.Reaper, "Reaper")
+	assert.NotNil(t, factory.Rebalancer, "Rebalancer")
+}
+
+func TestTemplate_Admin(t *testing.T) {
+	sMock := mock.NewStorageFake(t)
+	defer sMock.Close()
+	factory := build(sMock)
+
+	rec := httptest.NewRecorder()
+	factory.Admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, health.ReadyPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "The splitter is not started")
+	assert.Contains(t, rec.Body.String(), `"heartbeat":"the splitter is not started"`, "Heartbeat check")
+
+	factory.Controller.Start()
+	defer factory.Controller.Stop()
+
+	rec = httptest.NewRecorder()
+	factory.Admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, health.ReadyPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code, "Ready")
+
+	rec = httptest.NewRecorder()
+	factory.Admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, HeartbeatPath, nil))
+	if assert.Equal(t, http.StatusOK, rec.Code, "Heartbeat") {
+		assert.Contains(t, rec.Body.String(), `"started":true`, "Heartbeat state")
+	}
+
+	rec = httptest.NewRecorder()
+	factory.Admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, WorksPath, nil))
+	if assert.Equal(t, http.StatusOK, rec.Code, "Works") {
+		assert.Equal(t, "[]\n", rec.Body.String(), "Without works")
+	}
+}
+
+func TestTemplate_Instrument(t *testing.T) {
+	sMock := mock.NewStorageFake(t)
+	defer sMock.Close()
+	cnt := splitterMock.NewContainerFake()
+	store := sMock.Store()
+
+	reg, s := instrument(cnt, store)
+	assert.Nil(t, reg, "Metrics disabled")
+	assert.Equal(t, store, s, "Store not instrumented")
+
+	cnt.Metrics.Enabled = true
+	reg, s = instrument(cnt, store)
+	assert.NotNil(t, reg, "Metrics enabled")
+	assert.NotEqual(t, store, s, "Store instrumented")
+}
+
+func TestTemplate_Run(t *testing.T) {
+	sMock := mock.NewStorageFake(t)
+	defer sMock.Close()
+	factory := build(sMock)
+	factory.store = unclosed{factory.store}
+	factory.Config.Admin.Port = 0
+	ctx, cancel := context.WithCancel(context.Background())
+
+	code := make(chan int, 1)
+	go func() { code <- factory.Run(ctx) }()
+	if !assert.Eventually(t, func() bool { return factory.Controller.Heartbeat().Started }, 2*time.Second, 10*time.Millisecond) {
+		cancel()
+		return
+	}
+	cancel()
+
+	select {
+	case c := <-code:
+		assert.Zero(t, c, "Clean exit")
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "The splitter is not stopped")
+		return
+	}
+	found, err := sMock.Store().Exists(context.TODO(), cluster.InfoKey(factory.Controller.ID()))
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Deregistered")
+	}
+}
+
+func TestTemplate_RunWithAdminError(t *testing.T) {
+	sMock := mock.NewStorageFake(t)
+	defer sMock.Close()
+	factory := build(sMock)
+	factory.store = unclosed{factory.store}
+	busy, err := net.Listen("tcp", ":0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer busy.Close()
+	factory.Config.Admin.Port = busy.Addr().(*net.TCPAddr).Port
+
+	assert.Equal(t, 1, factory.Run(context.Background()), "The admin port is busy")
+}
+
+// unclosed keeps the client of the integration store open, so the integration cluster closes it
+type unclosed struct {
+	storage.CRUD
+}
+
+func (unclosed) Close() error {
+	return nil
 }
@@ -17,9 +17,13 @@
 package runtime
 
 import (
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/pkg/runtime"
+	"github.com/carisa/pkg/strings"
 	"github.com/rs/xid"
 )
 
@@ -36,9 +40,21 @@ func newServer() Server {
 	}
 }
 
+// Admin describes the http configuration of the admin port.
+// The admin port serves the probes, the heartbeat state and the metrics
+type Admin struct {
+	Port int `json:"port"`
+}
+
+// Address returns address to connection server
+func (a *Admin) Address() string {
+	return strings.Concat(":", strconv.Itoa(a.Port))
+}
+
 // Config defines the global information
 type Config struct {
 	Server `json:"-"`
+	Admin  Admin `json:"admin,omitempty"`
 	// RenewHeartbeatInSecs look at Controller.renewHeartbeatInSecs.
 	RenewHeartbeatInSecs time.Duration `json:"renewHeartbeatInSecs,omitempty"`
 	// RenewConsumptionInSecs look at Controller.renewConsumptionInSecs.
@@ -46,20 +62,64 @@ type Config struct {
 	// because it calculates an average and would need at least n values.
 	// Each sample is taken every RenewHeartbeatInSecs seconds
 	RenewConsumptionInSecs time.Duration `json:"renewConsumptionInSecs,omitempty"`
+	// LeaseTTLInSecs is the time that the keys of the splitter live without heartbeat.
+	// It should be several times RenewHeartbeatInSecs to tolerate missed heartbeats and it must be greater
+	// than the deadline of the splitter plus ReapInSecs, so the reaper sees the tick of the dead splitter. See Validate
+	LeaseTTLInSecs time.Duration `json:"leaseTTLInSecs,omitempty"`
+	// MaxFailures is the number of consecutive failures calling to the store after which the splitter gives up.
+	// The calls are retried with backoff up to RenewHeartbeatInSecs
+	MaxFailures int `json:"maxFailures,omitempty"`
+	// DeadAfter is the number of heartbeats without tick after which the splitter is dead. See cluster.Reaper
+	DeadAfter int `json:"deadAfter,omitempty"`
+	// ReapInSecs is the interval to remove the dead splitters. See cluster.Reaper
+	ReapInSecs time.Duration `json:"reapInSecs,omitempty"`
+	// Balance describes the rebalancing of the works among the splitters. See cluster.Rebalancer
+	Balance cluster.Balance `json:"balance,omitempty"`
+	// DrainInSecs is the max time to stop the works and deregister the splitter when it receives
+	// the signal to stop. If the time is exceeded the splitter exits with error and the lease expires its keys
+	DrainInSecs time.Duration `json:"drainInSecs,omitempty"`
 	runtime.CommonConfig
 }
 
+// Cluster returns the heartbeat of the splitters seen by the rest of the cluster
+func (c *Config) Cluster() cluster.Config {
+	return cluster.Config{HeartbeatInSecs: c.RenewHeartbeatInSecs, DeadAfter: c.DeadAfter}
+}
+
+// Validate checks that the lease of a dead splitter does not expire before the reaper finds it.
+// When the lease expires the store removes its tick and the reaper only finds the splitter by its works
+func (c *Config) Validate() error {
+	reaped := c.Cluster().Deadline() + c.ReapInSecs*time.Second
+	if c.LeaseTTLInSecs*time.Second <= reaped {
+		return fmt.Errorf(
+			"the leaseTTLInSecs %d must be greater than renewHeartbeatInSecs x deadAfter + reapInSecs (%d)",
+			c.LeaseTTLInSecs,
+			reaped/time.Second)
+	}
+	return nil
+}
+
 func (c *Config) Common() *runtime.CommonConfig {
 	return &c.CommonConfig
 }
 
-// LoadConfig loads the configuration from environment variable
+// LoadConfig loads the configuration from environment variable. It panics if the configuration is not valid
 func LoadConfig() Config {
 	cnf := Config{
 		Server:                 newServer(),
+		Admin:                  Admin{Port: 8081},
 		RenewHeartbeatInSecs:   15,
 		RenewConsumptionInSecs: 60,
+		LeaseTTLInSecs:         90,
+		MaxFailures:            5,
+		DeadAfter:              3,
+		ReapInSecs:             30,
+		Balance:                cluster.Balance{EveryInSecs: 120, Threshold: 20, MaxMoves: 5, SettleInSecs: 600},
+		DrainInSecs:            30,
 	}
 	runtime.LoadConfig(envConfig, &cnf)
+	if err := cnf.Validate(); err != nil {
+		panic(strings.Concat("configuration environment variable is not valid: ", err.Error()))
+	}
 	return cnf
 }
@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/pkg/logging"
 	"github.com/carisa/pkg/runtime"
 	"github.com/carisa/pkg/storage"
@@ -39,8 +40,15 @@ func TestRuntime_LoadConfig(t *testing.T) {
 			envC: "",
 			cnf: Config{
 				Server:                 Server{},
+				Admin:                  Admin{Port: 8081},
 				RenewHeartbeatInSecs:   15,
 				RenewConsumptionInSecs: 60,
+				LeaseTTLInSecs:         90,
+				MaxFailures:            5,
+				DeadAfter:              3,
+				ReapInSecs:             30,
+				Balance:                cluster.Balance{EveryInSecs: 120, Threshold: 20, MaxMoves: 5, SettleInSecs: 600},
+				DrainInSecs:            30,
 				CommonConfig: runtime.CommonConfig{
 					EtcdConfig: storage.EtcdConfig{RequestTimeout: 10},
 				},
@@ -51,6 +59,15 @@ func TestRuntime_LoadConfig(t *testing.T) {
 			envC: `{
   "RenewHeartbeatInSecs": 25,
   "RenewConsumptionInSecs": 120,	
+  "leaseTTLInSecs": 160,
+  "deadAfter": 5,
+  "drainInSecs": 60,
+  "balance": {
+    "threshold": 30
+  },
+  "admin": {
+    "port": 9091
+  },
   "log": {
     "development": true, 
     "level": 2, 
@@ -58,8 +75,15 @@ func TestRuntime_LoadConfig(t *testing.T) {
   }
 }`, cnf: Config{
 				Server:                 Server{},
+				Admin:                  Admin{Port: 9091},
 				RenewHeartbeatInSecs:   25,
 				RenewConsumptionInSecs: 120,
+				LeaseTTLInSecs:         160,
+				MaxFailures:            5,
+				DeadAfter:              5,
+				ReapInSecs:             30,
+				Balance:                cluster.Balance{EveryInSecs: 120, Threshold: 30, MaxMoves: 5, SettleInSecs: 600},
+				DrainInSecs:            60,
 				CommonConfig: runtime.CommonConfig{
 					ZapConfig: logging.ZapConfig{
 						Development: true,
@@ -79,3 +103,21 @@ func TestRuntime_LoadConfig(t *testing.T) {
 		assert.NotEqualf(t, cnf.Server.Name, xid.NilID(), tt.name)
 	}
 }
+
+func TestRuntime_LoadConfigNotValid(t *testing.T) {
+	_ = os.Setenv(envConfig, `{"leaseTTLInSecs": 75}`)
+	defer func() { _ = os.Unsetenv(envConfig) }()
+	assert.Panics(t, func() { LoadConfig() }, "The lease expires before the reaper finds the dead splitter")
+}
+
+func TestRuntime_Validate(t *testing.T) {
+	cnf := Config{RenewHeartbeatInSecs: 15, DeadAfter: 3, ReapInSecs: 30, LeaseTTLInSecs: 75}
+	assert.Error(t, cnf.Validate(), "Equal to the deadline plus the reap interval")
+	cnf.LeaseTTLInSecs = 76
+	assert.NoError(t, cnf.Validate(), "Greater")
+}
+
+func TestRuntime_Cluster(t *testing.T) {
+	cnf := Config{RenewHeartbeatInSecs: 15, DeadAfter: 3}
+	assert.Equal(t, cluster.Config{HeartbeatInSecs: 15, DeadAfter: 3}, cnf.Cluster())
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+)
+
+const locAssign = "splitter.assignments"
+
+// Processor processes the works assigned to the splitter. See cluster.Assigner
+type Processor interface {
+	// Start starts to process the work
+	Start(work string)
+	// Stop stops to process the work
+	Stop(work string)
+}
+
+// logProcessor only logs the works. It is the processor by default
+type logProcessor struct {
+	log logging.Logger
+}
+
+// Start implements Processor.Start
+func (l logProcessor) Start(work string) {
+	l.log.Info1("starting to process the work", locAssign, logging.String("work", work))
+}
+
+// Stop implements Processor.Stop
+func (l logProcessor) Stop(work string) {
+	l.log.Info1("stopping to process the work", locAssign, logging.String("work", work))
+}
+
+// assignments follows the works assigned to the splitter watching its assignment prefix and
+// starts or stops them into the processor. If the watch fails it is started again after the retry interval.
+// The first response of each watch has all the works, so the works removed meanwhile are stopped.
+// The works removed are released when they are stopped, so they can be acquired by other splitter
+type assignments struct {
+	log      logging.Logger
+	watcher  storage.Watcher
+	retry    time.Duration
+	released func(work string)
+
+	mu        sync.Mutex
+	processor Processor
+	works     map[string]struct{}
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newAssignments(
+	log logging.Logger,
+	watcher storage.Watcher,
+	retry time.Duration,
+	released func(work string)) *assignments {
+	//
+	return &assignments{
+		log:       log,
+		watcher:   watcher,
+		retry:     retry,
+		released:  released,
+		processor: logProcessor{log: log},
+		works:     make(map[string]struct{}),
+	}
+}
+
+// start watches the assignments of the prefix in background
+func (a *assignments) start(prefix string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	a.done = make(chan struct{})
+	go a.watch(ctx, prefix)
+}
+
+// stop stops the watch and the processing of all works
+func (a *assignments) stop() {
+	if a.cancel == nil {
+		return
+	}
+	a.cancel()
+	<-a.done
+	a.cancel = nil
+	a.sync(nil, true)
+}
+
+func (a *assignments) watch(ctx context.Context, prefix string) {
+	defer close(a.done)
+	for {
+		full := true
+		for resp := range a.watcher.Watch(ctx, prefix) {
+			if resp.Err != nil {
+				_ = a.log.ErrWrap(resp.Err, "watching the assignments", locAssign)
+				break
+			}
+			works := make([]storage.Event, len(resp.Events))
+			for i, ev := range resp.Events {
+				ev.Key = ev.Key[len(prefix):]
+				works[i] = ev
+			}
+			for _, work := range a.sync(works, full) {
+				a.released(work)
+			}
+			full = false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.retry):
+		}
+	}
+}
+
+// sync applies the changes of the works. If the changes are full the works not included are stopped.
+// Returns the works stopped
+func (a *assignments) sync(changes []storage.Event, full bool) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var stopped []string
+	if full {
+		assigned := make(map[string]struct{}, len(changes))
+		for _, ev := range changes {
+			assigned[ev.Key] = struct{}{}
+		}
+		for work := range a.works {
+			if _, ok := assigned[work]; !ok {
+				delete(a.works, work)
+				a.processor.Stop(work)
+				stopped = append(stopped, work)
+			}
+		}
+	}
+	for _, ev := range changes {
+		_, processing := a.works[ev.Key]
+		switch {
+		case ev.Type == storage.PutEvent && !processing:
+			a.works[ev.Key] = struct{}{}
+			a.processor.Start(ev.Key)
+		case ev.Type == storage.DeleteEvent && processing:
+			delete(a.works, ev.Key)
+			a.processor.Stop(ev.Key)
+			stopped = append(stopped, ev.Key)
+		}
+	}
+	return stopped
+}
+
+func (a *assignments) setProcessor(p Processor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.processor = p
+}
+
+// list returns the works sorted
+func (a *assignments) list() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	works := make([]string, 0, len(a.works))
+	for work := range a.works {
+		works = append(works, work)
+	}
+	sort.Strings(works)
+	return works
+}
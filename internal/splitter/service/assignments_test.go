@@ -0,0 +1,146 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carisa/internal/cluster"
+	"github.com/carisa/internal/splitter/mock"
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type processorFake struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *processorFake) Start(work string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, "start "+work)
+}
+
+func (p *processorFake) Stop(work string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, "stop "+work)
+}
+
+func (p *processorFake) get() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func TestAssignments_Sync(t *testing.T) {
+	log, _ := logging.NewZapWrapDev()
+	a := newAssignments(log, nil, time.Second, func(string) {})
+	p := &processorFake{}
+	a.setProcessor(p)
+
+	a.sync([]storage.Event{{Type: storage.PutEvent, Key: "space1"}, {Type: storage.PutEvent, Key: "space2"}}, true)
+	stopped := a.sync([]storage.Event{{Type: storage.PutEvent, Key: "space2"}, {Type: storage.DeleteEvent, Key: "space1"}}, false)
+	assert.Equal(t, []string{"space1"}, stopped, "Stopped")
+	assert.Empty(t, a.sync([]storage.Event{{Type: storage.DeleteEvent, Key: "space3"}}, false), "Not processed")
+	assert.Equal(t, []string{"space2"}, a.list(), "Works")
+
+	stopped = a.sync([]storage.Event{{Type: storage.PutEvent, Key: "space3"}}, true)
+	assert.Equal(t, []string{"space2"}, stopped, "Stopped by the full works")
+	assert.Equal(t, []string{"space3"}, a.list(), "Full works")
+	assert.Equal(t, []string{"start space1", "start space2", "stop space1", "stop space2", "start space3"}, p.get())
+}
+
+func TestAssignments_Watch(t *testing.T) {
+	mng := mock.NewStorageFake(t)
+	defer mng.Close()
+	store := mng.Store()
+	log, _ := logging.NewZapWrapDev()
+	var mu sync.Mutex
+	var released []string
+	a := newAssignments(log, storage.NewWatcher(store), time.Second, func(work string) {
+		mu.Lock()
+		defer mu.Unlock()
+		released = append(released, work)
+	})
+	p := &processorFake{}
+	a.setProcessor(p)
+	prefix := cluster.AssignKey("c0ob7bbu5ln0vjkdm7l0", "")
+	if !put(t, store, prefix+"space1") {
+		return
+	}
+
+	a.start(prefix)
+	if !put(t, store, prefix+"space2") {
+		return
+	}
+	assert.Eventually(t, func() bool { return len(a.list()) == 2 }, 2*time.Second, 10*time.Millisecond, "Watched")
+
+	txn := storage.NewTxn(store)
+	txn.Find(prefix + "space1")
+	txn.DoFound(store.Remove(prefix + "space1"))
+	if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool { return len(a.list()) == 1 }, 2*time.Second, 10*time.Millisecond, "Removed")
+
+	a.stop()
+	assert.Empty(t, a.list(), "Stopped")
+	assert.Equal(t, []string{"start space1", "start space2", "stop space1", "stop space2"}, sorted(p.get()))
+	mu.Lock()
+	assert.Equal(t, []string{"space1"}, released, "Released only the works removed")
+	mu.Unlock()
+}
+
+func TestAssignments_WatchWithError(t *testing.T) {
+	log, _ := logging.NewZapWrapDev()
+	a := newAssignments(log, storage.NewWatcher(&storage.ErrMockCRUD{}), 10*time.Millisecond, func(string) {})
+
+	a.start("prefix")
+	time.Sleep(50 * time.Millisecond)
+	a.stop()
+	assert.Empty(t, a.list(), "The watch is retried until the stop")
+}
+
+func put(t *testing.T, store storage.CRUD, key string) bool {
+	txn := storage.NewTxn(store)
+	txn.Find(key)
+	txn.DoNotFound(store.PutRaw(key, ""))
+	_, err := txn.Commit(context.TODO())
+	return assert.NoError(t, err)
+}
+
+// sorted sorts the calls keeping the starts before the stops
+func sorted(calls []string) []string {
+	var starts, stops []string
+	for _, c := range calls {
+		if c[:5] == "start" {
+			starts = append(starts, c)
+		} else {
+			stops = append(stops, c)
+		}
+	}
+	sort.Strings(starts)
+	sort.Strings(stops)
+	return append(starts, stops...)
+}
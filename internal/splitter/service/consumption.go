@@ -17,7 +17,6 @@
 package service
 
 import (
-	rx "runtime"
 	"time"
 
 	"github.com/carisa/pkg/runtime"
@@ -25,18 +24,20 @@ import (
 
 // consumption updates the actual consumption each wutime in seconds
 type consumption struct {
-	actual time.Time
-	wutime time.Duration
+	actual   time.Time
+	wutime   time.Duration
+	provider runtime.Provider
 
 	pmeasure int
 	cpu      uint8
-	mem      int
+	mem      int // Basis points of the memory limit
 }
 
-func newConsumption(wutime time.Duration) consumption {
+func newConsumption(wutime time.Duration, provider runtime.Provider) consumption {
 	return consumption{
-		actual: time.Now(),
-		wutime: wutime,
+		actual:   time.Now(),
+		wutime:   wutime,
+		provider: provider,
 	}
 }
 
@@ -49,17 +50,15 @@ func (c *consumption) wake() bool {
 	return false
 }
 
-// renew updates the cpu (avarage) and memory measure
+// renew updates the cpu (avarage) and memory measure relative to the limits of the splitter.
+// See runtime.Provider
 func (c *consumption) renew() error {
-	var m rx.MemStats
-	rx.ReadMemStats(&m)
-	c.mem = int(m.Alloc / 1024 / 1024) // MB
-
-	cpu, err := runtime.CPU()
+	u, err := c.provider.Usage()
 	if err != nil {
 		return err
 	}
-	c.cpu = (c.cpu + uint8(cpu)) / 2 // Average
+	c.mem = int(u.Memory * 100)
+	c.cpu = (c.cpu + uint8(u.CPU)) / 2 // Average
 	return nil
 }
 
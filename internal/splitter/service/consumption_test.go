@@ -17,16 +17,22 @@
 package service
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/carisa/pkg/runtime"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConsumption_Renew(t *testing.T) {
-	c := newConsumption(1 * time.Second)
-	_ = c.renew()
-	assert.GreaterOrEqual(t, c.measure(), 1000000)
+	c := newConsumption(1*time.Second, usageFake{u: runtime.Usage{CPU: 50, Memory: 12.5}})
+	if assert.NoError(t, c.renew()) {
+		assert.Equal(t, 2001250, c.measure(), "25% CPU (average) and 12.5% memory")
+	}
+
+	c.provider = usageFake{err: errors.New("cgroup")}
+	assert.Error(t, c.renew())
 }
 
 func TestConsumption_Meassure(t *testing.T) {
@@ -62,7 +68,7 @@ func TestConsumption_Meassure(t *testing.T) {
 		},
 	}
 
-	c := newConsumption(1 * time.Second)
+	c := newConsumption(1*time.Second, usageFake{})
 
 	for _, tt := range tests {
 		c.cpu = tt.cpu
@@ -71,19 +77,28 @@ func TestConsumption_Meassure(t *testing.T) {
 }
 
 func TestConsumption_SaveMeasure(t *testing.T) {
-	c := newConsumption(1 * time.Second)
+	c := newConsumption(1*time.Second, usageFake{})
 	_ = c.renew()
 	c.saveMeasure()
 	assert.Equal(t, c.pmeasure, c.measure())
 }
 
 func TestConsumption_Wake(t *testing.T) {
-	c := newConsumption(1)
+	c := newConsumption(1, usageFake{})
 	time.Sleep(1 * time.Second)
 	assert.True(t, c.wake())
 }
 
 func TestConsumption_No_Wake(t *testing.T) {
-	c := newConsumption(5)
+	c := newConsumption(5, usageFake{})
 	assert.False(t, c.wake())
 }
+
+type usageFake struct {
+	u   runtime.Usage
+	err error
+}
+
+func (u usageFake) Usage() (runtime.Usage, error) {
+	return u.u, u.err
+}
@@ -17,138 +17,321 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/internal/splitter/runtime"
 	"github.com/carisa/pkg/logging"
+	pkgr "github.com/carisa/pkg/runtime"
 	"github.com/carisa/pkg/storage"
 	"github.com/carisa/pkg/strings"
 )
 
-const loc = "splitter.controller"
+const (
+	loc = "splitter.controller"
+	// assignBatch is the number of assignments read by request
+	assignBatch = 50
+	// retryBase is the first backoff between the retries of the calls to the store
+	retryBase = time.Second
+	// consumptionValue is the value of the consumption keys. The measure is read from the key,
+	// see cluster.ConsumptionKey, so the value is a fixed placeholder
+	consumptionValue = "1024"
+)
 
 // Controller implements the functionality when the splitter service starts, stops, etc.
-// Each splitter must record so node information as a timestamp indicating whether
-// the splitter is alive or dead.
-// If the server is dead the timestamp will not be updated and therefore the platform controller
-// will know that the particular splitter is dead. The splitter controller manages
-// this timestamp through ticks.
+// Each splitter keeps alive a lease of the store and its keys are attached to the lease,
+// so if the splitter is dead the lease expires and its keys are removed by the store.
+// The splitter also records a timestamp of its last heartbeat through ticks.
+// The ticks are sorted by time, so the splitters that miss heartbeats are found and removed by the
+// cluster.Reaper before the lease expires.
+// The jobs that run on a single splitter of the cluster campaign through the elections of the splitter,
+// whose leaderships live with the splitter.
+// The splitter watches the works assigned to it, see cluster.Assigner, and releases them when it stops.
+// The calls to the store are retried with backoff and the splitter gives up after runtime.Config.MaxFailures
+// consecutive failures, see Failed. The state of the splitter is exposed through Heartbeat.
 type Controller struct {
 	cnt   *runtime.Container
 	store storage.CRUD
 
-	srv  server
-	tick ticks
-	cons consumption
+	srv     server
+	tick    ticks
+	cons    consumption
+	hb      *heartbeat
+	lease   storage.LeaseID
+	started time.Time
+
+	elections storage.Elections
+	assigns   *assignments
+	assigner  cluster.Assigner
 
-	notifyStop chan struct{}
+	retryBase time.Duration
+	failed    chan error
+	done      chan struct{}   // done is closed by Stop to terminate renewHeartbeat
+	renewing  *sync.WaitGroup // renewing waits for renewHeartbeat
+	stopOnce  *sync.Once
 }
 
-// NewController builds a Controller
-func NewController(cnt *runtime.Container, data storage.CRUD) Controller {
+// NewController builds a Controller. The jobs of the splitter campaign through the elections,
+// see storage.NewElections
+func NewController(cnt *runtime.Container, data storage.CRUD, elections storage.Elections) Controller {
+	srv := newServer()
+	assigner := cluster.NewAssigner(cnt.Cluster(), data, cnt.StoreWithTimeout, assignBatch)
+	retry := cnt.RenewHeartbeatInSecs * time.Second
 	return Controller{
-		cnt:        cnt,
-		store:      data,
-		tick:       newTicks(),
-		cons:       newConsumption(cnt.RenewConsumptionInSecs),
-		srv:        newServer(),
-		notifyStop: make(chan struct{}),
+		cnt:       cnt,
+		store:     data,
+		tick:      newTicks(),
+		cons:      newConsumption(cnt.RenewConsumptionInSecs, pkgr.NewProvider()),
+		srv:       srv,
+		hb:        &heartbeat{},
+		elections: elections,
+		assigns:   newAssignments(cnt.Log, storage.NewWatcher(data), retry, acquire(cnt, assigner, srv)),
+		assigner:  assigner,
+		retryBase: retryBase,
+		failed:    make(chan error, 1),
+		done:      make(chan struct{}),
+		renewing:  &sync.WaitGroup{},
+		stopOnce:  &sync.Once{},
 	}
 }
 
-// Start starts the splitter server and registers the ticks and server information
-func (c *Controller) Start() {
+// Start starts the splitter server and registers the ticks and server information.
+// The registration is retried until runtime.Config.MaxFailures consecutive failures, then returns the error
+func (c *Controller) Start() error {
 	splitterID := c.srv.id.String()
 
 	c.cnt.Log.Info2(
-		loc,
 		"starting splitter",
+		loc,
 		logging.String("splitter", splitterID),
 		logging.String("ticks", c.tick.tstring()))
 
-	txn := c.cnt.TxnF(c.store)
+	c.hb.starting(splitterID)
+	c.started = time.Now()
+	err := c.retry("starting splitter. error saving ticks", func() error {
+		inserted, err := c.register()
+		if err == nil && !inserted {
+			return errTicksExist
+		}
+		return err
+	})
+	if err != nil {
+		c.hb.stop()
+		return c.cnt.Log.ErrWrap1(err, "starting splitter. giving up", loc, logging.String("ticks key", c.keyTick()))
+	}
 
-	key := c.keyTick()
-	txn.Find(key)
-	txn.DoNotFound(c.store.PutRaw(key, splitterID))
-	ctx, cancel := c.cnt.StoreWithTimeout()
-	inserted, err := txn.Commit(ctx)
-	cancel()
+	c.hb.start(splitterID, time.Now())
+	c.renewing.Add(1)
+	go c.renewHeartbeat()
+	c.assigns.start(cluster.AssignKey(splitterID, ""))
+	return nil
+}
 
-	if err != nil {
-		c.cnt.Log.Panic1(
-			strings.Concat("starting splitter. error saving ticks. ", err.Error()),
+// retry calls fn until it succeeds waiting a backoff between calls. Returns the last error
+// after runtime.Config.MaxFailures consecutive failures. The ticks key that already exists and
+// the lease not found are not retried because they do not change
+func (c *Controller) retry(msg string, fn func() error) error {
+	for failures := 1; ; failures++ {
+		err := fn()
+		if err == nil || err == errTicksExist || err == storage.ErrLeaseNotFound {
+			return err
+		}
+		c.hb.failed(err)
+		if failures >= c.cnt.MaxFailures {
+			return err
+		}
+		c.cnt.Log.Warn2(
+			strings.Concat(msg, ". ", err.Error()),
 			loc,
-			logging.String("splitter", c.srv.id.String()))
+			logging.String("splitter", c.srv.id.String()),
+			logging.String("failures", strconv.Itoa(failures)))
+		time.Sleep(backoff(c.retryBase, failures, c.cnt.RenewHeartbeatInSecs*time.Second))
 	}
-	if !inserted {
-		c.cnt.Log.Panic1(
-			"starting splitter. the ticks key already exists",
-			loc,
-			logging.String("ticks key", key))
+}
+
+// register grants the lease of the splitter and puts the ticks, the server information
+// and the last consumption attached to the lease. If the ticks key already exists returns false
+func (c *Controller) register() (bool, error) {
+	splitterID := c.srv.id.String()
+
+	ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+	defer cancel()
+	lease, err := c.store.Grant(ctx, c.cnt.LeaseTTLInSecs*time.Second)
+	if err != nil {
+		return false, err
 	}
 
-	go c.renewHeartbeat()
+	txn := c.cnt.TxnF(c.store)
+	key := c.keyTick()
+	txn.Find(key)
+	txn.DoNotFound(c.store.PutRawLease(key, cluster.FormatLease(lease), lease))
+	txn.DoNotFound(c.store.PutRawLease(cluster.InfoKey(splitterID), cluster.FormatStarted(c.started), lease))
+	if c.cons.pmeasure != 0 {
+		txn.DoNotFound(c.store.PutRawLease(c.keyConsumption(c.cons.pmeasure), consumptionValue, lease))
+	}
+	inserted, err := txn.Commit(ctx)
+	if err != nil || !inserted {
+		_ = c.store.Revoke(ctx, lease)
+		return false, err
+	}
+	c.lease = lease
+	return true, nil
 }
 
-// renewHeartbeat renews the timestamp and the consumption (memory + cpu) of the splitter
-// each runtime.Config.renewHeartbeatInSecs seconds
+// renewHeartbeat keeps alive the lease and renews the timestamp and the consumption (memory + cpu)
+// of the splitter each runtime.Config.renewHeartbeatInSecs seconds.
+// If the renewal fails it is retried with backoff. After runtime.Config.MaxFailures consecutive failures
+// the splitter gives up and the error is sent to Failed
 func (c *Controller) renewHeartbeat() {
+	defer c.renewing.Done()
 	txn := c.cnt.TxnF(c.store)
+	period := c.cnt.RenewHeartbeatInSecs * time.Second
 
 	for {
+		wait := period
+		if failures := c.hb.get().Failures; failures > 0 {
+			wait = backoff(c.retryBase, failures, period)
+		}
 		select {
-		case <-c.notifyStop: // The stop service requests to terminate
-			close(c.notifyStop)
+		case <-c.done: // The stop service requests to terminate
 			return
-		case <-time.After(c.cnt.RenewHeartbeatInSecs * time.Second):
-			c.updateTimestamp(txn)
-			c.updateConsumption(txn)
-			txn.Clear()
+		case <-time.After(wait):
+			c.renew(txn)
+			if hb := c.hb.get(); hb.Failures >= c.cnt.MaxFailures {
+				c.giveUp(hb)
+				return
+			}
 		}
 	}
 }
 
-// updateTimestamp updates timestamp of the splitter into db
-func (c *Controller) updateTimestamp(txn storage.Txn) {
+// renew keeps alive the lease and updates the timestamp and the consumption of the splitter.
+// The heartbeat is renewed only if all of them are saved, so the failures of any of them are consecutive
+func (c *Controller) renew(txn storage.Txn) {
+	defer txn.Clear()
+	if !c.keepAlive() || !c.updateTimestamp(txn) {
+		return
+	}
+	txn.Clear()
+	if c.updateConsumption(txn) {
+		c.hb.renewed(time.Now())
+	}
+}
+
+// giveUp sends the last error of the heartbeat to Failed
+func (c *Controller) giveUp(hb Heartbeat) {
+	err := c.cnt.Log.ErrWrap1(
+		errors.New(hb.Error),
+		"renewHeartbeat splitter. giving up",
+		loc,
+		logging.String("failures", strconv.Itoa(hb.Failures)))
+	select {
+	case c.failed <- err:
+	default:
+	}
+}
+
+// keepAlive renews the lease of the splitter. If the lease expired, for example because the store
+// was not reachable during its ttl, the keys of the splitter were removed, so the splitter is registered again.
+// Returns false if the splitter is not registered
+func (c *Controller) keepAlive() bool {
+	ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+	err := c.store.KeepAlive(ctx, c.lease)
+	cancel()
+	if err == nil {
+		return true
+	}
+	if err != storage.ErrLeaseNotFound {
+		c.hb.failed(err)
+		_ = c.cnt.Log.ErrWrap1(err, "renewHeartbeat splitter. error keeping alive the lease", loc, lease(c.lease))
+		return false
+	}
+
+	c.cnt.Log.Warn1("renewHeartbeat splitter. the lease expired, registering again", loc, lease(c.lease))
+	c.reregister()
+	return false
+}
+
+// reregister registers the splitter again with a new lease and a new tick. Returns false if it fails
+func (c *Controller) reregister() bool {
+	c.tick.renew()
+	inserted, err := c.register()
+	if err == nil && !inserted {
+		err = errTicksExist
+	}
+	if err != nil {
+		c.tick.undo()
+		c.hb.failed(err)
+		_ = c.cnt.Log.ErrWrap1(err, "renewHeartbeat splitter. error registering the splitter", loc, lease(c.lease))
+		return false
+	}
+	c.hb.renewed(time.Now())
+	return true
+}
+
+// updateTimestamp updates timestamp of the splitter into db. If the tick or the information of the splitter
+// are not found, the reaper removed the splitter because it was late, so the splitter revokes its lease
+// and it registers again. Its works were released by the reaper. Returns false if the splitter is not registered
+func (c *Controller) updateTimestamp(txn storage.Txn) bool {
 	splitterID := c.srv.id.String()
 	key := c.keyTick()
 	c.tick.renew()
 	newKey := c.keyTick()
 
 	c.cnt.Log.Debug3(
-		loc,
 		"updating heartbeat of the splitter",
+		loc,
 		logging.String("splitter", splitterID),
 		logging.String("actual tick", key),
 		logging.String("new tick", newKey))
 
-	txn.Find(key)
-	txn.DoFound(c.store.Remove(key))
-	put := c.store.PutRaw(newKey, splitterID)
-	txn.DoFound(put)
-	txn.DoNotFound(put)
-	ctx, cancel := c.cnt.StoreWithTimeout()
-	_, err := txn.Commit(ctx)
+	txn.Match(key, cluster.FormatLease(c.lease))
+	txn.Match(cluster.InfoKey(splitterID), cluster.FormatStarted(c.started))
+	if newKey != key { // The tick is renewed into the same second
+		txn.DoFound(c.store.Remove(key))
+	}
+	txn.DoFound(c.store.PutRawLease(newKey, cluster.FormatLease(c.lease), c.lease))
+	ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+	renewed, err := txn.Commit(ctx)
 	cancel()
 	if err != nil {
 		c.tick.undo()
+		c.hb.failed(err)
 		_ = c.cnt.Log.ErrWrap1(
 			err,
-			loc,
 			"renewHeartbeat splitter. error updating ticks",
+			loc,
 			logging.String("ticks", key))
+		return false
+	}
+	if !renewed {
+		c.tick.undo()
+		c.cnt.Log.Warn1("renewHeartbeat splitter. the splitter was removed, registering again", loc, lease(c.lease))
+		ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+		if err := c.store.Revoke(ctx, c.lease); err != nil && err != storage.ErrLeaseNotFound {
+			_ = c.cnt.Log.ErrWrap1(err, "renewHeartbeat splitter. error revoking the lease", loc, lease(c.lease))
+		}
+		cancel()
+		return c.reregister()
 	}
+	return true
 }
 
-// updateTimestamp updates the consumption (cpu + memory) of the splitter into db
-func (c *Controller) updateConsumption(txn storage.Txn) {
+// updateConsumption updates the consumption (cpu + memory) of the splitter into db
+// each runtime.Config.RenewConsumptionInSecs seconds. Returns false if it fails
+func (c *Controller) updateConsumption(txn storage.Txn) bool {
 	if err := c.cons.renew(); err != nil {
-		c.cnt.Log.Panic1(
-			strings.Concat("updating consumption. error getting CPU. ", err.Error()),
+		c.hb.failed(err)
+		_ = c.cnt.Log.ErrWrap1(
+			err,
+			"updating consumption. error getting CPU",
 			loc,
 			logging.String("splitter", c.srv.id.String()))
+		return false
 	}
 
 	if c.cons.wake() {
@@ -157,67 +340,189 @@ func (c *Controller) updateConsumption(txn storage.Txn) {
 		newKey := c.keyConsumption(c.cons.measure())
 
 		c.cnt.Log.Debug3(
-			loc,
 			"updating consumption of the splitter",
+			loc,
 			logging.String("splitter", splitterID),
 			logging.String("actual consumption", key),
 			logging.String("new consumption", newKey))
 
 		txn.Find(key)
 		txn.DoFound(c.store.Remove(key))
-		put := c.store.PutRaw(newKey, "1024")
+		put := c.store.PutRawLease(newKey, consumptionValue, c.lease)
 		txn.DoFound(put)
 		txn.DoNotFound(put)
-		ctx, cancel := c.cnt.StoreWithTimeout()
+		ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
 		_, err := txn.Commit(ctx)
 		cancel()
-		if err == nil {
-			c.cons.saveMeasure()
-		} else {
+		if err != nil {
+			c.hb.failed(err)
 			_ = c.cnt.Log.ErrWrap1(
 				err,
-				loc,
 				"renewHeartbeat splitter. error updating consumption",
+				loc,
 				logging.String("consumption", key))
+			return false
 		}
+		c.cons.saveMeasure()
 	}
+	return true
 }
 
-// Start starts the splitter server and registers the ticks and server information.
-// if it was removed return true
-func (c *Controller) Stop(wait bool) bool {
-	if wait {
-		// it requests stop the renewHeartbeat
-		c.notifyStop <- struct{}{}
-	}
+// Stop drains the splitter and removes its keys revoking the lease. It always drains: it waits for
+// the renewHeartbeat and for the processors to stop the works before the keys are removed and the works
+// released, so there is no Stop(false). Removing the keys without draining would let other splitters
+// acquire the works while this splitter still processes them.
+// The revocation is retried until runtime.Config.MaxFailures consecutive failures.
+// Returns true if the keys were removed, if the lease expired or could not be revoked returns false.
+// Only the first call stops the splitter, the next calls return false
+func (c *Controller) Stop() bool {
+	removed := false
+	c.stopOnce.Do(func() { removed = c.stop() })
+	return removed
+}
 
-	key := c.keyTick()
-	txn := storage.NewTxn(c.store)
-	txn.Find(key)
-	txn.DoFound(c.store.Remove(key))
-	ctx, cancel := c.cnt.StoreWithTimeout()
-	removed, err := txn.Commit(ctx)
-	cancel()
-	if err != nil {
-		c.cnt.Log.Panic1(
-			strings.Concat("stopping splitter. error removing ticks. ", err.Error()),
+func (c *Controller) stop() bool {
+	c.hb.stopping()
+	// it requests stop the renewHeartbeat, if it is not running it does not wait
+	close(c.done)
+	c.renewing.Wait()
+	c.assigns.stop()
+
+	// The jobs of the splitter lose their leaderships
+	if err := c.elections.Close(); err != nil {
+		_ = c.cnt.Log.ErrWrap1(
+			err,
+			"stopping splitter. error closing the elections",
 			loc,
 			logging.String("splitter", c.srv.id.String()))
 	}
-	if !removed {
+
+	// The revocation of the lease removes the keys of the splitter
+	defer c.hb.stop()
+	err := c.retry("stopping splitter. error removing ticks", func() error {
+		ctx, cancel := c.cnt.StoreWithTimeout(context.Background())
+		defer cancel()
+		return c.store.Revoke(ctx, c.lease)
+	})
+	if err == storage.ErrLeaseNotFound {
 		c.cnt.Log.Warn1(
-			"stopping splitter. the ticks key is not found",
+			"stopping splitter. the lease is not found",
 			loc,
-			logging.String("ticks key", key))
+			lease(c.lease))
+		c.release()
+		return false
 	}
+	if err != nil {
+		// The keys of the splitter are removed when the lease expires
+		_ = c.cnt.Log.ErrWrap1(err, "stopping splitter. giving up removing ticks", loc, lease(c.lease))
+		return false
+	}
+	c.release()
+	return true
+}
 
-	return removed
+// release releases the works of the splitter, so they are assigned to other splitters.
+// The splitter is already removed, so it does not receive its works again
+func (c *Controller) release() {
+	released, err := c.assigner.Release(context.Background(), c.srv.id.String())
+	if err != nil {
+		_ = c.cnt.Log.ErrWrap1(err, "stopping splitter. error releasing the works", loc, logging.String("splitter", c.srv.id.String()))
+		return
+	}
+	if released > 0 {
+		c.cnt.Log.Info2(
+			"stopping splitter. released the works",
+			loc,
+			logging.String("splitter", c.srv.id.String()),
+			logging.String("works", strconv.Itoa(released)))
+	}
+}
+
+// acquire completes the handoff of the works stopped by the splitter, so other splitter processes them.
+// See cluster.Assigner.Handoff
+func acquire(cnt *runtime.Container, assigner cluster.Assigner, srv server) func(work string) {
+	return func(work string) {
+		acquired, err := assigner.Acquire(context.Background(), srv.id.String(), work)
+		if err != nil {
+			_ = cnt.Log.ErrWrap1(err, "acquiring the work", loc, logging.String("work", work))
+			return
+		}
+		if acquired {
+			cnt.Log.Info1("the work is handed off", loc, logging.String("work", work))
+		}
+	}
+}
+
+// Process sets the processor of the works assigned to the splitter. It must be called before Start.
+// By default the works are only logged
+func (c *Controller) Process(p Processor) {
+	c.assigns.setProcessor(p)
+}
+
+// Measure sets the provider of the consumption of the splitter. It must be called before Start.
+// By default the consumption is measured into the cgroup of the splitter or the host, see runtime.NewProvider
+func (c *Controller) Measure(p pkgr.Provider) {
+	c.cons.provider = p
+}
+
+// Works returns the works that the splitter processes
+func (c *Controller) Works() []string {
+	return c.assigns.list()
+}
+
+// ID returns the identifier of the splitter
+func (c *Controller) ID() string {
+	return c.srv.id.String()
 }
 
+// Elections returns the elections of the splitter. The jobs campaign with the splitter ID as value
+func (c *Controller) Elections() storage.Elections {
+	return c.elections
+}
+
+// Heartbeat returns the state of the heartbeat of the splitter
+func (c *Controller) Heartbeat() Heartbeat {
+	return c.hb.get()
+}
+
+// Failed receives the error when the splitter gives up renewing its heartbeat.
+// See runtime.Config.MaxFailures
+func (c *Controller) Failed() <-chan error {
+	return c.failed
+}
+
+// Check checks that the splitter is started and its heartbeat is renewed.
+// The heartbeat can miss a renewal, it is late when it is not renewed into two periods.
+// See runtime.Config.RenewHeartbeatInSecs
+func (c *Controller) Check(_ context.Context) error {
+	hb := c.hb.get()
+	if hb.State == Stopping {
+		return errStopping
+	}
+	if !hb.Started {
+		return errNotStarted
+	}
+	if time.Since(hb.Renewed) > 2*c.cnt.RenewHeartbeatInSecs*time.Second {
+		return errLate
+	}
+	return nil
+}
+
+var (
+	errNotStarted = errors.New("the splitter is not started")
+	errStopping   = errors.New("the splitter is stopping")
+	errLate       = errors.New("the heartbeat is not renewed")
+	errTicksExist = errors.New("the ticks key already exists")
+)
+
 func (c *Controller) keyTick() string {
-	return strings.Concat(c.tick.tstring(), c.srv.id.String())
+	return cluster.TickKey(c.tick.tstring(), c.srv.id.String())
 }
 
 func (c *Controller) keyConsumption(key int) string {
-	return strings.Concat(strconv.Itoa(key), c.srv.id.String())
+	return cluster.ConsumptionKey(key, c.srv.id.String())
+}
+
+func lease(id storage.LeaseID) logging.Field {
+	return logging.String("lease", strconv.FormatInt(int64(id), 10))
 }
@@ -18,11 +18,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
-	"github.com/carisa/pkg/strings"
-
+	"github.com/carisa/internal/cluster"
 	"github.com/carisa/internal/splitter/mock"
 	"github.com/carisa/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -34,9 +34,13 @@ func TestController_Start(t *testing.T) {
 
 	ctrl.Start()
 
-	_, srvID, err := mng.Store().GetRaw(context.TODO(), ctrl.keyTick())
+	_, lease, err := mng.Store().GetRaw(context.TODO(), ctrl.keyTick())
+	if assert.NoError(t, err) {
+		assert.Equal(t, cluster.FormatLease(ctrl.lease), lease)
+	}
+	found, _, err := mng.Store().GetRaw(context.TODO(), cluster.InfoKey(ctrl.srv.id.String()))
 	if assert.NoError(t, err) {
-		assert.Equal(t, ctrl.srv.id.String(), srvID)
+		assert.True(t, found, "Start time")
 	}
 }
 
@@ -45,7 +49,10 @@ func TestController_StartWithError(t *testing.T) {
 	defer mng.Close()
 	txnMock.Activate("Commit")
 
-	assert.Panics(t, func() { ctrl.Start() })
+	assert.Error(t, ctrl.Start(), "Gives up")
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, Stopped, hb.State, "State")
+	assert.Equal(t, ctrl.cnt.MaxFailures, hb.Failures, "Retried")
 }
 
 func TestController_RenewHeartbeat(t *testing.T) {
@@ -57,15 +64,46 @@ func TestController_RenewHeartbeat(t *testing.T) {
 	ctrl.Start()
 
 	time.Sleep(2 * time.Second)
-	ctrl.notifyStop <- struct{}{}
+	stopRenewal(&ctrl)
 
 	assert.Equal(t, pticks.timeStamp, ctrl.tick.previousTimeStamp, "Timestamp")
-	exists, err := mng.Store().Exists(context.TODO(), strings.Concat(pticks.tstring(), ctrl.srv.id.String()))
+	exists, err := mng.Store().Exists(context.TODO(), cluster.TickKey(pticks.tstring(), ctrl.srv.id.String()))
 	if assert.NoError(t, err) {
 		assert.False(t, exists, "Previous tick")
-		_, srvID, err := mng.Store().GetRaw(context.TODO(), ctrl.keyTick())
+		_, lease, err := mng.Store().GetRaw(context.TODO(), ctrl.keyTick())
 		if assert.NoError(t, err) {
-			assert.Equal(t, ctrl.srv.id.String(), srvID, "Actual tick")
+			assert.Equal(t, cluster.FormatLease(ctrl.lease), lease, "Actual tick")
+		}
+	}
+}
+
+func TestController_RenewHeartbeatReaped(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	if !assert.NoError(t, ctrl.Start(), "Starting") {
+		return
+	}
+	defer ctrl.Stop()
+
+	// The reaper removes the keys of the splitter that is late
+	old := ctrl.lease
+	txn := storage.NewTxn(store)
+	txn.Find(ctrl.keyTick())
+	txn.DoFound(store.Remove(ctrl.keyTick()))
+	txn.DoFound(store.Remove(cluster.InfoKey(ctrl.ID())))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err, "Reaping") {
+		return
+	}
+
+	ctrl.updateTimestamp(storage.NewTxn(store))
+	assert.NotEqual(t, old, ctrl.lease, "New lease")
+	assert.Equal(t, storage.ErrLeaseNotFound, store.KeepAlive(ctx, old), "The old lease is revoked")
+	for _, key := range []string{ctrl.keyTick(), cluster.InfoKey(ctrl.ID())} {
+		found, err := store.Exists(ctx, key)
+		if assert.NoError(t, err) {
+			assert.True(t, found, "Registered again. "+key)
 		}
 	}
 }
@@ -77,41 +115,339 @@ func TestController_RenewConsumption(t *testing.T) {
 
 	ctrl.Start()
 	time.Sleep(1 * time.Second)
-	ctrl.notifyStop <- struct{}{}
+	stopRenewal(&ctrl)
 
 	_, enteMem, err := mng.Store().GetRaw(context.TODO(), ctrl.keyConsumption(ctrl.cons.pmeasure))
 	if assert.NoError(t, err) {
-		assert.Equal(t, enteMem, "1024")
+		assert.Equal(t, consumptionValue, enteMem)
 	}
 }
+func TestController_MeasureWithError(t *testing.T) {
+	ctrl, txnMock, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.Measure(usageFake{err: errors.New("cgroup")})
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+
+	ctrl.updateConsumption(txnMock)
+
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, 1, hb.Failures, "Failures")
+	assert.Equal(t, Degraded, hb.State, "The splitter does not panic")
+}
+
+func TestController_RenewWithConsumptionError(t *testing.T) {
+	ctrl, txnMock, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.Measure(usageFake{err: errors.New("cgroup")})
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+
+	ctrl.renew(txnMock)
+	ctrl.renew(txnMock)
+
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, 2, hb.Failures, "The timestamp saved does not reset the failures of the consumption")
+	assert.Equal(t, Degraded, hb.State, "Degraded")
+}
+
+func TestController_UpdateConsumptionWithError(t *testing.T) {
+	ctrl, txnMock, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.Measure(usageFake{})
+	ctrl.cons.actual = time.Time{}
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+	txnMock.Activate("Commit")
+
+	ctrl.updateConsumption(txnMock)
+
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, 1, hb.Failures, "Failures")
+	assert.Equal(t, Degraded, hb.State, "The commit failure degrades the heartbeat")
+}
+
 func TestController_Stop(t *testing.T) {
 	ctrl, mng := newControllerFaked(t)
 	ctrl.cnt.RenewHeartbeatInSecs = 1
 	defer mng.Close()
 
 	ctrl.Start()
-	removed := ctrl.Stop(true)
+	removed := ctrl.Stop()
 
 	assert.True(t, removed)
+	found, err := mng.Store().Exists(context.TODO(), cluster.InfoKey(ctrl.srv.id.String()))
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Start time")
+	}
+}
+
+func TestController_Elections(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	ctx := context.TODO()
+
+	ctrl.Start()
+	election := ctrl.Elections().Election("job")
+	if _, err := election.Campaign(ctx, ctrl.ID()); !assert.NoError(t, err) {
+		return
+	}
+	leader, err := election.Leader(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, ctrl.ID(), leader, "Leader")
+	}
+
+	ctrl.Stop()
+	_, err = election.Leader(ctx)
+	assert.Equal(t, storage.ErrNoLeader, err, "The leadership is lost with the splitter")
+}
+
+func TestController_Works(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+	p := &processorFake{}
+	ctrl.Process(p)
+
+	ctrl.Start()
+	id := ctrl.ID()
+	txn := storage.NewTxn(store)
+	txn.Find(cluster.WorkKey("space1"))
+	txn.DoNotFound(store.PutRaw(cluster.WorkKey("space1"), id))
+	txn.DoNotFound(store.PutRaw(cluster.AssignKey(id, "space1"), ""))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool { return len(ctrl.Works()) == 1 }, 2*time.Second, 10*time.Millisecond, "Assigned")
+
+	ctrl.Stop()
+	assert.Equal(t, []string{"start space1", "stop space1"}, p.get(), "Processed")
+	_, owner, err := store.GetRaw(ctx, cluster.WorkKey("space1"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, cluster.Pending, owner, "Released")
+	}
+}
+
+func TestController_Handoff(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	store := mng.Store()
+	ctx := context.TODO()
+
+	ctrl.Start()
+	defer ctrl.Stop()
+	id, other := ctrl.ID(), cluster.Splitter{ID: "c0ob7bbu5ln0vjkdm7l0", Started: time.Now().UTC().Truncate(time.Second)}
+	txn := storage.NewTxn(store)
+	txn.Find(cluster.WorkKey("space1"))
+	txn.DoNotFound(store.PutRaw(cluster.WorkKey("space1"), id))
+	txn.DoNotFound(store.PutRaw(cluster.AssignKey(id, "space1"), ""))
+	txn.DoNotFound(store.PutRaw(cluster.InfoKey(other.ID), cluster.FormatStarted(other.Started)))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	assert.Eventually(t, func() bool { return len(ctrl.Works()) == 1 }, 2*time.Second, 10*time.Millisecond, "Assigned")
+
+	moved, err := ctrl.assigner.Handoff(ctx, "space1", id, other)
+	if !assert.NoError(t, err) || !assert.True(t, moved) {
+		return
+	}
+	assert.Eventually(t, func() bool {
+		_, owner, err := store.GetRaw(ctx, cluster.WorkKey("space1"))
+		return err == nil && owner == other.ID
+	}, 2*time.Second, 10*time.Millisecond, "Acquired by the other splitter when the splitter stops processing the work")
+	assert.Empty(t, ctrl.Works(), "Stopped")
 }
 
 func TestController_StopWithError(t *testing.T) {
+	ctrl, _, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.store.(*storage.ErrMockCRUD).Activate("Revoke")
+
+	assert.False(t, ctrl.Stop(), "The lease expires the keys")
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, Stopped, hb.State, "State")
+	assert.Equal(t, ctrl.cnt.MaxFailures, hb.Failures, "Retried")
+}
+
+func TestController_GiveUp(t *testing.T) {
+	ctrl, _, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.cnt.RenewHeartbeatInSecs = 1
+	ctrl.store.(*storage.ErrMockCRUD).Activate("KeepAlive")
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+
+	ctrl.renewing.Add(1)
+	go ctrl.renewHeartbeat()
+	select {
+	case err := <-ctrl.Failed():
+		assert.Error(t, err, "Gives up")
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "The splitter does not give up")
+	}
+	assert.Equal(t, Degraded, ctrl.Heartbeat().State, "State")
+	stopRenewal(&ctrl)
+}
+
+func TestController_StopTwice(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+
+	if !assert.NoError(t, ctrl.Start(), "Starting") {
+		return
+	}
+	assert.True(t, ctrl.Stop(), "Stopped")
+	assert.False(t, ctrl.Stop(), "Already stopped")
+}
+
+func TestController_StopAfterStartWithError(t *testing.T) {
 	ctrl, txnMock, mng := newControllerMock(t)
 	defer mng.Close()
 	txnMock.Activate("Commit")
 
-	assert.Panics(t, func() { ctrl.Stop(false) })
+	if !assert.Error(t, ctrl.Start(), "Gives up") {
+		return
+	}
+	stopped := make(chan bool)
+	go func() { stopped <- ctrl.Stop() }()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Stop waits for a renewal that is not running")
+	}
+}
+
+func TestController_StopWithoutLease(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+
+	ctrl.Start()
+	if !assert.NoError(t, mng.Store().Revoke(context.TODO(), ctrl.lease)) {
+		return
+	}
+
+	assert.False(t, ctrl.Stop(), "The lease expired")
+}
+
+func TestController_LeaseExpired(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	store := mng.Store()
+	id := ctrl.srv.id.String()
+
+	ctrl.Start()
+	expired := ctrl.lease
+	// The store removes the keys of the lease when it expires
+	if !assert.NoError(t, store.Revoke(context.TODO(), expired)) {
+		return
+	}
+	for _, key := range []string{ctrl.keyTick(), cluster.InfoKey(id)} {
+		found, err := store.Exists(context.TODO(), key)
+		if assert.NoError(t, err) {
+			assert.False(t, found, "Removed with the lease")
+		}
+	}
+
+	assert.False(t, ctrl.keepAlive(), "Registered again")
+	assert.NotEqual(t, expired, ctrl.lease, "New lease")
+	for _, key := range []string{ctrl.keyTick(), cluster.InfoKey(id)} {
+		found, err := store.Exists(context.TODO(), key)
+		if assert.NoError(t, err) {
+			assert.True(t, found, "Registered again")
+		}
+	}
+	assert.True(t, ctrl.keepAlive(), "Lease renewed")
+}
+
+func TestController_KeepAliveWithError(t *testing.T) {
+	ctrl, _, mng := newControllerMock(t)
+	defer mng.Close()
+	ctrl.store.(*storage.ErrMockCRUD).Activate("KeepAlive")
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+
+	assert.False(t, ctrl.keepAlive())
+	assert.Equal(t, 1, ctrl.Heartbeat().Failures, "Failures")
+}
+
+func TestController_Heartbeat(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+	ctrl.cnt.RenewHeartbeatInSecs = 1
+
+	assert.Equal(t, errNotStarted, ctrl.Check(context.TODO()), "Not started")
+	assert.Equal(t, Stopped, ctrl.Heartbeat().State, "Not started")
+
+	if !assert.NoError(t, ctrl.Start()) {
+		return
+	}
+	started := ctrl.Heartbeat()
+	assert.True(t, started.Started, "Started")
+	assert.Equal(t, Healthy, started.State, "Started")
+	assert.Equal(t, ctrl.srv.id.String(), started.Splitter, "Splitter")
+	assert.NoError(t, ctrl.Check(context.TODO()), "Started")
+
+	time.Sleep(1500 * time.Millisecond)
+	renewed := ctrl.Heartbeat()
+	assert.True(t, renewed.Renewed.After(started.Renewed), "Renewed")
+	assert.Zero(t, renewed.Failures, "Failures")
+
+	ctrl.hb.stopping()
+	assert.Equal(t, errStopping, ctrl.Check(context.TODO()), "Stopping")
+
+	ctrl.Stop()
+	assert.False(t, ctrl.Heartbeat().Started, "Stopped")
+	assert.Equal(t, Stopped, ctrl.Heartbeat().State, "Stopped")
+	assert.Equal(t, errNotStarted, ctrl.Check(context.TODO()), "Stopped")
+}
+
+func TestController_HeartbeatLate(t *testing.T) {
+	ctrl, mng := newControllerFaked(t)
+	defer mng.Close()
+
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now().Add(-2*ctrl.cnt.RenewHeartbeatInSecs*time.Second-time.Second))
+
+	assert.Equal(t, errLate, ctrl.Check(context.TODO()))
+}
+
+func TestController_HeartbeatWithError(t *testing.T) {
+	ctrl, txnMock, mng := newControllerMock(t)
+	defer mng.Close()
+	txnMock.Activate("Commit")
+	ctrl.hb.start(ctrl.srv.id.String(), time.Now())
+
+	ctrl.updateTimestamp(txnMock)
+	ctrl.updateTimestamp(txnMock)
+
+	hb := ctrl.Heartbeat()
+	assert.Equal(t, 2, hb.Failures, "Failures")
+	assert.NotEmpty(t, hb.Error, "Error")
+	assert.Equal(t, Degraded, hb.State, "Degraded")
+
+	txnMock.Clear()
+	ctrl.renew(txnMock)
+	hb = ctrl.Heartbeat()
+	assert.Zero(t, hb.Failures, "Renewed")
+	assert.Empty(t, hb.Error, "Renewed")
+	assert.Equal(t, Healthy, hb.State, "Renewed")
 }
 
 func newControllerFaked(t *testing.T) (Controller, storage.Integration) {
 	mng := mock.NewStorageFake(t)
 	cnt := mock.NewContainerFake()
 	cnt.RenewConsumptionInSecs = 1
-	return NewController(cnt, mng.Store()), mng
+	elections, err := storage.NewElections(mng.Store(), cnt.LeaseTTLInSecs*time.Second)
+	assert.NoError(t, err)
+	return NewController(cnt, mng.Store(), elections), mng
 }
 
 func newControllerMock(t *testing.T) (Controller, *storage.ErrMockTxn, storage.Integration) {
 	mng := mock.NewStorageFake(t)
 	cnt, txnMock := mock.NewContainerMock()
-	return NewController(cnt, &storage.ErrMockCRUD{}), txnMock, mng
+	cnt.MaxFailures = 2
+	ctrl := NewController(cnt, &storage.ErrMockCRUD{}, storage.NewLocalElections())
+	ctrl.retryBase = 10 * time.Millisecond
+	return ctrl, txnMock, mng
+}
+
+// stopRenewal terminates the renewal of the heartbeat without stopping the splitter
+func stopRenewal(ctrl *Controller) {
+	close(ctrl.done)
+	ctrl.renewing.Wait()
 }
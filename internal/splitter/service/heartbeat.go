@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the health state of the splitter
+type State string
+
+const (
+	// Stopped is the state before the splitter starts and after it stops
+	Stopped State = "stopped"
+	// Starting is the state while the splitter registers into the cluster
+	Starting State = "starting"
+	// Healthy is the state while the heartbeat is renewed
+	Healthy State = "healthy"
+	// Degraded is the state while the renewals fail. The splitter gives up after runtime.Config.MaxFailures
+	Degraded State = "degraded"
+	// Stopping is the state while the splitter stops its works and deregisters from the cluster
+	Stopping State = "stopping"
+)
+
+// Heartbeat is the state of the heartbeat of the splitter
+type Heartbeat struct {
+	Splitter string `json:"splitter"`
+	State    State  `json:"state"`
+	// Started is true since the splitter registers its ticks until it stops
+	Started bool `json:"started"`
+	// Renewed is the last time that the heartbeat was saved into the store
+	Renewed time.Time `json:"renewed"`
+	// Failures is the number of consecutive renewals that failed
+	Failures int `json:"failures"`
+	// Error is the error of the last renewal that failed
+	Error string `json:"error,omitempty"`
+}
+
+// heartbeat keeps the state of the heartbeat shared by the copies of the Controller
+type heartbeat struct {
+	mu    sync.RWMutex
+	state Heartbeat
+}
+
+func (h *heartbeat) get() Heartbeat {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.state.State) == 0 {
+		return Heartbeat{Splitter: h.state.Splitter, State: Stopped}
+	}
+	return h.state
+}
+
+func (h *heartbeat) starting(splitter string) {
+	h.mu.Lock()
+	h.state = Heartbeat{Splitter: splitter, State: Starting}
+	h.mu.Unlock()
+}
+
+func (h *heartbeat) start(splitter string, now time.Time) {
+	h.mu.Lock()
+	h.state = Heartbeat{Splitter: splitter, State: Healthy, Started: true, Renewed: now}
+	h.mu.Unlock()
+}
+
+func (h *heartbeat) renewed(now time.Time) {
+	h.mu.Lock()
+	h.state.Renewed = now
+	h.state.Failures = 0
+	h.state.Error = ""
+	if h.state.State == Degraded {
+		h.state.State = Healthy
+	}
+	h.mu.Unlock()
+}
+
+// failed counts the failure. Returns the number of consecutive failures
+func (h *heartbeat) failed(err error) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.state.Failures++
+	h.state.Error = err.Error()
+	if h.state.State == Healthy {
+		h.state.State = Degraded
+	}
+	return h.state.Failures
+}
+
+func (h *heartbeat) stopping() {
+	h.mu.Lock()
+	h.state.State = Stopping
+	h.state.Failures = 0
+	h.mu.Unlock()
+}
+
+func (h *heartbeat) stop() {
+	h.mu.Lock()
+	h.state.Started = false
+	h.state.State = Stopped
+	h.mu.Unlock()
+}
+
+// backoff returns the time to wait after the consecutive failures. It doubles from the base up to the max
+func backoff(base time.Duration, failures int, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat_State(t *testing.T) {
+	hb := &heartbeat{}
+	assert.Equal(t, Stopped, hb.get().State, "Initial")
+
+	hb.starting("splitter")
+	hb.failed(errors.New("store"))
+	assert.Equal(t, Starting, hb.get().State, "The failures do not degrade the start")
+
+	hb.start("splitter", time.Now())
+	assert.Equal(t, Healthy, hb.get().State, "Started")
+	assert.Equal(t, 1, hb.failed(errors.New("store")), "Failures")
+	assert.Equal(t, Degraded, hb.get().State, "Failed")
+	hb.renewed(time.Now())
+	assert.Equal(t, Healthy, hb.get().State, "Renewed")
+
+	hb.stopping()
+	hb.renewed(time.Now())
+	assert.Equal(t, Stopping, hb.get().State, "The renewals do not cancel the stop")
+	hb.stop()
+	assert.Equal(t, Stopped, hb.get().State, "Stopped")
+}
+
+func TestHeartbeat_Backoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(time.Second, 1, 15*time.Second), "First")
+	assert.Equal(t, 4*time.Second, backoff(time.Second, 3, 15*time.Second), "Doubled")
+	assert.Equal(t, 15*time.Second, backoff(time.Second, 10, 15*time.Second), "Max")
+}
@@ -17,10 +17,9 @@
 package service
 
 import (
-	"strconv"
 	"time"
 
-	"github.com/carisa/pkg/strings"
+	"github.com/carisa/internal/cluster"
 )
 
 // ticks defines a timestamp to let the controller know if the splitter service is dead
@@ -47,13 +46,7 @@ func (t *ticks) undo() {
 	t.timeStamp = t.previousTimeStamp
 }
 
-// tstring converts the timestamp to string
+// tstring converts the timestamp to string. See cluster.FormatTick
 func (t *ticks) tstring() string {
-	return strings.Concat(
-		strconv.Itoa(t.timeStamp.Year()),
-		strings.Lpad(strconv.Itoa(int(t.timeStamp.Month())), 2, "0"),
-		strings.Lpad(strconv.Itoa(t.timeStamp.Day()), 2, "0"),
-		strings.Lpad(strconv.Itoa(t.timeStamp.Hour()), 2, "0"),
-		strings.Lpad(strconv.Itoa(t.timeStamp.Minute()), 2, "0"),
-		strings.Lpad(strconv.Itoa(t.timeStamp.Second()), 2, "0"))
+	return cluster.FormatTick(t.timeStamp)
 }
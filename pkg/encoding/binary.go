@@ -0,0 +1,308 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ */
+
+package encoding
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+var (
+	binaryMarshaler   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshaler = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// binaryCodec encodes the values in a compact binary format.
+// The exported fields of the structs are written in order of declaration without names and
+// prefixed by the length of the struct, the integers are written as varints and the strings and slices
+// are prefixed by their length.
+// The types that implement encoding.BinaryMarshaler (e.g. time.Time) are encoded with it.
+// The fields added at the end of a struct are decoded as zero values from the values stored before and
+// the fields removed from the end are skipped, but moving or changing the type of the fields requires a migration
+type binaryCodec struct{}
+
+func (binaryCodec) Tag() byte {
+	return TagBinary
+}
+
+func (binaryCodec) Name() string {
+	return NameBinary
+}
+
+func (binaryCodec) Marshal(data interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := marshal(&b, reflect.Indirect(reflect.ValueOf(data))); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (binaryCodec) Unmarshal(value []byte, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("binary: the data must be a non nil pointer")
+	}
+	return unmarshal(bytes.NewReader(value), v.Elem())
+}
+
+// marshaler checks if the type is encoded with encoding.BinaryMarshaler and encoding.BinaryUnmarshaler
+func marshaler(t reflect.Type) bool {
+	return t.Implements(binaryMarshaler) && reflect.PtrTo(t).Implements(binaryUnmarshaler)
+}
+
+//nolint:gocyclo
+func marshal(b *bytes.Buffer, v reflect.Value) error {
+	if marshaler(v.Type()) {
+		value, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return err
+		}
+		writeBytes(b, value)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeVarint(b, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUvarint(b, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUvarint(b, math.Float64bits(v.Float()))
+	case reflect.String:
+		writeBytes(b, []byte(v.String()))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			writeBytes(b, v.Bytes())
+			return nil
+		}
+		writeUvarint(b, uint64(v.Len()))
+		return marshalElems(b, v)
+	case reflect.Array:
+		return marshalElems(b, v)
+	case reflect.Map:
+		writeUvarint(b, uint64(v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := marshal(b, iter.Key()); err != nil {
+				return err
+			}
+			if err := marshal(b, iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields bytes.Buffer
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" { // Unexported
+				continue
+			}
+			if err := marshal(&fields, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		writeBytes(b, fields.Bytes())
+	case reflect.Ptr:
+		if v.IsNil() {
+			b.WriteByte(0)
+			return nil
+		}
+		b.WriteByte(1)
+		return marshal(b, v.Elem())
+	default:
+		return fmt.Errorf("binary: type %s not supported", v.Type())
+	}
+	return nil
+}
+
+func marshalElems(b *bytes.Buffer, v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := marshal(b, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//nolint:gocyclo,gocognit
+func unmarshal(r *bytes.Reader, v reflect.Value) error {
+	if marshaler(v.Type()) {
+		value, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(value)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		v.SetBool(c == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		u, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(math.Float64frombits(u))
+	case reflect.String:
+		s, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		v.SetString(string(s))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			s, err := readBytes(r)
+			if err != nil {
+				return err
+			}
+			v.SetBytes(s)
+			return nil
+		}
+		l, err := readLen(r)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), l, l))
+		return unmarshalElems(r, v)
+	case reflect.Array:
+		return unmarshalElems(r, v)
+	case reflect.Map:
+		l, err := readLen(r)
+		if err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), l)
+		for i := 0; i < l; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := unmarshal(r, key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := unmarshal(r, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		value, err := readBytes(r)
+		if err != nil {
+			return err
+		}
+		fields := bytes.NewReader(value)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" { // Unexported
+				continue
+			}
+			if fields.Len() == 0 { // Field added after the value was stored
+				v.Field(i).Set(reflect.Zero(v.Field(i).Type()))
+				continue
+			}
+			if err := unmarshal(fields, v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Ptr:
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == 0 {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		return unmarshal(r, v.Elem())
+	default:
+		return fmt.Errorf("binary: type %s not supported", v.Type())
+	}
+	return nil
+}
+
+func unmarshalElems(r *bytes.Reader, v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := unmarshal(r, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeVarint(b *bytes.Buffer, i int64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], i)
+	b.Write(buf[:n])
+}
+
+func writeUvarint(b *bytes.Buffer, u uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], u)
+	b.Write(buf[:n])
+}
+
+func writeBytes(b *bytes.Buffer, value []byte) {
+	writeUvarint(b, uint64(len(value)))
+	b.Write(value)
+}
+
+func readLen(r *bytes.Reader) (int, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(r.Len()) { // Each element needs one byte at least
+		return 0, errors.New("binary: length out of range")
+	}
+	return int(l), nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	l, err := readLen(r)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, l)
+	if _, err := r.Read(value); err != nil && l > 0 {
+		return nil, err
+	}
+	return value, nil
+}
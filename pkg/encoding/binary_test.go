@@ -0,0 +1,121 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ */
+
+package encoding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type base struct {
+	ID   [12]byte
+	Name string
+}
+
+type complexT struct {
+	base
+	Base    base
+	F       float64
+	U       uint16
+	N       int64
+	Bytes   []byte
+	Strs    []string
+	Values  map[string]string
+	Ptr     *T
+	NilPtr  *T
+	Time    time.Time
+	private string
+}
+
+func TestBinary_MarshalUnmarshal(t *testing.T) {
+	data := complexT{
+		Base:   base{ID: [12]byte{1, 2, 3}, Name: "name"},
+		F:      1.5,
+		U:      300,
+		N:      -12345,
+		Bytes:  []byte("bytes"),
+		Strs:   []string{"a", "", "c"},
+		Values: map[string]string{"k1": "v1", "k2": "v2"},
+		Ptr:    &T{S: "s", I: -1, B: true},
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+	}
+	data.private = "private"
+
+	value, err := Binary().Marshal(&data)
+	if assert.NoError(t, err) {
+		var decode complexT
+		err = Binary().Unmarshal(value, &decode)
+		if assert.NoError(t, err) {
+			data.private = ""
+			assert.Equal(t, data, decode)
+		}
+	}
+}
+
+func TestBinary_UnmarshalPrefix(t *testing.T) {
+	type prefix struct {
+		Base base
+	}
+	data := complexT{Base: base{Name: "name"}, N: 10}
+
+	value, err := Binary().Marshal(data)
+	if assert.NoError(t, err) {
+		var decode prefix
+		err = Binary().Unmarshal(value, &decode)
+		if assert.NoError(t, err) {
+			assert.Equal(t, data.Base, decode.Base)
+		}
+	}
+}
+
+func TestBinary_UnmarshalAddedFields(t *testing.T) {
+	type v1 struct {
+		Base base
+		N    int64
+	}
+	type v2 struct {
+		Base  base
+		N     int64
+		Ptr   *T
+		Inner struct {
+			S string
+		}
+	}
+	data := v1{Base: base{Name: "name"}, N: 10}
+
+	value, err := Binary().Marshal(data)
+	if assert.NoError(t, err) {
+		decode := v2{Ptr: &T{S: "s"}}
+		decode.Inner.S = "inner"
+		err = Binary().Unmarshal(value, &decode)
+		if assert.NoError(t, err) {
+			assert.Equal(t, v2{Base: data.Base, N: data.N}, decode)
+		}
+	}
+}
+
+func TestBinary_Errors(t *testing.T) {
+	_, err := Binary().Marshal(struct{ C chan int }{})
+	assert.Error(t, err, "Type not supported")
+
+	var decode T
+	assert.Error(t, Binary().Unmarshal([]byte{}, decode), "Not pointer")
+	assert.Error(t, Binary().Unmarshal([]byte{0x10, 'a'}, &decode), "Length out of range")
+	assert.Error(t, Binary().Unmarshal([]byte{}, &decode), "EOF")
+	assert.Error(t, Binary().Unmarshal([]byte{0x01, 0x05}, &decode), "Truncated field")
+}
@@ -18,26 +18,114 @@ package encoding
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
 )
 
+// The tags of the codecs. Each encoded value starts with the tag of the codec.
+// The gob streams always start by a byte between 0x00-0x7F or 0xF8-0xFF (message length),
+// so the tags are chosen from 0x80-0xF7 to distinguish the values encoded without tag.
+const (
+	TagGob    byte = 0xC1
+	TagJSON   byte = 0xC2
+	TagBinary byte = 0xC3
+)
+
+// The names of the codecs used by configuration
+const (
+	NameGob    = "gob"
+	NameJSON   = "json"
+	NameBinary = "binary"
+)
+
+// Codec encodes and decodes the values of the store
+type Codec interface {
+	// Tag gets the tag that identifies the codec into the encoded values
+	Tag() byte
+	// Name gets the name of the codec
+	Name() string
+	// Marshal encodes data without tag
+	Marshal(data interface{}) ([]byte, error)
+	// Unmarshal decodes value without tag into data
+	Unmarshal(value []byte, data interface{}) error
+}
+
+var codecs = map[byte]Codec{
+	TagGob:    gobCodec{},
+	TagJSON:   jsonCodec{},
+	TagBinary: binaryCodec{},
+}
+
+// Gob gets the codec based on encoding gob
+func Gob() Codec {
+	return codecs[TagGob]
+}
+
+// JSON gets the codec based on encoding json
+func JSON() Codec {
+	return codecs[TagJSON]
+}
+
+// Binary gets the compact binary codec
+func Binary() Codec {
+	return codecs[TagBinary]
+}
+
+// ByName gets the codec from name. If the name is empty returns the gob codec
+func ByName(name string) (Codec, error) {
+	if len(name) == 0 {
+		return Gob(), nil
+	}
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("codec %s not found", name)
+}
+
 // Encode encodes data to string using encoding gob
 func Encode(data interface{}) (string, error) {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	if err := enc.Encode(data); err != nil {
+	return EncodeWith(Gob(), data)
+}
+
+// EncodeWith encodes data to string using the codec. The value starts with the tag of the codec
+func EncodeWith(codec Codec, data interface{}) (string, error) {
+	value, err := codec.Marshal(data)
+	if err != nil {
 		return "", err
 	}
+	var b bytes.Buffer
+	b.Grow(len(value) + 1)
+	b.WriteByte(codec.Tag())
+	b.Write(value)
 	return b.String(), nil
 }
 
-// Decode decodes data from string using decoding gob
+// Tagged checks if the value starts with the tag of some codec.
+// The values stored before the codecs existed are not tagged
+func Tagged(encode string) bool {
+	if len(encode) == 0 {
+		return false
+	}
+	_, ok := codecs[encode[0]]
+	return ok
+}
+
+// Decode decodes data from string using the codec of the tag.
+// If the value has not tag is decoded using decoding gob
 func Decode(encode string, data interface{}) error {
-	enc := gob.NewDecoder(bytes.NewBufferString(encode))
-	return enc.Decode(data)
+	return DecodeByte([]byte(encode), data)
 }
 
-// Decode decodes data from bytes using decoding gob
+// Decode decodes data from bytes using the codec of the tag.
+// If the value has not tag is decoded using decoding gob
 func DecodeByte(encode []byte, data interface{}) error {
-	enc := gob.NewDecoder(bytes.NewBuffer(encode))
-	return enc.Decode(data)
+	if len(encode) > 0 {
+		if codec, ok := codecs[encode[0]]; ok {
+			return codec.Unmarshal(encode[1:], data)
+		}
+	}
+	// Values stored before the codecs existed
+	dec := gob.NewDecoder(bytes.NewBuffer(encode))
+	return dec.Decode(data)
 }
@@ -16,7 +16,10 @@
 package encoding
 
 import (
+	"bytes"
+	"encoding/gob"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -60,3 +63,111 @@ func TestEncoding_DecodeByte(t *testing.T) {
 
 	assert.Equal(t, data, decode, "Encode and decode values are not equal")
 }
+
+func TestEncoding_Codecs(t *testing.T) {
+	data := T{
+		S: "String",
+		I: 1,
+		B: true,
+	}
+
+	tests := []struct {
+		codec Codec
+		tag   byte
+	}{
+		{codec: Gob(), tag: TagGob},
+		{codec: JSON(), tag: TagJSON},
+		{codec: Binary(), tag: TagBinary},
+	}
+
+	for _, tt := range tests {
+		encode, err := EncodeWith(tt.codec, &data)
+		if assert.NoError(t, err, tt.codec.Name()) {
+			assert.Equal(t, tt.tag, encode[0], tt.codec.Name())
+			assert.True(t, Tagged(encode), tt.codec.Name())
+			var decode T
+			err = Decode(encode, &decode)
+			if assert.NoError(t, err, tt.codec.Name()) {
+				assert.Equal(t, data, decode, tt.codec.Name())
+			}
+		}
+	}
+}
+
+func TestEncoding_JSONReadable(t *testing.T) {
+	encode, err := EncodeWith(JSON(), T{S: "String"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"S":"String","I":0,"B":false}`, encode[1:])
+	}
+}
+
+func TestEncoding_DecodeLegacy(t *testing.T) {
+	data := T{
+		S: "String",
+		I: 1,
+		B: true,
+	}
+
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(data)
+	if assert.NoError(t, err, "unexpected encode error") {
+		assert.False(t, Tagged(b.String()), "Legacy value")
+		var decode T
+		err = Decode(b.String(), &decode)
+		if assert.NoError(t, err, "unexpected decode error") {
+			assert.Equal(t, data, decode, "Value without tag")
+		}
+	}
+}
+
+func TestEncoding_ByName(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  byte
+	}{
+		{name: "", tag: TagGob},
+		{name: NameGob, tag: TagGob},
+		{name: NameJSON, tag: TagJSON},
+		{name: NameBinary, tag: TagBinary},
+	}
+
+	for _, tt := range tests {
+		c, err := ByName(tt.name)
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.tag, c.Tag(), tt.name)
+		}
+	}
+
+	_, err := ByName("protobuf")
+	assert.Error(t, err, "Codec not found")
+}
+
+func TestEncoding_JSONIgnoresTags(t *testing.T) {
+	type hidden struct {
+		T
+		ID     string            `json:"-"`
+		Name   string            `json:"name,omitempty"`
+		Time   time.Time         `json:"time"`
+		Values map[int][]byte    `json:"values"`
+		Ptr    *T                `json:"ptr"`
+		Strs   []string          `json:"strs"`
+		Empty  map[string]string `json:"empty"`
+	}
+	data := hidden{
+		T:      T{S: "String", I: 1},
+		ID:     "id",
+		Time:   time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		Values: map[int][]byte{1: []byte("value")},
+		Ptr:    &T{B: true},
+		Strs:   []string{"a", "b"},
+	}
+
+	encode, err := EncodeWith(JSON(), &data)
+	if assert.NoError(t, err) {
+		assert.Contains(t, encode, `"T":{"S":"String","I":1,"B":false},"ID":"id","Name":""`, "Names of the fields")
+		var decode hidden
+		if assert.NoError(t, Decode(encode, &decode)) {
+			assert.Equal(t, data, decode)
+		}
+	}
+}
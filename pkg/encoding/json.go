@@ -0,0 +1,248 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ */
+package encoding
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+var (
+	jsonMarshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshaler   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	rawMessage      = reflect.TypeOf(json.RawMessage{})
+)
+
+// jsonCodec encodes using encoding json. The values can be read with etcdctl or from other languages.
+// The json tags are ignored because they define the representation of the API, not the stored one
+// (e.g. the fields with json:"-" are stored). Like gob, the exported fields are encoded by their names,
+// the embedded structs are encoded as a field named by its type and the types that implement
+// json.Marshaler or encoding.TextMarshaler (e.g. time.Time, xid.ID) are encoded with them
+type jsonCodec struct{}
+
+func (jsonCodec) Tag() byte {
+	return TagJSON
+}
+
+func (jsonCodec) Name() string {
+	return NameJSON
+}
+
+func (jsonCodec) Marshal(data interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := marshalJSON(&b, reflect.ValueOf(data)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (jsonCodec) Unmarshal(value []byte, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("json: the data must be a non nil pointer")
+	}
+	return unmarshalJSON(value, v.Elem())
+}
+
+// jsonMarshaled checks if the type is encoded by encoding json
+func jsonMarshaled(t reflect.Type) bool {
+	return t.Implements(jsonMarshaler) || t.Implements(textMarshaler)
+}
+
+// jsonUnmarshaled checks if the type is decoded by encoding json
+func jsonUnmarshaled(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return p.Implements(jsonUnmarshaler) || p.Implements(textUnmarshaler)
+}
+
+func marshalJSON(b *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		b.WriteString("null")
+		return nil
+	}
+	if jsonMarshaled(v.Type()) {
+		return encodeJSON(b, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		b.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" { // Unexported
+				continue
+			}
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			name, _ := json.Marshal(f.Name)
+			b.Write(name)
+			b.WriteByte(':')
+			if err := marshalJSON(b, v.Field(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		return marshalJSON(b, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return encodeJSON(b, v)
+		}
+		return marshalJSONElems(b, v)
+	case reflect.Array:
+		return marshalJSONElems(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		// encoding json encodes the keys
+		m := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), rawMessage), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var value bytes.Buffer
+			if err := marshalJSON(&value, iter.Value()); err != nil {
+				return err
+			}
+			m.SetMapIndex(iter.Key(), reflect.ValueOf(json.RawMessage(value.Bytes())))
+		}
+		return encodeJSON(b, m)
+	default:
+		return encodeJSON(b, v)
+	}
+	return nil
+}
+
+func marshalJSONElems(b *bytes.Buffer, v reflect.Value) error {
+	b.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := marshalJSON(b, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(']')
+	return nil
+}
+
+func encodeJSON(b *bytes.Buffer, v reflect.Value) error {
+	value, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	b.Write(value)
+	return nil
+}
+
+//nolint:gocyclo
+func unmarshalJSON(value []byte, v reflect.Value) error {
+	if jsonUnmarshaled(v.Type()) {
+		return json.Unmarshal(value, v.Addr().Interface())
+	}
+	null := bytes.Equal(bytes.TrimSpace(value), []byte("null"))
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil {
+			return err
+		}
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath != "" { // Unexported
+				continue
+			}
+			if field, ok := fields[f.Name]; ok {
+				if err := unmarshalJSON(field, v.Field(i)); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Ptr:
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshalJSON(value, v.Elem())
+	case reflect.Slice:
+		if null || v.Type().Elem().Kind() == reflect.Uint8 {
+			return json.Unmarshal(value, v.Addr().Interface())
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(value, &elems); err != nil {
+			return err
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(elems), len(elems)))
+		return unmarshalJSONElems(elems, v)
+	case reflect.Array:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(value, &elems); err != nil {
+			return err
+		}
+		if len(elems) != v.Len() {
+			return errors.New("json: length of array out of range")
+		}
+		return unmarshalJSONElems(elems, v)
+	case reflect.Map:
+		if null {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		// encoding json decodes the keys
+		raw := reflect.New(reflect.MapOf(v.Type().Key(), rawMessage))
+		if err := json.Unmarshal(value, raw.Interface()); err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(v.Type(), raw.Elem().Len())
+		iter := raw.Elem().MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := unmarshalJSON(iter.Value().Bytes(), elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(iter.Key(), elem)
+		}
+		v.Set(m)
+	default:
+		return json.Unmarshal(value, v.Addr().Interface())
+	}
+	return nil
+}
+
+func unmarshalJSONElems(elems []json.RawMessage, v reflect.Value) error {
+	for i, elem := range elems {
+		if err := unmarshalJSON(elem, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package health
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/carisa/pkg/storage"
+)
+
+const (
+	// LivePath is the http path of the liveness probe
+	LivePath = "/healthz"
+	// ReadyPath is the http path of the readiness probe
+	ReadyPath = "/readyz"
+
+	// StatusOk is the status of the server or the check that works
+	StatusOk = "ok"
+	// StatusUnavailable is the status of the server that can not receive requests
+	StatusUnavailable = "unavailable"
+
+	// SentinelKey is the key read by the check of the store. It does not need to exist,
+	// the check only proves that the store answers
+	SentinelKey = "health#sentinel"
+)
+
+// Version and Commit identify the build. They are set when the binary is linked, for example:
+// go build -ldflags "-X github.com/carisa/pkg/health.Version=1.2.0 -X github.com/carisa/pkg/health.Commit=abc123"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// Build describes the binary of the server
+type Build struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Report is the answer of the probes
+type Report struct {
+	Status string `json:"status"`
+	// Checks is the result of each check: ok or the error
+	Checks map[string]string `json:"checks,omitempty"`
+	// ConfigVersion is the version of the configuration loaded. See runtime.CommonConfig.Version
+	ConfigVersion string `json:"configVersion,omitempty"`
+	Build         Build  `json:"build"`
+}
+
+// Check checks a dependency of the server. If it doesn't work returns the error
+type Check func(ctx context.Context) error
+
+// Probe answers the liveness and readiness probes. The server is ready if all checks work
+type Probe struct {
+	mu            sync.RWMutex
+	names         []string
+	checks        []Check
+	configVersion string
+}
+
+// NewProbe builds the probe without checks
+func NewProbe(configVersion string) *Probe {
+	return &Probe{configVersion: configVersion}
+}
+
+// Add adds the check to the readiness probe
+func (p *Probe) Add(name string, check Check) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.names = append(p.names, name)
+	p.checks = append(p.checks, check)
+}
+
+// Live answers the liveness probe. The server is alive while it answers, so the checks are not run
+func (p *Probe) Live() Report {
+	return p.report(StatusOk, nil)
+}
+
+// Ready runs all checks and returns true if all of them work
+func (p *Probe) Ready(ctx context.Context) (Report, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ready := true
+	checks := make(map[string]string, len(p.checks))
+	for i, check := range p.checks {
+		if err := check(ctx); err != nil {
+			ready = false
+			checks[p.names[i]] = err.Error()
+			continue
+		}
+		checks[p.names[i]] = StatusOk
+	}
+	if !ready {
+		return p.report(StatusUnavailable, checks), false
+	}
+	return p.report(StatusOk, checks), true
+}
+
+func (p *Probe) report(status string, checks map[string]string) Report {
+	return Report{
+		Status:        status,
+		Checks:        checks,
+		ConfigVersion: p.configVersion,
+		Build:         BuildInfo(),
+	}
+}
+
+// BuildInfo returns the information of the binary
+func BuildInfo() Build {
+	return Build{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+}
+
+// StoreCheck checks that the store answers with a cheap Exists of the SentinelKey.
+// The timeout is the timeout of the calls to the store. See runtime.CommonConfig.StoreWithTimeout
+func StoreCheck(store storage.CRUD, timeout func(parent context.Context) (context.Context, context.CancelFunc)) Check {
+	return func(ctx context.Context) error {
+		ctx, cancel := timeout(ctx)
+		defer cancel()
+		_, err := store.Exists(ctx, SentinelKey)
+		return err
+	}
+}
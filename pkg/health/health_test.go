@@ -0,0 +1,90 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package health
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe_Live(t *testing.T) {
+	p := NewProbe("v1")
+	p.Add("fail", func(context.Context) error { return errors.New("fail") })
+
+	r := p.Live()
+
+	assert.Equal(t, StatusOk, r.Status, "The checks are not run")
+	assert.Nil(t, r.Checks, "Checks")
+	assert.Equal(t, "v1", r.ConfigVersion, "Config version")
+	assert.Equal(t, Build{Version: "dev", GoVersion: runtime.Version()}, r.Build, "Build")
+}
+
+func TestProbe_Ready(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		ready  bool
+		status string
+		checks map[string]string
+	}{
+		{
+			name:   "All checks work",
+			ready:  true,
+			status: StatusOk,
+			checks: map[string]string{"store": StatusOk, "other": StatusOk},
+		},
+		{
+			name:   "A check fails",
+			err:    errors.New("timeout"),
+			status: StatusUnavailable,
+			checks: map[string]string{"store": StatusOk, "other": "timeout"},
+		},
+	}
+	for _, tt := range tests {
+		p := NewProbe("v2")
+		p.Add("store", func(context.Context) error { return nil })
+		p.Add("other", func(context.Context) error { return tt.err })
+
+		r, ready := p.Ready(context.TODO())
+
+		assert.Equal(t, tt.ready, ready, tt.name)
+		assert.Equal(t, tt.status, r.Status, tt.name)
+		assert.Equal(t, tt.checks, r.Checks, tt.name)
+		assert.Equal(t, "v2", r.ConfigVersion, tt.name)
+	}
+}
+
+func TestHealth_StoreCheck(t *testing.T) {
+	mng := storage.NewEctdIntegra(t)
+	defer mng.Close()
+	timeout := func(parent context.Context) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(parent, 5*time.Second)
+	}
+
+	assert.NoError(t, StoreCheck(mng.Store(), timeout)(context.TODO()), "The sentinel key does not exist")
+
+	mock := &storage.ErrMockCRUD{}
+	mock.Activate("Exists")
+	assert.Error(t, StoreCheck(mock, timeout)(context.TODO()), "The store does not answer")
+}
@@ -17,6 +17,8 @@
 package echo
 
 import (
+	stdcontext "context"
+	"fmt"
 	"strconv"
 
 	"github.com/carisa/pkg/http"
@@ -65,28 +67,62 @@ func (c *context) HTTPErrorLog(
 	msg string, err error,
 	logger logging.Logger,
 	loc string, fields ...logging.Field) error {
-	switch len(fields) {
+	logFields := fields
+	if c.ctx != nil && len(fields) < 3 {
+		if id := RequestID(c.ctx); len(id) > 0 {
+			logFields = append(append([]logging.Field{}, fields...), logging.String("requestId", id))
+		}
+	}
+	switch len(logFields) {
 	case 0:
 		_ = logger.ErrWrap(err, msg, loc)
 	case 1:
-		_ = logger.ErrWrap1(err, msg, loc, fields[0])
+		_ = logger.ErrWrap1(err, msg, loc, logFields[0])
 	case 2:
-		_ = logger.ErrWrap2(err, msg, loc, fields[0], fields[1])
+		_ = logger.ErrWrap2(err, msg, loc, logFields[0], logFields[1])
 	case 3:
-		_ = logger.ErrWrap3(err, msg, loc, fields[0], fields[1], fields[2])
+		_ = logger.ErrWrap3(err, msg, loc, logFields[0], logFields[1], logFields[2])
 	}
-	return echo.NewHTTPError(status, logging.Compose(msg, fields...))
+	return c.HTTPError(status, logging.Compose(msg, fields...))
 }
 
 // HTTPError implements Context.HTTPError
 func (c *context) HTTPError(code int, message ...interface{}) error {
-	return echo.NewHTTPError(code, message)
+	return c.HTTPErrorBody(code, http.ErrorBody{Message: fmt.Sprint(message...)})
+}
+
+// HTTPErrorBody implements Context.HTTPErrorBody
+func (c *context) HTTPErrorBody(status int, body http.ErrorBody) error {
+	if len(body.Code) == 0 {
+		body.Code = http.StatusCode(status)
+	}
+	return echo.NewHTTPError(status, body)
+}
+
+// Context implements Context.Context
+func (c *context) Context() stdcontext.Context {
+	if c.ctx == nil {
+		return stdcontext.Background()
+	}
+	return c.ctx.Request().Context()
+}
+
+// Principal implements Context.Principal
+func (c *context) Principal() http.Principal {
+	if c.ctx == nil {
+		return http.Principal{}
+	}
+	p, _ := c.ctx.Get(http.PrincipalKey).(http.Principal)
+	return p
 }
 
 // NoEmpty implements Context.NoEmpty
 func (c *context) NoEmpty(name string, value string) error {
 	if len(value) == 0 {
-		return c.HTTPError(nethttp.StatusBadRequest, strings.Concat("the property: '", name, "' can not be empty"))
+		return c.HTTPErrorBody(nethttp.StatusBadRequest, http.ErrorBody{
+			Message: strings.Concat("the property: '", name, "' can not be empty"),
+			Field:   name,
+		})
 	}
 	return nil
 }
@@ -94,9 +130,10 @@ func (c *context) NoEmpty(name string, value string) error {
 // MaxLen implements Context.MaxLen
 func (c *context) MaxLen(name string, value string, length int) error {
 	if len(value) > length {
-		return c.HTTPError(
-			nethttp.StatusBadRequest,
-			strings.Concat("the property: '", name, "' can not be more than ", strconv.Itoa(length)))
+		return c.HTTPErrorBody(nethttp.StatusBadRequest, http.ErrorBody{
+			Message: strings.Concat("the property: '", name, "' can not be more than ", strconv.Itoa(length)),
+			Field:   name,
+		})
 	}
 	return nil
 }
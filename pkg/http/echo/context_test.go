@@ -19,8 +19,12 @@ package echo
 import (
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+
 	"github.com/carisa/pkg/logging"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -110,7 +114,18 @@ func TestContext_HTTPErrorLog(t *testing.T) {
 func TestContext_HTTPError(t *testing.T) {
 	ctxw := NewContext(nil)
 	err := ctxw.HTTPError(500, "error")
-	assert.Equal(t, "code=500, message=[error]", err.Error(), "error")
+	assert.Equal(t, "code=500, message=error", err.Error(), "error")
+	assert.Equal(t, httpc.ErrorBody{Code: httpc.CodeInternal, Message: "error"}, err.(*echo.HTTPError).Message, "body")
+}
+
+func TestContext_HTTPErrorBody(t *testing.T) {
+	ctxw := NewContext(nil)
+
+	err := ctxw.HTTPErrorBody(404, httpc.ErrorBody{Code: "parent_not_found", Message: "error"})
+	assert.Equal(t, httpc.ErrorBody{Code: "parent_not_found", Message: "error"}, err.(*echo.HTTPError).Message, "Code")
+
+	err = ctxw.HTTPErrorBody(404, httpc.ErrorBody{Message: "error"})
+	assert.Equal(t, httpc.ErrorBody{Code: httpc.CodeNotFound, Message: "error"}, err.(*echo.HTTPError).Message, "Status code")
 }
 
 func TestValid_ValidNoEmpty(t *testing.T) {
@@ -127,7 +142,7 @@ func TestValid_ValidNoEmpty(t *testing.T) {
 		{
 			name:    "property",
 			value:   "",
-			message: "code=400, message=[the property: 'property' can not be empty]",
+			message: "code=400, message=the property: 'property' can not be empty",
 		},
 	}
 
@@ -139,6 +154,7 @@ func TestValid_ValidNoEmpty(t *testing.T) {
 			assert.Nil(t, r)
 		} else {
 			assert.Equal(t, tt.message, r.Error())
+			assert.Equal(t, tt.name, r.(*echo.HTTPError).Message.(httpc.ErrorBody).Field, "Field")
 		}
 	}
 }
@@ -160,7 +176,7 @@ func TestValid_ValidMaxLen(t *testing.T) {
 			name:    "property",
 			value:   "value",
 			len:     3,
-			message: "code=400, message=[the property: 'property' can not be more than 3]",
+			message: "code=400, message=the property: 'property' can not be more than 3",
 		},
 	}
 
@@ -176,7 +192,29 @@ func TestValid_ValidMaxLen(t *testing.T) {
 	}
 }
 
+func TestContext_Principal(t *testing.T) {
+	e := echo.New()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/api", nil), httptest.NewRecorder())
+
+	assert.False(t, NewContext(ctx).Principal().Authenticated(), "Anonymous")
+	assert.False(t, NewContext(nil).Principal().Authenticated(), "Without context")
+
+	p := httpc.Principal{ID: "id", Method: "apikey"}
+	ctx.Set(httpc.PrincipalKey, p)
+	assert.Equal(t, p, NewContext(ctx).Principal(), "Authenticated")
+	assert.True(t, NewContext(ctx).Principal().Authenticated(), "Authenticated")
+}
+
 func newLogger(level zapcore.Level) (*observer.ObservedLogs, logging.Logger) {
 	core, obs := observer.New(level)
 	return obs, logging.NewZapWrap(zap.New(core), logging.DebugLevel, "")
 }
+
+func TestContext_Context(t *testing.T) {
+	h := HTTPMock()
+	defer h.Close(nil)
+
+	_, ctx := h.NewHTTP(http.MethodGet, "/api", "", nil, nil)
+	assert.NotNil(t, ctx.Context(), "Context of the request")
+	assert.NotNil(t, NewContext(nil).Context(), "Without request")
+}
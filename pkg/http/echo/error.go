@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	"fmt"
+	nethttp "net/http"
+
+	"github.com/carisa/pkg/http"
+	"github.com/carisa/pkg/logging"
+	"github.com/labstack/echo/v4"
+)
+
+const locError = "http.error"
+
+// ErrorHandler builds the echo error handler that sends all errors with the same body. See http.ErrorBody.
+// The errors that are not http errors are logged and sent as internal errors without details
+func ErrorHandler(log logging.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		if _, ok := err.(*echo.HTTPError); !ok && log != nil {
+			log.ErrorE(err, locError)
+		}
+		status, body := errorBody(err)
+		body.RequestID = RequestID(c)
+
+		var rErr error
+		if c.Request().Method == nethttp.MethodHead {
+			rErr = c.NoContent(status)
+		} else {
+			rErr = c.JSON(status, body)
+		}
+		if rErr != nil && log != nil {
+			log.ErrorE(rErr, locError)
+		}
+	}
+}
+
+// RequestID returns the identifier of the request. It is recovered from the response
+// or the request header X-Request-ID
+func RequestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); len(id) > 0 {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
+
+func errorBody(err error) (int, http.ErrorBody) {
+	he, ok := err.(*echo.HTTPError)
+	if !ok {
+		return nethttp.StatusInternalServerError, http.ErrorBody{
+			Code:    http.CodeInternal,
+			Message: nethttp.StatusText(nethttp.StatusInternalServerError),
+		}
+	}
+
+	var body http.ErrorBody
+	switch msg := he.Message.(type) {
+	case http.ErrorBody:
+		body = msg
+	case string:
+		body.Message = msg
+	default:
+		body.Message = fmt.Sprint(msg)
+	}
+	if len(body.Code) == 0 {
+		body.Code = http.StatusCode(he.Code)
+	}
+	return he.Code, body
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpc "github.com/carisa/pkg/http"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_ErrorHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		reqID  string
+		err    error
+		status int
+		body   string
+		logs   int
+	}{
+		{
+			name:   "Error body.",
+			method: http.MethodPost,
+			reqID:  "id",
+			err: echo.NewHTTPError(
+				http.StatusBadRequest,
+				httpc.ErrorBody{Code: httpc.CodeInvalidArgument, Message: "message", Field: "name"}),
+			status: http.StatusBadRequest,
+			body:   `{"code":"invalid_argument","message":"message","field":"name","requestId":"id"}`,
+		},
+		{
+			name:   "Message.",
+			method: http.MethodGet,
+			err:    echo.NewHTTPError(http.StatusTooManyRequests, "too many requests"),
+			status: http.StatusTooManyRequests,
+			body:   `{"code":"rate_limited","message":"too many requests"}`,
+		},
+		{
+			name:   "Echo error.",
+			method: http.MethodGet,
+			err:    echo.ErrNotFound,
+			status: http.StatusNotFound,
+			body:   `{"code":"not_found","message":"Not Found"}`,
+		},
+		{
+			name:   "Internal error.",
+			method: http.MethodGet,
+			err:    errors.New("storage error"),
+			status: http.StatusInternalServerError,
+			body:   `{"code":"internal","message":"Internal Server Error"}`,
+			logs:   1,
+		},
+		{
+			name:   "Head.",
+			method: http.MethodHead,
+			err:    echo.NewHTTPError(http.StatusNotFound, "not found"),
+			status: http.StatusNotFound,
+		},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		recorded, l := newLogger(zapcore.ErrorLevel)
+		req := httptest.NewRequest(tt.method, "/api", nil)
+		if len(tt.reqID) > 0 {
+			req.Header.Set(echo.HeaderXRequestID, tt.reqID)
+		}
+		rec := httptest.NewRecorder()
+
+		ErrorHandler(l)(tt.err, e.NewContext(req, rec))
+
+		assert.Equal(t, tt.status, rec.Code, tt.name+"Status")
+		if len(tt.body) > 0 {
+			assert.JSONEq(t, tt.body, rec.Body.String(), tt.name+"Body")
+		} else {
+			assert.Empty(t, rec.Body.String(), tt.name+"Body")
+		}
+		assert.Equal(t, tt.logs, recorded.Len(), tt.name+"Logs")
+	}
+}
+
+func TestError_ErrorHandlerCommitted(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/api", nil), rec)
+	if assert.NoError(t, ctx.String(http.StatusOK, "ok")) {
+		ErrorHandler(nil)(echo.ErrNotFound, ctx)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "ok", rec.Body.String())
+	}
+}
+
+func TestError_RequestID(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.Header.Set(echo.HeaderXRequestID, "request")
+	ctx := e.NewContext(req, httptest.NewRecorder())
+	assert.Equal(t, "request", RequestID(ctx), "From request")
+
+	ctx.Response().Header().Set(echo.HeaderXRequestID, "response")
+	assert.Equal(t, "response", RequestID(ctx), "From response")
+}
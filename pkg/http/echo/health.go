@@ -0,0 +1,54 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/pkg/health"
+	"github.com/labstack/echo/v4"
+)
+
+// Liveness answers the liveness probe. See health.Probe.Live
+func Liveness(probe *health.Probe) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(nethttp.StatusOK, probe.Live())
+	}
+}
+
+// Readiness answers the readiness probe. If some check doesn't work the status is 503.
+// See health.Probe.Ready
+func Readiness(probe *health.Probe) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		report, ready := probe.Ready(c.Request().Context())
+		if !ready {
+			return c.JSON(nethttp.StatusServiceUnavailable, report)
+		}
+		return c.JSON(nethttp.StatusOK, report)
+	}
+}
+
+// Probes adds the routes of the liveness and readiness probes. See health.LivePath and health.ReadyPath
+func Probes(e *echo.Echo, probe *health.Probe) {
+	e.GET(health.LivePath, Liveness(probe))
+	e.GET(health.ReadyPath, Readiness(probe))
+}
+
+// IsProbe returns true if the route is a probe. The probes are not authenticated nor limited
+func IsProbe(route string) bool {
+	return route == health.LivePath || route == health.ReadyPath
+}
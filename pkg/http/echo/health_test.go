@@ -0,0 +1,86 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	stdcontext "context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/carisa/pkg/health"
+	"github.com/labstack/echo/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth_Probes(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "Alive",
+			path:   health.LivePath,
+			status: http.StatusOK,
+			body:   `"status":"ok"`,
+		},
+		{
+			name:   "Alive although the store fails",
+			err:    errors.New("store down"),
+			path:   health.LivePath,
+			status: http.StatusOK,
+			body:   `"status":"ok"`,
+		},
+		{
+			name:   "Ready",
+			path:   health.ReadyPath,
+			status: http.StatusOK,
+			body:   `"checks":{"store":"ok"},"configVersion":"v1"`,
+		},
+		{
+			name:   "Not ready",
+			err:    errors.New("store down"),
+			path:   health.ReadyPath,
+			status: http.StatusServiceUnavailable,
+			body:   `"status":"unavailable","checks":{"store":"store down"}`,
+		},
+	}
+	for _, tt := range tests {
+		p := health.NewProbe("v1")
+		err := tt.err
+		p.Add("store", func(stdcontext.Context) error { return err })
+		e := echo.New()
+		Probes(e, p)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		assert.Equal(t, tt.status, rec.Code, tt.name)
+		assert.Contains(t, rec.Body.String(), tt.body, tt.name)
+	}
+}
+
+func TestHealth_IsProbe(t *testing.T) {
+	assert.True(t, IsProbe(health.LivePath), "Liveness")
+	assert.True(t, IsProbe(health.ReadyPath), "Readiness")
+	assert.False(t, IsProbe("/api/spaces/:id"), "API")
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/carisa/pkg/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatched is the route of the requests without route
+const unmatched = "unmatched"
+
+// Metrics collects the requests by method, route and status and their latency.
+// The collectors are registered into reg
+func Metrics(reg prometheus.Registerer) (echo.MiddlewareFunc, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "carisa_http_requests_total",
+		Help: "Number of http requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "carisa_http_request_duration_seconds",
+		Help:    "Latency of the http requests by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	for _, c := range []prometheus.Collector{requests, duration} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+
+			route := c.Path()
+			if len(route) == 0 {
+				route = unmatched
+			}
+			method := c.Request().Method
+			requests.WithLabelValues(method, route, strconv.Itoa(status(c, err))).Inc()
+			duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}, nil
+}
+
+// MetricsHandler exposes the metrics of the registry. See metrics.Handler
+func MetricsHandler(reg *prometheus.Registry) echo.HandlerFunc {
+	return echo.WrapHandler(metrics.Handler(reg))
+}
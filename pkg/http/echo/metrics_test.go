@@ -0,0 +1,77 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carisa/pkg/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics_Middleware(t *testing.T) {
+	reg := metrics.NewRegistry()
+	mw, err := Metrics(reg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = Metrics(reg)
+	assert.Error(t, err, "Registered twice")
+
+	e := echo.New()
+	e.Use(mw)
+	e.GET("/api/spaces/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	e.GET("/api/entes/:id", func(c echo.Context) error { return echo.NewHTTPError(http.StatusNotFound, "not found") })
+	e.GET("/metrics", MetricsHandler(reg))
+	for _, url := range []string{"/api/spaces/1", "/api/spaces/2", "/api/entes/1"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if assert.Equal(t, http.StatusOK, rec.Code) {
+		body := rec.Body.String()
+		assert.Contains(t, body, `carisa_http_requests_total{method="GET",route="/api/spaces/:id",status="200"} 2`)
+		assert.Contains(t, body, `carisa_http_requests_total{method="GET",route="/api/entes/:id",status="404"} 1`)
+		assert.Contains(t, body, `carisa_http_request_duration_seconds_count{method="GET",route="/api/spaces/:id"} 2`)
+		assert.True(t, strings.Contains(body, "go_goroutines"), "Metrics of the runtime")
+	}
+}
+
+func TestMetrics_Unmatched(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mw, err := Metrics(reg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/none", nil), httptest.NewRecorder())
+	_ = mw(func(c echo.Context) error { return echo.ErrNotFound })(c)
+
+	families, err := reg.Gather()
+	if assert.NoError(t, err) && assert.NotEmpty(t, families) {
+		labels := families[1].Metric[0].Label
+		assert.Equal(t, "unmatched", labels[1].GetValue(), "Route")
+		assert.Equal(t, "404", labels[2].GetValue(), "Status")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	nethttp "net/http"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/carisa/pkg/strings"
+	"github.com/carisa/pkg/tracing"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/xid"
+)
+
+const locTrace = "http.trace"
+
+// RequestIDMiddleware sets the header X-Request-ID to the response. If the request has not the header
+// a new identifier is generated. See RequestID
+func RequestIDMiddleware() echo.MiddlewareFunc {
+	return middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Generator: func() string { return xid.New().String() },
+	})
+}
+
+// Trace starts the server span of the request. The span continues the trace of the header traceparent
+// if it is valid. The context of the request carries the span, so the services and the store create their spans
+// as children. See httpc.Context.Context
+func Trace(tracer *tracing.Tracer, log logging.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			remote, _ := tracing.Parse(req.Header.Get(tracing.Header))
+			ctx, span := tracer.Start(
+				req.Context(),
+				strings.Concat("HTTP ", req.Method, " ", c.Path()),
+				tracing.Server,
+				remote,
+				tracing.String("http.method", req.Method),
+				tracing.String("http.route", c.Path()),
+				tracing.String("http.target", req.URL.RequestURI()),
+				tracing.String("request.id", RequestID(c)))
+			defer span.End()
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			code := status(c, err)
+			span.SetAttributes(tracing.Int("http.status_code", code))
+			if code >= nethttp.StatusInternalServerError {
+				span.SetStatus(tracing.Error, nethttp.StatusText(code))
+			}
+			if log != nil {
+				log.Debug2(
+					"request traced",
+					locTrace,
+					logging.String("requestId", RequestID(c)),
+					logging.String("traceId", span.SpanContext().TraceID.String()))
+			}
+			return err
+		}
+	}
+}
+
+// status gets the status of the response. The error returned by the handler is sent
+// later by the error handler, so the status is gotten from the error. See ErrorHandler
+func status(c echo.Context, err error) int {
+	if err != nil {
+		code, _ := errorBody(err)
+		return code
+	}
+	return c.Response().Status
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package echo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/carisa/pkg/tracing"
+	"github.com/labstack/echo/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace_Middleware(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name        string
+		traceparent string
+		handler     echo.HandlerFunc
+		traceID     string
+		status      int
+		state       tracing.StatusCode
+	}{
+		{
+			name:        "Remote trace.",
+			traceparent: parent,
+			handler:     func(c echo.Context) error { return c.NoContent(http.StatusOK) },
+			traceID:     "4bf92f3577b34da6a3ce929d0e0e4736",
+			status:      http.StatusOK,
+			state:       tracing.Unset,
+		},
+		{
+			name:    "New trace with error.",
+			handler: func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable, "unavailable") },
+			status:  http.StatusServiceUnavailable,
+			state:   tracing.Error,
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tracer := tracing.NewTracer(tracing.NewWriterExporter(&out), nil)
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/api/spaces/1", nil)
+		req.Header.Set(echo.HeaderXRequestID, "rid")
+		if len(tt.traceparent) > 0 {
+			req.Header.Set(tracing.Header, tt.traceparent)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/api/spaces/:id")
+
+		var span *tracing.Span
+		_ = Trace(tracer, nil)(func(c echo.Context) error {
+			span = tracing.FromContext(NewContext(c).Context())
+			return tt.handler(c)
+		})(c)
+
+		assert.NotNil(t, span, tt.name+"Span into the context of the request")
+		var data tracing.Data
+		if assert.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(out.String())), &data), tt.name+"Span exported") {
+			assert.Equal(t, "HTTP GET /api/spaces/:id", data.Name, tt.name+"Name")
+			assert.Equal(t, tracing.Server, data.Kind, tt.name+"Kind")
+			if len(tt.traceID) > 0 {
+				assert.Equal(t, tt.traceID, data.TraceID, tt.name+"Trace")
+				assert.Equal(t, "00f067aa0ba902b7", data.ParentID, tt.name+"Parent")
+			}
+			assert.Equal(t, span.SpanContext().SpanID.String(), data.SpanID, tt.name+"Span")
+			assert.Contains(t, data.Attributes, tracing.String("request.id", "rid"), tt.name+"Request ID")
+			assert.Contains(t, data.Attributes, tracing.Attribute{Key: "http.status_code", Value: float64(tt.status)}, tt.name+"Status")
+			assert.Equal(t, tt.state, data.Status.Code, tt.name+"State")
+		}
+	}
+}
+
+func TestTrace_RequestIDMiddleware(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	err := RequestIDMiddleware()(func(c echo.Context) error { return nil })(c)
+	if assert.NoError(t, err) {
+		assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 20, "Generated with xid")
+		assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), RequestID(c), "Request ID")
+	}
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	httpc "net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError_StatusCode(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+	}{
+		{status: httpc.StatusBadRequest, code: CodeInvalidArgument},
+		{status: httpc.StatusUnauthorized, code: CodeUnauthenticated},
+		{status: httpc.StatusForbidden, code: CodePermissionDenied},
+		{status: httpc.StatusNotFound, code: CodeNotFound},
+		{status: httpc.StatusConflict, code: CodeConflict},
+		{status: httpc.StatusTooManyRequests, code: CodeRateLimited},
+		{status: httpc.StatusInternalServerError, code: CodeInternal},
+		{status: httpc.StatusBadGateway, code: CodeInternal},
+		{status: httpc.StatusServiceUnavailable, code: CodeUnavailable},
+		{status: httpc.StatusMethodNotAllowed, code: CodeInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.code, StatusCode(tt.status), httpc.StatusText(tt.status))
+	}
+}
+
+func TestError_String(t *testing.T) {
+	body := ErrorBody{Code: CodeNotFound, Message: "message", Field: "field"}
+	assert.Equal(t, "message", fmt.Sprint(body))
+}
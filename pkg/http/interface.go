@@ -17,11 +17,31 @@
 package http
 
 import (
+	"context"
 	"net/http/httptest"
 
 	"github.com/carisa/pkg/logging"
 )
 
+// PrincipalKey is the key of the authenticated principal into the context of the request
+const PrincipalKey = "carisa.principal"
+
+// Principal is the identity authenticated of the request
+type Principal struct {
+	// ID identifies the principal: the identifier of the API key or the subject of the token
+	ID string `json:"id"`
+	// Method is the method used to authenticate the principal
+	Method string `json:"method"`
+	// Tenant is the only tenant that the principal can reach. If it is empty the principal
+	// only reaches the keys out of any tenant
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Authenticated returns true if the principal is not anonymous
+func (p Principal) Authenticated() bool {
+	return len(p.ID) > 0
+}
+
 // Context is a adapter for http
 type Context interface {
 	// Param return path param
@@ -40,14 +60,26 @@ type Context interface {
 	// HTTPErrorLog creates http error and sending a log error
 	HTTPErrorLog(status int, msg string, err error, logger logging.Logger, loc string, fields ...logging.Field) error
 
-	// HTTPError return http error
+	// HTTPError return http error. The code of the error body depends on the status. See StatusCode
 	HTTPError(code int, message ...interface{}) error
 
+	// HTTPErrorBody return http error with the body provided.
+	// If the code of the body is empty, it depends on the status. See StatusCode
+	HTTPErrorBody(status int, body ErrorBody) error
+
 	// NoEmpty validates that the value is not empty
 	NoEmpty(name string, value string) error
 
 	// MaxLen validates that the value length can not be more than length param
 	MaxLen(name string, value string, length int) error
+
+	// Context returns the context of the request. It carries the span of the request
+	// and it is cancelled when the client closes the connection
+	Context() context.Context
+
+	// Principal returns the authenticated principal of the request.
+	// If the request is not authenticated the principal is anonymous
+	Principal() Principal
 }
 
 // Mock mocks http operations
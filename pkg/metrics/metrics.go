@@ -0,0 +1,57 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultPath is the path of the metrics if the configuration has not path
+const DefaultPath = "/metrics"
+
+// Config describes the metrics exposed to Prometheus
+type Config struct {
+	// Enabled collects and exposes the metrics
+	Enabled bool `json:"enabled,omitempty"`
+	// Path is the http path of the metrics. Common value: /metrics
+	Path string `json:"path,omitempty"`
+}
+
+// Route returns the http path of the metrics
+func (c Config) Route() string {
+	if len(c.Path) == 0 {
+		return DefaultPath
+	}
+	return c.Path
+}
+
+// NewRegistry builds the registry of the metrics with the metrics of the Go runtime and the process.
+// Each server has its own registry, so the metrics are not shared among the tests
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGoCollector())
+	reg.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return reg
+}
+
+// Handler exposes the metrics of the registry in the text format of Prometheus
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
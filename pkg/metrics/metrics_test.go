@@ -0,0 +1,35 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_Route(t *testing.T) {
+	assert.Equal(t, DefaultPath, Config{}.Route(), "Default path")
+	assert.Equal(t, "/stats", Config{Path: "/stats"}.Route(), "Path")
+}
+
+func TestMetrics_NewRegistry(t *testing.T) {
+	families, err := NewRegistry().Gather()
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, families, "Metrics of the runtime and the process")
+	}
+}
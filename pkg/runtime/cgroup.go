@@ -0,0 +1,211 @@
+/*
+ *  Copyright 2019-2022 the original author or authors.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing,
+ *  software  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package runtime
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CgroupRoot is the mount point of the cgroup filesystem. Into a container it is the cgroup of the container
+const CgroupRoot = "/sys/fs/cgroup"
+
+// NewCgroupProvider measures the cgroup mounted at root, cgroup v2 if root is a unified hierarchy or cgroup v1.
+// The CPU is relative to the quota of the cgroup and the memory to its limit.
+// If the cgroup is not limited the CPUs and the memory of the host are used, the memory is read from proc
+func NewCgroupProvider(root string, proc string) Provider {
+	return newSampler(newCgroup(root, proc).read)
+}
+
+type cgroup struct {
+	root string
+	proc string
+	v2   bool
+	dirs map[string]string // dirs are the directories of the controllers ("" for cgroup v2) if they are not at root
+}
+
+func newCgroup(root string, proc string) cgroup {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return cgroup{root: root, proc: proc, v2: err == nil}
+}
+
+// newProcessCgroup returns the cgroup of the process mounted at root reading proc/self/cgroup.
+// If the directory of the cgroup of the process is not mounted, the mount point is the cgroup of the process
+// (a container without cgroup namespace). Returns false if the process is into the root cgroup,
+// the root cgroup measures the host
+func newProcessCgroup(root string, proc string) (cgroup, bool) {
+	c := newCgroup(root, proc)
+	paths, err := readCgroupPaths(filepath.Join(proc, "self", "cgroup"))
+	if err != nil {
+		return cgroup{}, false
+	}
+	controllers := []string{"cpu", "cpuacct", "memory"}
+	if c.v2 {
+		controllers = []string{""}
+	}
+	c.dirs = make(map[string]string, len(controllers))
+	inRoot := true
+	for _, controller := range controllers {
+		path, ok := paths[controller]
+		if !ok {
+			return cgroup{}, false
+		}
+		inRoot = inRoot && path == "/"
+		dir := filepath.Join(root, controller, path)
+		if _, err := os.Stat(dir); err != nil {
+			dir = filepath.Join(root, controller)
+		}
+		c.dirs[controller] = dir
+	}
+	if inRoot && c.v2 {
+		// The root of a cgroup namespace is not the root cgroup, the root cgroup has not memory.current
+		_, err := os.Stat(filepath.Join(root, "memory.current"))
+		inRoot = err != nil
+	}
+	return c, !inRoot
+}
+
+// readCgroupPaths reads the paths of the controllers from a file of lines with the format:
+// hierarchy:controller[,controller]:path. The controller of cgroup v2 is empty
+func readCgroupPaths(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	paths := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// file returns the file of the controller, the controller is empty for cgroup v2
+func (c cgroup) file(controller string, name string) string {
+	if dir, ok := c.dirs[controller]; ok {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(c.root, controller, name)
+}
+
+func (c cgroup) read() (sample, error) {
+	var smp sample
+	var err error
+	if c.v2 {
+		smp, err = c.readV2()
+	} else {
+		smp, err = c.readV1()
+	}
+	if err != nil {
+		return sample{}, err
+	}
+	if smp.cpus <= 0 {
+		smp.cpus = hostCPUs()
+	}
+	if smp.limit == 0 {
+		if smp.limit, err = hostMemory(c.proc); err != nil {
+			return sample{}, err
+		}
+	}
+	return smp, nil
+}
+
+// readV2 reads the usage in microseconds of cpu.stat, the quota and period of cpu.max ("max" without quota),
+// memory.current and memory.max ("max" without limit)
+func (c cgroup) readV2() (sample, error) {
+	usec, err := readKey(c.file("", "cpu.stat"), "usage_usec")
+	if err != nil {
+		return sample{}, err
+	}
+	smp := sample{cpu: time.Duration(usec) * time.Microsecond}
+
+	data, err := ioutil.ReadFile(c.file("", "cpu.max"))
+	if err != nil && !os.IsNotExist(err) {
+		return sample{}, err
+	}
+	if fields := strings.Fields(string(data)); len(fields) == 2 && fields[0] != "max" {
+		quota, errq := strconv.ParseFloat(fields[0], 64)
+		period, errp := strconv.ParseFloat(fields[1], 64)
+		if errq == nil && errp == nil && period > 0 {
+			smp.cpus = quota / period
+		}
+	}
+
+	if smp.mem, err = readUint(c.file("", "memory.current")); err != nil {
+		return sample{}, err
+	}
+	data, err = ioutil.ReadFile(c.file("", "memory.max"))
+	if err != nil && !os.IsNotExist(err) {
+		return sample{}, err
+	}
+	if limit := strings.TrimSpace(string(data)); limit != "max" && len(limit) > 0 {
+		if smp.limit, err = strconv.ParseUint(limit, 10, 64); err != nil {
+			return sample{}, err
+		}
+	}
+	return smp, nil
+}
+
+// readV1 reads the usage in nanoseconds of cpuacct.usage, the quota and period of cpu.cfs_quota_us
+// and cpu.cfs_period_us (-1 without quota), memory.usage_in_bytes and memory.limit_in_bytes
+// (the max of the page counter without limit)
+func (c cgroup) readV1() (sample, error) {
+	nsec, err := readUint(c.file("cpuacct", "cpuacct.usage"))
+	if err != nil {
+		return sample{}, err
+	}
+	smp := sample{cpu: time.Duration(nsec)}
+
+	quota, err := readInt(c.file("cpu", "cpu.cfs_quota_us"))
+	if err == nil && quota > 0 {
+		if period, err := readUint(c.file("cpu", "cpu.cfs_period_us")); err == nil && period > 0 {
+			smp.cpus = float64(quota) / float64(period)
+		}
+	}
+
+	if smp.mem, err = readUint(c.file("memory", "memory.usage_in_bytes")); err != nil {
+		return sample{}, err
+	}
+	if limit, err := readUint(c.file("memory", "memory.limit_in_bytes")); err == nil {
+		smp.limit = limit
+	}
+	if host, err := hostMemory(c.proc); err == nil && (smp.limit == 0 || smp.limit > host) {
+		smp.limit = host // Without limit
+	}
+	return smp, nil
+}
+
+// readInt reads the file that contains an integer
+func readInt(file string) (int64, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
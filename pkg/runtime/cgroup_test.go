@@ -0,0 +1,128 @@
+/*
+ *  Copyright 2019-2022 the original author or authors.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing,
+ *  software  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgroup_Read(t *testing.T) {
+	const host = 8192000 * 1024
+	tests := []struct {
+		name string
+		root string
+		v2   bool
+		smp  sample
+	}{
+		{
+			name: "Cgroup v2",
+			root: "testdata/cgroup2",
+			v2:   true,
+			smp:  sample{cpu: 7500 * time.Millisecond, cpus: 2, mem: 256 << 20, limit: 1 << 30},
+		},
+		{
+			name: "Cgroup v2 without limits",
+			root: "testdata/cgroup2-unlimited",
+			v2:   true,
+			smp:  sample{cpu: 7500 * time.Millisecond, cpus: hostCPUs(), mem: 800 << 20, limit: host},
+		},
+		{
+			name: "Cgroup v1 without memory limit",
+			root: "testdata/cgroup1",
+			smp:  sample{cpu: 7500 * time.Millisecond, cpus: 0.5, mem: 128 << 20, limit: host},
+		},
+	}
+	for _, tt := range tests {
+		c := newCgroup(tt.root, "testdata/proc")
+		assert.Equal(t, tt.v2, c.v2, tt.name)
+		smp, err := c.read()
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.smp, smp, tt.name)
+		}
+	}
+
+	_, err := newCgroup("testdata/none", "testdata/proc").read()
+	assert.Error(t, err, "Without cgroup")
+}
+
+func TestCgroup_Process(t *testing.T) {
+	tests := []struct {
+		name string
+		root string
+		proc string
+		ok   bool
+		dirs map[string]string
+	}{
+		{
+			name: "Root cgroup v1",
+			root: "testdata/cgroup1",
+			proc: "testdata/proc",
+		},
+		{
+			name: "Cgroup v1 of a container",
+			root: "testdata/cgroup1",
+			proc: "testdata/proc-container",
+			ok:   true,
+			dirs: map[string]string{
+				"cpu":     "testdata/cgroup1/cpu",
+				"cpuacct": "testdata/cgroup1/cpuacct",
+				"memory":  "testdata/cgroup1/memory",
+			},
+		},
+		{
+			name: "Root cgroup v2",
+			root: "testdata/cgroup2-root",
+			proc: "testdata/proc-v2-root",
+		},
+		{
+			name: "Cgroup v2",
+			root: "testdata/cgroup2-root",
+			proc: "testdata/proc-v2",
+			ok:   true,
+			dirs: map[string]string{"": "testdata/cgroup2-root/app.slice"},
+		},
+		{
+			name: "Root of a cgroup v2 namespace",
+			root: "testdata/cgroup2",
+			proc: "testdata/proc-v2-root",
+			ok:   true,
+			dirs: map[string]string{"": "testdata/cgroup2"},
+		},
+		{
+			name: "Without cgroup of the process",
+			root: "testdata/cgroup2",
+			proc: "testdata/none",
+		},
+	}
+	for _, tt := range tests {
+		c, ok := newProcessCgroup(tt.root, tt.proc)
+		assert.Equal(t, tt.ok, ok, tt.name)
+		if ok {
+			assert.Equal(t, tt.dirs, c.dirs, tt.name)
+		}
+	}
+}
+
+func TestCgroup_Usage(t *testing.T) {
+	u, err := NewCgroupProvider("testdata/cgroup2", "testdata/proc").Usage()
+	if assert.NoError(t, err) {
+		assert.Equal(t, Usage{Memory: 25}, u)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/carisa/pkg/metrics"
 	"github.com/carisa/pkg/storage"
+	"github.com/carisa/pkg/tracing"
 
 	"github.com/carisa/pkg/logging"
 
@@ -38,6 +40,10 @@ type Config interface {
 type CommonConfig struct {
 	logging.ZapConfig  `json:"log,omitempty"`
 	storage.EtcdConfig `json:"etcd,omitempty"`
+	Tracing            tracing.Config `json:"tracing,omitempty"`
+	Metrics            metrics.Config `json:"metrics,omitempty"`
+	// Version is the version of the configuration deployed. It is reported by the readiness probe
+	Version string `json:"version,omitempty"`
 }
 
 // LoadConfig loads the configuration from environment variable
@@ -56,9 +62,18 @@ func LoadConfig(envConfig string, cnf Config) {
 	}
 }
 
-// StoreWithTimeout creates the timeout context with the value Store.RequestTimeout
-func (c *CommonConfig) StoreWithTimeout() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), time.Duration(c.RequestTimeout)*time.Second)
+// StoreWithTimeout creates the timeout context with the value Store.RequestTimeout.
+// The context derives from the parent, so the cancellation and the trace of the request reach the store
+func (c *CommonConfig) StoreWithTimeout(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, time.Duration(c.RequestTimeout)*time.Second)
+}
+
+// StoreTimeout returns the function that creates the timeout contexts derived from the parent.
+// See StoreWithTimeout
+func (c *CommonConfig) StoreTimeout(parent context.Context) storage.StoreWithTimeout {
+	return func() (context.Context, context.CancelFunc) {
+		return c.StoreWithTimeout(parent)
+	}
 }
 
 func (c *CommonConfig) String() string {
@@ -17,6 +17,7 @@
 package runtime
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -68,7 +69,8 @@ func TestRuntime_LoadConfig(t *testing.T) {
     ], 
     "dialTimeout": 1, 
     "dialKeepAliveTimeout": 3, 
-    "requestTimeout": 4
+    "requestTimeout": 4,
+    "codec": "json"
   }
 }`,
 			cnf: TestConfig{
@@ -84,6 +86,7 @@ func TestRuntime_LoadConfig(t *testing.T) {
 						DialKeepAliveTimeout: 3,
 						RequestTimeout:       4,
 						Endpoints:            []string{"server1", "server2"},
+						Codec:                "json",
 					},
 				},
 			},
@@ -129,9 +132,21 @@ func TestRuntime_LoadConfigPanic(t *testing.T) {
 }
 
 func TestRuntime_StoreWithTimeout(t *testing.T) {
-	c := CommonConfig{}
-	ctx, _ := c.StoreWithTimeout()
+	c := CommonConfig{EtcdConfig: storage.EtcdConfig{RequestTimeout: 10}}
+	type key struct{}
+	parent, cancelp := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+
+	ctx, cancel := c.StoreWithTimeout(parent)
+	defer cancel()
 	assert.NotNil(t, ctx, "Request timeout context")
+	_, ok := ctx.Deadline()
+	assert.True(t, ok, "Deadline")
+	assert.Equal(t, "value", ctx.Value(key{}), "Derived from the parent")
+
+	sctx, scancel := c.StoreTimeout(parent)()
+	defer scancel()
+	cancelp()
+	assert.Error(t, sctx.Err(), "Cancelled by the parent")
 }
 
 func TestRuntime_ConfigToString(t *testing.T) {
@@ -0,0 +1,89 @@
+/*
+ *  Copyright 2019-2022 the original author or authors.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing,
+ *  software  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package runtime
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ProcRoot is the mount point of the proc filesystem
+	ProcRoot = "/proc"
+	// userHZ is the number of clock ticks per second of the times of /proc. It is 100 on all Linux architectures
+	userHZ = 100
+)
+
+// NewProcProvider measures the process through the proc filesystem mounted at root.
+// The CPU is relative to the CPUs of the host and the memory (resident set size) to the memory of the host
+func NewProcProvider(root string) Provider {
+	return newSampler(func() (sample, error) { return readProc(root) })
+}
+
+func readProc(root string) (sample, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, "self", "stat"))
+	if err != nil {
+		return sample{}, err
+	}
+	// The name of the process can contain spaces, so the fields are read after it
+	stat := string(data)
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return sample{}, errors.New("can't read the stat process")
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return sample{}, errors.New("can't read the stat process")
+	}
+	// The fields start at the state (3), utime is 14 and stime is 15
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return sample{}, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return sample{}, err
+	}
+
+	rss, err := readKey(filepath.Join(root, "self", "status"), "VmRSS")
+	if err != nil {
+		return sample{}, err
+	}
+	total, err := hostMemory(root)
+	if err != nil {
+		return sample{}, err
+	}
+	return sample{
+		cpu:   time.Duration(utime+stime) * time.Second / userHZ,
+		cpus:  hostCPUs(),
+		mem:   rss * 1024,
+		limit: total,
+	}, nil
+}
+
+// hostMemory returns the bytes of memory of the host
+func hostMemory(root string) (uint64, error) {
+	total, err := readKey(filepath.Join(root, "meminfo"), "MemTotal")
+	if err != nil {
+		return 0, err
+	}
+	return total * 1024, nil
+}
@@ -18,13 +18,27 @@ package runtime
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestCPU(t *testing.T) {
-	res, err := CPU()
+func TestProc_Read(t *testing.T) {
+	smp, err := readProc("testdata/proc")
 	if assert.NoError(t, err) {
-		assert.Greater(t, res, float64(0))
+		assert.Equal(t, 3*time.Second, smp.cpu, "utime + stime")
+		assert.Equal(t, hostCPUs(), smp.cpus, "CPUs")
+		assert.Equal(t, uint64(20480*1024), smp.mem, "Resident set size")
+		assert.Equal(t, uint64(8192000*1024), smp.limit, "Host memory")
+	}
+
+	_, err = readProc("testdata/none")
+	assert.Error(t, err, "Without proc")
+}
+
+func TestProc_Usage(t *testing.T) {
+	u, err := NewProcProvider("testdata/proc").Usage()
+	if assert.NoError(t, err) {
+		assert.Equal(t, Usage{Memory: 0.25}, u)
 	}
 }
@@ -0,0 +1,158 @@
+/*
+ *  Copyright 2019-2022 the original author or authors.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing,
+ *  software  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	rx "runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	s "github.com/carisa/pkg/strings"
+)
+
+// Usage is the consumption of the process
+type Usage struct {
+	// CPU is the % of the CPUs available to the process used since the previous measure.
+	// The CPUs are the quota of the cgroup or the CPUs of the host
+	CPU float64
+	// Memory is the % of the memory limit used. The limit is the limit of the cgroup or the memory of the host
+	Memory float64
+}
+
+// Provider measures the consumption of the process.
+// The CPU is measured over the interval since the previous call, so the first call returns 0% of CPU
+type Provider interface {
+	Usage() (Usage, error)
+}
+
+// NewProvider returns the provider of the cgroup of the process (/proc/self/cgroup) if it can be read,
+// otherwise returns the provider of /proc. The root cgroup measures the host, so the process into
+// the root cgroup uses the provider of /proc. See NewCgroupProvider and NewProcProvider
+func NewProvider() Provider {
+	return newProvider(CgroupRoot, ProcRoot)
+}
+
+func newProvider(root string, proc string) Provider {
+	if cgroup, ok := newProcessCgroup(root, proc); ok {
+		if _, err := cgroup.read(); err == nil {
+			return newSampler(cgroup.read)
+		}
+	}
+	return NewProcProvider(proc)
+}
+
+// sample is a cumulative measure of the process
+type sample struct {
+	at    time.Time
+	cpu   time.Duration // CPU time used
+	cpus  float64       // CPUs available
+	mem   uint64        // Bytes used
+	limit uint64        // Bytes available
+}
+
+// sampler computes the usage between two samples
+type sampler struct {
+	read func() (sample, error)
+	now  func() time.Time
+
+	mu   sync.Mutex
+	prev sample
+}
+
+func newSampler(read func() (sample, error)) *sampler {
+	return &sampler{read: read, now: time.Now}
+}
+
+// Usage implements Provider.Usage
+func (m *sampler) Usage() (Usage, error) {
+	cur, err := m.read()
+	if err != nil {
+		return Usage{}, err
+	}
+	cur.at = m.now()
+
+	m.mu.Lock()
+	prev := m.prev
+	m.prev = cur
+	m.mu.Unlock()
+	return usage(prev, cur), nil
+}
+
+// usage returns the usage of the CPU between the samples and the usage of the memory of the actual sample
+func usage(prev sample, cur sample) Usage {
+	var u Usage
+	if cur.limit > 0 {
+		u.Memory = percent(float64(cur.mem) / float64(cur.limit))
+	}
+	elapsed := cur.at.Sub(prev.at)
+	if prev.at.IsZero() || elapsed <= 0 || cur.cpus <= 0 || cur.cpu < prev.cpu {
+		return u
+	}
+	u.CPU = percent(float64(cur.cpu-prev.cpu) / (float64(elapsed) * cur.cpus))
+	return u
+}
+
+// percent converts the ratio to % between 0 and 100
+func percent(ratio float64) float64 {
+	switch {
+	case ratio < 0:
+		return 0
+	case ratio > 1:
+		return 100
+	}
+	return 100 * ratio
+}
+
+// hostCPUs returns the number of CPUs of the host
+func hostCPUs() float64 {
+	return float64(rx.NumCPU())
+}
+
+// readUint reads the file that contains an unsigned integer
+func readUint(file string) (uint64, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readKey reads the unsigned integer of the key from a file of lines with the format: key[:] value [unit]
+func readKey(file string, key string) (uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && strings.TrimSuffix(fields[0], ":") == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New(s.Concat("the key ", key, " is not found into ", file))
+}
@@ -0,0 +1,101 @@
+/*
+ *  Copyright 2019-2022 the original author or authors.
+ *
+ *  Licensed under the Apache License, Version 2.0 (the "License");
+ *  you may not use this file except in compliance with the License.
+ *  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *  Unless required by applicable law or agreed to in writing,
+ *  software  distributed under the License is distributed on an "AS IS" BASIS,
+ *  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *  See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package runtime
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage_Sampler(t *testing.T) {
+	now := time.Date(2022, time.January, 10, 10, 0, 0, 0, time.UTC)
+	smp := sample{cpu: 10 * time.Second, cpus: 2, mem: 256, limit: 1024}
+	var err error
+	m := newSampler(func() (sample, error) { return smp, err })
+	m.now = func() time.Time { return now }
+
+	u, e := m.Usage()
+	if assert.NoError(t, e) {
+		assert.Equal(t, Usage{CPU: 0, Memory: 25}, u, "Without previous measure")
+	}
+
+	now = now.Add(10 * time.Second)
+	smp.cpu += 5 * time.Second
+	u, e = m.Usage()
+	if assert.NoError(t, e) {
+		assert.Equal(t, Usage{CPU: 25, Memory: 25}, u, "5s of 2 CPUs in 10s")
+	}
+
+	now = now.Add(time.Second)
+	smp.cpu += 4 * time.Second
+	u, _ = m.Usage()
+	assert.Equal(t, float64(100), u.CPU, "Throttled")
+
+	err = errors.New("read")
+	_, e = m.Usage()
+	assert.Error(t, e, "Error")
+}
+
+func TestUsage_Usage(t *testing.T) {
+	at := time.Date(2022, time.January, 10, 10, 0, 0, 0, time.UTC)
+	prev := sample{at: at, cpu: 10 * time.Second, cpus: 1}
+	tests := []struct {
+		name string
+		cur  sample
+		u    Usage
+	}{
+		{
+			name: "Counter reset",
+			cur:  sample{at: at.Add(time.Second), cpu: time.Second, cpus: 1},
+		},
+		{
+			name: "Without elapsed time",
+			cur:  sample{at: at, cpu: 11 * time.Second, cpus: 1},
+		},
+		{
+			name: "Without limit of memory",
+			cur:  sample{at: at.Add(time.Second), cpu: 10*time.Second + 500*time.Millisecond, cpus: 1, mem: 1024},
+			u:    Usage{CPU: 50},
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.u, usage(prev, tt.cur), tt.name)
+	}
+}
+
+func TestUsage_ProcessCgroup(t *testing.T) {
+	u, err := newProvider("testdata/cgroup2-root", "testdata/proc-v2").Usage()
+	if assert.NoError(t, err) {
+		assert.Equal(t, Usage{Memory: 25}, u, "Cgroup of the process")
+	}
+
+	u, err = newProvider("testdata/cgroup1", "testdata/proc").Usage()
+	if assert.NoError(t, err) {
+		assert.Equal(t, Usage{Memory: 0.25}, u, "The root cgroup measures the host")
+	}
+}
+
+func TestUsage_NewProvider(t *testing.T) {
+	p := NewProvider()
+	u, err := p.Usage()
+	if assert.NoError(t, err) {
+		assert.Greater(t, u.Memory, float64(0), "Memory")
+	}
+}
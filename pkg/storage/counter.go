@@ -0,0 +1,87 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/carisa/pkg/strings"
+)
+
+// CountRetries is the number of attempts to change the counters when other process changes them at the same time.
+// See Count
+const CountRetries = 5
+
+// ErrCountConflict is returned when the counters are changed by other processes in all attempts
+var ErrCountConflict = errors.New("the counters have been changed by other processes")
+
+// Counter counts the entities to limit them. The value is kept into the store as a decimal number
+type Counter struct {
+	Key   string // Key of the counter
+	Name  string // Name of the counted entities
+	Limit int    // Max value of the counter. Zero is unlimited
+}
+
+// Counters gets the counters of the entity contained into the parent
+type Counters func(ctx context.Context, key string, parentKey string) ([]Counter, error)
+
+// LimitError is returned when an entity is created and the counter has reached her limit
+type LimitError struct {
+	Counter Counter
+}
+
+func (e *LimitError) Error() string {
+	return strings.Concat("the limit of ", strconv.Itoa(e.Counter.Limit), " ", e.Counter.Name, " has been reached")
+}
+
+// Count adds to the transaction the change of the counters by delta. The operations are added with DoFound
+// if found is true or else with DoNotFound.
+// The counters are changed with compare-and-swap (see Txn.Match), so if other process changes them
+// the commit returns false and the transaction must be repeated.
+// If the delta is positive and some counter has reached her limit returns LimitError.
+// The counters are never negative, the entities created before the counter existed are not counted
+func Count(ctx context.Context, store CRUD, txn Txn, counters []Counter, delta int, found bool) error {
+	for _, c := range counters {
+		_, value, err := store.GetRaw(ctx, c.Key)
+		if err != nil {
+			return err
+		}
+		n := 0
+		if len(value) > 0 {
+			if n, err = strconv.Atoi(value); err != nil {
+				return err
+			}
+		}
+		if delta > 0 && c.Limit > 0 && n+delta > c.Limit {
+			return &LimitError{Counter: c}
+		}
+		next := n + delta
+		if next < 0 {
+			next = 0
+		}
+		txn.Match(c.Key, value)
+		ope := store.PutRaw(c.Key, strconv.Itoa(next))
+		if found {
+			txn.DoFound(ope)
+		} else {
+			txn.DoNotFound(ope)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCRUDOperation_CreateWithRelCounted(t *testing.T) {
+	const parentKey = "parentKey"
+
+	storef := NewEctdIntegra(t)
+	defer storef.Close()
+	oper := newCountedCRUDOper(storef, func(_ context.Context, key string, parentKey string) ([]Counter, error) {
+		return []Counter{{Key: "counter", Name: "objects", Limit: 2}}, nil
+	})
+	if _, err := oper.Create("loc", storeTimeout, &Object{ID: parentKey}); !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		put     bool
+		created bool
+		limit   bool
+		counter string
+	}{
+		{
+			name:    "Creating.",
+			id:      "key1",
+			created: true,
+			counter: "1",
+		},
+		{
+			name:    "Creating. Entity exists.",
+			id:      "key1",
+			created: false,
+			counter: "1",
+		},
+		{
+			name:    "Creating with put.",
+			id:      "key2",
+			put:     true,
+			created: true,
+			counter: "2",
+		},
+		{
+			name:    "Creating. Limit reached.",
+			id:      "key3",
+			limit:   true,
+			counter: "2",
+		},
+		{
+			name:    "Creating with put. Limit reached.",
+			id:      "key3",
+			put:     true,
+			limit:   true,
+			counter: "2",
+		},
+		{
+			name:    "Updating with put. Limit reached.",
+			id:      "key2",
+			put:     true,
+			counter: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		e := &Object{ID: tt.id, Name: "name", Parent: parentKey}
+		var created, found bool
+		var err error
+		if tt.put {
+			var updated bool
+			updated, found, err = oper.PutWithRel("loc", storeTimeout, e)
+			created = !updated
+		} else {
+			created, found, err = oper.CreateWithRel("loc", storeTimeout, e)
+		}
+		if tt.limit {
+			if assert.Error(t, err, tt.name) {
+				assert.Equal(t, "the limit of 2 objects has been reached", err.Error(), tt.name)
+			}
+		} else if assert.NoError(t, err, tt.name) {
+			assert.True(t, found, tt.name)
+			assert.Equal(t, tt.created, created, tt.name)
+		}
+		_, value, err := oper.Store().GetRaw(context.TODO(), "counter")
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.counter, value, tt.name)
+		}
+	}
+}
+
+func TestCRUDOperation_CreateWithRelCountedError(t *testing.T) {
+	storef := NewEctdIntegra(t)
+	defer storef.Close()
+	oper := newCountedCRUDOper(storef, func(_ context.Context, key string, parentKey string) ([]Counter, error) {
+		return nil, errors.New("counters")
+	})
+
+	_, _, err := oper.CreateWithRel("loc", storeTimeout, &Object{ID: "key", Parent: Virtual})
+	if assert.Error(t, err) {
+		assert.Equal(t, "getting the counters. key: key: counters", err.Error())
+	}
+}
+
+func TestCount(t *testing.T) {
+	storef := NewEctdIntegra(t)
+	defer storef.Close()
+	store := storef.Store()
+	ctx := context.TODO()
+	counters := []Counter{{Key: "counter", Name: "objects"}}
+
+	for _, delta := range []int{-1, 1, 1} {
+		txn := NewTxn(store)
+		txn.Find("none")
+		if !assert.NoError(t, Count(ctx, store, txn, counters, delta, false)) {
+			return
+		}
+		_, err := txn.Commit(ctx)
+		assert.NoError(t, err)
+	}
+	_, value, err := store.GetRaw(ctx, "counter")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2", value, "Never negative")
+	}
+
+	txn := NewTxn(store)
+	txn.Find("none")
+	assert.NoError(t, Count(ctx, store, txn, counters, 1, false), "Counting")
+	_, err = store.(*etcdStore).client.Put(ctx, "counter", "3")
+	if assert.NoError(t, err) {
+		applied, err := txn.Commit(ctx)
+		if assert.NoError(t, err) {
+			assert.False(t, applied, "Counter changed by other process")
+		}
+	}
+
+	txn.Clear()
+	txn.Find("none")
+	_, err = store.(*etcdStore).client.Put(ctx, "counter", "a")
+	if assert.NoError(t, err) {
+		assert.Error(t, Count(ctx, store, txn, counters, 1, false), "Counter not valid")
+	}
+}
+
+func newCountedCRUDOper(storef Integration, counters Counters) CrudOperation {
+	core, _ := observer.New(zap.DebugLevel)
+	log := logging.NewZapWrap(zap.New(core), logging.DebugLevel, "")
+	return NewCountedCrudOperation(storef.Store(), log, NewTxn, counters)
+}
@@ -52,6 +52,7 @@ type CrudOperation interface {
 	// If the entity was created returns true in the first param returned.
 	// If the parent exists into store returns true in the second param returned.
 	// The parent key is gotten using Relation.ParentKey().
+	// The counters of the entity are increased in the same transaction. See Counters
 	CreateWithRel(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error)
 
 	Put(loc string, storeTimeout StoreWithTimeout, entity Entity) (bool, error)
@@ -73,6 +74,7 @@ type CrudOperation interface {
 	// If the entity was found returns true in the first param returned otherwise it is created.
 	// If the parent exists into store returns true in the second param returned.
 	// The parent key is gotten using dlr DLRel.ParentKey().
+	// If the entity is created the counters of the entity are increased in the same transaction. See Counters
 	PutWithRel(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error)
 
 	// LinkTo creates relation that links parent and child.
@@ -92,8 +94,12 @@ type CrudOperation interface {
 		parentID string,
 		fill func(child Entity)) (bool, bool, Entity, error)
 
-	// Return a DLR slice from child identifier
+	// ListDLR returns a DLR slice from child identifier
 	ListDLR(storeTimeout StoreWithTimeout, childID string) ([]Entity, error)
+
+	// Counters gets the counters of the entity contained into the parent.
+	// If the operations don't count the entities returns nil. See NewCountedCrudOperation
+	Counters(ctx context.Context, key string, parentKey string) ([]Counter, error)
 }
 
 // crudOperation defines the CRUD operations
@@ -101,14 +107,24 @@ type crudOperation struct {
 	store    CRUD
 	log      logging.Logger
 	buildTxn BuildTxn
+	counters Counters
+	metrics  *Metrics
 }
 
 // NewCrudOperation builds the Crud operations
 func NewCrudOperation(store CRUD, log logging.Logger, buildTxn BuildTxn) CrudOperation {
+	return NewCountedCrudOperation(store, log, buildTxn, nil)
+}
+
+// NewCountedCrudOperation builds the Crud operations that count the entities created with relation.
+// The counters of each entity are gotten with the counters function
+func NewCountedCrudOperation(store CRUD, log logging.Logger, buildTxn BuildTxn, counters Counters) CrudOperation {
 	return &crudOperation{
 		store:    store,
 		log:      log,
 		buildTxn: buildTxn,
+		counters: counters,
+		metrics:  metricsOf(store),
 	}
 }
 
@@ -119,11 +135,23 @@ func (c *crudOperation) Store() CRUD {
 
 // Create implements CrudOperation.Put
 func (c *crudOperation) Create(loc string, storeTimeout StoreWithTimeout, entity Entity) (bool, error) {
-	return c.create(loc, storeTimeout, entity, false)
+	storeTimeout, span := trace(loc, "create", entity.Key(), storeTimeout)
+	created, err := c.create(loc, storeTimeout, entity, false, nil)
+	end(span, err)
+	c.metrics.create(loc, created)
+	return created, err
 }
 
 // CreateWithRel implements CrudOperation.CreateWithRel
 func (c *crudOperation) CreateWithRel(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error) {
+	storeTimeout, span := trace(loc, "createWithRel", entity.Key(), storeTimeout)
+	created, found, err := c.createWithRel(loc, storeTimeout, entity)
+	end(span, err)
+	c.metrics.create(loc, created)
+	return created, found, err
+}
+
+func (c *crudOperation) createWithRel(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error) {
 	found, err := c.existsParent(loc, storeTimeout, entity)
 	if err != nil {
 		return false, false, err
@@ -131,15 +159,60 @@ func (c *crudOperation) CreateWithRel(loc string, storeTimeout StoreWithTimeout,
 	if !found {
 		return false, false, nil
 	}
-	created, err := c.create(loc, storeTimeout, entity, true)
+	created, _, err := c.createCounted(loc, storeTimeout, entity)
 	return created, true, err
 }
 
-// create creates the entity and if the relation exists entity also is created
-func (c *crudOperation) create(loc string, storeTimeout StoreWithTimeout, entity Entity, isRel bool) (bool, error) {
+// createCounted creates the entity with relation and increases her counters in the same transaction.
+// If other process changes the counters the creation is repeated.
+// If the entity exists returns true in the second param returned
+func (c *crudOperation) createCounted(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error) {
+	ctx, cancel := storeTimeout()
+	counters, err := c.Counters(ctx, entity.Key(), entity.ParentKey())
+	cancel()
+	if err != nil {
+		return false, false, c.log.ErrWrap1(err, "getting the counters", loc, logging.String("key", entity.Key()))
+	}
+	if len(counters) == 0 {
+		created, err := c.create(loc, storeTimeout, entity, true, nil)
+		return created, !created, err
+	}
+	for i := 0; i < CountRetries; i++ {
+		created, err := c.create(loc, storeTimeout, entity, true, counters)
+		if err != nil || created {
+			return created, false, err
+		}
+		found, err := c.exists(loc, storeTimeout, entity.Key())
+		if err != nil || found {
+			return false, found, err
+		}
+	}
+	return false, false, c.log.ErrWrap1(ErrCountConflict, "creating", loc, logging.String("key", entity.Key()))
+}
+
+// create creates the entity and if the relation exists entity also is created.
+// The counters are increased in the same transaction. See Count
+func (c *crudOperation) create(
+	loc string,
+	storeTimeout StoreWithTimeout,
+	entity Entity,
+	isRel bool,
+	counters []Counter) (bool, error) {
 	txn := c.buildTxn(c.store)
 	txn.Find(entity.Key())
 
+	if len(counters) > 0 {
+		ctx, cancel := storeTimeout()
+		err := Count(ctx, c.store, txn, counters, 1, false)
+		cancel()
+		if _, ok := err.(*LimitError); ok {
+			return false, err
+		}
+		if err != nil {
+			return false, c.log.ErrWrap(err, "counting", loc)
+		}
+	}
+
 	create, err := c.store.Put(entity)
 	if err != nil {
 		return false, c.log.ErrWrap(err, "creating", loc)
@@ -166,17 +239,36 @@ func (c *crudOperation) create(loc string, storeTimeout StoreWithTimeout, entity
 
 // Put implements CrudOperation.Put
 func (c *crudOperation) Put(loc string, storeTimeout StoreWithTimeout, entity Entity) (bool, error) {
+	storeTimeout, span := trace(loc, "put", entity.Key(), storeTimeout)
 	updated, _, err := c.put(loc, storeTimeout, entity, false)
+	end(span, err)
+	c.metrics.create(loc, err == nil && !updated)
 	return updated, err
 }
 
 // PutWithRel implements CrudOperation.PutWithRel
 func (c *crudOperation) PutWithRel(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error) {
-	return c.put(loc, storeTimeout, entity, true)
+	storeTimeout, span := trace(loc, "putWithRel", entity.Key(), storeTimeout)
+	updated, found, err := c.put(loc, storeTimeout, entity, true)
+	end(span, err)
+	c.metrics.create(loc, err == nil && found && !updated)
+	return updated, found, err
 }
 
 // Update implements CrudOperation.Update
 func (c *crudOperation) Update(
+	loc string,
+	storeTimeout StoreWithTimeout,
+	entity Entity,
+	upd func(entity Entity)) (bool, error) {
+	//
+	storeTimeout, span := trace(loc, "update", entity.Key(), storeTimeout)
+	updated, err := c.update(loc, storeTimeout, entity, upd)
+	end(span, err)
+	return updated, err
+}
+
+func (c *crudOperation) update(
 	loc string,
 	storeTimeout StoreWithTimeout,
 	entity Entity,
@@ -195,11 +287,27 @@ func (c *crudOperation) Update(
 	// Update the fields
 	upd(entity)
 
-	return c.Put(loc, storeTimeout, entity)
+	updated, _, err := c.put(loc, storeTimeout, entity, false)
+	return updated, err
 }
 
 // ConnectTo implements CrudOperation.ConnectTo
 func (c *crudOperation) LinkTo(
+	loc string,
+	storeTimeout StoreWithTimeout,
+	txn Txn,
+	child EntityRelation,
+	parentID string,
+	fill func(child Entity)) (bool, bool, Entity, error) {
+	//
+	storeTimeout, span := trace(loc, "linkTo", child.Key(), storeTimeout)
+	cfound, pfound, link, err := c.linkTo(loc, storeTimeout, txn, child, parentID, fill)
+	end(span, err)
+	c.metrics.link(loc, err == nil && cfound && pfound)
+	return cfound, pfound, link, err
+}
+
+func (c *crudOperation) linkTo(
 	loc string,
 	storeTimeout StoreWithTimeout,
 	txn Txn,
@@ -251,13 +359,21 @@ func (c *crudOperation) LinkTo(
 
 func (c *crudOperation) ListDLR(storeTimeout StoreWithTimeout, childID string) ([]Entity, error) {
 	ctx, cancel := storeTimeout()
-	es, err := c.store.Range(ctx, strings.Concat(childID, dlrSep), childID, 0, func() Entity {
+	es, err := c.store.StartKey(ctx, DLRPrefix(childID), 0, func() Entity {
 		return &DLRel{}
 	})
 	cancel()
 	return es, err
 }
 
+// Counters implements CrudOperation.Counters
+func (c *crudOperation) Counters(ctx context.Context, key string, parentKey string) ([]Counter, error) {
+	if c.counters == nil {
+		return nil, nil
+	}
+	return c.counters(ctx, key, parentKey)
+}
+
 func (c *crudOperation) exists(loc string, storeTimeout StoreWithTimeout, id string) (bool, error) {
 	ctx, cancel := storeTimeout()
 	found, err := c.store.Exists(ctx, id)
@@ -286,6 +402,9 @@ func (c *crudOperation) put(loc string, storeTimeout StoreWithTimeout, entity En
 			if !found { // The parent must exist
 				return false, false, nil
 			}
+			if c.counters != nil {
+				return c.putCounted(loc, storeTimeout, entity.(EntityRelation))
+			}
 		}
 	}
 
@@ -318,6 +437,19 @@ func (c *crudOperation) put(loc string, storeTimeout StoreWithTimeout, entity En
 	return updated, true, nil
 }
 
+// putCounted creates the entity with her counters. If other process has created the entity at the same time
+// the entity is updated
+func (c *crudOperation) putCounted(loc string, storeTimeout StoreWithTimeout, entity EntityRelation) (bool, bool, error) {
+	_, found, err := c.createCounted(loc, storeTimeout, entity)
+	if err != nil {
+		return false, true, err
+	}
+	if found {
+		return c.put(loc, storeTimeout, entity, true)
+	}
+	return false, true, nil
+}
+
 // createRel creates the relation between the parent and child and adds a doubly linked relation from child to the relation
 // and to the parent
 func (c *crudOperation) createRel(loc string, txn Txn, relation EntityRelation) error {
@@ -362,10 +494,9 @@ func (c *crudOperation) updateRel(storeTimeout StoreWithTimeout, loc string, ent
 	if len(name) != 0 && name != oldEntity.RelName() {
 		// it removes old relation and creating new relation when change Name. Name is part of key
 		ctx, cancel := storeTimeout()
-		dlrs, err := c.store.Range(
+		dlrs, err := c.store.StartKey(
 			ctx,
-			strings.Concat(entity.Key(), dlrSep),
-			entity.Key(),
+			DLRPrefix(entity.Key()),
 			0,
 			func() Entity { return &DLRel{} })
 		cancel()
@@ -437,3 +568,9 @@ func (r *DLRel) Key() string {
 func DLRKey(childID string, parentID string) string {
 	return strings.Concat(childID, dlrSep, parentID)
 }
+
+// DLRPrefix gets the prefix of all DLR keys of the child.
+// The links of the child start by the child key too, so the DLRs must be searched with this prefix
+func DLRPrefix(childID string) string {
+	return strings.Concat(childID, dlrSep)
+}
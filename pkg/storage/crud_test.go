@@ -714,8 +714,16 @@ func TestCRUDOperation_ListDLR(t *testing.T) {
 		}
 	}
 
+	// The links of the child are not DLR
+	_, err := oper.Create("loc", storeTimeout, &Link{ID: strings.Concat(childID, "name"), Name: "name"})
+	if err != nil {
+		assert.NoError(t, err, "Creating link")
+		return
+	}
+
 	dlrs, err := oper.ListDLR(storeTimeout, childID)
 	if assert.NoError(t, err, "Listing DLR") {
+		assert.Equal(t, len(dlrTest), len(dlrs), "DLR length")
 		for i, dlr := range dlrs {
 			assert.Equal(t, &dlrTest[i], dlr)
 		}
@@ -0,0 +1,272 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	strs "strings"
+	"sync"
+	"time"
+
+	"github.com/carisa/pkg/strings"
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/clientv3/concurrency"
+)
+
+const (
+	// ElectionPrefix is the prefix of the keys of the candidates of the elections
+	ElectionPrefix = "election#"
+	// LockPrefix is the prefix of the keys of the candidates of the locks
+	LockPrefix = "lock#"
+)
+
+var (
+	// ErrNoLeader is returned when the election has no leader
+	ErrNoLeader = errors.New("the election has no leader")
+	// ErrStoreType is returned when the elections can not be built on the type of the store
+	ErrStoreType = errors.New("the elections are not defined for the store type")
+)
+
+// Leadership is won by a campaign or by a lock
+type Leadership struct {
+	// Token is the fencing token. The tokens of the successive leaders of an election always increase,
+	// so the resources can reject the requests of an old leader that does not know that it lost the leadership
+	Token int64
+	// Done is closed when the leadership is lost because the session of the process expired
+	Done <-chan struct{}
+	// Key is the key of the leader into the store of the elections and Value is its value.
+	// The leaderships into the process and the locks have not key. See Fence
+	Key   string
+	Value string
+}
+
+// Fence adds to the transaction the condition that the key of the leader has not changed, so the transaction
+// is not applied if the leadership is lost meanwhile. The transaction must be built on the store of the elections.
+// The leaderships without key are not checked. See Txn.Match
+func (l *Leadership) Fence(txn Txn) {
+	if len(l.Key) > 0 {
+		txn.Match(l.Key, l.Value)
+	}
+}
+
+// Election elects a single leader among the candidates that campaign
+type Election interface {
+	// Campaign blocks until the candidate is elected or the context is done.
+	// The value identifies the leader, see Leader
+	Campaign(ctx context.Context, value string) (Leadership, error)
+	// Resign gives up the leadership, so other candidate can be elected. It does nothing if it is not the leader
+	Resign(ctx context.Context) error
+	// Leader returns the value of the actual leader or ErrNoLeader
+	Leader(ctx context.Context) (string, error)
+	// Observe returns the values of the successive leaders until the context is done
+	Observe(ctx context.Context) (<-chan string, error)
+}
+
+// Lock is a distributed lock
+type Lock interface {
+	// Lock blocks until the lock is acquired or the context is done
+	Lock(ctx context.Context) (Leadership, error)
+	// Unlock releases the lock. It does nothing if the lock is not acquired
+	Unlock(ctx context.Context) error
+}
+
+// Elections builds the elections and the locks of a process. The leaderships of the process live
+// while the session of the process is alive, so they are lost if the process dies or it is not reachable.
+// The candidates of the same Elections share the session in the distributed stores,
+// so the process must campaign once by election
+type Elections interface {
+	// Election returns a candidate to the election
+	Election(name string) Election
+	// Lock returns a candidate to the lock
+	Lock(name string) Lock
+	// Close resigns all leaderships and ends the session
+	Close() error
+}
+
+// NewElections builds the elections depending of the store. The ttl is the time the leaderships survive
+// after the process dies. It returns error if the store type is not defined, the elections into the process
+// must be requested with NewLocalElections
+func NewElections(store CRUD, ttl time.Duration) (Elections, error) {
+	return newElections(store, ttl, "")
+}
+
+func newElections(store CRUD, ttl time.Duration, prefix string) (Elections, error) {
+	switch s := store.(type) {
+	case *etcdStore:
+		return newEtcdElections(s.client, ttl, prefix), nil
+	case *namespaceStore:
+		return newElections(s.store, ttl, strings.Concat(s.prefix, prefix))
+	case *instrumentedStore:
+		return newElections(s.store, ttl, prefix)
+	case *ttlStore:
+		return newElections(s.CRUD, ttl, prefix)
+	default:
+		return nil, ErrStoreType
+	}
+}
+
+// etcdElections implements the elections with the concurrency package of etcd.
+// The session is a lease kept alive by etcd client and it is created again if it expires
+type etcdElections struct {
+	client *clientv3.Client
+	ttl    int
+	prefix string
+
+	mu      sync.Mutex
+	session *concurrency.Session
+}
+
+func newEtcdElections(client *clientv3.Client, ttl time.Duration, prefix string) *etcdElections {
+	secs := int((ttl + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return &etcdElections{client: client, ttl: secs, prefix: prefix}
+}
+
+// Election implements Elections.Election
+func (e *etcdElections) Election(name string) Election {
+	return &etcdElection{elections: e, pfx: strings.Concat(e.prefix, ElectionPrefix, name)}
+}
+
+// Lock implements Elections.Lock. The lock is an election whose leader is the owner of the lock
+func (e *etcdElections) Lock(name string) Lock {
+	return &electionLock{election: &etcdElection{elections: e, pfx: strings.Concat(e.prefix, LockPrefix, name)}}
+}
+
+// Close implements Elections.Close. The revocation of the session removes the keys of the candidates
+func (e *etcdElections) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return nil
+	}
+	err := e.session.Close()
+	e.session = nil
+	return err
+}
+
+// get returns the session or creates it if it does not exist or it expired
+func (e *etcdElections) get() (*concurrency.Session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session != nil {
+		select {
+		case <-e.session.Done():
+		default:
+			return e.session, nil
+		}
+	}
+	s, err := concurrency.NewSession(e.client, concurrency.WithTTL(e.ttl))
+	if err != nil {
+		return nil, err
+	}
+	e.session = s
+	return s, nil
+}
+
+type etcdElection struct {
+	elections *etcdElections
+	pfx       string
+
+	mu      sync.Mutex
+	elected *concurrency.Election
+}
+
+// Campaign implements Election.Campaign. The fencing token is the revision of the key of the leader
+func (e *etcdElection) Campaign(ctx context.Context, value string) (Leadership, error) {
+	s, err := e.elections.get()
+	if err != nil {
+		return Leadership{}, err
+	}
+	el := concurrency.NewElection(s, e.pfx)
+	if err := el.Campaign(ctx, value); err != nil {
+		return Leadership{}, err
+	}
+	e.mu.Lock()
+	e.elected = el
+	e.mu.Unlock()
+	l := Leadership{Token: el.Rev(), Done: s.Done()}
+	if len(value) > 0 { // The empty value can not be compared, see Txn.Match
+		l.Key, l.Value = strs.TrimPrefix(el.Key(), e.elections.prefix), value
+	}
+	return l, nil
+}
+
+// Resign implements Election.Resign
+func (e *etcdElection) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	el := e.elected
+	e.elected = nil
+	e.mu.Unlock()
+	if el == nil {
+		return nil
+	}
+	return el.Resign(ctx)
+}
+
+// Leader implements Election.Leader. The leader is the oldest candidate
+func (e *etcdElection) Leader(ctx context.Context) (string, error) {
+	resp, err := e.elections.client.Get(ctx, strings.Concat(e.pfx, "/"), clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Kvs) == 0 {
+		return "", ErrNoLeader
+	}
+	return string(resp.Kvs[0].Value), nil
+}
+
+// Observe implements Election.Observe
+func (e *etcdElection) Observe(ctx context.Context) (<-chan string, error) {
+	s, err := e.elections.get()
+	if err != nil {
+		return nil, err
+	}
+	resps := concurrency.NewElection(s, e.pfx).Observe(ctx)
+	leaders := make(chan string)
+	go func() {
+		defer close(leaders)
+		for resp := range resps {
+			if len(resp.Kvs) == 0 {
+				continue
+			}
+			select {
+			case leaders <- string(resp.Kvs[0].Value):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return leaders, nil
+}
+
+// electionLock implements a lock through an election without value
+type electionLock struct {
+	election Election
+}
+
+// Lock implements Lock.Lock
+func (l *electionLock) Lock(ctx context.Context) (Leadership, error) {
+	return l.election.Campaign(ctx, "")
+}
+
+// Unlock implements Lock.Unlock
+func (l *electionLock) Unlock(ctx context.Context) error {
+	return l.election.Resign(ctx)
+}
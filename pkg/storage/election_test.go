@@ -0,0 +1,231 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElection_Etcd(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	p1, err := NewElections(i.Store(), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer p1.Close()
+	p2, err := NewElections(i.Store(), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer p2.Close()
+
+	testElection(t, p1.Election("job"), p2.Election("job"))
+	testLock(t, p1.Lock("job"), p2.Lock("job"))
+}
+
+func TestElection_Local(t *testing.T) {
+	e := NewLocalElections()
+	testElection(t, e.Election("job"), e.Election("job"))
+	testLock(t, e.Lock("job"), e.Lock("job"))
+}
+
+func TestElection_Namespace(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	acme, err := NewElections(NewInstrumented(NewNamespace(root, "acme"), nil), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer acme.Close()
+	corp, err := NewElections(NewNamespace(root, "corp"), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer corp.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Second)
+	defer cancel()
+	_, err = acme.Election("job").Campaign(ctx, "acme")
+	assert.NoError(t, err, "Leader of acme")
+	_, err = corp.Election("job").Campaign(ctx, "corp")
+	assert.NoError(t, err, "Leader of corp")
+	leader, err := corp.Election("job").Leader(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "corp", leader, "Elections isolated by namespace")
+	}
+}
+
+func TestElection_Fence(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	store := NewNamespace(i.Store(), "acme")
+	elections, err := NewElections(store, 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer elections.Close()
+	ctx := context.TODO()
+
+	election := elections.Election("job")
+	l, err := election.Campaign(ctx, "p1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, l.Key, "Key of the leader")
+	put := func(key string) bool {
+		txn := NewTxn(store)
+		l.Fence(txn)
+		txn.DoFound(store.PutRaw(key, "value"))
+		ok, err := txn.Commit(ctx)
+		assert.NoError(t, err)
+		return ok
+	}
+	assert.True(t, put("k1"), "Leader")
+	if assert.NoError(t, election.Resign(ctx)) {
+		assert.False(t, put("k2"), "Leadership lost")
+	}
+
+	local, err := NewLocalElections().Election("job").Campaign(ctx, "p1")
+	if assert.NoError(t, err) {
+		assert.Empty(t, local.Key, "Local without key")
+	}
+}
+
+func TestElection_Close(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	p1, err := NewElections(i.Store(), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	p2, err := NewElections(i.Store(), 5*time.Second)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer p2.Close()
+	ctx := context.TODO()
+
+	if _, err := p1.Election("job").Campaign(ctx, "p1"); !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, p1.Close())
+	_, err = p2.Election("job").Leader(ctx)
+	assert.Equal(t, ErrNoLeader, err, "The session is closed")
+
+	local := NewLocalElections()
+	l, err := local.Election("job").Campaign(ctx, "p1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, local.Close())
+	select {
+	case <-l.Done:
+	default:
+		assert.Fail(t, "The leadership is lost")
+	}
+	_, err = local.Election("job").Leader(ctx)
+	assert.Equal(t, ErrNoLeader, err, "Local closed")
+}
+
+func TestElection_NewElections(t *testing.T) {
+	_, err := NewElections(&ErrMockCRUD{}, time.Second)
+	assert.Equal(t, ErrStoreType, err, "Store type not defined")
+}
+
+func testElection(t *testing.T, a Election, b Election) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	_, err := a.Leader(ctx)
+	assert.Equal(t, ErrNoLeader, err, "Without leader")
+	observed, err := b.Observe(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	la, err := a.Campaign(ctx, "a")
+	if !assert.NoError(t, err) {
+		return
+	}
+	leader, err := b.Leader(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a", leader, "Leader")
+	}
+	assert.Equal(t, "a", receive(t, observed), "Observed")
+
+	tctx, tcancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	_, err = b.Campaign(tctx, "b")
+	tcancel()
+	assert.Error(t, err, "Only a leader")
+
+	elected := make(chan Leadership)
+	go func() {
+		lb, err := b.Campaign(ctx, "b")
+		assert.NoError(t, err)
+		elected <- lb
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, a.Resign(ctx), "Resign")
+	assert.NoError(t, a.Resign(ctx), "Resign without leadership")
+	lb := <-elected
+	assert.Greater(t, lb.Token, la.Token, "Fencing token")
+	leader, err = a.Leader(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "b", leader, "New leader")
+	}
+	assert.Equal(t, "b", receive(t, observed), "Observed")
+
+	assert.NoError(t, b.Resign(ctx))
+	_, err = a.Leader(ctx)
+	assert.Equal(t, ErrNoLeader, err, "Resigned")
+}
+
+func testLock(t *testing.T, a Lock, b Lock) {
+	ctx := context.TODO()
+	la, err := a.Lock(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	_, err = b.Lock(tctx)
+	cancel()
+	assert.Error(t, err, "Locked")
+
+	assert.NoError(t, a.Unlock(ctx))
+	lb, err := b.Lock(ctx)
+	if assert.NoError(t, err) {
+		assert.Greater(t, lb.Token, la.Token, "Fencing token")
+	}
+	assert.NoError(t, b.Unlock(ctx))
+}
+
+func receive(t *testing.T, leaders <-chan string) string {
+	select {
+	case leader := <-leaders:
+		return leader
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "Leader not observed")
+		return ""
+	}
+}
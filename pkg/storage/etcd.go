@@ -18,7 +18,6 @@ package storage
 
 import (
 	"context"
-	"fmt"
 	strs "strings"
 	"testing"
 	"time"
@@ -34,10 +33,9 @@ import (
 	"github.com/carisa/pkg/encoding"
 	"github.com/pkg/errors"
 	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 )
 
-const operTrans = 4
-
 // EtcdConfig defines the configuration for store framework
 type EtcdConfig struct {
 	// DialTimeout is the timeout for failing to establish a connection in seconds. Common value: 2 seconds.
@@ -52,6 +50,9 @@ type EtcdConfig struct {
 	RequestTimeout uint8 `json:"requestTimeout,omitempty"`
 	// Endpoints is a startKey of URLs.
 	Endpoints []string `json:"endpoints,omitempty"`
+	// Codec is the name of the codec used to encode the values: gob, json or binary. Default value: gob.
+	// The values stored with other codec are decoded using the tag of the value
+	Codec string `json:"codec,omitempty"`
 }
 
 // String converts endpoint startKey to string
@@ -65,23 +66,37 @@ func (c *EtcdConfig) EPSString() string {
 	return b.String()
 }
 
+// operTrans is the initial capacity of the operations of a transaction.
+// Most of the transactions don't need more operations, so the slices don't grow
+const operTrans = 4
+
 // etcdStore defines the CRUD operations for etcd
 type etcdStore struct {
 	client *clientv3.Client
+	codec  encoding.Codec
 }
 
-// NewEtcd builds a store to CRUD operations from client
+// NewEtcd builds a store to CRUD operations from client. The values are encoded with gob
 func NewEtcd(client *clientv3.Client) CRUD {
-	return &etcdStore{client}
+	return NewEtcdCodec(client, encoding.Gob())
+}
+
+// NewEtcdCodec builds a store to CRUD operations from client. The values are encoded with the codec
+func NewEtcdCodec(client *clientv3.Client, codec encoding.Codec) CRUD {
+	return &etcdStore{client: client, codec: codec}
 }
 
 // NewEtcdConfig builds a store to CRUD operations based on etcd3 from config
 func NewEtcdConfig(cnf EtcdConfig) CRUD {
+	codec, err := encoding.ByName(cnf.Codec)
+	if err != nil {
+		panic(strings.Concat("Error configuring etcd codec: ", err.Error()))
+	}
 	client, err := clientv3.New(config(cnf))
 	if err != nil {
 		panic(strings.Concat("Error creating etcd client: ", err.Error()))
 	}
-	return &etcdStore{client: client}
+	return NewEtcdCodec(client, codec)
 }
 
 // Done for test
@@ -115,6 +130,7 @@ func config(cnf EtcdConfig) clientv3.Config {
 
 	dialOptions := []grpc.DialOption{
 		grpc.WithBlock(), // block until the underlying connection is up
+		grpc.WithChainUnaryInterceptor(traceInterceptor),
 	}
 	return clientv3.Config{
 		DialTimeout:          dialTimeout,
@@ -127,7 +143,7 @@ func config(cnf EtcdConfig) clientv3.Config {
 
 // Put implements CRUD.Put
 func (s *etcdStore) Put(entity Entity) (OpeWrap, error) {
-	encode, err := encoding.Encode(entity)
+	encode, err := encoding.EncodeWith(s.codec, entity)
 	if err != nil {
 		return OpeWrap{},
 			errors.Wrap(
@@ -136,17 +152,55 @@ func (s *etcdStore) Put(entity Entity) (OpeWrap, error) {
 					logging.String("Entity", entity.ToString())))
 	}
 
-	return OpeWrap{clientv3.OpPut(entity.Key(), encode)}, err
+	return OpeWrap{opeEtcd: clientv3.OpPut(entity.Key(), encode)}, err
 }
 
 // PutRaw implements CRUD.PutRaw
 func (s *etcdStore) PutRaw(key string, value string) OpeWrap {
-	return OpeWrap{clientv3.OpPut(key, value)}
+	return OpeWrap{opeEtcd: clientv3.OpPut(key, value)}
+}
+
+// PutRawLease implements CRUD.PutRawLease
+func (s *etcdStore) PutRawLease(key string, value string, id LeaseID) OpeWrap {
+	return OpeWrap{opeEtcd: clientv3.OpPut(key, value, clientv3.WithLease(clientv3.LeaseID(id))), lease: id}
 }
 
 // Remove implements CRUD.Remove
 func (s *etcdStore) Remove(key string) OpeWrap {
-	return OpeWrap{clientv3.OpDelete(key)}
+	return OpeWrap{opeEtcd: clientv3.OpDelete(key)}
+}
+
+// Grant implements CRUD.Grant. The ttl of etcd is into seconds, so it is rounded up
+func (s *etcdStore) Grant(ctx context.Context, ttl time.Duration) (LeaseID, error) {
+	secs := int64((ttl + time.Second - 1) / time.Second)
+	res, err := s.client.Grant(ctx, secs)
+	if err != nil {
+		return 0, errors.Wrap(err, "unexpected error granting lease into etcd store")
+	}
+	return LeaseID(res.ID), nil
+}
+
+// KeepAlive implements CRUD.KeepAlive
+func (s *etcdStore) KeepAlive(ctx context.Context, id LeaseID) error {
+	if _, err := s.client.KeepAliveOnce(ctx, clientv3.LeaseID(id)); err != nil {
+		return leaseErr(err, "unexpected error keeping alive lease into etcd store")
+	}
+	return nil
+}
+
+// Revoke implements CRUD.Revoke
+func (s *etcdStore) Revoke(ctx context.Context, id LeaseID) error {
+	if _, err := s.client.Revoke(ctx, clientv3.LeaseID(id)); err != nil {
+		return leaseErr(err, "unexpected error revoking lease into etcd store")
+	}
+	return nil
+}
+
+func leaseErr(err error, msg string) error {
+	if err == rpctypes.ErrLeaseNotFound {
+		return ErrLeaseNotFound
+	}
+	return errors.Wrap(err, msg)
 }
 
 // Get implements CRUD.Get
@@ -283,11 +337,18 @@ func (s *etcdStore) Close() error {
 // etcdStore defines the operations of a transaction
 type etcdTxn struct {
 	client     *clientv3.Client
-	opeFound   [operTrans]OpeWrap // Could use make but this avoids escape to heap
-	opeNoFound [operTrans]OpeWrap
-	indexF     uint8
-	indexNf    uint8
+	opeFound   []clientv3.Op
+	opeNoFound []clientv3.Op
 	keyValue   string
+	matches    []clientv3.Cmp
+}
+
+func newEtcdTxn(client *clientv3.Client) *etcdTxn {
+	return &etcdTxn{
+		client:     client,
+		opeFound:   make([]clientv3.Op, 0, operTrans),
+		opeNoFound: make([]clientv3.Op, 0, operTrans),
+	}
 }
 
 // Exists implements Txn.Find
@@ -295,59 +356,49 @@ func (txn *etcdTxn) Find(keyValue string) {
 	txn.keyValue = keyValue
 }
 
+// Match implements Txn.Match.
+// The value of a key that doesn't exist can not be compared, so the empty value is compared as key not found
+func (txn *etcdTxn) Match(key string, value string) {
+	if len(value) == 0 {
+		txn.matches = append(txn.matches, clientv3.Compare(clientv3.ModRevision(key), "=", 0))
+		return
+	}
+	txn.matches = append(txn.matches, clientv3.Compare(clientv3.Value(key), "=", value))
+}
+
 // DoFound implements Txn.DoFound
 func (txn *etcdTxn) DoFound(ope OpeWrap) {
-	if txn.indexF > operTrans-1 {
-		panic(fmt.Sprintf("the transaction cannot have more than %v operations for found", operTrans))
-	}
-	txn.opeFound[txn.indexF] = ope
-	txn.indexF++
+	txn.opeFound = append(txn.opeFound, ope.opeEtcd)
 }
 
 // DoNotFound implements Txn.DoNotFound
 func (txn *etcdTxn) DoNotFound(ope OpeWrap) {
-	if txn.indexNf > operTrans-1 {
-		panic(fmt.Sprintf("the transaction cannot have more than %v operations for found", operTrans))
-	}
-	txn.opeNoFound[txn.indexNf] = ope
-	txn.indexNf++
+	txn.opeNoFound = append(txn.opeNoFound, ope.opeEtcd)
 }
 
 // Commit implements Txn.Commit
 func (txn *etcdTxn) Commit(ctx context.Context) (bool, error) {
-	if txn.indexF == 0 && txn.indexNf == 0 {
+	if len(txn.opeFound) == 0 && len(txn.opeNoFound) == 0 {
 		panic("commit. there isn't condition")
 	}
-	if len(txn.keyValue) == 0 {
+	if len(txn.keyValue) == 0 && len(txn.matches) == 0 {
 		panic("commit. the key to find can not be empty")
 	}
 
 	tx := txn.client.KV.Txn(ctx)
 
-	if txn.indexF > 0 && txn.indexNf > 0 {
-		tx = txn.ifThen(tx, ">", txn.opeFound, txn.indexF)
-		switch txn.indexNf {
-		case 1:
-			tx = tx.Else(txn.opeNoFound[0].opeEtcd)
-		case 2:
-			tx = tx.Else(txn.opeNoFound[0].opeEtcd, txn.opeNoFound[1].opeEtcd)
-		case 3:
-			tx = tx.Else(txn.opeNoFound[0].opeEtcd, txn.opeNoFound[1].opeEtcd, txn.opeNoFound[2].opeEtcd)
-		case 4:
-			tx = tx.Else(
-				txn.opeNoFound[0].opeEtcd,
-				txn.opeNoFound[1].opeEtcd,
-				txn.opeNoFound[2].opeEtcd,
-				txn.opeNoFound[3].opeEtcd)
-		}
+	if len(txn.matches) > 0 {
+		tx = txn.ifMatch(tx)
+	} else if len(txn.opeFound) > 0 && len(txn.opeNoFound) > 0 {
+		tx = txn.ifThen(tx, ">", txn.opeFound).Else(txn.opeNoFound...)
 	} else {
-		if txn.indexF > 0 {
+		if len(txn.opeFound) > 0 {
 			// > 0 means that the key has been found
-			tx = txn.ifThen(tx, ">", txn.opeFound, txn.indexF)
+			tx = txn.ifThen(tx, ">", txn.opeFound)
 		}
-		if txn.indexNf > 0 {
+		if len(txn.opeNoFound) > 0 {
 			// = 0 means that the key has not been found
-			tx = txn.ifThen(tx, "=", txn.opeNoFound, txn.indexNf)
+			tx = txn.ifThen(tx, "=", txn.opeNoFound)
 		}
 	}
 
@@ -359,24 +410,29 @@ func (txn *etcdTxn) Commit(ctx context.Context) (bool, error) {
 	return result.Succeeded, nil
 }
 
-func (txn *etcdTxn) ifThen(tx clientv3.Txn, compare string, opes [operTrans]OpeWrap, index uint8) clientv3.Txn {
-	tx = tx.If(clientv3.Compare(clientv3.ModRevision(txn.keyValue), compare, 0))
-	switch index {
-	case 1:
-		return tx.Then(opes[0].opeEtcd)
-	case 2:
-		return tx.Then(opes[0].opeEtcd, opes[1].opeEtcd)
-	case 3:
-		return tx.Then(opes[0].opeEtcd, opes[1].opeEtcd, opes[2].opeEtcd)
-	default:
-		return tx.Then(opes[0].opeEtcd, opes[1].opeEtcd, opes[2].opeEtcd, opes[3].opeEtcd)
+func (txn *etcdTxn) ifThen(tx clientv3.Txn, compare string, opes []clientv3.Op) clientv3.Txn {
+	return tx.If(clientv3.Compare(clientv3.ModRevision(txn.keyValue), compare, 0)).Then(opes...)
+}
+
+// ifMatch adds the conditions of the matches. If the key to find exists it is added to the conditions
+func (txn *etcdTxn) ifMatch(tx clientv3.Txn) clientv3.Txn {
+	if len(txn.keyValue) == 0 {
+		return tx.If(txn.matches...).Then(txn.opeFound...).Else(txn.opeNoFound...)
+	}
+	if len(txn.opeFound) > 0 && len(txn.opeNoFound) > 0 {
+		panic("commit. the matches can not be found and not found at the same time")
+	}
+	if len(txn.opeFound) > 0 {
+		return tx.If(append(txn.matches, clientv3.Compare(clientv3.ModRevision(txn.keyValue), ">", 0))...).Then(txn.opeFound...)
 	}
+	return tx.If(append(txn.matches, clientv3.Compare(clientv3.ModRevision(txn.keyValue), "=", 0))...).Then(txn.opeNoFound...)
 }
 
 func (txn *etcdTxn) Clear() {
-	txn.indexF = 0
-	txn.indexNf = 0
+	txn.opeFound = txn.opeFound[:0]
+	txn.opeNoFound = txn.opeNoFound[:0]
 	txn.keyValue = ""
+	txn.matches = txn.matches[:0]
 }
 
 type etcdIntegra struct {
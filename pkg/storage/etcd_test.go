@@ -18,6 +18,7 @@ package storage
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
@@ -102,6 +103,7 @@ func TestEtcd_Config(t *testing.T) {
 		assert.Equal(t, tt.t.DialKeepAliveTime, r.DialKeepAliveTime, strings.Concat(tt.name, "DialKeepAliveTime"))
 		assert.Equal(t, tt.t.DialKeepAliveTimeout, r.DialKeepAliveTimeout, strings.Concat(tt.name, "DialKeepAliveTimeout"))
 		assert.Equal(t, tt.t.Endpoints, r.Endpoints, strings.Concat(tt.name, "Endpoints"))
+		assert.Len(t, r.DialOptions, 2, strings.Concat(tt.name, "DialOptions"))
 	}
 }
 
@@ -328,6 +330,37 @@ func TestEtcd_Put(t *testing.T) {
 	}
 }
 
+func TestEtcd_PutCodec(t *testing.T) {
+	cluster, ctx, store := newStore(t)
+	defer cluster.Terminate(t)
+
+	codecs := []encoding.Codec{encoding.Gob(), encoding.JSON(), encoding.Binary()}
+
+	for _, codec := range codecs {
+		e := &EntityTest{Prop1: codec.Name(), Prop2: 3}
+		put, err := NewEtcdCodec(cluster.RandClient(), codec).Put(e)
+		if !assert.NoError(t, err, codec.Name()) {
+			continue
+		}
+		txn := NewTxn(store)
+		txn.Find(e.Key())
+		txn.DoNotFound(put)
+		_, err = txn.Commit(ctx)
+		if assert.NoError(t, err, codec.Name()) {
+			_, value, err := store.GetRaw(ctx, e.Key())
+			if assert.NoError(t, err, codec.Name()) {
+				assert.Equal(t, codec.Tag(), value[0], strings.Concat(codec.Name(), " Tag"))
+			}
+			// The values are decoded by tag, whatever the codec of the store
+			var er EntityTest
+			_, err = store.Get(ctx, e.Key(), &er)
+			if assert.NoError(t, err, codec.Name()) {
+				assert.Equal(t, e, &er, codec.Name())
+			}
+		}
+	}
+}
+
 func TestEtcd_PutRaw(t *testing.T) {
 	cluster, ctx, store := newStore(t)
 	defer cluster.Terminate(t)
@@ -797,18 +830,134 @@ func TestEtcd_RangeRaw(t *testing.T) {
 	}
 }
 
+func TestEtcdTransaction_Match(t *testing.T) {
+	cluster, ctx, store := newStore(t)
+	defer cluster.Terminate(t)
+
+	tests := []struct {
+		name    string
+		value   string
+		put     string
+		matched bool
+	}{
+		{
+			name:    "Matching key not found.",
+			value:   "",
+			put:     "v1",
+			matched: true,
+		},
+		{
+			name:    "Matching key not found. Key exists.",
+			value:   "",
+			put:     "v2",
+			matched: false,
+		},
+		{
+			name:    "Matching value.",
+			value:   "v1",
+			put:     "v2",
+			matched: true,
+		},
+		{
+			name:    "Matching value. Value changed.",
+			value:   "v1",
+			put:     "v3",
+			matched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		txn := NewTxn(store)
+		txn.Match("key", tt.value)
+		txn.DoFound(store.PutRaw("key", tt.put))
+		txn.DoNotFound(store.PutRaw("else", tt.put))
+		matched, err := txn.Commit(ctx)
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.matched, matched, tt.name)
+			key := "else"
+			if tt.matched {
+				key = "key"
+			}
+			_, value, err := store.GetRaw(ctx, key)
+			if assert.NoError(t, err, tt.name) {
+				assert.Equal(t, tt.put, value, tt.name)
+			}
+		}
+	}
+}
+
+func TestEtcdTransaction_FindMatch(t *testing.T) {
+	cluster, ctx, store := newStore(t)
+	defer cluster.Terminate(t)
+
+	tests := []struct {
+		name    string
+		found   bool
+		value   string
+		applied bool
+	}{
+		{
+			name:    "Creating with counter.",
+			value:   "",
+			applied: true,
+		},
+		{
+			name:    "Creating with counter. Counter changed.",
+			value:   "",
+			applied: false,
+		},
+		{
+			name:    "Creating with counter. Counter matched.",
+			value:   "1",
+			applied: true,
+		},
+		{
+			name:    "Removing with counter.",
+			found:   true,
+			value:   "1",
+			applied: true,
+		},
+	}
+
+	for i, tt := range tests {
+		key := strconv.Itoa(i)
+		if tt.found {
+			key = "0"
+		}
+		txn := NewTxn(store)
+		txn.Find(key)
+		txn.Match("counter", tt.value)
+		if tt.found {
+			txn.DoFound(store.Remove(key))
+			txn.DoFound(store.PutRaw("counter", "1"))
+		} else {
+			txn.DoNotFound(store.PutRaw(key, "value"))
+			txn.DoNotFound(store.PutRaw("counter", "1"))
+		}
+		applied, err := txn.Commit(ctx)
+		if assert.NoError(t, err, tt.name) {
+			assert.Equal(t, tt.applied, applied, tt.name)
+			found, err := store.Exists(ctx, key)
+			if assert.NoError(t, err, tt.name) {
+				assert.Equal(t, tt.applied != tt.found, found, tt.name)
+			}
+		}
+	}
+}
+
 func TestEtcdTransaction_Clear(t *testing.T) {
 	cluster, _, store := newStore(t)
 	defer cluster.Terminate(t)
 
 	txn := NewTxn(store).(*etcdTxn)
-	txn.indexF = 2
-	txn.indexNf = 5
-	txn.Find("key")
+	txn.DoFound(store.Remove("key1"))
+	txn.DoNotFound(store.Remove("key2"))
+	txn.Match("key", "value")
 	txn.Clear()
-	assert.Equal(t, uint8(0), txn.indexF)
-	assert.Equal(t, uint8(0), txn.indexNf)
+	assert.Equal(t, 0, len(txn.opeFound))
+	assert.Equal(t, 0, len(txn.opeNoFound))
 	assert.Equal(t, "", txn.keyValue)
+	assert.Equal(t, 0, len(txn.matches))
 }
 
 func sampling(ctx context.Context, t *testing.T, samples []EntityTest, client *clientv3.Client) bool {
@@ -20,7 +20,13 @@ package storage
 func NewTxn(store CRUD) Txn {
 	switch s := store.(type) {
 	case *etcdStore:
-		return &etcdTxn{client: s.client}
+		return newEtcdTxn(s.client)
+	case *namespaceStore:
+		return &namespaceTxn{Txn: NewTxn(s.store), prefix: s.prefix}
+	case *instrumentedStore:
+		return &instrumentedTxn{Txn: NewTxn(s.store), metrics: s.metrics}
+	case *ttlStore:
+		return &ttlTxn{Txn: NewTxn(s.CRUD)}
 	default:
 		panic("store type not defined")
 	}
@@ -37,6 +37,10 @@ func TestNewTxn(t *testing.T) {
 			store: NewEtcd(cluster.RandClient()),
 			typeN: "*storage.etcdTxn",
 		},
+		{
+			store: NewNamespace(NewEtcd(cluster.RandClient()), "acme"),
+			typeN: "*storage.namespaceTxn",
+		},
 	}
 	for _, tt := range tests {
 		txn := NewTxn(tt.store)
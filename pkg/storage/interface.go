@@ -17,6 +17,7 @@ package storage
 
 import (
 	"context"
+	"time"
 )
 
 type (
@@ -100,6 +101,20 @@ type (
 		// RangeRaw lists all keys and values that is greater than skey and ended by eKey with the limit of the top parameter.
 		RangeRaw(ctx context.Context, skey string, ekey string, top int) (map[string]string, error)
 
+		// Grant creates a lease that expires after ttl without keep alive.
+		// The keys attached to the lease are removed when it expires or it is revoked. See PutRawLease
+		Grant(ctx context.Context, ttl time.Duration) (LeaseID, error)
+
+		// KeepAlive renews the lease once. If the lease expired returns ErrLeaseNotFound
+		KeepAlive(ctx context.Context, id LeaseID) error
+
+		// Revoke removes the lease and the keys attached to it. If the lease expired returns ErrLeaseNotFound
+		Revoke(ctx context.Context, id LeaseID) error
+
+		// PutRawLease puts the key and value attached to the lease depending of transaction.
+		// This context is added to the transaction.
+		PutRawLease(key string, value string, id LeaseID) OpeWrap
+
 		// Close closes resources
 		Close() error
 	}
@@ -109,6 +124,14 @@ type (
 		// Find checks if exists the keyValue. If it is found does DoFound or else does DoNotFound into commit
 		Find(keyValue string)
 
+		// Match adds the condition that the key has the value. The empty value matches when the key doesn't exist.
+		// It allows the compare-and-swap of the values.
+		// Without Find, it does DoFound if all values match or else does DoNotFound into commit.
+		// With Find, the operations are only done if all values match, so the transaction can not have
+		// DoFound and DoNotFound operations at the same time.
+		// The commit returns true if all conditions are met
+		Match(key string, value string)
+
 		// DoFound saves the operations to transaction if it is found into commit
 		DoFound(ope OpeWrap)
 
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	strs "strings"
+	"sync"
+	"time"
+
+	"github.com/carisa/pkg/strings"
+)
+
+// LeaseID identifies a lease. The zero value is without lease
+type LeaseID int64
+
+// ErrLeaseNotFound is returned when the lease expired or it was revoked
+var ErrLeaseNotFound = errors.New("the lease is not found")
+
+const (
+	// LeasePrefix is the prefix of the records of the emulated leases. See NewTTLLeases
+	LeasePrefix = "lease#"
+	leaseSep    = "#"
+)
+
+// ttlStore decorates a store without leases to emulate them. Each lease is a record with its expiration time
+// and each key attached to the lease is recorded under the lease into the same transaction as the key.
+// The expired leases are removed with their keys by the next Grant or KeepAlive of any client,
+// so the keys of a dead client live until other client is alive
+type ttlStore struct {
+	CRUD
+	txn   func() Txn
+	now   func() time.Time
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	batch int
+}
+
+// NewTTLLeases decorates the store to emulate the leases. It is used by the stores without leases
+// or TTL. The batch is the number of records read by request when the expired leases are swept
+func NewTTLLeases(store CRUD, batch int) CRUD {
+	return &ttlStore{
+		CRUD:  store,
+		txn:   func() Txn { return NewTxn(store) },
+		now:   time.Now,
+		rnd:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		batch: batch,
+	}
+}
+
+// Grant implements CRUD.Grant
+func (t *ttlStore) Grant(ctx context.Context, ttl time.Duration) (LeaseID, error) {
+	if err := t.sweep(ctx); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	id := LeaseID(t.rnd.Int63())
+	t.mu.Unlock()
+
+	txn := t.txn()
+	txn.Find(leaseKey(id))
+	txn.DoNotFound(t.CRUD.PutRaw(leaseKey(id), t.expiration(ttl)))
+	ok, err := txn.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, errors.New("granting the lease. the lease already exists")
+	}
+	return id, nil
+}
+
+// KeepAlive implements CRUD.KeepAlive. The lease is renewed with the ttl of its grant
+func (t *ttlStore) KeepAlive(ctx context.Context, id LeaseID) error {
+	found, value, err := t.CRUD.GetRaw(ctx, leaseKey(id))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrLeaseNotFound
+	}
+	expires, ttl, ok := parseLease(value)
+	if !ok || t.now().After(expires) {
+		if err := t.Revoke(ctx, id); err != nil && err != ErrLeaseNotFound {
+			return err
+		}
+		return ErrLeaseNotFound
+	}
+
+	txn := t.txn()
+	txn.Match(leaseKey(id), value)
+	txn.DoFound(t.CRUD.PutRaw(leaseKey(id), t.expiration(ttl)))
+	renewed, err := txn.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return ErrLeaseNotFound
+	}
+	return t.sweep(ctx)
+}
+
+// Revoke implements CRUD.Revoke
+func (t *ttlStore) Revoke(ctx context.Context, id LeaseID) error {
+	found, err := t.CRUD.Exists(ctx, leaseKey(id))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrLeaseNotFound
+	}
+	prefix := strings.Concat(leaseKey(id), leaseSep)
+	storeTimeout := func() (context.Context, context.CancelFunc) { return context.WithCancel(ctx) }
+	err = ScanRaw(storeTimeout, t.CRUD, prefix, t.batch, func(keys []string, _ map[string]string) error {
+		txn := t.txn()
+		txn.Find(leaseKey(id))
+		for _, key := range keys {
+			txn.DoFound(t.CRUD.Remove(key))
+			txn.DoFound(t.CRUD.Remove(strs.TrimPrefix(key, prefix)))
+		}
+		_, err := txn.Commit(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	txn := t.txn()
+	txn.Find(leaseKey(id))
+	txn.DoFound(t.CRUD.Remove(leaseKey(id)))
+	_, err = txn.Commit(ctx)
+	return err
+}
+
+// PutRawLease implements CRUD.PutRawLease. The key is recorded under the lease into the same transaction.
+// See ttlTxn
+func (t *ttlStore) PutRawLease(key string, value string, id LeaseID) OpeWrap {
+	ope := t.CRUD.PutRaw(key, value)
+	attach := t.CRUD.PutRaw(strings.Concat(leaseKey(id), leaseSep, key), "")
+	ope.attach = &attach
+	return ope
+}
+
+// sweep revokes the expired leases
+func (t *ttlStore) sweep(ctx context.Context) error {
+	var expired []LeaseID
+	storeTimeout := func() (context.Context, context.CancelFunc) { return context.WithCancel(ctx) }
+	err := ScanRaw(storeTimeout, t.CRUD, LeasePrefix, t.batch, func(keys []string, values map[string]string) error {
+		for _, key := range keys {
+			id, err := strconv.ParseInt(strs.TrimPrefix(key, LeasePrefix), 10, 64)
+			if err != nil {
+				continue // It is a key attached to a lease
+			}
+			if expires, _, ok := parseLease(values[key]); ok && t.now().After(expires) {
+				expired = append(expired, LeaseID(id))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if err := t.Revoke(ctx, id); err != nil && err != ErrLeaseNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// expiration returns the value of the lease record: the expiration time and the ttl into nanoseconds
+func (t *ttlStore) expiration(ttl time.Duration) string {
+	return strings.Concat(
+		strconv.FormatInt(t.now().Add(ttl).UnixNano(), 10),
+		leaseSep,
+		strconv.FormatInt(int64(ttl), 10))
+}
+
+// parseLease parses the value of the lease record. See ttlStore.expiration
+func parseLease(value string) (time.Time, time.Duration, bool) {
+	i := strs.Index(value, leaseSep)
+	if i < 0 {
+		return time.Time{}, 0, false
+	}
+	expires, err := strconv.ParseInt(value[:i], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	ttl, err := strconv.ParseInt(value[i+1:], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return time.Unix(0, expires), time.Duration(ttl), true
+}
+
+func leaseKey(id LeaseID) string {
+	return strings.Concat(LeasePrefix, strconv.FormatInt(int64(id), 10))
+}
+
+// ttlTxn decorates the transaction of the store to add the operations that attach the keys to the emulated leases.
+// See ttlStore.PutRawLease
+type ttlTxn struct {
+	Txn
+}
+
+// DoFound implements Txn.DoFound
+func (t *ttlTxn) DoFound(ope OpeWrap) {
+	t.Txn.DoFound(ope)
+	if ope.attach != nil {
+		t.Txn.DoFound(*ope.attach)
+	}
+}
+
+// DoNotFound implements Txn.DoNotFound
+func (t *ttlTxn) DoNotFound(ope OpeWrap) {
+	t.Txn.DoNotFound(ope)
+	if ope.attach != nil {
+		t.Txn.DoNotFound(*ope.attach)
+	}
+}
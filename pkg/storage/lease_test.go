@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLease_Etcd(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	testLease(t, i.Store())
+}
+
+func TestLease_Namespace(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	acme := NewNamespace(root, "acme")
+	testLease(t, acme)
+
+	ctx := context.TODO()
+	id, err := acme.Grant(ctx, 10*time.Second)
+	if !assert.NoError(t, err) || !assert.True(t, putLease(t, acme, "key", id)) {
+		return
+	}
+	found, err := root.Exists(ctx, "@acme#key")
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Key into the namespace")
+	}
+}
+
+func TestLease_TTL(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	store := NewTTLLeases(root, 2)
+	testLease(t, store)
+
+	ttl := store.(*ttlStore)
+	now := time.Now()
+	ttl.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	expired, err := store.Grant(ctx, 10*time.Second)
+	if !assert.NoError(t, err) || !assert.True(t, putLease(t, store, "expired", expired)) {
+		return
+	}
+	alive, err := store.Grant(ctx, 10*time.Second)
+	if !assert.NoError(t, err) || !assert.True(t, putLease(t, store, "alive", alive)) {
+		return
+	}
+
+	now = now.Add(8 * time.Second)
+	assert.NoError(t, store.KeepAlive(ctx, alive), "Renewed before its ttl")
+	now = now.Add(8 * time.Second)
+	assert.NoError(t, store.KeepAlive(ctx, alive), "Renewed with the ttl of its grant")
+
+	found, err := root.Exists(ctx, "expired")
+	if assert.NoError(t, err) {
+		assert.False(t, found, "The expired lease is swept by the keep alive of other lease")
+	}
+	found, err = root.Exists(ctx, "alive")
+	if assert.NoError(t, err) {
+		assert.True(t, found, "The key of the lease renewed is kept")
+	}
+	assert.Equal(t, ErrLeaseNotFound, store.KeepAlive(ctx, expired), "Expired")
+
+	now = now.Add(11 * time.Second)
+	assert.Equal(t, ErrLeaseNotFound, store.KeepAlive(ctx, alive), "Expired")
+	values, err := root.RangeRaw(ctx, LeasePrefix, LeasePrefix, 0)
+	if assert.NoError(t, err) {
+		assert.Empty(t, values, "Records of the leases")
+	}
+}
+
+func TestLease_TTLNamespace(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	testLease(t, NewNamespace(NewTTLLeases(root, 2), "acme"))
+	testLease(t, NewTTLLeases(NewNamespace(root, "acme"), 2))
+}
+
+func TestLease_ParseLease(t *testing.T) {
+	expires, ttl, ok := parseLease("1000#10")
+	assert.True(t, ok, "Valid")
+	assert.Equal(t, time.Unix(0, 1000), expires, "Expiration")
+	assert.Equal(t, time.Duration(10), ttl, "TTL")
+
+	for _, value := range []string{"", "1000", "a#10", "1000#a"} {
+		_, _, ok := parseLease(value)
+		assert.False(t, ok, value)
+	}
+}
+
+// testLease tests the lease API of the store
+func testLease(t *testing.T, store CRUD) {
+	ctx := context.TODO()
+
+	id, err := store.Grant(ctx, 10*time.Second)
+	if !assert.NoError(t, err, "Grant") {
+		return
+	}
+	if !assert.True(t, putLease(t, store, "leased", id), "Put") {
+		return
+	}
+	assert.NoError(t, store.KeepAlive(ctx, id), "KeepAlive")
+
+	assert.NoError(t, store.Revoke(ctx, id), "Revoke")
+	found, err := store.Exists(ctx, "leased")
+	if assert.NoError(t, err) {
+		assert.False(t, found, "The key is removed with the lease")
+	}
+	assert.Equal(t, ErrLeaseNotFound, store.KeepAlive(ctx, id), "KeepAlive after revoke")
+	assert.Equal(t, ErrLeaseNotFound, store.Revoke(ctx, id), "Revoke after revoke")
+}
+
+func putLease(t *testing.T, store CRUD, key string, id LeaseID) bool {
+	txn := NewTxn(store)
+	txn.Find(key)
+	txn.DoNotFound(store.PutRawLease(key, "value", id))
+	ok, err := txn.Commit(context.TODO())
+	return assert.NoError(t, err) && ok
+}
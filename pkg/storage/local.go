@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/carisa/pkg/strings"
+)
+
+// localElections implements the elections into the process. Each candidate returned competes with the rest,
+// so the tests can elect among several candidates of the same Elections
+type localElections struct {
+	mu        sync.Mutex
+	token     int64
+	elections map[string]*localElection
+}
+
+// NewLocalElections builds elections into the process. It is used by the tests
+func NewLocalElections() Elections {
+	return &localElections{elections: make(map[string]*localElection)}
+}
+
+// Election implements Elections.Election
+func (l *localElections) Election(name string) Election {
+	return &localCandidate{election: l.get(strings.Concat(ElectionPrefix, name))}
+}
+
+// Lock implements Elections.Lock
+func (l *localElections) Lock(name string) Lock {
+	return &electionLock{election: &localCandidate{election: l.get(strings.Concat(LockPrefix, name))}}
+}
+
+// Close implements Elections.Close
+func (l *localElections) Close() error {
+	l.mu.Lock()
+	elections := make([]*localElection, 0, len(l.elections))
+	for _, e := range l.elections {
+		elections = append(elections, e)
+	}
+	l.mu.Unlock()
+	for _, e := range elections {
+		e.resign(nil)
+	}
+	return nil
+}
+
+func (l *localElections) get(name string) *localElection {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.elections[name]
+	if !ok {
+		e = &localElection{owner: l, free: make(chan struct{})}
+		l.elections[name] = e
+	}
+	return e
+}
+
+func (l *localElections) next() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.token++
+	return l.token
+}
+
+// localElection is the state of an election. The channel free is closed when the leader resigns
+// and the channel lost is closed when the leadership ends
+type localElection struct {
+	owner *localElections
+
+	mu        sync.Mutex
+	leader    *localCandidate
+	value     string
+	token     int64
+	lost      chan struct{}
+	free      chan struct{}
+	observers []chan string
+}
+
+// elect elects the candidate if the election is free. Returns the channel to wait if it is not elected
+func (e *localElection) elect(c *localCandidate, value string) (Leadership, <-chan struct{}, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader == c {
+		return Leadership{Token: e.token, Done: e.lost}, nil, true
+	}
+	if e.leader != nil {
+		return Leadership{}, e.free, false
+	}
+	e.leader, e.value, e.token, e.lost = c, value, e.owner.next(), make(chan struct{})
+	for _, o := range e.observers {
+		notify(o, value)
+	}
+	return Leadership{Token: e.token, Done: e.lost}, nil, true
+}
+
+// resign ends the leadership of the candidate. A nil candidate ends any leadership
+func (e *localElection) resign(c *localCandidate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader == nil || c != nil && e.leader != c {
+		return
+	}
+	e.leader, e.value = nil, ""
+	close(e.lost)
+	close(e.free)
+	e.free = make(chan struct{})
+}
+
+func (e *localElection) observe(ctx context.Context) <-chan string {
+	o := make(chan string, 1)
+	e.mu.Lock()
+	e.observers = append(e.observers, o)
+	if e.leader != nil {
+		notify(o, e.value)
+	}
+	e.mu.Unlock()
+
+	leaders := make(chan string)
+	go func() {
+		defer close(leaders)
+		defer e.unobserve(o)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case value := <-o:
+				select {
+				case leaders <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return leaders
+}
+
+func (e *localElection) unobserve(o chan string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, ob := range e.observers {
+		if ob == o {
+			e.observers = append(e.observers[:i], e.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify replaces the pending value of the observer, so the slow observers only receive the last leader
+func notify(o chan string, value string) {
+	select {
+	case <-o:
+	default:
+	}
+	o <- value
+}
+
+type localCandidate struct {
+	election *localElection
+}
+
+// Campaign implements Election.Campaign
+func (c *localCandidate) Campaign(ctx context.Context, value string) (Leadership, error) {
+	for {
+		l, free, elected := c.election.elect(c, value)
+		if elected {
+			return l, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Leadership{}, ctx.Err()
+		case <-free:
+		}
+	}
+}
+
+// Resign implements Election.Resign
+func (c *localCandidate) Resign(_ context.Context) error {
+	c.election.resign(c)
+	return nil
+}
+
+// Leader implements Election.Leader
+func (c *localCandidate) Leader(_ context.Context) (string, error) {
+	c.election.mu.Lock()
+	defer c.election.mu.Unlock()
+	if c.election.leader == nil {
+		return "", ErrNoLeader
+	}
+	return c.election.value, nil
+}
+
+// Observe implements Election.Observe
+func (c *localCandidate) Observe(ctx context.Context) (<-chan string, error) {
+	return c.election.observe(ctx), nil
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultOk    = "ok"
+	resultError = "error"
+
+	// Outcomes of the transactions
+	outcomeCommitted = "committed"
+	outcomeFailed    = "failed"
+	outcomeFalse     = "condition_false"
+)
+
+// Metrics collects the calls to the store, the outcome of the transactions and
+// the entities created and linked by the CRUD operations. All methods can be called with nil metrics
+type Metrics struct {
+	operations *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	txns       *prometheus.CounterVec
+	created    *prometheus.CounterVec
+	linked     *prometheus.CounterVec
+}
+
+// NewMetrics builds the metrics of the store and registers them into reg
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "carisa_store_operations_total",
+			Help: "Number of calls to the store by operation and result.",
+		}, []string{"operation", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "carisa_store_operation_duration_seconds",
+			Help:    "Latency of the calls to the store by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		txns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "carisa_store_transactions_total",
+			Help: "Number of transactions by outcome: committed if the condition was true, condition_false or failed.",
+		}, []string{"outcome"}),
+		created: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "carisa_entities_created_total",
+			Help: "Number of entities created by service.",
+		}, []string{"service"}),
+		linked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "carisa_links_total",
+			Help: "Number of links made between entities by service.",
+		}, []string{"service"}),
+	}
+	for _, c := range []prometheus.Collector{m.operations, m.duration, m.txns, m.created, m.linked} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// observe records the call to the store that started at start
+func (m *Metrics) observe(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	result := resultOk
+	if err != nil {
+		result = resultError
+	}
+	m.operations.WithLabelValues(operation, result).Inc()
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// commit records the outcome of the transaction
+func (m *Metrics) commit(start time.Time, ok bool, err error) {
+	if m == nil {
+		return
+	}
+	m.observe("commit", start, err)
+	switch {
+	case err != nil:
+		m.txns.WithLabelValues(outcomeFailed).Inc()
+	case ok:
+		m.txns.WithLabelValues(outcomeCommitted).Inc()
+	default:
+		m.txns.WithLabelValues(outcomeFalse).Inc()
+	}
+}
+
+// create counts the entity created by the service loc
+func (m *Metrics) create(loc string, created bool) {
+	if m != nil && created {
+		m.created.WithLabelValues(loc).Inc()
+	}
+}
+
+// link counts the link made by the service loc
+func (m *Metrics) link(loc string, linked bool) {
+	if m != nil && linked {
+		m.linked.WithLabelValues(loc).Inc()
+	}
+}
+
+// metricsOf gets the metrics of the store if it is instrumented. See NewInstrumented
+func metricsOf(store CRUD) *Metrics {
+	switch s := store.(type) {
+	case *instrumentedStore:
+		return s.metrics
+	case *namespaceStore:
+		return metricsOf(s.store)
+	default:
+		return nil
+	}
+}
+
+// instrumentedStore decorates a store to collect the metrics of the calls.
+// The operations of the transactions are collected into the commit. See instrumentedTxn
+type instrumentedStore struct {
+	store   CRUD
+	metrics *Metrics
+}
+
+// NewInstrumented decorates the store to collect the metrics of the calls
+func NewInstrumented(store CRUD, metrics *Metrics) CRUD {
+	return &instrumentedStore{store: store, metrics: metrics}
+}
+
+// Put implements CRUD.Put
+func (i *instrumentedStore) Put(entity Entity) (OpeWrap, error) {
+	return i.store.Put(entity)
+}
+
+// PutRaw implements CRUD.PutRaw
+func (i *instrumentedStore) PutRaw(key string, value string) OpeWrap {
+	return i.store.PutRaw(key, value)
+}
+
+// Remove implements CRUD.Remove
+func (i *instrumentedStore) Remove(key string) OpeWrap {
+	return i.store.Remove(key)
+}
+
+// Get implements CRUD.Get
+func (i *instrumentedStore) Get(ctx context.Context, key string, entity Entity) (bool, error) {
+	start := time.Now()
+	found, err := i.store.Get(ctx, key, entity)
+	i.metrics.observe("get", start, err)
+	return found, err
+}
+
+// GetRaw implements CRUD.GetRaw
+func (i *instrumentedStore) GetRaw(ctx context.Context, key string) (bool, string, error) {
+	start := time.Now()
+	found, value, err := i.store.GetRaw(ctx, key)
+	i.metrics.observe("getRaw", start, err)
+	return found, value, err
+}
+
+// Exists implements CRUD.Exists
+func (i *instrumentedStore) Exists(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	found, err := i.store.Exists(ctx, key)
+	i.metrics.observe("exists", start, err)
+	return found, err
+}
+
+// StartKey implements CRUD.StartKey
+func (i *instrumentedStore) StartKey(ctx context.Context, key string, top int, empty func() Entity) ([]Entity, error) {
+	start := time.Now()
+	list, err := i.store.StartKey(ctx, key, top, empty)
+	i.metrics.observe("startKey", start, err)
+	return list, err
+}
+
+// Range implements CRUD.Range
+func (i *instrumentedStore) Range(ctx context.Context, skey string, ekey string, top int, empty func() Entity) ([]Entity, error) {
+	start := time.Now()
+	list, err := i.store.Range(ctx, skey, ekey, top, empty)
+	i.metrics.observe("range", start, err)
+	return list, err
+}
+
+// RangeRaw implements CRUD.RangeRaw
+func (i *instrumentedStore) RangeRaw(ctx context.Context, skey string, ekey string, top int) (map[string]string, error) {
+	start := time.Now()
+	values, err := i.store.RangeRaw(ctx, skey, ekey, top)
+	i.metrics.observe("rangeRaw", start, err)
+	return values, err
+}
+
+// Grant implements CRUD.Grant
+func (i *instrumentedStore) Grant(ctx context.Context, ttl time.Duration) (LeaseID, error) {
+	start := time.Now()
+	id, err := i.store.Grant(ctx, ttl)
+	i.metrics.observe("grant", start, err)
+	return id, err
+}
+
+// KeepAlive implements CRUD.KeepAlive
+func (i *instrumentedStore) KeepAlive(ctx context.Context, id LeaseID) error {
+	start := time.Now()
+	err := i.store.KeepAlive(ctx, id)
+	i.metrics.observe("keepAlive", start, err)
+	return err
+}
+
+// Revoke implements CRUD.Revoke
+func (i *instrumentedStore) Revoke(ctx context.Context, id LeaseID) error {
+	start := time.Now()
+	err := i.store.Revoke(ctx, id)
+	i.metrics.observe("revoke", start, err)
+	return err
+}
+
+// PutRawLease implements CRUD.PutRawLease
+func (i *instrumentedStore) PutRawLease(key string, value string, id LeaseID) OpeWrap {
+	return i.store.PutRawLease(key, value, id)
+}
+
+// Close implements CRUD.Close
+func (i *instrumentedStore) Close() error {
+	return i.store.Close()
+}
+
+// instrumentedTxn decorates a transaction to collect the outcome of the commit
+type instrumentedTxn struct {
+	Txn
+	metrics *Metrics
+}
+
+// Commit implements Txn.Commit
+func (t *instrumentedTxn) Commit(ctx context.Context) (bool, error) {
+	start := time.Now()
+	ok, err := t.Txn.Commit(ctx)
+	t.metrics.commit(start, ok, err)
+	return ok, err
+}
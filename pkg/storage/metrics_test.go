@@ -0,0 +1,109 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMetrics_Instrumented(t *testing.T) {
+	storef := NewEctdIntegra(t)
+	defer storef.Close()
+
+	m, err := NewMetrics(prometheus.NewRegistry())
+	if !assert.NoError(t, err) {
+		return
+	}
+	store := NewNamespace(NewInstrumented(storef.Store(), m), "ns")
+	core, _ := observer.New(zap.DebugLevel)
+	oper := NewCrudOperation(store, logging.NewZapWrap(zap.New(core), logging.DebugLevel, ""), NewTxn)
+
+	e := entity()
+	e.Parent = ""
+	created, err := oper.Create("loc", storeTimeout, e)
+	if assert.NoError(t, err) {
+		assert.True(t, created, "Created")
+	}
+	created, err = oper.Create("loc", storeTimeout, e)
+	if assert.NoError(t, err) {
+		assert.False(t, created, "Exists")
+	}
+	found, err := store.Exists(context.TODO(), e.Key())
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Found")
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.created.WithLabelValues("loc")), "Entities created")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.txns.WithLabelValues(outcomeFalse)), "Not found into the commit")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.txns.WithLabelValues(outcomeCommitted)), "Found into the commit")
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.operations.WithLabelValues("commit", resultOk)), "Commits")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("exists", resultOk)), "Exists")
+}
+
+func TestMetrics_InstrumentedLease(t *testing.T) {
+	storef := NewEctdIntegra(t)
+	defer storef.Close()
+
+	m, err := NewMetrics(prometheus.NewRegistry())
+	if !assert.NoError(t, err) {
+		return
+	}
+	testLease(t, NewInstrumented(storef.Store(), m))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("grant", resultOk)), "Grant")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("keepAlive", resultOk)), "KeepAlive")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("revoke", resultOk)), "Revoke")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("revoke", resultError)), "Lease not found")
+}
+
+func TestMetrics_Nil(t *testing.T) {
+	var m *Metrics
+	m.observe("get", time.Now(), nil)
+	m.commit(time.Now(), true, nil)
+	m.create("loc", true)
+	m.link("loc", true)
+	assert.Nil(t, metricsOf(nil), "Not instrumented")
+}
+
+func TestMetrics_Commit(t *testing.T) {
+	m, err := NewMetrics(prometheus.NewRegistry())
+	if !assert.NoError(t, err) {
+		return
+	}
+	m.commit(time.Now(), false, errors.New("commit"))
+	m.link("loc", true)
+	m.link("loc", false)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.txns.WithLabelValues(outcomeFailed)), "Failed")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.operations.WithLabelValues("commit", resultError)), "Error")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.linked.WithLabelValues("loc")), "Linked")
+
+	reg := prometheus.NewRegistry()
+	_, err = NewMetrics(reg)
+	assert.NoError(t, err)
+	_, err = NewMetrics(reg)
+	assert.Error(t, err, "Registered twice")
+}
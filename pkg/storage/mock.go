@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 // ErrMockCRUD allows test the errors.
@@ -18,6 +19,9 @@ type ErrMockCRUD struct {
 	rang     bool
 	rangRaw  bool
 	close    bool
+	grant    bool
+	keep     bool
+	revoke   bool
 }
 
 func (e *ErrMockCRUD) Close() error {
@@ -38,6 +42,31 @@ func (e *ErrMockCRUD) PutRaw(key string, value string) OpeWrap {
 	return OpeWrap{}
 }
 
+func (e *ErrMockCRUD) PutRawLease(key string, value string, id LeaseID) OpeWrap {
+	return OpeWrap{}
+}
+
+func (e *ErrMockCRUD) Grant(ctx context.Context, ttl time.Duration) (LeaseID, error) {
+	if e.grant {
+		return 0, errors.New("grant")
+	}
+	return 1, nil
+}
+
+func (e *ErrMockCRUD) KeepAlive(ctx context.Context, id LeaseID) error {
+	if e.keep {
+		return errors.New("keepAlive")
+	}
+	return nil
+}
+
+func (e *ErrMockCRUD) Revoke(ctx context.Context, id LeaseID) error {
+	if e.revoke {
+		return errors.New("revoke")
+	}
+	return nil
+}
+
 func (e *ErrMockCRUD) Get(ctx context.Context, key string, entity Entity) (bool, error) {
 	if e.get {
 		return false, errors.New("get")
@@ -113,6 +142,12 @@ func (e *ErrMockCRUD) Activate(methods ...string) {
 			e.rangRaw = true
 		case "Close":
 			e.close = true
+		case "Grant":
+			e.grant = true
+		case "KeepAlive":
+			e.keep = true
+		case "Revoke":
+			e.revoke = true
 		default:
 			panic("method not found")
 		}
@@ -131,6 +166,9 @@ func (e *ErrMockCRUD) Clear() {
 	e.rang = false
 	e.rangRaw = false
 	e.close = false
+	e.grant = false
+	e.keep = false
+	e.revoke = false
 }
 
 // ErrMockTxn allows test the errors.
@@ -174,6 +212,9 @@ func (e *ErrMockTxn) DoNotFound(ope OpeWrap) {
 func (e *ErrMockTxn) Find(keyValue string) {
 }
 
+func (e *ErrMockTxn) Match(key string, value string) {
+}
+
 // ErrMockCRUDOper allows test the errors.
 type ErrMockCRUDOper struct {
 	create        bool
@@ -293,3 +334,7 @@ func (e *ErrMockCRUDOper) ListDLR(storeTimeout StoreWithTimeout, childID string)
 	}
 	return nil, nil
 }
+
+func (e *ErrMockCRUDOper) Counters(_ context.Context, key string, parentKey string) ([]Counter, error) {
+	return nil, nil
+}
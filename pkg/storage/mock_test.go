@@ -19,13 +19,15 @@ package storage
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestErrMockCRUD_Activate(t *testing.T) {
 	m := ErrMockCRUD{}
-	m.Activate("Put", "PutRaw", "Remove", "Get", "GetRaw", "Exists", "StartKey", "Range", "RangeRaw", "Close")
+	m.Activate("Put", "PutRaw", "Remove", "Get", "GetRaw", "Exists", "StartKey", "Range", "RangeRaw", "Close",
+		"Grant", "KeepAlive", "Revoke")
 	_, err := m.Put(nil)
 	assert.Error(t, err, "Put")
 	opew := m.PutRaw("", "")
@@ -46,6 +48,11 @@ func TestErrMockCRUD_Activate(t *testing.T) {
 	assert.Error(t, err, "RangeRaw")
 	err = m.Close()
 	assert.Error(t, err, "Close")
+	_, err = m.Grant(context.TODO(), time.Second)
+	assert.Error(t, err, "Grant")
+	assert.Error(t, m.KeepAlive(context.TODO(), 1), "KeepAlive")
+	assert.Error(t, m.Revoke(context.TODO(), 1), "Revoke")
+	assert.Equal(t, OpeWrap{}, m.PutRawLease("", "", 1), "PutRawLease")
 }
 
 func TestErrMockCRUD_ActivateMethodNotFound(t *testing.T) {
@@ -66,6 +73,9 @@ func TestErrMockCRUD_Clear(t *testing.T) {
 	assert.False(t, m.rang, "Range")
 	assert.False(t, m.rangRaw, "RangeRaw")
 	assert.False(t, m.close, "Close")
+	assert.False(t, m.grant, "Grant")
+	assert.False(t, m.keep, "KeepAlive")
+	assert.False(t, m.revoke, "Revoke")
 }
 
 func TestErrMockTxn_Activate(t *testing.T) {
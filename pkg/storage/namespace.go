@@ -0,0 +1,171 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	strs "strings"
+	"time"
+
+	"github.com/carisa/pkg/strings"
+)
+
+const (
+	// Namespace is the scheme of the keys that belong to a namespace
+	Namespace = "@"
+	nsSep     = "#"
+	// nsMaxLen is the max length of the name of a namespace
+	nsMaxLen = 63
+)
+
+// NamespaceKey returns the prefix of all keys of the namespace
+func NamespaceKey(name string) string {
+	return strings.Concat(Namespace, name, nsSep)
+}
+
+// ValidNamespace returns true if the name only contains lowercase letters, digits, '-' or '_'
+// and its length is between 1 and 63
+func ValidNamespace(name string) bool {
+	if len(name) == 0 || len(name) > nsMaxLen {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// namespaceStore decorates a store to isolate all keys into a namespace.
+// The keys are prefixed when they are written or read and the prefix is removed from the keys returned,
+// so the caller never sees or reaches keys out of the namespace
+type namespaceStore struct {
+	store  CRUD
+	prefix string
+}
+
+// NewNamespace decorates the store to isolate the keys into the namespace name.
+// The name must be valid. See ValidNamespace
+func NewNamespace(store CRUD, name string) CRUD {
+	if !ValidNamespace(name) {
+		panic(strings.Concat("namespace not valid: ", name))
+	}
+	return &namespaceStore{store: store, prefix: NamespaceKey(name)}
+}
+
+// Put implements CRUD.Put
+func (n *namespaceStore) Put(entity Entity) (OpeWrap, error) {
+	ope, err := n.store.Put(entity)
+	if err != nil {
+		return OpeWrap{}, err
+	}
+	return ope.prefix(n.prefix), nil
+}
+
+// PutRaw implements CRUD.PutRaw
+func (n *namespaceStore) PutRaw(key string, value string) OpeWrap {
+	return n.store.PutRaw(n.key(key), value)
+}
+
+// Remove implements CRUD.Remove
+func (n *namespaceStore) Remove(key string) OpeWrap {
+	return n.store.Remove(n.key(key))
+}
+
+// Get implements CRUD.Get
+func (n *namespaceStore) Get(ctx context.Context, key string, entity Entity) (bool, error) {
+	return n.store.Get(ctx, n.key(key), entity)
+}
+
+// GetRaw implements CRUD.GetRaw
+func (n *namespaceStore) GetRaw(ctx context.Context, key string) (bool, string, error) {
+	return n.store.GetRaw(ctx, n.key(key))
+}
+
+// Exists implements CRUD.Exists
+func (n *namespaceStore) Exists(ctx context.Context, key string) (bool, error) {
+	return n.store.Exists(ctx, n.key(key))
+}
+
+// StartKey implements CRUD.StartKey
+func (n *namespaceStore) StartKey(ctx context.Context, key string, top int, empty func() Entity) ([]Entity, error) {
+	return n.store.StartKey(ctx, n.key(key), top, empty)
+}
+
+// Range implements CRUD.Range
+func (n *namespaceStore) Range(ctx context.Context, skey string, ekey string, top int, empty func() Entity) ([]Entity, error) {
+	return n.store.Range(ctx, n.key(skey), n.key(ekey), top, empty)
+}
+
+// RangeRaw implements CRUD.RangeRaw. The prefix of the namespace is removed from the keys
+func (n *namespaceStore) RangeRaw(ctx context.Context, skey string, ekey string, top int) (map[string]string, error) {
+	values, err := n.store.RangeRaw(ctx, n.key(skey), n.key(ekey), top)
+	if err != nil {
+		return nil, err
+	}
+	list := make(map[string]string, len(values))
+	for k, v := range values {
+		list[strs.TrimPrefix(k, n.prefix)] = v
+	}
+	return list, nil
+}
+
+// Grant implements CRUD.Grant
+func (n *namespaceStore) Grant(ctx context.Context, ttl time.Duration) (LeaseID, error) {
+	return n.store.Grant(ctx, ttl)
+}
+
+// KeepAlive implements CRUD.KeepAlive
+func (n *namespaceStore) KeepAlive(ctx context.Context, id LeaseID) error {
+	return n.store.KeepAlive(ctx, id)
+}
+
+// Revoke implements CRUD.Revoke
+func (n *namespaceStore) Revoke(ctx context.Context, id LeaseID) error {
+	return n.store.Revoke(ctx, id)
+}
+
+// PutRawLease implements CRUD.PutRawLease
+func (n *namespaceStore) PutRawLease(key string, value string, id LeaseID) OpeWrap {
+	return n.store.PutRawLease(n.key(key), value, id)
+}
+
+// Close implements CRUD.Close. The decorated store is not closed because it is shared by all namespaces
+func (n *namespaceStore) Close() error {
+	return nil
+}
+
+func (n *namespaceStore) key(key string) string {
+	return strings.Concat(n.prefix, key)
+}
+
+// namespaceTxn decorates a transaction to find the keys into the namespace.
+// The operations are already prefixed by the namespaceStore
+type namespaceTxn struct {
+	Txn
+	prefix string
+}
+
+// Find implements Txn.Find
+func (t *namespaceTxn) Find(keyValue string) {
+	t.Txn.Find(strings.Concat(t.prefix, keyValue))
+}
+
+// Match implements Txn.Match
+func (t *namespaceTxn) Match(key string, value string) {
+	t.Txn.Match(strings.Concat(t.prefix, key), value)
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ */
+
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/carisa/pkg/logging"
+)
+
+func TestNamespace_Isolation(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	acme := NewNamespace(root, "acme")
+	other := NewNamespace(root, "other")
+
+	core, _ := observer.New(zap.DebugLevel)
+	log := logging.NewZapWrap(zap.New(core), logging.DebugLevel, "")
+	oper := NewCrudOperation(acme, log, NewTxn)
+
+	parent := Object{ID: "parent", Name: "parent"}
+	if _, err := oper.Create("loc", storeTimeout, parent); !assert.NoError(t, err) {
+		return
+	}
+	child := entity()
+	child.Parent = parent.ID
+	created, found, err := oper.CreateWithRel("loc", storeTimeout, child)
+	if !assert.NoError(t, err) || !assert.True(t, created, "Created") || !assert.True(t, found, "Parent found") {
+		return
+	}
+
+	ctx := context.TODO()
+
+	var obj Object
+	found, err = acme.Get(ctx, child.Key(), &obj)
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Found into the namespace")
+		assert.Equal(t, child, obj, "Entity")
+	}
+	found, err = other.Exists(ctx, child.Key())
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Not found into other namespace")
+	}
+	found, err = root.Exists(ctx, child.Key())
+	if assert.NoError(t, err) {
+		assert.False(t, found, "Not found out of the namespace")
+	}
+	found, err = root.Exists(ctx, NamespaceKey("acme")+child.Key())
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Found with the prefix")
+	}
+
+	list, err := acme.StartKey(ctx, parent.ID, 0, func() Entity { return &Link{} })
+	if assert.NoError(t, err) {
+		assert.Len(t, list, 2, "Parent and link")
+	}
+	list, err = other.StartKey(ctx, "", 0, func() Entity { return &Object{} })
+	if assert.NoError(t, err) {
+		assert.Len(t, list, 0, "Other namespace is empty")
+	}
+
+	raw, err := acme.RangeRaw(ctx, "", "", 0)
+	if assert.NoError(t, err) {
+		assert.Len(t, raw, 4, "Parent, child, link and DLR")
+		_, ok := raw[DLRKey(child.Key(), parent.Key())]
+		assert.True(t, ok, "The keys have not prefix")
+	}
+
+	// The transactions find the keys into the namespace
+	txn := NewTxn(other)
+	txn.Find(child.Key())
+	txn.DoNotFound(other.PutRaw(child.Key(), "value"))
+	ok, err := txn.Commit(ctx)
+	if assert.NoError(t, err) {
+		assert.True(t, ok, "Key not found into other namespace")
+	}
+	_, value, err := other.GetRaw(ctx, child.Key())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "value", value, "Value of other namespace")
+	}
+
+	txn = NewTxn(acme)
+	txn.Find(child.Key())
+	txn.DoFound(acme.Remove(child.Key()))
+	ok, err = txn.Commit(ctx)
+	if assert.NoError(t, err) {
+		assert.True(t, ok, "Removed")
+	}
+	found, err = other.Exists(ctx, child.Key())
+	if assert.NoError(t, err) {
+		assert.True(t, found, "Other namespace is not changed")
+	}
+
+	txn = NewTxn(other)
+	txn.Match(child.Key(), "value")
+	txn.DoFound(other.PutRaw(child.Key(), "swapped"))
+	ok, err = txn.Commit(ctx)
+	if assert.NoError(t, err) {
+		assert.True(t, ok, "Value matched into the namespace")
+	}
+
+	assert.NoError(t, acme.Close(), "Close")
+	_, err = root.Exists(ctx, child.Key())
+	assert.NoError(t, err, "The decorated store is not closed")
+}
+
+func TestNamespace_Valid(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "acme", valid: true},
+		{name: "acme-01_eu", valid: true},
+		{name: "", valid: false},
+		{name: "Acme", valid: false},
+		{name: "ac#me", valid: false},
+		{name: "ac/me", valid: false},
+		{name: string(make([]byte, 64)), valid: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.valid, ValidNamespace(tt.name), tt.name)
+	}
+	assert.Panics(t, func() { NewNamespace(&ErrMockCRUD{}, "#") }, "Invalid namespace")
+}
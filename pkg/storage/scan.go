@@ -0,0 +1,75 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"sort"
+
+	"github.com/carisa/pkg/strings"
+)
+
+// ScanRaw scans in batches all keys and values that start by the prefix. The keys of each batch are sorted.
+// The scan stops when the function returns error.
+// The keys written by the function into the same prefix can be scanned again if they are greater than the
+// keys of the batch
+func ScanRaw(
+	storeTimeout StoreWithTimeout,
+	store CRUD,
+	prefix string,
+	batch int,
+	fn func(keys []string, values map[string]string) error) error {
+	//
+	return ScanRangeRaw(storeTimeout, store, prefix, prefix, batch, fn)
+}
+
+// ScanRangeRaw scans in batches all keys and values that are greater than or equal to skey and start by ekey
+// or are lower than ekey. See ScanRaw and CRUD.RangeRaw
+func ScanRangeRaw(
+	storeTimeout StoreWithTimeout,
+	store CRUD,
+	skey string,
+	ekey string,
+	batch int,
+	fn func(keys []string, values map[string]string) error) error {
+	//
+	next := skey
+	for {
+		ctx, cancel := storeTimeout()
+		values, err := store.RangeRaw(ctx, next, ekey, batch)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		if err := fn(keys, values); err != nil {
+			return err
+		}
+		if len(values) < batch {
+			return nil
+		}
+		next = strings.Concat(keys[len(keys)-1], "\x00")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanRaw(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	store := i.Store()
+
+	values := map[string]string{"P1": "1", "P2": "2", "P3": "3", "P4": "4", "P5": "5", "Q1": "1"}
+	for k, v := range values {
+		txn := NewTxn(store)
+		txn.Find(k)
+		txn.DoNotFound(store.PutRaw(k, v))
+		if _, err := txn.Commit(context.TODO()); !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	var scanned []string
+	batches := 0
+	err := ScanRaw(storeTimeout, store, "P", 2, func(keys []string, values map[string]string) error {
+		batches++
+		for _, k := range keys {
+			assert.Equal(t, k[1:], values[k], k)
+		}
+		scanned = append(scanned, keys...)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"P1", "P2", "P3", "P4", "P5"}, scanned, "Keys")
+		assert.Equal(t, 3, batches, "Batches")
+	}
+
+	err = ScanRaw(storeTimeout, store, "P", 2, func(keys []string, values map[string]string) error {
+		return errors.New("scan")
+	})
+	assert.Error(t, err, "Scan error")
+
+	scanned = nil
+	err = ScanRangeRaw(storeTimeout, store, "P2", "P4", 2, func(keys []string, values map[string]string) error {
+		scanned = append(scanned, keys...)
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"P2", "P3", "P4"}, scanned, "Range")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	strs "strings"
+
+	"github.com/carisa/pkg/strings"
+	"github.com/carisa/pkg/tracing"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"google.golang.org/grpc"
+)
+
+// traceInterceptor creates a span for each call to etcd. The span is child of the span of the context,
+// so the calls of the request are traced and the rest of calls are ignored. See tracing.Start
+func traceInterceptor(
+	ctx context.Context,
+	method string,
+	req, reply interface{},
+	cc *grpc.ClientConn,
+	invoker grpc.UnaryInvoker,
+	opts ...grpc.CallOption) error {
+	//
+	ctx, span := tracing.Start(
+		ctx,
+		strs.TrimPrefix(method, "/"),
+		tracing.String("db.system", "etcd"),
+		tracing.String("rpc.method", method))
+	if span == nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	switch r := req.(type) {
+	case *etcdserverpb.RangeRequest:
+		span.SetAttributes(tracing.String("db.key", string(r.Key)))
+	case *etcdserverpb.TxnRequest:
+		span.SetAttributes(tracing.Int("db.operations", len(r.Success)+len(r.Failure)))
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+// trace starts the span of the CRUD operation. The contexts created by the function returned
+// carry the span, so the calls to the store are children of the operation
+func trace(loc string, op string, key string, storeTimeout StoreWithTimeout) (StoreWithTimeout, *tracing.Span) {
+	ctx, cancel := storeTimeout()
+	_, span := tracing.Start(ctx, strings.Concat(loc, ".", op), tracing.String("key", key))
+	cancel()
+	if span == nil {
+		return storeTimeout, nil
+	}
+	return func() (context.Context, context.CancelFunc) {
+		ctx, cancel := storeTimeout()
+		return tracing.ContextWithSpan(ctx, span), cancel
+	}, span
+}
+
+// end records the error of the operation and ends the span
+func end(span *tracing.Span, err error) {
+	span.RecordError(err)
+	span.End()
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carisa/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/etcdserver/etcdserverpb"
+	"google.golang.org/grpc"
+)
+
+func TestTrace_Interceptor(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   interface{}
+		err   error
+		attr  tracing.Attribute
+		state tracing.StatusCode
+	}{
+		{
+			name:  "Range.",
+			req:   &etcdserverpb.RangeRequest{Key: []byte("key")},
+			attr:  tracing.String("db.key", "key"),
+			state: tracing.Unset,
+		},
+		{
+			name:  "Txn with error.",
+			req:   &etcdserverpb.TxnRequest{Success: []*etcdserverpb.RequestOp{{}, {}}},
+			err:   errors.New("txn"),
+			attr:  tracing.Attribute{Key: "db.operations", Value: float64(2)}, // JSON number
+			state: tracing.Error,
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tracer := tracing.NewTracer(tracing.NewWriterExporter(&out), nil)
+		ctx, root := tracer.Start(context.Background(), "root", tracing.Server, tracing.SpanContext{})
+
+		err := traceInterceptor(ctx, "/etcdserverpb.KV/Range", tt.req, nil, nil, invoker(tt.err))
+		assert.Equal(t, tt.err, err, tt.name+"Error")
+
+		spans := exported(t, &out)
+		if assert.Len(t, spans, 1, tt.name+"Span of the call") {
+			assert.Equal(t, "etcdserverpb.KV/Range", spans[0].Name, tt.name+"Name")
+			assert.Equal(t, root.SpanContext().SpanID.String(), spans[0].ParentID, tt.name+"Parent")
+			assert.Contains(t, spans[0].Attributes, tt.attr, tt.name+"Attribute")
+			assert.Equal(t, tt.state, spans[0].Status.Code, tt.name+"Status")
+		}
+	}
+
+	err := traceInterceptor(context.Background(), "/etcdserverpb.KV/Range", nil, nil, nil, invoker(nil))
+	assert.NoError(t, err, "Without trace")
+}
+
+func TestTrace_CRUDOperation(t *testing.T) {
+	storef := NewEctdIntegra(t)
+	oper := newCRUDOper(storef)
+	defer storef.Close()
+
+	var out bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&out), nil)
+	ctx, root := tracer.Start(context.Background(), "root", tracing.Server, tracing.SpanContext{})
+	timeout := func() (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, 10*time.Second)
+	}
+
+	_, err := oper.Create("loc", timeout, entity())
+	if assert.NoError(t, err) {
+		spans := exported(t, &out)
+		if assert.Len(t, spans, 1, "Span of the operation") {
+			assert.Equal(t, "loc.create", spans[0].Name, "Name")
+			assert.Equal(t, root.SpanContext().SpanID.String(), spans[0].ParentID, "Parent")
+		}
+	}
+
+	_, err = oper.Create("loc", storeTimeout, entity())
+	if assert.NoError(t, err) {
+		assert.Empty(t, out.String(), "Without trace")
+	}
+}
+
+func invoker(err error) grpc.UnaryInvoker {
+	return func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return err
+	}
+}
+
+func exported(t *testing.T, out *bytes.Buffer) []tracing.Data {
+	var spans []tracing.Data
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		var data tracing.Data
+		if assert.NoError(t, json.Unmarshal([]byte(line), &data), "Span decoded") {
+			spans = append(spans, data)
+		}
+	}
+	out.Reset()
+	return spans
+}
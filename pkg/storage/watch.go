@@ -0,0 +1,150 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/carisa/pkg/strings"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// EventType is the type of the change of a key
+type EventType int
+
+const (
+	// PutEvent is the creation or update of a key
+	PutEvent EventType = iota
+	// DeleteEvent is the removal of a key
+	DeleteEvent
+)
+
+// Event is the change of a key. The value is raw and it is empty when the key is removed
+type Event struct {
+	Type  EventType
+	Key   string
+	Value string
+}
+
+// WatchResponse groups the changes of the keys done by the same revision of the store.
+// If the watch fails the response has the error and it is the last one
+type WatchResponse struct {
+	Events []Event
+	Err    error
+}
+
+// Watcher watches the changes of the keys
+type Watcher interface {
+	// Watch returns the keys of the prefix as PutEvent into the first response and then their changes.
+	// The changes follow the keys read, so no change is lost between them.
+	// The channel is closed when the context is done or the watch fails
+	Watch(ctx context.Context, prefix string) <-chan WatchResponse
+}
+
+// ErrWatchNotSupported is returned by the stores that can not be watched, as the mocks
+var ErrWatchNotSupported = errors.New("the store can not be watched")
+
+// NewWatcher returns a watcher depending of the store
+func NewWatcher(store CRUD) Watcher {
+	return newWatcher(store, "")
+}
+
+func newWatcher(store CRUD, prefix string) Watcher {
+	switch s := store.(type) {
+	case *etcdStore:
+		return &etcdWatcher{client: s.client, prefix: prefix}
+	case *namespaceStore:
+		return newWatcher(s.store, strings.Concat(s.prefix, prefix))
+	case *instrumentedStore:
+		return newWatcher(s.store, prefix)
+	case *ttlStore:
+		return newWatcher(s.CRUD, prefix)
+	default:
+		return unsupportedWatcher{}
+	}
+}
+
+// etcdWatcher watches the keys of etcd. The prefix is the namespace of the keys, it is removed from the keys returned
+type etcdWatcher struct {
+	client *clientv3.Client
+	prefix string
+}
+
+// Watch implements Watcher.Watch. The watch starts from the next revision of the keys read
+func (e *etcdWatcher) Watch(ctx context.Context, prefix string) <-chan WatchResponse {
+	out := make(chan WatchResponse)
+	go func() {
+		defer close(out)
+		key := strings.Concat(e.prefix, prefix)
+		resp, err := e.client.Get(ctx, key, clientv3.WithPrefix())
+		if err != nil {
+			send(ctx, out, WatchResponse{Err: err})
+			return
+		}
+		events := make([]Event, len(resp.Kvs))
+		for i, kv := range resp.Kvs {
+			events[i] = Event{Type: PutEvent, Key: e.key(kv.Key), Value: string(kv.Value)}
+		}
+		if !send(ctx, out, WatchResponse{Events: events}) {
+			return
+		}
+
+		changes := e.client.Watch(ctx, key, clientv3.WithPrefix(), clientv3.WithRev(resp.Header.Revision+1))
+		for wr := range changes {
+			if err := wr.Err(); err != nil {
+				send(ctx, out, WatchResponse{Err: err})
+				return
+			}
+			events := make([]Event, len(wr.Events))
+			for i, ev := range wr.Events {
+				events[i] = Event{Type: PutEvent, Key: e.key(ev.Kv.Key), Value: string(ev.Kv.Value)}
+				if ev.Type == clientv3.EventTypeDelete {
+					events[i].Type, events[i].Value = DeleteEvent, ""
+				}
+			}
+			if !send(ctx, out, WatchResponse{Events: events}) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (e *etcdWatcher) key(key []byte) string {
+	return string(key[len(e.prefix):])
+}
+
+// send sends the response unless the context is done. Returns false if the context is done
+func send(ctx context.Context, out chan<- WatchResponse, resp WatchResponse) bool {
+	select {
+	case out <- resp:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+type unsupportedWatcher struct{}
+
+// Watch implements Watcher.Watch. It always fails
+func (unsupportedWatcher) Watch(_ context.Context, _ string) <-chan WatchResponse {
+	out := make(chan WatchResponse, 1)
+	out <- WatchResponse{Err: ErrWatchNotSupported}
+	close(out)
+	return out
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch_Etcd(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	testWatch(t, i.Store())
+}
+
+func TestWatch_Namespace(t *testing.T) {
+	i := NewEctdIntegra(t)
+	defer i.Close()
+	root := i.Store()
+	if !putRaw(t, root, "w#out", "1") {
+		return
+	}
+	testWatch(t, NewInstrumented(NewNamespace(root, "acme"), nil))
+}
+
+func TestWatch_NotSupported(t *testing.T) {
+	changes := NewWatcher(&ErrMockCRUD{}).Watch(context.TODO(), "w#")
+	resp, ok := <-changes
+	assert.True(t, ok, "Response")
+	assert.Equal(t, ErrWatchNotSupported, resp.Err)
+	_, ok = <-changes
+	assert.False(t, ok, "Closed after the error")
+}
+
+func testWatch(t *testing.T, store CRUD) {
+	if !putRaw(t, store, "w#a", "1") || !putRaw(t, store, "w#b", "2") {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	changes := NewWatcher(store).Watch(ctx, "w#")
+
+	expected := []Event{{Type: PutEvent, Key: "w#a", Value: "1"}, {Type: PutEvent, Key: "w#b", Value: "2"}}
+	assert.Equal(t, expected, next(t, changes).Events, "Keys read")
+
+	if !putRaw(t, store, "w#c", "3") {
+		return
+	}
+	assert.Equal(t, []Event{{Type: PutEvent, Key: "w#c", Value: "3"}}, next(t, changes).Events, "Put")
+
+	txn := NewTxn(store)
+	txn.Find("w#a")
+	txn.DoFound(store.Remove("w#a"))
+	if _, err := txn.Commit(ctx); !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Event{{Type: DeleteEvent, Key: "w#a"}}, next(t, changes).Events, "Delete")
+
+	cancel()
+	for range changes {
+	}
+}
+
+func putRaw(t *testing.T, store CRUD, key string, value string) bool {
+	txn := NewTxn(store)
+	txn.Find(key)
+	txn.DoNotFound(store.PutRaw(key, value))
+	ok, err := txn.Commit(context.TODO())
+	return assert.NoError(t, err) && assert.True(t, ok, key)
+}
+
+func next(t *testing.T, changes <-chan WatchResponse) WatchResponse {
+	select {
+	case resp := <-changes:
+		assert.NoError(t, resp.Err)
+		return resp
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "Change not watched")
+		return WatchResponse{}
+	}
+}
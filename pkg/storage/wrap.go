@@ -15,10 +15,32 @@
 
 package storage
 
-import "go.etcd.io/etcd/clientv3"
+import (
+	"github.com/carisa/pkg/strings"
+	"go.etcd.io/etcd/clientv3"
+)
 
 // OpeWrap uncouples the store from its use. Exchanges operations with transactions
 // This avoids the use of an interface that is slower
 type OpeWrap struct {
 	opeEtcd clientv3.Op
+	// lease is the lease of the put. See CRUD.PutRawLease
+	lease LeaseID
+	// attach is the operation that attaches the key to an emulated lease. See NewTTLLeases
+	attach *OpeWrap
+}
+
+// prefix returns the same operation over the key with the prefix
+func (o OpeWrap) prefix(p string) OpeWrap {
+	key := strings.Concat(p, string(o.opeEtcd.KeyBytes()))
+	if o.opeEtcd.IsDelete() {
+		return OpeWrap{opeEtcd: clientv3.OpDelete(key)}
+	}
+	if o.lease != 0 {
+		return OpeWrap{
+			opeEtcd: clientv3.OpPut(key, string(o.opeEtcd.ValueBytes()), clientv3.WithLease(clientv3.LeaseID(o.lease))),
+			lease:   o.lease,
+		}
+	}
+	return OpeWrap{opeEtcd: clientv3.OpPut(key, string(o.opeEtcd.ValueBytes()))}
 }
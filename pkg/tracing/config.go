@@ -0,0 +1,56 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"os"
+
+	"github.com/carisa/pkg/logging"
+)
+
+const (
+	// StdoutExporter writes the spans into the standard output
+	StdoutExporter = "stdout"
+	// FileExporter writes the spans into the file of Config.Path
+	FileExporter = "file"
+)
+
+// Config describes the trace of the requests
+type Config struct {
+	// Enabled traces the requests. If it is false the spans are not created
+	Enabled bool `json:"enabled,omitempty"`
+	// Exporter is where the spans are written: stdout or file. Common value: stdout
+	Exporter string `json:"exporter,omitempty"`
+	// Path is the file of the spans for the file exporter
+	Path string `json:"path,omitempty"`
+}
+
+// New builds the tracer from the configuration.
+// If the trace is not enabled returns nil
+func New(cnf Config, log logging.Logger) (*Tracer, error) {
+	if !cnf.Enabled {
+		return nil, nil
+	}
+	if cnf.Exporter == FileExporter {
+		exp, err := NewFileExporter(cnf.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewTracer(exp, log), nil
+	}
+	return NewTracer(NewWriterExporter(os.Stdout), log), nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_New(t *testing.T) {
+	tracer, err := New(Config{}, nil)
+	if assert.NoError(t, err, "Disabled") {
+		assert.Nil(t, tracer, "Disabled")
+	}
+
+	tracer, err = New(Config{Enabled: true, Exporter: StdoutExporter}, nil)
+	if assert.NoError(t, err, "Stdout") {
+		assert.NotNil(t, tracer, "Stdout")
+		assert.NoError(t, tracer.Close(), "Stdout")
+	}
+
+	_, err = New(Config{Enabled: true, Exporter: FileExporter, Path: "/notexist/spans"}, nil)
+	assert.Error(t, err, "Wrong file")
+}
+
+func TestConfig_FileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.json")
+
+	tracer, err := New(Config{Enabled: true, Exporter: FileExporter, Path: path}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, span := tracer.Start(context.Background(), "root", Server, SpanContext{})
+	span.End()
+	if !assert.NoError(t, tracer.Close()) {
+		return
+	}
+
+	spans, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(spans), `"name":"root"`)
+		assert.Contains(t, string(spans), span.SpanContext().TraceID.String())
+	}
+}
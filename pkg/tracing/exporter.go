@@ -0,0 +1,71 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Exporter sends the spans ended to the backend
+type Exporter interface {
+	// Export sends the span
+	Export(data Data) error
+
+	// Close flushes the spans and releases the resources
+	Close() error
+}
+
+// writerExporter writes each span as a line of JSON
+type writerExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewWriterExporter builds the exporter that writes each span as a line of JSON
+func NewWriterExporter(w io.Writer) Exporter {
+	return &writerExporter{enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter builds the exporter that appends each span as a line of JSON into the file
+func NewFileExporter(path string) (Exporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening the file of the spans")
+	}
+	return &writerExporter{enc: json.NewEncoder(f), closer: f}, nil
+}
+
+// Export implements Exporter.Export
+func (w *writerExporter) Export(data Data) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(data)
+}
+
+// Close implements Exporter.Close
+func (w *writerExporter) Close() error {
+	if w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Header is the W3C Trace Context header that propagates the trace among processes
+const Header = "traceparent"
+
+const version = "00"
+
+// Format formats the span context as the value of the traceparent header.
+// The spans are always sampled
+func Format(sc SpanContext) string {
+	return strings.Join([]string{version, sc.TraceID.String(), sc.SpanID.String(), "01"}, "-")
+}
+
+// Parse parses the value of the traceparent header.
+// If the value is not valid returns false
+func Parse(value string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if parts[0] == version && len(parts) != 4 {
+		return sc, false
+	}
+	if !decode(parts[1], sc.TraceID[:]) || !decode(parts[2], sc.SpanID[:]) {
+		return sc, false
+	}
+	return sc, sc.Valid()
+}
+
+func decode(value string, dst []byte) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagation_Parse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "Valid.", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{name: "Not sampled.", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", valid: true},
+		{name: "Future version.", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xx", valid: true},
+		{name: "Empty.", value: ""},
+		{name: "Invalid version.", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "Extra fields.", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xx"},
+		{name: "Upper case.", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "Short trace.", value: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01"},
+		{name: "Zero trace.", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "Zero span.", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "Not hexadecimal.", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+	}
+
+	for _, tt := range tests {
+		sc, valid := Parse(tt.value)
+		assert.Equal(t, tt.valid, valid, tt.name)
+		if valid {
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String(), tt.name)
+			assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String(), tt.name)
+		}
+	}
+}
+
+func TestPropagation_Format(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, valid := Parse(value)
+	if assert.True(t, valid) {
+		assert.Equal(t, value, Format(sc))
+	}
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies the trace. It is compatible with the W3C Trace Context and OpenTelemetry
+type TraceID [16]byte
+
+// String returns the hexadecimal representation
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// Valid returns false if all bytes are zero
+func (t TraceID) Valid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies the span into the trace. It is compatible with the W3C Trace Context and OpenTelemetry
+type SpanID [8]byte
+
+// String returns the hexadecimal representation
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// Valid returns false if all bytes are zero
+func (s SpanID) Valid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies the span across the processes
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// Valid returns true if the trace and the span are valid
+func (s SpanContext) Valid() bool {
+	return s.TraceID.Valid() && s.SpanID.Valid()
+}
+
+// Kind is the role of the span into the trace
+type Kind string
+
+const (
+	Internal Kind = "internal"
+	Server   Kind = "server"
+	Client   Kind = "client"
+)
+
+// StatusCode is the result of the operation of the span
+type StatusCode string
+
+const (
+	Unset StatusCode = "unset"
+	Ok    StatusCode = "ok"
+	Error StatusCode = "error"
+)
+
+// Status is the result of the operation of the span
+type Status struct {
+	Code        StatusCode `json:"code"`
+	Description string     `json:"description,omitempty"`
+}
+
+// Attribute describes the operation of the span
+type Attribute struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// String builds a string attribute
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int builds a integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool builds a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Data is the information of the span exported when the span ends. See Exporter
+type Data struct {
+	Name       string      `json:"name"`
+	TraceID    string      `json:"traceId"`
+	SpanID     string      `json:"spanId"`
+	ParentID   string      `json:"parentSpanId,omitempty"`
+	Kind       Kind        `json:"kind"`
+	Start      time.Time   `json:"startTime"`
+	End        time.Time   `json:"endTime"`
+	Attributes []Attribute `json:"attributes,omitempty"`
+	Status     Status      `json:"status"`
+}
+
+// Span is an operation of the trace. All methods can be called with a nil span,
+// so the code does not need to check if the trace is enabled. See Start
+type Span struct {
+	mu       sync.Mutex
+	tracer   *Tracer
+	ctx      SpanContext
+	parent   SpanID
+	name     string
+	kind     Kind
+	start    time.Time
+	attrs    []Attribute
+	status   Status
+	finished bool
+}
+
+// SpanContext returns the identifiers of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttributes adds the attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetStatus changes the result of the span
+func (s *Span) SetStatus(code StatusCode, description string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = Status{Code: code, Description: description}
+	s.mu.Unlock()
+}
+
+// RecordError sets the error status if the error is not nil
+func (s *Span) RecordError(err error) {
+	if err != nil {
+		s.SetStatus(Error, err.Error())
+	}
+}
+
+// End finishes the span and exports it. The next calls are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return
+	}
+	s.finished = true
+	data := Data{
+		Name:       s.name,
+		TraceID:    s.ctx.TraceID.String(),
+		SpanID:     s.ctx.SpanID.String(),
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        time.Now(),
+		Attributes: s.attrs,
+		Status:     s.status,
+	}
+	if s.parent.Valid() {
+		data.ParentID = s.parent.String()
+	}
+	s.mu.Unlock()
+	s.tracer.export(data)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of the context with the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// FromContext returns the span of the context or nil if the context has not span
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Start starts a child span of the span of the context. If the context has not span
+// the trace is not enabled or the operation is not traced, so it returns the same context and a nil span
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := parent.tracer.newSpan(name, Internal, SpanContext{TraceID: parent.ctx.TraceID, SpanID: parent.ctx.SpanID}, attrs)
+	return ContextWithSpan(ctx, span), span
+}
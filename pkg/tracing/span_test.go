@@ -0,0 +1,88 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpan_Start(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&out), nil)
+
+	ctx, root := tracer.Start(context.Background(), "root", Server, SpanContext{}, String("http.method", "GET"))
+	cctx, child := Start(ctx, "child", Int("n", 1))
+	child.RecordError(errors.New("failed"))
+	assert.Equal(t, child, FromContext(cctx), "Child into the context")
+	child.End()
+	child.End()
+	root.SetStatus(Ok, "")
+	root.End()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if !assert.Len(t, lines, 2, "Spans exported once") {
+		return
+	}
+	var cdata, rdata Data
+	if assert.NoError(t, json.Unmarshal([]byte(lines[0]), &cdata)) && assert.NoError(t, json.Unmarshal([]byte(lines[1]), &rdata)) {
+		assert.Equal(t, "child", cdata.Name)
+		assert.Equal(t, Internal, cdata.Kind)
+		assert.Equal(t, rdata.TraceID, cdata.TraceID, "Same trace")
+		assert.Equal(t, rdata.SpanID, cdata.ParentID, "Parent")
+		assert.Equal(t, Status{Code: Error, Description: "failed"}, cdata.Status)
+		assert.Equal(t, "root", rdata.Name)
+		assert.Equal(t, Server, rdata.Kind)
+		assert.Empty(t, rdata.ParentID, "Root")
+		assert.Equal(t, Ok, rdata.Status.Code)
+		assert.Equal(t, []Attribute{{Key: "http.method", Value: "GET"}}, rdata.Attributes)
+		assert.False(t, rdata.End.Before(rdata.Start), "End")
+	}
+}
+
+func TestSpan_Remote(t *testing.T) {
+	var out bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&out), nil)
+	remote, _ := Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	_, span := tracer.Start(context.Background(), "root", Server, remote)
+	assert.Equal(t, remote.TraceID, span.SpanContext().TraceID, "Trace of the remote process")
+	assert.NotEqual(t, remote.SpanID, span.SpanContext().SpanID, "New span")
+	span.End()
+	assert.Contains(t, out.String(), `"parentSpanId":"00f067aa0ba902b7"`)
+}
+
+func TestSpan_WithoutTrace(t *testing.T) {
+	ctx := context.Background()
+	sctx, span := Start(ctx, "name")
+	assert.Nil(t, span, "Span")
+	assert.Equal(t, ctx, sctx, "Context")
+
+	// The nil spans are ignored
+	span.SetAttributes(String("k", "v"))
+	span.SetStatus(Error, "error")
+	span.RecordError(errors.New("error"))
+	span.End()
+	assert.False(t, span.SpanContext().Valid())
+	assert.Nil(t, FromContext(nil))
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2019-2022 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software  distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and  limitations under the License.
+ *
+ */
+
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+
+	"github.com/carisa/pkg/logging"
+)
+
+const locTracer = "tracing.tracer"
+
+// Tracer starts the root spans and exports the spans when they end
+type Tracer struct {
+	exporter Exporter
+	log      logging.Logger
+}
+
+// NewTracer builds the tracer with the exporter
+func NewTracer(exporter Exporter, log logging.Logger) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		log:      log,
+	}
+}
+
+// Start starts the root span of the process. If the remote span context is valid, the span continues
+// the trace of the remote process, for example the trace received into the traceparent header. See Parse
+func (t *Tracer) Start(
+	ctx context.Context,
+	name string,
+	kind Kind,
+	remote SpanContext,
+	attrs ...Attribute) (context.Context, *Span) {
+	//
+	span := t.newSpan(name, kind, remote, attrs)
+	return ContextWithSpan(ctx, span), span
+}
+
+// Close flushes and closes the exporter
+func (t *Tracer) Close() error {
+	return t.exporter.Close()
+}
+
+func (t *Tracer) newSpan(name string, kind Kind, parent SpanContext, attrs []Attribute) *Span {
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+		attrs:  attrs,
+		status: Status{Code: Unset},
+	}
+	if parent.Valid() {
+		span.ctx.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		_, _ = rand.Read(span.ctx.TraceID[:])
+	}
+	_, _ = rand.Read(span.ctx.SpanID[:])
+	return span
+}
+
+func (t *Tracer) export(data Data) {
+	if err := t.exporter.Export(data); err != nil && t.log != nil {
+		t.log.ErrorE(err, locTracer)
+	}
+}